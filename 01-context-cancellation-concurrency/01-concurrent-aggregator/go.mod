@@ -2,7 +2,12 @@ module concurrent-aggregator
 
 go 1.25.0
 
-require golang.org/x/sync v0.19.0
+require (
+	panic-safe-errgroup v0.0.0
+	weighted-semaphore v0.0.0
+)
+
+require golang.org/x/sync v0.19.0 // indirect
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -10,3 +15,7 @@ require (
 	github.com/stretchr/testify v1.11.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace panic-safe-errgroup => ../27-panic-safe-errgroup
+
+replace weighted-semaphore => ../24-weighted-semaphore
@@ -8,7 +8,8 @@ import (
 	"os"
 	"time"
 
-	"golang.org/x/sync/errgroup"
+	"panic-safe-errgroup"
+	"weighted-semaphore"
 )
 
 // ErrNoServices is returned when no services are configured
@@ -43,11 +44,23 @@ func WithLogger(logger *slog.Logger) Options {
 	}
 }
 
+// WithMaxConcurrency caps the service fetches running at once across all
+// Aggregate calls, so a burst of requests cannot multiply the load on the
+// downstream services. A fetch waiting for a slot still honours the timeout.
+func WithMaxConcurrency(n int) Options {
+	return func(ua *UserAggregator) {
+		if n > 0 {
+			ua.sem = semaphore.NewWeighted(int64(n))
+		}
+	}
+}
+
 // UserAggregator aggregates data from multiple services concurrently
 type UserAggregator struct {
 	services []Service
 	timeout  time.Duration
 	logger   *slog.Logger
+	sem      *semaphore.Weighted // nil means unlimited
 }
 
 // NewUserAggregator creates a new UserAggregator with the given options
@@ -82,25 +95,30 @@ func (ua *UserAggregator) Aggregate(ctx context.Context, userID string) ([]strin
 	ctx, cancel := ua.createContextWithTimeout(ctx)
 	defer cancel()
 
-	g, ctx := errgroup.WithContext(ctx)
-	resultChan := make(chan string, len(ua.services))
+	g, _ := group.New(ctx)
+	collector := group.NewCollector[string](g)
 	for _, svc := range ua.services {
-		svc := svc
-		g.Go(func() error {
+		collector.GoValue(func(ctx context.Context) (string, error) {
+			if ua.sem != nil {
+				if err := ua.sem.Acquire(ctx, 1); err != nil {
+					return "", err
+				}
+				defer ua.sem.Release(1)
+			}
 			data, err := svc.FetchData(ctx, userID)
 			if err != nil {
 				ua.logger.Error("service fetch failed",
 					slog.String("error", err.Error()),
 					slog.String("userID", userID),
 				)
-				return err
+				return "", err
 			}
-			resultChan <- data
-			return nil
+			return data, nil
 		})
 	}
 
-	if err := g.Wait(); err != nil {
+	results, err := collector.Wait()
+	if err != nil {
 		ua.logger.Error("aggregation failed",
 			slog.String("error", err.Error()),
 			slog.String("userID", userID),
@@ -109,12 +127,6 @@ func (ua *UserAggregator) Aggregate(ctx context.Context, userID string) ([]strin
 		return nil, err
 	}
 
-	close(resultChan)
-	results := make([]string, 0, len(ua.services))
-	for data := range resultChan {
-		results = append(results, data)
-	}
-
 	ua.logger.Info("aggregation succeeded",
 		slog.String("userID", userID),
 		slog.Int("resultCount", len(results)),
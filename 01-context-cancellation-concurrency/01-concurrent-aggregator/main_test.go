@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -302,6 +304,50 @@ func TestUserAggregator_Options(t *testing.T) {
 	}
 }
 
+// peakService records how many fetches run at once across all its copies.
+type peakService struct {
+	inFlight, peak *atomic.Int32
+}
+
+func (ps peakService) FetchData(ctx context.Context, id string) (string, error) {
+	n := ps.inFlight.Add(1)
+	defer ps.inFlight.Add(-1)
+	for {
+		p := ps.peak.Load()
+		if n <= p || ps.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(10 * time.Millisecond):
+		return "ok", nil
+	}
+}
+
+func TestUserAggregator_MaxConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	services := make([]Service, 4)
+	for i := range services {
+		services[i] = peakService{inFlight: &inFlight, peak: &peak}
+	}
+	aggregator := NewUserAggregator(WithServices(services...), WithMaxConcurrency(3))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			results, err := aggregator.Aggregate(context.Background(), "user-1")
+			assert.NoError(t, err)
+			assert.Len(t, results, len(services))
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), peak.Load(),
+		"Fetches across concurrent Aggregate calls should be capped")
+}
+
 func TestServices(t *testing.T) {
 	tests := []struct {
 		name        string
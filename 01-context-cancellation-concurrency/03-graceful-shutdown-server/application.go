@@ -7,6 +7,9 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"config-store"
+	"heartbeat-supervisor"
 )
 
 type Application struct {
@@ -18,6 +21,13 @@ type Application struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	config *configstore.Store[Config]
+	health *Health
+
+	supervisor     *supervisor.Supervisor
+	supervisorStop context.CancelFunc
+	supervisorDone chan struct{}
+
 	srvAddr string
 	dbAddr  string
 
@@ -29,7 +39,8 @@ func InitApplication(srvAddr, dbAddr string) *Application {
 	db := NewDatabase(ctx, dbAddr, 10)
 	cache := NewCache(ctx, 30*time.Second)
 	pool := NewWorkerPool[Data](ctx, 10)
-	controller := NewController(pool, cache, db)
+	health := NewHealth()
+	controller := NewController(pool, cache, db).WithHealth(health)
 	httpServer := NewHttpServer(srvAddr, controller)
 
 	return &Application{
@@ -39,19 +50,81 @@ func InitApplication(srvAddr, dbAddr string) *Application {
 		db:              db,
 		ctx:             ctx,
 		cancel:          cancel,
+		health:          health,
 		srvAddr:         srvAddr,
 		dbAddr:          dbAddr,
 		shutdownTimeout: 10 * time.Second,
 	}
 }
 
+// WithConfig takes the shutdown timeout and cache refresh interval from
+// config, and makes Start reload it on SIGHUP.
+func (app *Application) WithConfig(config *configstore.Store[Config]) *Application {
+	app.config = config
+	app.applyConfig(nil, config.Load())
+	config.Subscribe(app.applyConfig)
+	return app
+}
+
+// WithHealthCheck adds check to the report served at /healthz.
+func (app *Application) WithHealthCheck(name string, check HealthCheck) *Application {
+	app.health.Register(name, check)
+	return app
+}
+
+// WithSupervisor runs the supervisor's background workers from Start until
+// shutdown, and reports their health as the "workers" check.
+func (app *Application) WithSupervisor(s *supervisor.Supervisor) *Application {
+	app.supervisor = s
+	return app.WithHealthCheck("workers", s.Check)
+}
+
+func (app *Application) applyConfig(old, cfg *Config) {
+	app.shutdownTimeout = time.Duration(cfg.ShutdownTimeout)
+	if app.cache != nil && (old == nil || old.CacheRefreshInterval != cfg.CacheRefreshInterval) {
+		app.cache.SetRefreshInterval(time.Duration(cfg.CacheRefreshInterval))
+	}
+}
+
 func (app *Application) Start() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	// Without a config, SIGHUP keeps its default action of terminating.
+	var reload chan os.Signal
+	if app.config != nil {
+		reload = make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		defer signal.Stop(reload)
+	}
+
+	if app.supervisor != nil {
+		ctx, stop := context.WithCancel(app.ctx)
+		app.supervisorStop = stop
+		app.supervisorDone = make(chan struct{})
+		go func() {
+			defer close(app.supervisorDone)
+			if err := app.supervisor.Run(ctx); err != nil {
+				log.Println("Supervisor stopped with failed workers:", err)
+			}
+		}()
+	}
+
 	go app.httpServer.Start()
 
-	<-stop
+	for running := true; running; {
+		select {
+		case <-reload:
+			// A bad config is logged and the application keeps the last good one.
+			if err := app.config.Reload(); err != nil {
+				log.Println("Config reload failed:", err)
+			} else {
+				log.Println("Config reloaded")
+			}
+		case <-stop:
+			running = false
+		}
+	}
 	log.Println("Shutdown signal received, starting graceful shutdown...")
 	app.cancel()
 
@@ -69,6 +142,14 @@ func (app *Application) Shutdown(ctx context.Context) {
 	log.Println("Shutting down application components...")
 
 	app.httpServer.Shutdown(ctx)
+	if app.supervisorDone != nil {
+		app.supervisorStop()
+		select {
+		case <-app.supervisorDone:
+		case <-ctx.Done():
+			log.Println("Supervised workers did not stop before the shutdown timeout")
+		}
+	}
 	if app.pool != nil {
 		app.pool.Shutdown()
 	}
@@ -2,14 +2,19 @@ package gracefulshutdownserver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
+
+	"heartbeat-supervisor"
 )
 
 // TestSuddenDeath tests the "Sudden Death Test" from the self-correction section
@@ -489,6 +494,139 @@ func TestConcurrentRequests(t *testing.T) {
 	}
 }
 
+// TestConfigReloadOnSIGHUP verifies SIGHUP swaps in a new config, and that
+// a bad one is rejected while the application keeps running on the old one.
+func TestConfigReloadOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`{"shutdown_timeout": "5s"}`)
+
+	config, err := NewConfigStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan *Config, 1)
+	config.Subscribe(func(_, cfg *Config) { reloaded <- cfg })
+
+	app := InitApplication("localhost:18086", "localhost:18086").WithConfig(config)
+	appDone := make(chan struct{})
+	go func() {
+		defer close(appDone)
+		app.Start()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to find process: %v", err)
+	}
+
+	writeConfig(`{"shutdown_timeout": "-1s"}`)
+	proc.Signal(syscall.SIGHUP)
+	select {
+	case cfg := <-reloaded:
+		t.Errorf("Invalid config was applied: %+v", cfg)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	writeConfig(`{"shutdown_timeout": "3s", "cache_refresh_interval": "1m"}`)
+	proc.Signal(syscall.SIGHUP)
+	select {
+	case cfg := <-reloaded:
+		if time.Duration(cfg.ShutdownTimeout) != 3*time.Second || time.Duration(cfg.CacheRefreshInterval) != time.Minute {
+			t.Errorf("Unexpected config after reload: %+v", cfg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Config was not reloaded on SIGHUP")
+	}
+	if got := time.Duration(config.Load().ShutdownTimeout); got != 3*time.Second {
+		t.Errorf("Expected shutdown timeout 3s, got %v", got)
+	}
+
+	proc.Signal(syscall.SIGTERM)
+	select {
+	case <-appDone:
+	case <-time.After(15 * time.Second):
+		t.Fatal("Application did not shutdown within timeout")
+	}
+}
+
+func TestHealthReportsSupervisedWorkers(t *testing.T) {
+	var healthy atomic.Bool
+	stopped := make(chan struct{})
+	sup := supervisor.New(supervisor.WithHeartbeatTimeout(time.Second))
+	sup.Add("indexer", func(ctx context.Context, beat func()) error {
+		if !healthy.Load() {
+			return errors.New("index unavailable")
+		}
+		defer close(stopped)
+		for {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				beat()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+
+	app := InitApplication("localhost:18087", "localhost:18087").WithSupervisor(sup)
+	appDone := make(chan struct{})
+	go func() {
+		defer close(appDone)
+		app.Start()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	getHealth := func() (int, healthReport) {
+		t.Helper()
+		resp, err := http.Get("http://localhost:18087/healthz")
+		if err != nil {
+			t.Fatalf("Health request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var report healthReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("Invalid health report: %v", err)
+		}
+		return resp.StatusCode, report
+	}
+
+	if code, report := getHealth(); code != http.StatusServiceUnavailable || report.Checks["workers"] == "ok" {
+		t.Errorf("Expected a crash-looping worker to be unhealthy, got %d %+v", code, report)
+	}
+
+	healthy.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, report := getHealth()
+		if code == http.StatusOK && report.Checks["workers"] == "ok" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the restarted worker to be healthy, got %d %+v", code, report)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	proc, _ := os.FindProcess(os.Getpid())
+	proc.Signal(syscall.SIGTERM)
+	select {
+	case <-appDone:
+	case <-time.After(15 * time.Second):
+		t.Fatal("Application did not shutdown within timeout")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("Supervised worker was not stopped by shutdown")
+	}
+}
+
 // BenchmarkRequestThroughput measures request handling performance
 func BenchmarkRequestThroughput(b *testing.B) {
 	app := InitApplication("localhost:18085", "localhost:18085")
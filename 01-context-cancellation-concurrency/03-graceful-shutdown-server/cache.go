@@ -7,6 +7,7 @@ import (
 )
 
 type Cache interface {
+	SetRefreshInterval(d time.Duration)
 	Shutdown()
 }
 
@@ -14,6 +15,7 @@ type cache struct {
 	ctx             context.Context
 	cancel          context.CancelFunc
 	refreshInterval time.Duration
+	intervals       chan time.Duration
 }
 
 func NewCache(parentCtx context.Context, refreshInterval time.Duration) Cache {
@@ -22,6 +24,7 @@ func NewCache(parentCtx context.Context, refreshInterval time.Duration) Cache {
 		ctx:             ctx,
 		cancel:          cancel,
 		refreshInterval: refreshInterval,
+		intervals:       make(chan time.Duration),
 	}
 	go c.pooling()
 	return c
@@ -33,6 +36,14 @@ func (c *cache) Shutdown() {
 	log.Default().Println("cache stopped")
 }
 
+// SetRefreshInterval makes the cache refresh every d from now on.
+func (c *cache) SetRefreshInterval(d time.Duration) {
+	select {
+	case c.intervals <- d:
+	case <-c.ctx.Done():
+	}
+}
+
 func (c *cache) pooling() {
 	c.refresh()
 	ticker := time.NewTicker(c.refreshInterval)
@@ -42,6 +53,9 @@ func (c *cache) pooling() {
 		select {
 		case <-ticker.C:
 			c.refresh()
+		case d := <-c.intervals:
+			c.refreshInterval = d
+			ticker.Reset(d)
 		case <-c.ctx.Done():
 			log.Default().Println("stopping cache refresh...")
 			return
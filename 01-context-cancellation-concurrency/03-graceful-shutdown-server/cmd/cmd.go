@@ -1,8 +1,17 @@
 package main
 
-import gracefulshutdownserver "graceful-shutdown-serve"
+import (
+	"log"
+	"os"
+
+	gracefulshutdownserver "graceful-shutdown-serve"
+)
 
 func main() {
-	app := gracefulshutdownserver.InitApplication("localhost:8080", "localhost:8080")
+	config, err := gracefulshutdownserver.NewConfigStore(os.Getenv("APP_CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	app := gracefulshutdownserver.InitApplication("localhost:8080", "localhost:8080").WithConfig(config)
 	app.Start()
 }
@@ -0,0 +1,46 @@
+package gracefulshutdownserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"config-store"
+)
+
+// Config holds the settings that can change without a restart: edit the
+// file or the environment and send the process SIGHUP.
+type Config struct {
+	ShutdownTimeout      configstore.Duration `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	CacheRefreshInterval configstore.Duration `json:"cache_refresh_interval" env:"CACHE_REFRESH_INTERVAL"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ShutdownTimeout:      configstore.Duration(10 * time.Second),
+		CacheRefreshInterval: configstore.Duration(30 * time.Second),
+	}
+}
+
+func (c *Config) Validate() error {
+	var err error
+	if c.ShutdownTimeout <= 0 {
+		err = errors.Join(err, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.CacheRefreshInterval <= 0 {
+		err = errors.Join(err, errors.New("cache_refresh_interval must be positive"))
+	}
+	return err
+}
+
+// NewConfigStore loads DefaultConfig, overridden by the JSON file at path
+// if path is not empty, then by APP_* environment variables.
+func NewConfigStore(path string) (*configstore.Store[Config], error) {
+	opts := []configstore.Option[Config]{configstore.WithValidator((*Config).Validate)}
+	if path != "" {
+		opts = append(opts, configstore.WithLoader(configstore.JSONFile[Config](os.DirFS(filepath.Dir(path)), filepath.Base(path))))
+	}
+	opts = append(opts, configstore.WithLoader(configstore.Env[Config]("APP_")))
+	return configstore.New(DefaultConfig(), opts...)
+}
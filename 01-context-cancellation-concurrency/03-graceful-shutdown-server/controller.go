@@ -8,9 +8,10 @@ import (
 
 type Data string
 type Controller struct {
-	pool  WorkerPool[Data]
-	cache Cache
-	db    Database
+	pool   WorkerPool[Data]
+	cache  Cache
+	db     Database
+	health *Health
 }
 
 func NewController(pool WorkerPool[Data], cache Cache, db Database) *Controller {
@@ -21,8 +22,17 @@ func NewController(pool WorkerPool[Data], cache Cache, db Database) *Controller
 	}
 }
 
+// WithHealth serves health at /healthz.
+func (h *Controller) WithHealth(health *Health) *Controller {
+	h.health = health
+	return h
+}
+
 func (h *Controller) SetupRouter(srv *http.ServeMux) {
 	srv.HandleFunc("/ping", h.handlerRequest)
+	if h.health != nil {
+		srv.Handle("/healthz", h.health)
+	}
 }
 
 func (h *Controller) handlerRequest(rw http.ResponseWriter, r *http.Request) {
@@ -6,6 +6,8 @@ import (
 	"log"
 	"net"
 	"sync"
+
+	"object-pool"
 )
 
 type Database interface {
@@ -46,9 +48,8 @@ type ConnPool struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	factory  func() (net.Conn, error)
-	idleCons chan net.Conn
-	sema     chan struct{}
+	idle *pool.Bounded[net.Conn]
+	sema chan struct{}
 }
 
 func NewPool(ctx context.Context, cap int, factory func() (net.Conn, error)) *ConnPool {
@@ -59,9 +60,11 @@ func NewPool(ctx context.Context, cap int, factory func() (net.Conn, error)) *Co
 		cancel: cancel,
 		wg:     sync.WaitGroup{},
 
-		factory:  factory,
-		idleCons: make(chan net.Conn, cap),
-		sema:     make(chan struct{}, cap),
+		idle: pool.NewBounded(cap,
+			func(context.Context) (net.Conn, error) { return factory() },
+			pool.WithDestroy(func(conn net.Conn) { _ = conn.Close() }),
+		),
+		sema: make(chan struct{}, cap),
 	}
 }
 
@@ -92,27 +95,11 @@ func (cp *ConnPool) Get(ctx context.Context) (net.Conn, error) {
 	if cp.IsShutdown() {
 		return nil, errors.New("already shutdown")
 	}
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case conn := <-cp.idleCons:
-		return conn, nil
-	default:
-		return cp.factory()
-	}
-
+	return cp.idle.Get(ctx)
 }
 
 func (cp *ConnPool) Put(conn net.Conn) {
-	if cp.IsShutdown() {
-		_ = conn.Close()
-		return
-	}
-	select {
-	case cp.idleCons <- conn:
-	default:
-		_ = conn.Close()
-	}
+	cp.idle.Put(conn)
 }
 
 func (cp *ConnPool) Shutdown() {
@@ -121,11 +108,7 @@ func (cp *ConnPool) Shutdown() {
 	}
 	cp.cancel()
 	cp.wg.Wait()
-
-	close(cp.idleCons)
-	for conn := range cp.idleCons {
-		_ = conn.Close()
-	}
+	cp.idle.Close()
 }
 
 func (cp *ConnPool) IsShutdown() bool {
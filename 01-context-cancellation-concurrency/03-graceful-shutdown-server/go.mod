@@ -1,3 +1,22 @@
 module graceful-shutdown-serve
 
 go 1.25.0
+
+require (
+	config-store v0.0.0
+	heartbeat-supervisor v0.0.0
+	object-pool v0.0.0
+)
+
+require (
+	golang.org/x/sync v0.19.0 // indirect
+	panic-safe-errgroup v0.0.0 // indirect
+)
+
+replace object-pool => ../../02-performance-allocation/30-object-pool
+
+replace config-store => ../../05-filesystems-packaging/33-config-store
+
+replace heartbeat-supervisor => ../39-heartbeat-supervisor
+
+replace panic-safe-errgroup => ../27-panic-safe-errgroup
@@ -0,0 +1,65 @@
+package gracefulshutdownserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck reports whether a component can do its job; nil is healthy.
+type HealthCheck func(ctx context.Context) error
+
+// Health serves the application's health at /healthz: 200 when every
+// registered check passes, 503 naming the failures otherwise.
+type Health struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]HealthCheck
+}
+
+func NewHealth() *Health {
+	return &Health{checks: make(map[string]HealthCheck)}
+}
+
+// Register adds or replaces the check called name.
+func (h *Health) Register(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+type healthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (h *Health) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	report := healthReport{Status: "ok", Checks: make(map[string]string, len(h.names))}
+	for _, name := range h.names {
+		if err := h.checks[name](ctx); err != nil {
+			report.Status = "unhealthy"
+			report.Checks[name] = err.Error()
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(rw).Encode(report); err != nil {
+		log.Println("health report error: ", err)
+	}
+}
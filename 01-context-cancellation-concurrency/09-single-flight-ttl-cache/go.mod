@@ -3,3 +3,7 @@ module single-flight-ttl-cache
 go 1.25.0
 
 require golang.org/x/sync v0.19.0
+
+require weighted-semaphore v0.0.0
+
+replace weighted-semaphore => ../24-weighted-semaphore
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// KeyEncoder turns a key into the string used to deduplicate in-flight loads.
+// Two keys must encode to the same string only if they are equal (==).
+type KeyEncoder[K comparable] func(K) string
+
+// WithKeyEncoder replaces the default reflection-based encoder, e.g. with a
+// hand-written one for a hot struct key.
+func WithKeyEncoder[K comparable, V any](enc KeyEncoder[K]) Options[K, V] {
+	return func(c *Cache[K, V]) {
+		c.encode = enc
+	}
+}
+
+// defaultKeyEncoder returns an identity encoder when K is string and a
+// canonical binary encoder otherwise. Unlike fmt's %#v, it tags every dynamic
+// type, length-prefixes strings and records pointer identity, so int(1) and
+// int64(1) stored in an `any` key, or structs whose %#v output happens to
+// coincide, never share a load.
+func defaultKeyEncoder[K comparable]() KeyEncoder[K] {
+	var zero K
+	if _, ok := any(zero).(string); ok {
+		return func(key K) string { return any(key).(string) }
+	}
+	return func(key K) string {
+		buf := make([]byte, 0, 64)
+		return string(appendKey(buf, reflect.ValueOf(&key).Elem()))
+	}
+}
+
+func appendKey(buf []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			f = 0 // +0 and -0 are equal keys
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(real(c)))
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(imag(c)))
+	case reflect.String:
+		s := v.String()
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		return append(buf, s...)
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return binary.AppendUvarint(buf, uint64(v.Pointer()))
+	case reflect.Array:
+		for i := range v.Len() {
+			buf = appendKey(buf, v.Index(i))
+		}
+		return buf
+	case reflect.Struct:
+		for i := range v.NumField() {
+			buf = appendKey(buf, v.Field(i))
+		}
+		return buf
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(buf, 0)
+		}
+		buf = append(buf, 1)
+		buf = appendType(buf, v.Elem().Type())
+		return appendKey(buf, v.Elem())
+	default:
+		// Not reachable for comparable types; keep the encoding total anyway.
+		return appendType(buf, v.Type())
+	}
+}
+
+func appendType(buf []byte, t reflect.Type) []byte {
+	name := t.PkgPath() + "." + t.String()
+	buf = append(buf, strconv.Itoa(len(name))...)
+	buf = append(buf, ':')
+	return append(buf, name...)
+}
@@ -2,79 +2,364 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/singleflight"
+	"weighted-semaphore"
 )
 
+var (
+	// ErrNotFound is returned by Peek when the key has never been cached.
+	ErrNotFound = errors.New("cache: key not found")
+	// ErrExpired is returned by Peek when the key is cached but its TTL has elapsed.
+	ErrExpired = errors.New("cache: entry expired")
+)
+
+// LoadError reports that the loader (the backing store) failed for Key.
+type LoadError[K comparable] struct {
+	Key K
+	Err error
+}
+
+func (e *LoadError[K]) Error() string {
+	return fmt.Sprintf("failed to load key %v: %v", e.Key, e.Err)
+}
+
+func (e *LoadError[K]) Unwrap() error {
+	return e.Err
+}
+
 type Cache[K comparable, V any] struct {
 	g   *singleflight.Group
-	c   map[K]*Item[V]
+	c   map[entryKey[K]]*Item[V]
 	mu  sync.RWMutex
 	ttl time.Duration
+
+	encode  KeyEncoder[K]
+	costFn  func(V) int64
+	maxCost int64
+	cost    int64 // guarded by mu
+
+	preloadParallelism int
+	loadSem            *semaphore.Weighted // nil means unlimited
+
+	hits, misses, loads, loadErrors, evictions atomic.Uint64
+}
+
+// entryKey scopes a key to the group it belongs to; the cache's own
+// key space is the group with the empty name.
+type entryKey[K comparable] struct {
+	group string
+	key   K
+}
+
+func NewCache[K comparable, V any](ttl time.Duration, opts ...Options[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		g:      new(singleflight.Group),
+		c:      make(map[entryKey[K]]*Item[V]),
+		ttl:    ttl,
+		encode: defaultKeyEncoder[K](),
+
+		preloadParallelism: 8,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type Options[K comparable, V any] func(c *Cache[K, V])
+
+// WithCost sets how much each value counts toward the max total cost.
+// Without it every entry costs 1, so WithMaxCost acts as an entry limit.
+func WithCost[K comparable, V any](fn func(V) int64) Options[K, V] {
+	return func(c *Cache[K, V]) {
+		c.costFn = fn
+	}
 }
 
-func NewCache[K comparable, V any](ttl time.Duration) *Cache[K, V] {
-	return &Cache[K, V]{
-		g:   new(singleflight.Group),
-		c:   make(map[K]*Item[V]),
-		ttl: ttl,
+// WithMaxCost caps the summed cost of all cached entries. Zero means unbounded.
+func WithMaxCost[K comparable, V any](maxCost int64) Options[K, V] {
+	return func(c *Cache[K, V]) {
+		c.maxCost = maxCost
+	}
+}
+
+// WithPreloadParallelism bounds how many loads Preload runs at once.
+func WithPreloadParallelism[K comparable, V any](n int) Options[K, V] {
+	return func(c *Cache[K, V]) {
+		c.preloadParallelism = n
+	}
+}
+
+// WithMaxConcurrentLoads caps loader executions running at once across all
+// keys and groups, protecting the backing store when a cold cache misses on
+// everything. Waiters still honour their own ctx while a load is queued.
+func WithMaxConcurrentLoads[K comparable, V any](n int) Options[K, V] {
+	return func(c *Cache[K, V]) {
+		if n > 0 {
+			c.loadSem = semaphore.NewWeighted(int64(n))
+		}
 	}
 }
 
 func (c *Cache[K, V]) Get(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return c.get(ctx, entryKey[K]{key: key}, loader)
+}
+
+// Peek returns the cached value for key without ever calling a loader.
+// It returns ErrNotFound for unknown keys and ErrExpired for stale entries.
+func (c *Cache[K, V]) Peek(key K) (V, error) {
+	return c.peek(entryKey[K]{key: key})
+}
+
+// Preload warms the cache with keys, running at most the configured number
+// of loads concurrently. Loads go through the same single-flight path as Get,
+// so live traffic for a key being preloaded waits for it instead of loading
+// again. Every key is attempted; failures are returned joined.
+func (c *Cache[K, V]) Preload(ctx context.Context, keys []K, loader func(context.Context, K) (V, error)) error {
+	return c.preload(ctx, "", keys, loader)
+}
+
+func (c *Cache[K, V]) preload(ctx context.Context, group string, keys []K, loader func(context.Context, K) (V, error)) error {
+	errs := make([]error, len(keys))
+
+	var g errgroup.Group
+	g.SetLimit(max(c.preloadParallelism, 1))
+	for i, key := range keys {
+		if ctx.Err() != nil {
+			errs[i] = fmt.Errorf("preload key %v: %w", key, ctx.Err())
+			continue
+		}
+		g.Go(func() error {
+			_, errs[i] = c.get(ctx, entryKey[K]{group: group, key: key}, func(ctx context.Context) (V, error) {
+				return loader(ctx, key)
+			})
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Stats is a snapshot of the cache counters, aggregated over all groups.
+type Stats struct {
+	Hits       uint64
+	Misses     uint64
+	Loads      uint64
+	LoadErrors uint64
+	Evictions  uint64
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Loads:      c.loads.Load(),
+		LoadErrors: c.loadErrors.Load(),
+		Evictions:  c.evictions.Load(),
+	}
+}
+
+// Group is an isolated key space inside a Cache. Groups share the parent's
+// TTL, cost budget, single-flight pool and stats, but a key in one group
+// never sees the value stored under the same key in another.
+type Group[K comparable, V any] struct {
+	name  string
+	cache *Cache[K, V]
+}
+
+// NewGroup returns the namespace called name. Calling it twice with the
+// same name yields views over the same entries.
+func (c *Cache[K, V]) NewGroup(name string) *Group[K, V] {
+	return &Group[K, V]{name: name, cache: c}
+}
+
+func (g *Group[K, V]) Name() string {
+	return g.name
+}
+
+func (g *Group[K, V]) Get(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return g.cache.get(ctx, entryKey[K]{group: g.name, key: key}, loader)
+}
+
+func (g *Group[K, V]) Peek(key K) (V, error) {
+	return g.cache.peek(entryKey[K]{group: g.name, key: key})
+}
+
+func (g *Group[K, V]) Preload(ctx context.Context, keys []K, loader func(context.Context, K) (V, error)) error {
+	return g.cache.preload(ctx, g.name, keys, loader)
+}
+
+func (c *Cache[K, V]) get(ctx context.Context, ek entryKey[K], loader func(context.Context) (V, error)) (V, error) {
 	c.mu.RLock()
-	item, ok := c.c[key]
+	item, ok := c.c[ek]
 	c.mu.RUnlock()
 
 	if ok && !item.isExpired() {
+		c.hits.Add(1)
+		item.touch()
 		return item.value, nil
 	}
+	c.misses.Add(1)
 
 	select {
 	case <-ctx.Done():
-		return *new(V), fmt.Errorf("failed to load key %v: %w", key, ctx.Err())
-	case res := <-c.g.DoChan(keyToString(key), c.newLoaderFunc(ctx, key, loader)):
+		return *new(V), fmt.Errorf("failed to load key %v: %w", ek.key, ctx.Err())
+	case res := <-c.g.DoChan(c.flightKey(ek), c.newLoaderFunc(ctx, ek, loader)):
 		if res.Err != nil {
-			return *new(V), fmt.Errorf("failed to load key %v: %w", key, res.Err)
+			return *new(V), &LoadError[K]{Key: ek.key, Err: res.Err}
 		}
 		return res.Val.(V), nil
 	}
 }
 
-func (c *Cache[K, V]) newLoaderFunc(ctx context.Context, key K, loader func(context.Context) (V, error)) func() (interface{}, error) {
+func (c *Cache[K, V]) peek(ek entryKey[K]) (V, error) {
+	c.mu.RLock()
+	item, ok := c.c[ek]
+	c.mu.RUnlock()
+
+	if !ok {
+		return *new(V), fmt.Errorf("peek key %v: %w", ek.key, ErrNotFound)
+	}
+	if item.isExpired() {
+		return *new(V), fmt.Errorf("peek key %v: %w", ek.key, ErrExpired)
+	}
+	return item.value, nil
+}
+
+// Cost returns the summed cost of all cached entries.
+func (c *Cache[K, V]) Cost() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cost
+}
+
+func (c *Cache[K, V]) newLoaderFunc(ctx context.Context, ek entryKey[K], loader func(context.Context) (V, error)) func() (interface{}, error) {
 	return func() (interface{}, error) {
-		v, err := loader(context.WithoutCancel(ctx))
-		if err == nil {
-			c.mu.Lock()
-			c.c[key] = NewCacheItem(v, c.ttl)
-			c.mu.Unlock()
+		// The load is shared by every waiter, so it must not give up when the
+		// first caller's ctx does; it simply queues for a slot.
+		ctx := context.WithoutCancel(ctx)
+		if c.loadSem != nil {
+			if err := c.loadSem.Acquire(ctx, 1); err != nil {
+				return nil, err
+			}
+			defer c.loadSem.Release(1)
 		}
-		return v, err
+
+		c.loads.Add(1)
+		v, err := loader(ctx)
+		if err != nil {
+			c.loadErrors.Add(1)
+			return v, err
+		}
+		c.set(ek, v)
+		return v, nil
+	}
+}
+
+func (c *Cache[K, V]) set(key entryKey[K], v V) {
+	item := NewCacheItem(v, c.ttl)
+	item.cost = c.costOf(v)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+	// A value that can never fit is still returned to callers, just not cached.
+	if c.maxCost > 0 && item.cost > c.maxCost {
+		return
+	}
+	for c.maxCost > 0 && c.cost+item.cost > c.maxCost {
+		c.remove(c.victim())
+		c.evictions.Add(1)
+	}
+	c.c[key] = item
+	c.cost += item.cost
+}
+
+func (c *Cache[K, V]) costOf(v V) int64 {
+	if c.costFn == nil {
+		return 1
+	}
+	return max(c.costFn(v), 0)
+}
+
+// evictionSamples is how many entries victim weighs against each other.
+const evictionSamples = 8
+
+// victim picks the entry to evict among a few sampled ones, so eviction
+// stays O(1) however large the cache grows: an expired entry first,
+// otherwise the one with the highest idle-time × cost score, so a large
+// cold blob goes before a small cold value. The score moves with the clock,
+// which rules out keeping entries in a fixed order; like Redis, the cache
+// approximates the policy instead. Map iteration starts at a random entry,
+// so every entry is eventually considered. Callers must hold mu for writing
+// with a non-empty cache.
+func (c *Cache[K, V]) victim() entryKey[K] {
+	now := time.Now().UnixNano()
+	var (
+		victim    entryKey[K]
+		bestScore float64 = -1
+		sampled   int
+	)
+	for k, item := range c.c {
+		if item.isExpired() {
+			return k
+		}
+		idle := float64(now - item.lastAccess.Load() + 1)
+		if score := idle * float64(item.cost+1); score > bestScore {
+			victim, bestScore = k, score
+		}
+		if sampled++; sampled == evictionSamples {
+			break
+		}
+	}
+	return victim
+}
+
+func (c *Cache[K, V]) remove(key entryKey[K]) {
+	if item, ok := c.c[key]; ok {
+		c.cost -= item.cost
+		delete(c.c, key)
 	}
 }
 
 type Item[V any] struct {
-	value V
-	exp   time.Time
+	value      V
+	exp        time.Time
+	cost       int64
+	lastAccess atomic.Int64
 }
 
 func NewCacheItem[V any](value V, ttl time.Duration) *Item[V] {
-	return &Item[V]{
+	item := &Item[V]{
 		value: value,
 		exp:   time.Now().Add(ttl),
 	}
+	item.touch()
+	return item
 }
 
 func (i *Item[V]) isExpired() bool {
 	return time.Now().After(i.exp)
 }
 
-func keyToString[K comparable](key K) string {
-	if s, ok := any(key).(string); ok {
-		return s
-	}
-	return fmt.Sprintf("%#v", key)
+func (i *Item[V]) touch() {
+	i.lastAccess.Store(time.Now().UnixNano())
+}
+
+// flightKey length-prefixes the group name so that ("ab", "c") and
+// ("a", "bc") never share a single-flight call.
+func (c *Cache[K, V]) flightKey(ek entryKey[K]) string {
+	return strconv.Itoa(len(ek.group)) + ":" + ek.group + c.encode(ek.key)
 }
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -217,3 +219,372 @@ func TestCache_Cancellation(t *testing.T) {
 		t.Errorf("expected 200 or 300, got %v", val)
 	}
 }
+
+func TestCache_TypedErrors(t *testing.T) {
+	c := NewCache[string, int](20 * time.Millisecond)
+
+	if _, err := c.Peek("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Get(context.Background(), "k", func(ctx context.Context) (int, error) {
+		return 7, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, err := c.Peek("k"); err != nil || v != 7 {
+		t.Errorf("expected 7, nil; got %v, %v", v, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Peek("k"); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+
+	backendErr := errors.New("db down")
+	_, err := c.Get(context.Background(), "bad", func(ctx context.Context) (int, error) {
+		return 0, backendErr
+	})
+	var le *LoadError[string]
+	if !errors.As(err, &le) {
+		t.Fatalf("expected *LoadError, got %T: %v", err, err)
+	}
+	if le.Key != "bad" {
+		t.Errorf("expected key %q, got %q", "bad", le.Key)
+	}
+	if !errors.Is(err, backendErr) {
+		t.Errorf("expected error to wrap backend error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx, "cancelled", func(ctx context.Context) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 1, nil
+	})
+	if errors.As(err, &le) {
+		t.Errorf("cancelled wait must not be reported as a LoadError: %v", err)
+	}
+}
+
+func TestCache_CostWeightedEviction(t *testing.T) {
+	c := NewCache[string, string](time.Second,
+		WithCost[string, string](func(v string) int64 { return int64(len(v)) }),
+		WithMaxCost[string, string](10),
+	)
+	ctx := context.Background()
+	load := func(v string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) { return v, nil }
+	}
+
+	_, _ = c.Get(ctx, "blob", load("abcdef"))
+	_, _ = c.Get(ctx, "small", load("ab"))
+
+	if got := c.Cost(); got != 8 {
+		t.Fatalf("expected cost 8, got %d", got)
+	}
+
+	_, _ = c.Get(ctx, "new", load("abcd"))
+
+	if _, err := c.Peek("blob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected blob to be evicted, got %v", err)
+	}
+	if _, err := c.Peek("small"); err != nil {
+		t.Errorf("expected small to survive, got %v", err)
+	}
+	if got := c.Cost(); got > 10 {
+		t.Errorf("cost %d exceeds max cost", got)
+	}
+
+	v, err := c.Get(ctx, "huge", load("this value is too large"))
+	if err != nil || v != "this value is too large" {
+		t.Errorf("oversized value must still be returned, got %q, %v", v, err)
+	}
+	if _, err := c.Peek("huge"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("oversized value must not be cached, got %v", err)
+	}
+}
+
+func TestCache_MaxCostDefaultsToEntryCount(t *testing.T) {
+	c := NewCache[int, int](time.Second, WithMaxCost[int, int](3))
+	for i := range 10 {
+		_, _ = c.Get(context.Background(), i, func(context.Context) (int, error) { return i, nil })
+	}
+	if got := c.Cost(); got != 3 {
+		t.Errorf("expected 3 entries, got %d", got)
+	}
+}
+
+func TestCache_EvictionAtCapacity(t *testing.T) {
+	const capacity = 5000
+	c := NewCache[int, int](time.Minute, WithMaxCost[int, int](capacity))
+	ctx := context.Background()
+	for i := range 4 * capacity {
+		_, _ = c.Get(ctx, i, func(context.Context) (int, error) { return i, nil })
+	}
+	if got := c.Cost(); got != capacity {
+		t.Errorf("expected cost %d, got %d", capacity, got)
+	}
+	if st := c.Stats(); st.Evictions != 3*capacity {
+		t.Errorf("expected %d evictions, got %d", 3*capacity, st.Evictions)
+	}
+}
+
+func TestCache_Groups(t *testing.T) {
+	c := NewCache[string, string](time.Second, WithMaxCost[string, string](2))
+	users := c.NewGroup("users")
+	orders := c.NewGroup("orders")
+	ctx := context.Background()
+
+	u, _ := users.Get(ctx, "1", func(context.Context) (string, error) { return "alice", nil })
+	o, _ := orders.Get(ctx, "1", func(context.Context) (string, error) { return "order-1", nil })
+	if u != "alice" || o != "order-1" {
+		t.Fatalf("groups leaked values: users=%q orders=%q", u, o)
+	}
+	if _, err := c.Peek("1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("root key space must not see group entries, got %v", err)
+	}
+
+	// The third entry, in any group, must evict from the shared budget.
+	_, _ = c.NewGroup("sessions").Get(ctx, "1", func(context.Context) (string, error) { return "s", nil })
+	if got := c.Cost(); got != 2 {
+		t.Errorf("expected shared cost 2, got %d", got)
+	}
+
+	st := c.Stats()
+	if st.Loads != 3 || st.Misses != 3 || st.Evictions != 1 {
+		t.Errorf("unexpected shared stats: %+v", st)
+	}
+}
+
+func TestCache_GroupsSingleFlightIsolation(t *testing.T) {
+	c := NewCache[string, string](time.Second)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		results[0], _ = c.NewGroup("ab").Get(context.Background(), "c", func(context.Context) (string, error) {
+			<-release
+			return "ab/c", nil
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		results[1], _ = c.NewGroup("a").Get(context.Background(), "bc", func(context.Context) (string, error) {
+			<-release
+			return "a/bc", nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if results[0] != "ab/c" || results[1] != "a/bc" {
+		t.Errorf("groups shared a single-flight call: %v", results)
+	}
+}
+
+func TestDefaultKeyEncoder(t *testing.T) {
+	type inner struct{ v any }
+	type key struct {
+		a string
+		b string
+		i inner
+		p *int
+	}
+	x, y := 1, 1
+
+	enc := defaultKeyEncoder[key]()
+	distinct := []key{
+		{a: "ab", b: "c"},
+		{a: "a", b: "bc"},
+		{i: inner{v: 1}},
+		{i: inner{v: int64(1)}},
+		{i: inner{v: "1"}},
+		{p: &x},
+		{p: &y},
+	}
+	seen := make(map[string]key)
+	for _, k := range distinct {
+		s := enc(k)
+		if prev, ok := seen[s]; ok {
+			t.Errorf("keys %+v and %+v share encoding %q", prev, k, s)
+		}
+		seen[s] = k
+	}
+
+	if enc(key{a: "x", p: &x}) != enc(key{a: "x", p: &x}) {
+		t.Error("equal keys must encode identically")
+	}
+
+	anyEnc := defaultKeyEncoder[any]()
+	if anyEnc(1) == anyEnc(int64(1)) || anyEnc("1") == anyEnc(1) {
+		t.Error("dynamic types must be part of the encoding")
+	}
+}
+
+func TestCache_InterfaceKeysDoNotShareLoads(t *testing.T) {
+	c := NewCache[any, string](time.Second)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+
+	for i, k := range []any{1, int64(1)} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = c.Get(context.Background(), k, func(context.Context) (string, error) {
+				<-release
+				return fmt.Sprintf("%T", k), nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if results[0] != "int" || results[1] != "int64" {
+		t.Errorf("distinct keys shared a load: %v", results)
+	}
+}
+
+func TestCache_WithKeyEncoder(t *testing.T) {
+	type key struct{ ID int }
+	var calls atomic.Int32
+	c := NewCache[key, int](time.Second, WithKeyEncoder[key, int](func(k key) string {
+		calls.Add(1)
+		return strconv.Itoa(k.ID)
+	}))
+
+	v, err := c.Get(context.Background(), key{ID: 7}, func(context.Context) (int, error) { return 7, nil })
+	if err != nil || v != 7 {
+		t.Fatalf("expected 7, nil; got %v, %v", v, err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected custom encoder to be used once, got %d", calls.Load())
+	}
+}
+
+func TestCache_Preload(t *testing.T) {
+	c := NewCache[int, int](time.Second, WithPreloadParallelism[int, int](3))
+
+	var inFlight, peak atomic.Int32
+	loader := func(ctx context.Context, k int) (int, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if k == 13 {
+			return 0, errors.New("unlucky")
+		}
+		return k * 10, nil
+	}
+
+	keys := make([]int, 20)
+	for i := range keys {
+		keys[i] = i
+	}
+	err := c.Preload(context.Background(), keys, loader)
+
+	var le *LoadError[int]
+	if !errors.As(err, &le) || le.Key != 13 {
+		t.Errorf("expected LoadError for key 13, got %v", err)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("expected at most 3 concurrent loads, got %d", p)
+	}
+	for _, k := range keys {
+		v, err := c.Peek(k)
+		if k == 13 {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("failed key must not be cached, got %v", err)
+			}
+			continue
+		}
+		if err != nil || v != k*10 {
+			t.Errorf("key %d: expected %d, got %v, %v", k, k*10, v, err)
+		}
+	}
+}
+
+func TestCache_PreloadCancelled(t *testing.T) {
+	c := NewCache[int, int](time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var loads atomic.Int32
+	err := c.Preload(ctx, []int{1, 2, 3}, func(ctx context.Context, k int) (int, error) {
+		loads.Add(1)
+		return k, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if loads.Load() != 0 {
+		t.Errorf("expected no loads after cancellation, got %d", loads.Load())
+	}
+}
+
+func TestCache_MaxConcurrentLoads(t *testing.T) {
+	c := NewCache[int, int](time.Second, WithMaxConcurrentLoads[int, int](2))
+
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Get(context.Background(), i, func(context.Context) (int, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				return i, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p != 2 {
+		t.Errorf("expected peak of 2 concurrent loads, got %d", p)
+	}
+	if got := c.Stats().Loads; got != 10 {
+		t.Errorf("expected 10 loads, got %d", got)
+	}
+}
+
+func TestCache_MaxConcurrentLoadsWaiterCancel(t *testing.T) {
+	c := NewCache[int, int](time.Second, WithMaxConcurrentLoads[int, int](1))
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = c.Get(context.Background(), 1, func(context.Context) (int, error) {
+			close(started)
+			<-block
+			return 1, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(ctx, 2, func(context.Context) (int, error) { return 2, nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued waiter must return on ctx timeout, got %v", err)
+	}
+	close(block)
+}
@@ -0,0 +1,11 @@
+module token-bucket-rate-limiter
+
+go 1.25.0
+
+require concurrent-map-with-sharded-locks v0.0.0
+
+require consistent-hash-ring v0.0.0 // indirect
+
+replace concurrent-map-with-sharded-locks => ../../02-performance-allocation/02-concurrent-map-with-sharded-locks
+
+replace consistent-hash-ring => ../../02-performance-allocation/35-consistent-hash-ring
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+
+	"concurrent-map-with-sharded-locks"
+)
+
+// KeyedRateLimiter gives every key, such as a user or tenant ID, its own
+// RateLimiter. The limiters live in a sharded map, so limiting different
+// keys does not contend on one mutex. No shard is locked while a limiter is
+// used, let alone while waiting for tokens.
+type KeyedRateLimiter[K comparable] struct {
+	limiters   concurrentmapwithshardedlocks.ShardedMap[K, *RateLimiter]
+	newLimiter func() *RateLimiter // built once, so lookups do not allocate
+}
+
+// NewKeyedRateLimiter limits each key to rate events per second with bursts
+// of burst, over numShards shards.
+func NewKeyedRateLimiter[K comparable](rate float64, burst int, numShards int, opts ...Option) *KeyedRateLimiter[K] {
+	return &KeyedRateLimiter[K]{
+		limiters: concurrentmapwithshardedlocks.NewShardedMap[K, *RateLimiter](uint(max(numShards, 1))),
+		newLimiter: func() *RateLimiter {
+			return NewRateLimiter(rate, burst, opts...)
+		},
+	}
+}
+
+// Allow reports whether key may have an event now.
+func (k *KeyedRateLimiter[K]) Allow(key K) bool {
+	for {
+		l, _ := k.limiters.GetOrCompute(key, k.newLimiter)
+		if allowed, live := l.allowN(1); live {
+			return allowed
+		}
+		// Prune dropped l between the lookup and now; use its replacement.
+	}
+}
+
+// Reserve reserves a token of key's.
+func (k *KeyedRateLimiter[K]) Reserve(key K) *Reservation {
+	for {
+		l, _ := k.limiters.GetOrCompute(key, k.newLimiter)
+		if r, live := l.reserveN(1); live {
+			return r
+		}
+	}
+}
+
+// Wait blocks until key may have an event or ctx is done. Other keys of the
+// same shard are not held up while it waits.
+func (k *KeyedRateLimiter[K]) Wait(ctx context.Context, key K) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return k.Reserve(key).wait(ctx)
+}
+
+// Prune forgets the keys whose bucket has refilled completely and returns
+// how many it removed. A full bucket is indistinguishable from a new one,
+// and a dropped limiter is retired so no caller still holding it can take
+// a token from it, so pruning never lets a key through faster. Call it
+// periodically to keep memory bounded by the number of recently active
+// keys.
+func (k *KeyedRateLimiter[K]) Prune() int {
+	removed := 0
+	for key := range k.limiters.KeysSeq() {
+		k.limiters.Update(key, func(l *RateLimiter, exists bool) (*RateLimiter, bool) {
+			if exists && l.retire() {
+				removed++
+				return nil, false
+			}
+			return l, exists
+		})
+	}
+	return removed
+}
+
+// Len is the number of keys currently tracked.
+func (k *KeyedRateLimiter[K]) Len() int {
+	return k.limiters.Len()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrBurstExceeded = errors.New("request exceeds limiter burst")
+
+// RateLimiter is a token bucket: it holds up to burst tokens and refills at
+// rate tokens per second. Every event takes one. It is safe for concurrent
+// use; the bucket is refilled lazily on each call rather than by a ticker
+// goroutine, so an idle limiter costs nothing.
+type RateLimiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	retired bool // dropped by KeyedRateLimiter.Prune; takes no more tokens
+}
+
+type Option func(*RateLimiter)
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(l *RateLimiter) {
+		l.now = now
+	}
+}
+
+// NewRateLimiter allows rate events per second on average and bursts of up
+// to burst events. The bucket starts full.
+func NewRateLimiter(rate float64, burst int, opts ...Option) *RateLimiter {
+	l := &RateLimiter{
+		rate:   rate,
+		burst:  burst,
+		now:    time.Now,
+		tokens: float64(burst),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.last = l.now()
+	return l
+}
+
+// Allow reports whether an event may happen now, taking a token if so.
+func (l *RateLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, taking n tokens if so.
+func (l *RateLimiter) AllowN(n int) bool {
+	allowed, _ := l.allowN(n)
+	return allowed
+}
+
+// allowN is AllowN that refuses to touch a retired limiter, reporting
+// live=false instead.
+func (l *RateLimiter) allowN(n int) (allowed, live bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.retired {
+		return false, false
+	}
+	l.refill(l.now())
+	if l.tokens < float64(n) {
+		return false, true
+	}
+	l.tokens -= float64(n)
+	return true, true
+}
+
+// Reservation is a promise of tokens at a time in the future. The caller
+// must wait until then before acting, or Cancel to give the tokens back.
+type Reservation struct {
+	limiter   *RateLimiter
+	ok        bool
+	tokens    int
+	timeToAct time.Time
+}
+
+// Reserve takes a token now, even if it will only be available later, and
+// says when. Unlike Allow it never refuses an event within the burst; it
+// queues it.
+func (l *RateLimiter) Reserve() *Reservation {
+	return l.ReserveN(1)
+}
+
+// ReserveN is Reserve for n tokens. A reservation for more than burst
+// tokens can never be honoured and is not OK.
+func (l *RateLimiter) ReserveN(n int) *Reservation {
+	r, _ := l.reserveN(n)
+	return r
+}
+
+// reserveN is ReserveN that refuses to touch a retired limiter, reporting
+// live=false instead.
+func (l *RateLimiter) reserveN(n int) (_ *Reservation, live bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.retired {
+		return nil, false
+	}
+	now := l.now()
+	if n > l.burst {
+		return &Reservation{limiter: l, tokens: n, timeToAct: now}, true
+	}
+
+	l.refill(now)
+	l.tokens -= float64(n)
+	r := &Reservation{limiter: l, ok: true, tokens: n, timeToAct: now}
+	if l.tokens < 0 {
+		r.timeToAct = now.Add(l.durationFor(-l.tokens))
+	}
+	return r, true
+}
+
+// OK reports whether the limiter can honour the reservation at all.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay is how long the caller must wait before acting on the reservation.
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(r.limiter.now())
+}
+
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	return max(r.timeToAct.Sub(now), 0)
+}
+
+// Cancel gives the reserved tokens back for others to use, as far as the
+// bucket can hold them. Cancelling after the time to act does nothing: the
+// tokens are considered spent.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	l := r.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if !now.Before(r.timeToAct) {
+		return
+	}
+	l.refill(now)
+	l.tokens = min(l.tokens+float64(r.tokens), float64(l.burst))
+	r.ok = false
+}
+
+// Wait blocks until an event may happen or ctx is done. It fails at once,
+// without taking a token, when ctx's deadline is sooner than the wait.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.ReserveN(n).wait(ctx)
+}
+
+// wait waits for the reservation's time to act, cancelling it if ctx ends
+// first or cannot last that long.
+func (r *Reservation) wait(ctx context.Context) error {
+	if !r.OK() {
+		return fmt.Errorf("wait for %d tokens, burst %d: %w", r.tokens, r.limiter.burst, ErrBurstExceeded)
+	}
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel()
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens earned since the last call. l.mu must be held.
+func (l *RateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.tokens+elapsed.Seconds()*l.rate, float64(l.burst))
+		l.last = now
+	}
+}
+
+// durationFor is how long the bucket takes to earn tokens.
+func (l *RateLimiter) durationFor(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// retire marks the limiter retired if its bucket is full, i.e. it holds no
+// memory of past events and can be dropped and recreated at no cost, and
+// reports whether it did.
+func (l *RateLimiter) retire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(l.now())
+	if l.tokens < float64(l.burst) {
+		return false
+	}
+	l.retired = true
+	return true
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is advanced by hand. It is not safe for concurrent use.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestRateLimiter_Allow(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(1, 3, WithClock(clock.Now))
+
+	for i := range 3 {
+		if !l.Allow() {
+			t.Fatalf("event %d: expected the burst to allow it", i)
+		}
+	}
+	if l.Allow() {
+		t.Fatal("expected the limiter to refuse once the burst is spent")
+	}
+
+	clock.Advance(time.Second)
+	if !l.Allow() {
+		t.Error("expected one token after a second")
+	}
+	if l.Allow() {
+		t.Error("expected only one token after a second")
+	}
+
+	clock.Advance(time.Hour)
+	if !l.AllowN(3) || l.Allow() {
+		t.Error("expected the bucket to refill up to the burst only")
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(10, 1, WithClock(clock.Now))
+
+	if d := l.Reserve().Delay(); d != 0 {
+		t.Errorf("expected the first reservation to be immediate, got %v", d)
+	}
+	r := l.Reserve()
+	if d := r.Delay(); d != 100*time.Millisecond {
+		t.Errorf("expected the second reservation in 100ms, got %v", d)
+	}
+
+	r.Cancel()
+	if l.Allow() {
+		t.Error("expected the cancelled token not to be available before it is earned")
+	}
+	clock.Advance(100 * time.Millisecond)
+	if !l.Allow() {
+		t.Error("expected the cancelled reservation to give its token back")
+	}
+
+	if l.ReserveN(2).OK() {
+		t.Error("expected a reservation beyond the burst not to be OK")
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("Paces", func(t *testing.T) {
+		l := NewRateLimiter(100, 1)
+		start := time.Now()
+		for range 3 {
+			if err := l.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+			t.Errorf("expected 3 events at 100/s to take about 20ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("DeadlineTooSoon", func(t *testing.T) {
+		l := NewRateLimiter(1, 1)
+		l.Allow()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+			t.Errorf("expected to fail without waiting, took %v", elapsed)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		l := NewRateLimiter(1, 1)
+		l.Allow()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("BurstExceeded", func(t *testing.T) {
+		l := NewRateLimiter(1, 1)
+		if err := l.WaitN(context.Background(), 2); !errors.Is(err, ErrBurstExceeded) {
+			t.Errorf("expected ErrBurstExceeded, got %v", err)
+		}
+	})
+}
+
+func TestRateLimiter_Concurrency(t *testing.T) {
+	l := NewRateLimiter(0, 50)
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Go(func() {
+			if l.Allow() {
+				allowed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if allowed.Load() != 50 {
+		t.Errorf("expected exactly the burst of 50 events, got %d", allowed.Load())
+	}
+}
+
+func TestKeyedRateLimiter(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyedRateLimiter[string](1, 1, 8, WithClock(clock.Now))
+
+	if !k.Allow("alice") || !k.Allow("bob") {
+		t.Fatal("expected every key to have its own bucket")
+	}
+	if k.Allow("alice") {
+		t.Error("expected alice to be limited")
+	}
+
+	if n := k.Prune(); n != 0 || k.Len() != 2 {
+		t.Errorf("expected nothing to prune while buckets refill, pruned %d of %d", n, k.Len())
+	}
+	clock.Advance(time.Second)
+	if n := k.Prune(); n != 2 || k.Len() != 0 {
+		t.Errorf("expected both full buckets pruned, pruned %d, %d left", n, k.Len())
+	}
+	if !k.Allow("alice") || k.Allow("alice") {
+		t.Error("expected a pruned key to start again from a full bucket")
+	}
+
+	// A caller that looked alice's limiter up just before Prune dropped it
+	// must not take a token from it on top of the new bucket's burst.
+	clock.Advance(time.Second)
+	stale, _ := k.limiters.Get("alice")
+	k.Prune()
+	if allowed, live := stale.allowN(1); allowed || live {
+		t.Error("expected a pruned limiter to be retired")
+	}
+}
+
+func BenchmarkRateLimiter_Allow(b *testing.B) {
+	l := NewRateLimiter(1e9, 1e6)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Allow()
+		}
+	})
+}
+
+func BenchmarkKeyedRateLimiter_Allow(b *testing.B) {
+	keys := make([]int, 1024)
+	for i := range keys {
+		keys[i] = i
+	}
+	for _, shards := range []int{1, 64} {
+		b.Run(fmt.Sprintf("Shards%d", shards), func(b *testing.B) {
+			k := NewKeyedRateLimiter[int](1e9, 1e6, shards)
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					k.Allow(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
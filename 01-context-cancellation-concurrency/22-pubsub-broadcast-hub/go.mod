@@ -0,0 +1,3 @@
+module pubsub-broadcast-hub
+
+go 1.25.0
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrHubClosed = errors.New("hub closed")
+
+// SlowSubscriberPolicy decides what Publish does for a subscriber whose
+// buffer is full.
+type SlowSubscriberPolicy int
+
+const (
+	// Drop skips the message for that subscriber only.
+	Drop SlowSubscriberPolicy = iota
+	// Block waits for the subscriber to make room, until the publisher's
+	// context ends. One slow subscriber slows every publisher down.
+	Block
+	// Disconnect unsubscribes the subscriber, closing its channel, so it
+	// learns it fell behind instead of silently missing messages.
+	Disconnect
+)
+
+// Hub broadcasts every published message to all current subscribers, each
+// through its own buffered channel. It is safe for concurrent use.
+type Hub[T any] struct {
+	bufferSize int
+	policy     SlowSubscriberPolicy
+	dropped    atomic.Int64
+	done       chan struct{} // closed when Close starts
+	closeOnce  sync.Once
+
+	mu     sync.RWMutex
+	subs   map[*subscriber[T]]struct{}
+	closed bool
+}
+
+type subscriber[T any] struct {
+	ch   chan T
+	gone chan struct{} // closed as soon as the subscriber is leaving
+	once sync.Once
+	stop func() bool // stops watching the subscriber's context
+}
+
+type Option func(*hubConfig)
+
+type hubConfig struct {
+	bufferSize int
+	policy     SlowSubscriberPolicy
+}
+
+// WithBufferSize sets how many messages a subscriber may fall behind by
+// before the slow-subscriber policy applies. The default is 16.
+func WithBufferSize(n int) Option {
+	return func(c *hubConfig) {
+		c.bufferSize = n
+	}
+}
+
+func WithSlowSubscriberPolicy(policy SlowSubscriberPolicy) Option {
+	return func(c *hubConfig) {
+		c.policy = policy
+	}
+}
+
+func NewHub[T any](opts ...Option) *Hub[T] {
+	cfg := hubConfig{bufferSize: 16, policy: Drop}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Hub[T]{
+		bufferSize: cfg.bufferSize,
+		policy:     cfg.policy,
+		done:       make(chan struct{}),
+		subs:       make(map[*subscriber[T]]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every message published from now
+// on. The hub closes it when ctx ends, when the subscriber is disconnected
+// for being slow, or when the hub closes; messages already buffered can
+// still be received after that.
+func (h *Hub[T]) Subscribe(ctx context.Context) (<-chan T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sub := &subscriber[T]{
+		ch:   make(chan T, h.bufferSize),
+		gone: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	h.subs[sub] = struct{}{}
+	sub.stop = context.AfterFunc(ctx, func() { h.remove(sub) })
+	return sub.ch, nil
+}
+
+// Publish delivers msg to every subscriber according to the hub's
+// slow-subscriber policy. Only Block can make it wait; it returns ctx.Err()
+// if ctx ends first, with msg delivered to some subscribers only.
+func (h *Hub[T]) Publish(ctx context.Context, msg T) error {
+	var slow []*subscriber[T]
+	err := func() error {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		if h.closed {
+			return ErrHubClosed
+		}
+		for sub := range h.subs {
+			select {
+			case <-sub.gone:
+				continue
+			case sub.ch <- msg:
+				continue
+			default:
+			}
+
+			switch h.policy {
+			case Block:
+				select {
+				case sub.ch <- msg:
+				case <-sub.gone:
+				case <-h.done:
+					return ErrHubClosed
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			case Disconnect:
+				sub.leave()
+				slow = append(slow, sub)
+			default:
+				h.dropped.Add(1)
+			}
+		}
+		return nil
+	}()
+
+	for _, sub := range slow {
+		h.remove(sub)
+	}
+	return err
+}
+
+// Dropped counts the messages skipped under the Drop policy.
+func (h *Hub[T]) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Len is the number of current subscribers.
+func (h *Hub[T]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Close unsubscribes everyone and refuses further Subscribe and Publish
+// calls. It waits for Publish calls in progress, which it releases from
+// blocking on slow subscribers, so no message is sent on a closed channel.
+func (h *Hub[T]) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for sub := range h.subs {
+		h.drop(sub)
+	}
+}
+
+// leave tells publishers blocked on the subscriber to give up on it.
+func (s *subscriber[T]) leave() {
+	s.once.Do(func() { close(s.gone) })
+}
+
+func (h *Hub[T]) remove(sub *subscriber[T]) {
+	sub.leave()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		h.drop(sub)
+	}
+}
+
+// drop unsubscribes sub. h.mu must be held for writing.
+func (h *Hub[T]) drop(sub *subscriber[T]) {
+	sub.leave()
+	sub.stop()
+	delete(h.subs, sub)
+	close(sub.ch)
+}
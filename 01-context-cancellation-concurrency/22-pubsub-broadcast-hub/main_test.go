@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receive[T any](t *testing.T, ch <-chan T) (T, bool) {
+	t.Helper()
+	select {
+	case msg, ok := <-ch:
+		return msg, ok
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		var zero T
+		return zero, false
+	}
+}
+
+func TestHub_Broadcast(t *testing.T) {
+	h := NewHub[string]()
+	defer h.Close()
+
+	var subs []<-chan string
+	for range 3 {
+		ch, err := h.Subscribe(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, ch)
+	}
+
+	if err := h.Publish(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range subs {
+		if msg, _ := receive(t, ch); msg != "hello" {
+			t.Errorf("subscriber %d: expected hello, got %q", i, msg)
+		}
+	}
+}
+
+func TestHub_SlowSubscriberPolicies(t *testing.T) {
+	t.Run("Drop", func(t *testing.T) {
+		h := NewHub[int](WithBufferSize(1))
+		defer h.Close()
+		slow, _ := h.Subscribe(context.Background())
+
+		for i := range 3 {
+			if err := h.Publish(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if msg, _ := receive(t, slow); msg != 0 {
+			t.Errorf("expected the first message, got %d", msg)
+		}
+		if h.Dropped() != 2 {
+			t.Errorf("expected 2 dropped messages, got %d", h.Dropped())
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		h := NewHub[int](WithBufferSize(1), WithSlowSubscriberPolicy(Block))
+		defer h.Close()
+		slow, _ := h.Subscribe(context.Background())
+		h.Publish(context.Background(), 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := h.Publish(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the publisher to block until its deadline, got %v", err)
+		}
+
+		published := make(chan error, 1)
+		go func() { published <- h.Publish(context.Background(), 3) }()
+		receive(t, slow)
+		if err := <-published; err != nil {
+			t.Errorf("expected the publisher to proceed once there was room, got %v", err)
+		}
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		h := NewHub[int](WithBufferSize(1), WithSlowSubscriberPolicy(Disconnect))
+		defer h.Close()
+		slow, _ := h.Subscribe(context.Background())
+		fast, _ := h.Subscribe(context.Background())
+
+		h.Publish(context.Background(), 1)
+		receive(t, fast)
+		h.Publish(context.Background(), 2)
+
+		if msg, ok := receive(t, slow); !ok || msg != 1 {
+			t.Errorf("expected the buffered message before the close, got %d, %v", msg, ok)
+		}
+		if _, ok := receive(t, slow); ok {
+			t.Error("expected the slow subscriber to be disconnected")
+		}
+		if msg, _ := receive(t, fast); msg != 2 {
+			t.Errorf("expected the fast subscriber to keep receiving, got %d", msg)
+		}
+		if h.Len() != 1 {
+			t.Errorf("expected 1 subscriber left, got %d", h.Len())
+		}
+	})
+}
+
+func TestHub_UnsubscribeOnCancel(t *testing.T) {
+	h := NewHub[int]()
+	defer h.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, _ := h.Subscribe(ctx)
+
+	cancel()
+	if _, ok := receive(t, ch); ok {
+		t.Error("expected the channel to be closed after cancel")
+	}
+	if h.Len() != 0 {
+		t.Errorf("expected no subscribers, got %d", h.Len())
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	h := NewHub[int](WithBufferSize(1), WithSlowSubscriberPolicy(Block))
+	ch, _ := h.Subscribe(context.Background())
+	h.Publish(context.Background(), 1)
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- h.Publish(context.Background(), 2) }()
+	time.Sleep(10 * time.Millisecond)
+	h.Close()
+
+	if err := <-blocked; !errors.Is(err, ErrHubClosed) {
+		t.Errorf("expected the blocked publisher to fail with ErrHubClosed, got %v", err)
+	}
+	if msg, ok := receive(t, ch); !ok || msg != 1 {
+		t.Errorf("expected buffered messages to survive Close, got %d, %v", msg, ok)
+	}
+	if _, ok := receive(t, ch); ok {
+		t.Error("expected the channel to be closed")
+	}
+	if err := h.Publish(context.Background(), 3); !errors.Is(err, ErrHubClosed) {
+		t.Errorf("expected Publish after Close to fail, got %v", err)
+	}
+	if _, err := h.Subscribe(context.Background()); !errors.Is(err, ErrHubClosed) {
+		t.Errorf("expected Subscribe after Close to fail, got %v", err)
+	}
+	h.Close()
+}
+
+func TestHub_ConcurrencyNoLeaks(t *testing.T) {
+	before := runtime.NumGoroutine()
+	h := NewHub[int](WithBufferSize(4), WithSlowSubscriberPolicy(Disconnect))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for i := range 100 {
+				h.Publish(context.Background(), i)
+			}
+		})
+		wg.Go(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			ch, err := h.Subscribe(ctx)
+			if err != nil {
+				cancel()
+				return
+			}
+			timeout := time.After(10 * time.Millisecond)
+		read:
+			for range 10 {
+				select {
+				case _, ok := <-ch:
+					if !ok {
+						break read
+					}
+				case <-timeout:
+					break read
+				}
+			}
+			cancel()
+			for range ch {
+			}
+		})
+	}
+	wg.Wait()
+	h.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected no leaked goroutines, %d before and %d after", before, n)
+	}
+}
@@ -0,0 +1,3 @@
+module channel-pipeline
+
+go 1.25.0
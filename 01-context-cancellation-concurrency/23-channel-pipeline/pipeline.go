@@ -0,0 +1,175 @@
+// Package pipeline builds channel pipelines out of generic stages. Every
+// stage owns and closes its output channel, and stops, closing it, as soon
+// as its input is exhausted or its context is done, so a cancelled pipeline
+// leaves no goroutine behind. Errors are values: a stage that can fail
+// emits a result type carrying the error.
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// Stage turns a stream of I into a stream of O.
+type Stage[I, O any] func(ctx context.Context, in <-chan I) <-chan O
+
+// Map is the Stage applying fn to every item, one at a time.
+func Map[I, O any](fn func(ctx context.Context, item I) O) Stage[I, O] {
+	return func(ctx context.Context, in <-chan I) <-chan O {
+		out := make(chan O)
+		go func() {
+			defer close(out)
+			for item := range OrDone(ctx, in) {
+				if !Send(ctx, out, fn(ctx, item)) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Filter is the Stage passing on only the items keep accepts.
+func Filter[T any](keep func(item T) bool) Stage[T, T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		out := make(chan T)
+		go func() {
+			defer close(out)
+			for item := range OrDone(ctx, in) {
+				if keep(item) && !Send(ctx, out, item) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Then chains two stages.
+func Then[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in <-chan A) <-chan C {
+		return second(ctx, first(ctx, in))
+	}
+}
+
+// FanOut runs n copies of stage on the same input, each item going to
+// whichever copy is free first, and returns their outputs.
+func FanOut[I, O any](ctx context.Context, in <-chan I, n int, stage Stage[I, O]) []<-chan O {
+	outs := make([]<-chan O, max(n, 1))
+	for i := range outs {
+		outs[i] = stage(ctx, in)
+	}
+	return outs
+}
+
+// FanIn merges ins into one channel, in no particular order, closing it
+// once every input is closed.
+func FanIn[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Go(func() {
+			for item := range OrDone(ctx, in) {
+				if !Send(ctx, out, item) {
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Parallel is Map with n workers. Outputs come in the order they are ready.
+func Parallel[I, O any](n int, fn func(ctx context.Context, item I) O) Stage[I, O] {
+	return func(ctx context.Context, in <-chan I) <-chan O {
+		return FanIn(ctx, FanOut(ctx, in, n, Map(fn))...)
+	}
+}
+
+// ParallelOrdered is Parallel keeping outputs in input order. At most n
+// items are in flight, so a slow item holds back the ones after it rather
+// than letting them pile up in memory. fn must return promptly once ctx is
+// done, as every stage's function must.
+func ParallelOrdered[I, O any](n int, fn func(ctx context.Context, item I) O) Stage[I, O] {
+	return func(ctx context.Context, in <-chan I) <-chan O {
+		out := make(chan O)
+		// pending holds one result channel per item in flight, in input order.
+		pending := make(chan chan O, max(n, 1)-1)
+		go func() {
+			defer close(pending)
+			for item := range OrDone(ctx, in) {
+				result := make(chan O, 1)
+				if !Send(ctx, pending, result) {
+					return
+				}
+				go func() {
+					result <- fn(ctx, item)
+				}()
+			}
+		}()
+		go func() {
+			defer close(out)
+			for result := range pending {
+				if !Send(ctx, out, <-result) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Source emits items, then closes.
+func Source[T any](ctx context.Context, items ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, item := range items {
+			if !Send(ctx, out, item) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Collect receives everything from in until it closes or ctx is done.
+func Collect[T any](ctx context.Context, in <-chan T) []T {
+	var items []T
+	for item := range OrDone(ctx, in) {
+		items = append(items, item)
+	}
+	return items
+}
+
+// Send sends item on out unless ctx is done first, and reports whether it
+// did.
+func Send[T any](ctx context.Context, out chan<- T, item T) bool {
+	select {
+	case out <- item:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// OrDone ranges over in until it closes or ctx is done, whichever is first.
+func OrDone[T any](ctx context.Context, in <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok || !yield(item) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
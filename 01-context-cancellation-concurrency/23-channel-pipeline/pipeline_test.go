@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func checkNoLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if n := runtime.NumGoroutine(); n > before {
+			t.Errorf("expected no leaked goroutines, %d before and %d after", before, n)
+		}
+	})
+}
+
+func TestMapFilterThen(t *testing.T) {
+	checkNoLeaks(t)
+	ctx := context.Background()
+	double := Map(func(ctx context.Context, n int) int { return 2 * n })
+	format := Map(func(ctx context.Context, n int) string { return strconv.Itoa(n) })
+	stage := Then(Then(double, Filter(func(n int) bool { return n%3 != 0 })), format)
+
+	got := Collect(ctx, stage(ctx, Source(ctx, 1, 2, 3, 4, 5)))
+	want := []string{"2", "4", "8", "10"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFanOutFanIn(t *testing.T) {
+	checkNoLeaks(t)
+	ctx := context.Background()
+	var workers atomic.Int32
+	stage := func(ctx context.Context, in <-chan int) <-chan int {
+		workers.Add(1)
+		return Map(func(ctx context.Context, n int) int { return n * n })(ctx, in)
+	}
+
+	outs := FanOut(ctx, Source(ctx, 1, 2, 3, 4), 3, stage)
+	got := Collect(ctx, FanIn(ctx, outs...))
+	slices.Sort(got)
+	if want := []int{1, 4, 9, 16}; !slices.Equal(got, want) {
+		t.Errorf("expected %v in any order, got %v", want, got)
+	}
+	if workers.Load() != 3 {
+		t.Errorf("expected 3 workers, got %d", workers.Load())
+	}
+}
+
+func TestParallel(t *testing.T) {
+	checkNoLeaks(t)
+	ctx := context.Background()
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	jitter := func(ctx context.Context, n int) int {
+		time.Sleep(time.Duration(rand.IntN(100)) * time.Microsecond)
+		return n
+	}
+
+	t.Run("Unordered", func(t *testing.T) {
+		got := Collect(ctx, Parallel(8, jitter)(ctx, Source(ctx, items...)))
+		slices.Sort(got)
+		if !slices.Equal(got, items) {
+			t.Errorf("expected every item once, got %v", got)
+		}
+	})
+
+	t.Run("Ordered", func(t *testing.T) {
+		var inFlight, maxInFlight atomic.Int32
+		fn := func(ctx context.Context, n int) int {
+			current := inFlight.Add(1)
+			for {
+				old := maxInFlight.Load()
+				if current <= old || maxInFlight.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			defer inFlight.Add(-1)
+			return jitter(ctx, n)
+		}
+
+		got := Collect(ctx, ParallelOrdered(4, fn)(ctx, Source(ctx, items...)))
+		if !slices.Equal(got, items) {
+			t.Errorf("expected items in input order, got %v", got)
+		}
+		if maxInFlight.Load() > 4 {
+			t.Errorf("expected at most 4 items in flight, got %d", maxInFlight.Load())
+		}
+	})
+}
+
+func TestCancellationLeavesNoGoroutines(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		stage Stage[int, int]
+	}{
+		{"Map", Map(func(ctx context.Context, n int) int { return n })},
+		{"Parallel", Parallel(4, func(ctx context.Context, n int) int { return n })},
+		{"ParallelOrdered", ParallelOrdered(4, func(ctx context.Context, n int) int { return n })},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			checkNoLeaks(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			infinite := make(chan int)
+			go func() {
+				defer close(infinite)
+				for i := 0; Send(ctx, infinite, i); i++ {
+				}
+			}()
+
+			out := tt.stage(ctx, infinite)
+			for range 10 {
+				<-out
+			}
+			// Stop reading and cancel: every goroutine must notice.
+			cancel()
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module weighted-semaphore
+
+go 1.25.0
@@ -0,0 +1,164 @@
+// Package semaphore provides a weighted semaphore that serves waiters first
+// come, first served and keeps statistics on how long they queued. It caps
+// the cache's concurrent loads and the aggregator's concurrent sources.
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrWeightTooLarge = errors.New("weight exceeds semaphore size")
+
+// Weighted is a semaphore with a total capacity that each caller takes a
+// chosen share of, such as bytes of memory or connections to a shard.
+// Waiters are served strictly first come, first served: a caller asking for
+// much is never overtaken forever by callers asking for little.
+type Weighted struct {
+	size int64
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of *waiter, oldest first
+	stats   Stats
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{} // closed once the waiter holds its weight
+}
+
+// Stats describes how callers have fared, to tell a well-sized semaphore
+// from one every caller queues on.
+type Stats struct {
+	Acquired int64         // successful acquisitions
+	Waited   int64         // acquisitions that had to queue
+	Canceled int64         // waits abandoned because the context ended
+	Waiting  int           // callers queued right now
+	WaitTime time.Duration // total time spent queued, abandoned waits included
+	MaxWait  time.Duration // longest single wait
+}
+
+func NewWeighted(size int64) *Weighted {
+	return &Weighted{size: size}
+}
+
+// Acquire takes n, waiting behind earlier callers until it is available or
+// ctx is done. On failure it holds nothing.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("acquire %d of %d: %w", n, s.size, ErrWeightTooLarge)
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.stats.Acquired++
+		s.mu.Unlock()
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.stats.Waiting++
+	s.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-w.ready:
+		s.recordWait(time.Since(start), false)
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// Granted while we were cancelled: keep it rather than lose it.
+			s.mu.Unlock()
+			s.recordWait(time.Since(start), false)
+			return nil
+		default:
+		}
+		isFront := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		s.stats.Waiting--
+		// The waiters behind us may fit now that we no longer block them.
+		if isFront && s.size > s.cur {
+			s.notifyWaiters()
+		}
+		s.mu.Unlock()
+		s.recordWait(time.Since(start), true)
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes n if it is available right now and nobody is queued
+// for it, without waiting.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur < n || s.waiters.Len() > 0 {
+		return false
+	}
+	s.cur += n
+	s.stats.Acquired++
+	return true
+}
+
+// Release gives back n. Releasing more than is held is a bug and panics.
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// Stats returns a snapshot of the semaphore's statistics.
+func (s *Weighted) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// notifyWaiters grants queued waiters in order until the first one that does
+// not fit: letting a smaller one behind it go first would starve it.
+// s.mu must be held.
+func (s *Weighted) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*waiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		s.stats.Waiting--
+		s.stats.Acquired++
+		close(w.ready)
+	}
+}
+
+func (s *Weighted) recordWait(d time.Duration, canceled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.WaitTime += d
+	s.stats.MaxWait = max(s.stats.MaxWait, d)
+	if canceled {
+		s.stats.Canceled++
+	} else {
+		s.stats.Waited++
+	}
+}
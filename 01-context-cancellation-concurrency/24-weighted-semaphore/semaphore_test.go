@@ -0,0 +1,152 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n callers are queued on s.
+func waitQueued(t *testing.T, s *Weighted, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.Stats().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiters, got %d", n, s.Stats().Waiting)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWeighted_AcquireRelease(t *testing.T) {
+	s := NewWeighted(10)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if s.TryAcquire(4) {
+		t.Error("expected TryAcquire beyond capacity to fail")
+	}
+	if !s.TryAcquire(3) {
+		t.Error("expected TryAcquire of the remaining capacity to succeed")
+	}
+	s.Release(10)
+
+	if err := s.Acquire(ctx, 11); !errors.Is(err, ErrWeightTooLarge) {
+		t.Errorf("expected ErrWeightTooLarge, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected releasing more than held to panic")
+		}
+	}()
+	s.Release(1)
+}
+
+func TestWeighted_FIFONoStarvation(t *testing.T) {
+	s := NewWeighted(4)
+	ctx := context.Background()
+	s.Acquire(ctx, 1)
+
+	var order []string
+	var mu sync.Mutex
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		s.Acquire(ctx, 4)
+		record("large")
+		s.Release(4)
+	})
+	waitQueued(t, s, 1)
+
+	// Capacity for small callers is free, but they must queue behind large.
+	if s.TryAcquire(1) {
+		t.Error("expected TryAcquire to respect the queue")
+	}
+	for range 3 {
+		wg.Go(func() {
+			s.Acquire(ctx, 1)
+			record("small")
+			s.Release(1)
+		})
+	}
+	waitQueued(t, s, 4)
+
+	s.Release(1)
+	wg.Wait()
+	if len(order) != 4 || order[0] != "large" {
+		t.Errorf("expected the large waiter to go first, got %v", order)
+	}
+}
+
+func TestWeighted_CancelUnblocksWaitersBehind(t *testing.T) {
+	s := NewWeighted(2)
+	s.Acquire(context.Background(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- s.Acquire(ctx, 2) }()
+	waitQueued(t, s, 1)
+
+	acquired := make(chan struct{})
+	go func() {
+		s.Acquire(context.Background(), 1)
+		close(acquired)
+	}()
+	waitQueued(t, s, 2)
+
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiter behind the cancelled one to acquire")
+	}
+
+	stats := s.Stats()
+	if stats.Canceled != 1 || stats.Waited != 1 || stats.Waiting != 0 || stats.Acquired != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWeighted_ConcurrencyCap(t *testing.T) {
+	s := NewWeighted(3)
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if err := s.Acquire(context.Background(), 1); err != nil {
+				t.Error(err)
+				return
+			}
+			defer s.Release(1)
+			current := active.Add(1)
+			for {
+				old := maxActive.Load()
+				if current <= old || maxActive.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			active.Add(-1)
+		})
+	}
+	wg.Wait()
+	if maxActive.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent holders, got %d", maxActive.Load())
+	}
+	if stats := s.Stats(); stats.Acquired != 50 || stats.MaxWait <= 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
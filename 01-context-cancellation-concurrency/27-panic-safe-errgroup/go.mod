@@ -0,0 +1,5 @@
+module panic-safe-errgroup
+
+go 1.25.0
+
+require golang.org/x/sync v0.19.0
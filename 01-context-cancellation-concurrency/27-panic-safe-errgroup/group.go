@@ -0,0 +1,130 @@
+// Package group runs goroutines the way every package here should: with a
+// bound on how many run at once, with the first error cancelling the rest,
+// and with a panic in one of them returned as an error instead of crashing
+// the process. It wraps golang.org/x/sync/errgroup.
+package group
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultLimit bounds the goroutines of a Group running at once unless
+// WithLimit says otherwise. An unbounded fan-out over user-supplied input is
+// a denial of service waiting to happen.
+const DefaultLimit = 32
+
+// PanicError is the error a Group returns for a function that panicked.
+type PanicError struct {
+	Value any    // what was passed to panic
+	Stack []byte // the panicking goroutine's stack
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the value passed to panic if it is an error, so errors.Is
+// sees through a panic(err).
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type config struct {
+	limit int
+}
+
+type Option func(*config)
+
+// WithLimit bounds the goroutines running at once to n. A negative n means
+// no bound.
+func WithLimit(n int) Option {
+	return func(c *config) {
+		c.limit = n
+	}
+}
+
+// Group is a set of goroutines working on subtasks of one task. Its zero
+// value is not usable; create one with New.
+type Group struct {
+	eg  *errgroup.Group
+	ctx context.Context
+}
+
+// New returns a Group and the context its functions receive, derived from
+// ctx and cancelled when a function fails or Wait returns.
+func New(ctx context.Context, opts ...Option) (*Group, context.Context) {
+	cfg := config{limit: DefaultLimit}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(cfg.limit)
+	return &Group{eg: eg, ctx: ctx}, ctx
+}
+
+// Go runs fn in a new goroutine, blocking while the limit is reached. A
+// panic in fn is recovered and becomes the group's error, as a *PanicError.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.eg.Go(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		return fn(g.ctx)
+	})
+}
+
+// Wait waits for every function and returns the first error, if any.
+func (g *Group) Wait() error {
+	return g.eg.Wait()
+}
+
+// Collector gathers the values of functions run on a Group, in the order
+// they were started rather than the order they finished.
+type Collector[T any] struct {
+	g *Group
+
+	mu     sync.Mutex
+	values []T
+}
+
+func NewCollector[T any](g *Group) *Collector[T] {
+	return &Collector[T]{g: g}
+}
+
+// GoValue runs fn on the group and keeps its value in the slot of this
+// call.
+func (c *Collector[T]) GoValue(fn func(ctx context.Context) (T, error)) {
+	c.mu.Lock()
+	i := len(c.values)
+	var zero T
+	c.values = append(c.values, zero)
+	c.mu.Unlock()
+
+	c.g.Go(func(ctx context.Context) error {
+		value, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.values[i] = value
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+// Wait waits for the group and returns the values in the order their
+// functions were started, or nil and the group's error.
+func (c *Collector[T]) Wait() ([]T, error) {
+	if err := c.g.Wait(); err != nil {
+		return nil, err
+	}
+	return c.values, nil
+}
@@ -0,0 +1,123 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_FirstErrorCancels(t *testing.T) {
+	errBoom := errors.New("boom")
+	g, _ := New(context.Background())
+
+	g.Go(func(ctx context.Context) error {
+		return errBoom
+	})
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("expected the failure to cancel the context")
+		}
+	})
+
+	if err := g.Wait(); !errors.Is(err, errBoom) {
+		t.Errorf("expected the first error, got %v", err)
+	}
+}
+
+func TestGroup_PanicBecomesError(t *testing.T) {
+	errCause := errors.New("nil map")
+	g, _ := New(context.Background())
+	g.Go(func(ctx context.Context) error {
+		panic(errCause)
+	})
+
+	err := g.Wait()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	if !errors.Is(err, errCause) {
+		t.Error("expected the panic value to be unwrapped")
+	}
+	if !strings.Contains(string(panicErr.Stack), "TestGroup_PanicBecomesError") {
+		t.Errorf("expected the stack of the panicking goroutine, got %s", panicErr.Stack)
+	}
+}
+
+func TestGroup_Limit(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want int32
+	}{
+		{"Default", nil, DefaultLimit},
+		{"Custom", []Option{WithLimit(2)}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g, _ := New(context.Background(), tt.opts...)
+			var active, maxActive atomic.Int32
+			for range 100 {
+				g.Go(func(ctx context.Context) error {
+					current := active.Add(1)
+					for {
+						old := maxActive.Load()
+						if current <= old || maxActive.CompareAndSwap(old, current) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					active.Add(-1)
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				t.Fatal(err)
+			}
+			if maxActive.Load() > tt.want {
+				t.Errorf("expected at most %d at once, got %d", tt.want, maxActive.Load())
+			}
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	t.Run("Ordered", func(t *testing.T) {
+		g, _ := New(context.Background())
+		c := NewCollector[int](g)
+		for i := range 10 {
+			c.GoValue(func(ctx context.Context) (int, error) {
+				// Later calls finish first.
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				return i * i, nil
+			})
+		}
+
+		values, err := c.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}; !slices.Equal(values, want) {
+			t.Errorf("expected %v, got %v", want, values)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		g, _ := New(context.Background())
+		c := NewCollector[string](g)
+		c.GoValue(func(ctx context.Context) (string, error) { return "ok", nil })
+		c.GoValue(func(ctx context.Context) (string, error) { panic("bad input") })
+
+		values, err := c.Wait()
+		var panicErr *PanicError
+		if values != nil || !errors.As(err, &panicErr) || panicErr.Value != "bad input" {
+			t.Errorf("expected no values and the panic, got %v, %v", values, err)
+		}
+	})
+}
@@ -0,0 +1,3 @@
+module debounce-throttle
+
+go 1.25.0
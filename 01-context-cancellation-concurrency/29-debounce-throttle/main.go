@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type edges struct {
+	leading  bool
+	trailing bool
+}
+
+type Option func(*edges)
+
+// WithLeading runs fn on the first call of a burst, right away.
+func WithLeading(leading bool) Option {
+	return func(e *edges) {
+		e.leading = leading
+	}
+}
+
+// WithTrailing runs fn with the last value of a burst once it is over.
+func WithTrailing(trailing bool) Option {
+	return func(e *edges) {
+		e.trailing = trailing
+	}
+}
+
+// limiter holds what Debouncer and Throttler share: the function, the value
+// waiting for the trailing edge and the timer that will deliver it.
+type limiter[T any] struct {
+	fn func(T)
+	edges
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	gen     uint64 // bumped whenever timer is replaced, to ignore stale fires
+	pending T
+	has     bool
+	closed  bool
+	stop    func() bool // stops watching the context
+
+	runMu sync.Mutex // fn never runs concurrently with itself
+}
+
+func newLimiter[T any](ctx context.Context, fn func(T), defaults edges, opts []Option) *limiter[T] {
+	l := &limiter[T]{fn: fn, edges: defaults}
+	for _, opt := range opts {
+		opt(&l.edges)
+	}
+	l.stop = context.AfterFunc(ctx, l.cancel)
+	return l
+}
+
+// schedule (re)starts the timer calling fire after d. l.mu must be held.
+func (l *limiter[T]) schedule(d time.Duration, fire func()) {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.gen++
+	gen := l.gen
+	l.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		if gen != l.gen || l.closed {
+			l.mu.Unlock()
+			return
+		}
+		fire()
+	})
+}
+
+// take removes the pending value. l.mu must be held.
+func (l *limiter[T]) take() (T, bool) {
+	var zero T
+	v, ok := l.pending, l.has
+	l.pending, l.has = zero, false
+	return v, ok
+}
+
+// stopTimer ends the current burst. l.mu must be held.
+func (l *limiter[T]) stopTimer() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.gen++
+}
+
+func (l *limiter[T]) run(v T) {
+	l.runMu.Lock()
+	defer l.runMu.Unlock()
+	l.fn(v)
+}
+
+// Flush runs fn now with the pending value, if any, instead of waiting for
+// the trailing edge, and ends the current burst.
+func (l *limiter[T]) Flush() {
+	l.mu.Lock()
+	l.stopTimer()
+	v, ok := l.take()
+	l.mu.Unlock()
+	if ok {
+		l.run(v)
+	}
+}
+
+// Close flushes the pending value and ignores every later call. It returns
+// once fn has run.
+func (l *limiter[T]) Close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	l.stop()
+	l.stopTimer()
+	v, ok := l.take()
+	l.mu.Unlock()
+	if ok {
+		l.run(v)
+	}
+}
+
+// cancel is Close without the flush: the context that owned the work is
+// gone, so the pending value is dropped.
+func (l *limiter[T]) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	l.stopTimer()
+	l.take()
+}
+
+// Debouncer runs fn once calls stop coming for a while, such as reloading
+// configuration after a burst of file change events.
+type Debouncer[T any] struct {
+	*limiter[T]
+	wait time.Duration
+}
+
+// Debounce runs fn with the last value once Call has not been called for
+// wait. WithLeading also runs it on the first call of a burst. Cancelling
+// ctx stops it, dropping any pending value; Close flushes instead.
+func Debounce[T any](ctx context.Context, fn func(T), wait time.Duration, opts ...Option) *Debouncer[T] {
+	return &Debouncer[T]{
+		limiter: newLimiter(ctx, fn, edges{trailing: true}, opts),
+		wait:    wait,
+	}
+}
+
+// Call records v and restarts the quiet period. On a leading edge fn runs
+// on the caller's goroutine before Call returns; otherwise on a timer's.
+func (d *Debouncer[T]) Call(v T) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	leading := d.timer == nil && d.leading
+	if !leading && d.trailing {
+		d.pending, d.has = v, true
+	}
+	d.schedule(d.wait, d.fire)
+	d.mu.Unlock()
+
+	if leading {
+		d.run(v)
+	}
+}
+
+// fire ends a burst. It is called with d.mu held and releases it.
+func (d *Debouncer[T]) fire() {
+	d.timer = nil
+	v, ok := d.take()
+	d.mu.Unlock()
+	if ok {
+		d.run(v)
+	}
+}
+
+// Throttler runs fn at most once per interval however often it is called,
+// such as refreshing a cache entry that is read in a hot loop.
+type Throttler[T any] struct {
+	*limiter[T]
+	interval time.Duration
+}
+
+// Throttle runs fn at most once per interval: on the first call, and with
+// the last value of the calls made during the interval once it ends. Either
+// edge can be turned off. Cancelling ctx stops it, dropping any pending
+// value; Close flushes instead.
+func Throttle[T any](ctx context.Context, fn func(T), interval time.Duration, opts ...Option) *Throttler[T] {
+	return &Throttler[T]{
+		limiter:  newLimiter(ctx, fn, edges{leading: true, trailing: true}, opts),
+		interval: interval,
+	}
+}
+
+// Call runs fn with v now if no interval is under way and the leading edge
+// is on, on the caller's goroutine; otherwise it keeps v for the end of the
+// interval.
+func (t *Throttler[T]) Call(v T) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	idle := t.timer == nil
+	if idle {
+		t.schedule(t.interval, t.fire)
+	}
+	leading := idle && t.leading
+	if !leading && t.trailing {
+		t.pending, t.has = v, true
+	}
+	t.mu.Unlock()
+
+	if leading {
+		t.run(v)
+	}
+}
+
+// fire ends an interval, starting another if it runs fn, so the next call
+// cannot run it again right away. Called with t.mu held; releases it.
+func (t *Throttler[T]) fire() {
+	v, ok := t.take()
+	if ok {
+		t.schedule(t.interval, t.fire)
+	} else {
+		t.timer = nil
+	}
+	t.mu.Unlock()
+	if ok {
+		t.run(v)
+	}
+}
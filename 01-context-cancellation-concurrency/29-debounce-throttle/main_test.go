@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder collects the values fn was called with.
+type recorder struct {
+	mu    sync.Mutex
+	calls []int
+}
+
+func (r *recorder) fn(v int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, v)
+}
+
+func (r *recorder) got() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// waitCalls waits until fn has been called n times.
+func (r *recorder) waitCalls(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(r.got()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls, got %v", n, r.got())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	const wait = 30 * time.Millisecond
+
+	t.Run("trailing edge runs once with the last value", func(t *testing.T) {
+		var r recorder
+		d := Debounce(context.Background(), r.fn, wait)
+		defer d.Close()
+		for i := range 5 {
+			d.Call(i)
+			time.Sleep(wait / 5)
+		}
+		if got := r.got(); len(got) != 0 {
+			t.Fatalf("expected no call during the burst, got %v", got)
+		}
+		r.waitCalls(t, 1)
+		time.Sleep(2 * wait)
+		if got := r.got(); !slices.Equal(got, []int{4}) {
+			t.Errorf("expected [4], got %v", got)
+		}
+	})
+
+	t.Run("leading edge runs on the first call only", func(t *testing.T) {
+		var r recorder
+		d := Debounce(context.Background(), r.fn, wait, WithLeading(true), WithTrailing(false))
+		defer d.Close()
+		d.Call(1)
+		d.Call(2)
+		d.Call(3)
+		if got := r.got(); !slices.Equal(got, []int{1}) {
+			t.Fatalf("expected [1] right away, got %v", got)
+		}
+		time.Sleep(2 * wait)
+		d.Call(4)
+		if got := r.got(); !slices.Equal(got, []int{1, 4}) {
+			t.Errorf("expected a new burst to lead again, got %v", got)
+		}
+	})
+
+	t.Run("both edges", func(t *testing.T) {
+		var r recorder
+		d := Debounce(context.Background(), r.fn, wait, WithLeading(true))
+		defer d.Close()
+		d.Call(1)
+		d.Call(2)
+		d.Call(3)
+		r.waitCalls(t, 2)
+		if got := r.got(); !slices.Equal(got, []int{1, 3}) {
+			t.Errorf("expected [1 3], got %v", got)
+		}
+
+		// A single call is only the leading edge.
+		time.Sleep(2 * wait)
+		d.Call(4)
+		time.Sleep(2 * wait)
+		if got := r.got(); !slices.Equal(got, []int{1, 3, 4}) {
+			t.Errorf("expected [1 3 4], got %v", got)
+		}
+	})
+
+	t.Run("flush and close run the pending value", func(t *testing.T) {
+		var r recorder
+		d := Debounce(context.Background(), r.fn, time.Hour)
+		d.Call(1)
+		d.Flush()
+		d.Flush()
+		d.Call(2)
+		d.Close()
+		d.Call(3)
+		d.Close()
+		if got := r.got(); !slices.Equal(got, []int{1, 2}) {
+			t.Errorf("expected [1 2], got %v", got)
+		}
+	})
+
+	t.Run("cancellation drops the pending value", func(t *testing.T) {
+		var r recorder
+		ctx, cancel := context.WithCancel(context.Background())
+		d := Debounce(ctx, r.fn, wait)
+		d.Call(1)
+		cancel()
+		time.Sleep(2 * wait)
+		d.Call(2)
+		d.Close()
+		if got := r.got(); len(got) != 0 {
+			t.Errorf("expected no calls, got %v", got)
+		}
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	t.Run("leading and trailing edges", func(t *testing.T) {
+		var r recorder
+		th := Throttle(context.Background(), r.fn, interval)
+		defer th.Close()
+		th.Call(1)
+		th.Call(2)
+		th.Call(3)
+		if got := r.got(); !slices.Equal(got, []int{1}) {
+			t.Fatalf("expected [1] right away, got %v", got)
+		}
+		r.waitCalls(t, 2)
+		if got := r.got(); !slices.Equal(got, []int{1, 3}) {
+			t.Errorf("expected [1 3], got %v", got)
+		}
+
+		// The trailing call opened a new interval: this one waits for it.
+		th.Call(4)
+		if got := r.got(); len(got) != 2 {
+			t.Errorf("expected the call to wait for the interval, got %v", got)
+		}
+		r.waitCalls(t, 3)
+	})
+
+	t.Run("at most once per interval", func(t *testing.T) {
+		var r recorder
+		th := Throttle(context.Background(), r.fn, interval)
+		defer th.Close()
+		start := time.Now()
+		for i := 0; time.Since(start) < 4*interval; i++ {
+			th.Call(i)
+			time.Sleep(time.Millisecond)
+		}
+		// 4 intervals: the leading call plus one per interval end, at most.
+		if got := r.got(); len(got) < 3 || len(got) > 5 {
+			t.Errorf("expected about 4 calls, got %d", len(got))
+		}
+	})
+
+	t.Run("trailing only", func(t *testing.T) {
+		var r recorder
+		th := Throttle(context.Background(), r.fn, interval, WithLeading(false))
+		defer th.Close()
+		th.Call(1)
+		th.Call(2)
+		if got := r.got(); len(got) != 0 {
+			t.Fatalf("expected no call before the interval ends, got %v", got)
+		}
+		r.waitCalls(t, 1)
+		if got := r.got(); !slices.Equal(got, []int{2}) {
+			t.Errorf("expected [2], got %v", got)
+		}
+	})
+
+	t.Run("close flushes, cancellation drops", func(t *testing.T) {
+		var r recorder
+		th := Throttle(context.Background(), r.fn, time.Hour)
+		th.Call(1)
+		th.Call(2)
+		th.Close()
+		if got := r.got(); !slices.Equal(got, []int{1, 2}) {
+			t.Errorf("expected [1 2], got %v", got)
+		}
+
+		var r2 recorder
+		ctx, cancel := context.WithCancel(context.Background())
+		th = Throttle(ctx, r2.fn, time.Hour)
+		th.Call(1)
+		th.Call(2)
+		cancel()
+		time.Sleep(10 * time.Millisecond) // context.AfterFunc runs in its own goroutine
+		th.Close()
+		if got := r2.got(); !slices.Equal(got, []int{1}) {
+			t.Errorf("expected [1], got %v", got)
+		}
+	})
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	// guard counts calls and whether fn ever ran concurrently with itself.
+	type guard struct {
+		running, calls, overlaps atomic.Int32
+	}
+	fnFor := func(g *guard) func(int) {
+		return func(int) {
+			if g.running.Add(1) > 1 {
+				g.overlaps.Add(1)
+			}
+			time.Sleep(100 * time.Microsecond)
+			g.calls.Add(1)
+			g.running.Add(-1)
+		}
+	}
+	var dg, tg guard
+	d := Debounce(context.Background(), fnFor(&dg), time.Millisecond, WithLeading(true))
+	th := Throttle(context.Background(), fnFor(&tg), time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 50 {
+				d.Call(i*100 + j)
+				th.Call(i*100 + j)
+			}
+		})
+	}
+	wg.Wait()
+	d.Close()
+	th.Close()
+	for name, g := range map[string]*guard{"debounce": &dg, "throttle": &tg} {
+		if g.calls.Load() == 0 {
+			t.Errorf("%s: expected calls", name)
+		}
+		if n := g.overlaps.Load(); n != 0 {
+			t.Errorf("%s: fn ran concurrently %d times", name, n)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+type config struct {
+	ttl        time.Duration
+	renewEvery time.Duration
+	retryEvery time.Duration
+}
+
+type Option func(*config)
+
+// WithTTL sets how long a lease lasts without renewal: how long the group
+// may go without a leader when one dies. The default is 15 seconds.
+func WithTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.ttl = d
+	}
+}
+
+// WithRenewInterval sets how often the leader renews its lease; it must be
+// well below the TTL. A leader steps down once it has gone the TTL minus
+// this interval without a successful renewal. The default is a third of
+// the TTL.
+func WithRenewInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.renewEvery = d
+	}
+}
+
+// WithRetryInterval sets how often a follower tries to take the lease. The
+// default is a third of the TTL.
+func WithRetryInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.retryEvery = d
+	}
+}
+
+// Elector campaigns for one lease on behalf of one candidate, and runs the
+// leader's work while, and only while, it holds it.
+type Elector struct {
+	store  LockStore
+	name   string
+	id     string
+	cfg    config
+	leader atomic.Bool
+}
+
+func NewElector(store LockStore, name, id string, opts ...Option) *Elector {
+	cfg := config{ttl: 15 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.renewEvery <= 0 {
+		cfg.renewEvery = cfg.ttl / 3
+	}
+	if cfg.retryEvery <= 0 {
+		cfg.retryEvery = cfg.ttl / 3
+	}
+	return &Elector{store: store, name: name, id: id, cfg: cfg}
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is done. Each time it wins the lease it calls
+// lead with a context that is cancelled when the lease is lost, or about to
+// expire because renewals keep failing, and waits for lead to return before
+// campaigning again. lead returning on its own steps down. Run returns
+// ctx's error, after releasing the lease if it held one.
+//
+// lead must stop acting when its context is done, and should pass
+// lease.Token with every write so a FencedStore can reject it if it did
+// not stop in time.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context, lease Lease)) error {
+	for {
+		lease, validUntil, err := e.campaign(ctx)
+		if err != nil {
+			return err
+		}
+		e.lead(ctx, lease, validUntil, lead)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// campaign tries to take the lease every retry interval until it does.
+func (e *Elector) campaign(ctx context.Context) (Lease, time.Time, error) {
+	retry := time.NewTicker(e.cfg.retryEvery)
+	defer retry.Stop()
+	for {
+		sent := time.Now()
+		lease, err := e.store.Acquire(ctx, e.name, e.id, e.cfg.ttl)
+		if err == nil {
+			return lease, e.validUntil(sent), nil
+		}
+		select {
+		case <-ctx.Done():
+			return Lease{}, time.Time{}, ctx.Err()
+		case <-retry.C:
+		}
+	}
+}
+
+// validUntil is when a leader whose request was sent at sent must stop: the
+// store started counting the TTL no earlier than sent, and the leader keeps
+// one renew interval in hand to wind down before the lease can change hands.
+func (e *Elector) validUntil(sent time.Time) time.Time {
+	return sent.Add(e.cfg.ttl - e.cfg.renewEvery)
+}
+
+func (e *Elector) lead(ctx context.Context, lease Lease, validUntil time.Time, lead func(context.Context, Lease)) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	e.leader.Store(true)
+	go func() {
+		defer close(done)
+		lead(leaderCtx, lease)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		e.leader.Store(false)
+		// Release even when ctx is done, so a successor need not wait for
+		// the lease to expire. It fails harmlessly if the lease was lost.
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), e.cfg.renewEvery)
+		defer cancelRelease()
+		_ = e.store.Release(releaseCtx, lease)
+	}()
+
+	renew := time.NewTicker(e.cfg.renewEvery)
+	defer renew.Stop()
+	expire := time.NewTimer(time.Until(validUntil))
+	defer expire.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-expire.C:
+			return
+		case <-renew.C:
+			sent := time.Now()
+			renewCtx, cancelRenew := context.WithDeadline(ctx, validUntil)
+			next, err := e.store.Renew(renewCtx, lease, e.cfg.ttl)
+			cancelRenew()
+			if errors.Is(err, ErrLeaseLost) {
+				return
+			}
+			if err != nil {
+				continue // try again until expire fires
+			}
+			lease, validUntil = next, e.validUntil(sent)
+			expire.Reset(time.Until(validUntil))
+		}
+	}
+}
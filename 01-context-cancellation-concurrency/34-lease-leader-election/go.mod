@@ -0,0 +1,3 @@
+module lease-leader-election
+
+go 1.25.0
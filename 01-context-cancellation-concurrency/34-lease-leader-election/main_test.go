@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	a, err := s.Acquire(ctx, "job", "a", time.Second)
+	if err != nil || a.Token != 1 {
+		t.Fatalf("expected a to get token 1, got %+v, %v", a, err)
+	}
+	if _, err := s.Acquire(ctx, "job", "b", time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("expected ErrLeaseHeld, got %v", err)
+	}
+	if again, _ := s.Acquire(ctx, "job", "a", time.Second); again != a {
+		t.Errorf("expected the holder to get its lease back, got %+v", again)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if a, err = s.Renew(ctx, a, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(900 * time.Millisecond) // past the first expiry, within the renewed one
+	if _, err := s.Acquire(ctx, "job", "b", time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("expected the renewed lease to be held, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	b, err := s.Acquire(ctx, "job", "b", time.Second)
+	if err != nil || b.Token != 2 {
+		t.Fatalf("expected b to take the expired lease with token 2, got %+v, %v", b, err)
+	}
+	if _, err := s.Renew(ctx, a, time.Second); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected the old holder's renewal to fail with ErrLeaseLost, got %v", err)
+	}
+	if err := s.Release(ctx, a); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected the old holder's release to fail with ErrLeaseLost, got %v", err)
+	}
+	if err := s.Release(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s.Acquire(ctx, "job", "c", time.Second); err != nil || c.Token != 3 {
+		t.Errorf("expected c to get the released lease with token 3, got %+v, %v", c, err)
+	}
+}
+
+func TestFencedStore(t *testing.T) {
+	f := NewFencedStore()
+	if err := f.Put(2, "k", "new leader"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(1, "k", "old leader"); !errors.Is(err, ErrStaleToken) {
+		t.Errorf("expected ErrStaleToken, got %v", err)
+	}
+	if v, _ := f.Get("k"); v != "new leader" {
+		t.Errorf("expected the new leader's value, got %q", v)
+	}
+}
+
+// fastOptions make tests take milliseconds instead of seconds.
+var fastOptions = []Option{
+	WithTTL(60 * time.Millisecond),
+	WithRenewInterval(15 * time.Millisecond),
+	WithRetryInterval(10 * time.Millisecond),
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestElector_OneLeaderAtATime(t *testing.T) {
+	store := NewMemoryStore()
+	var leaders, maxLeaders, terms atomic.Int32
+	lead := func(ctx context.Context, _ Lease) {
+		n := leaders.Add(1)
+		for m := maxLeaders.Load(); n > m && !maxLeaders.CompareAndSwap(m, n); m = maxLeaders.Load() {
+		}
+		terms.Add(1)
+		<-ctx.Done()
+		leaders.Add(-1)
+	}
+
+	electors := make([]*Elector, 3)
+	cancels := make([]context.CancelFunc, 3)
+	errs := make(chan error, 3)
+	for i := range electors {
+		var ctx context.Context
+		ctx, cancels[i] = context.WithCancel(context.Background())
+		electors[i] = NewElector(store, "job", fmt.Sprint("node-", i), fastOptions...)
+		go func() { errs <- electors[i].Run(ctx, lead) }()
+	}
+
+	// Stop the leader three times: each time another must take over.
+	for range 3 {
+		var current int
+		waitFor(t, "a leader", func() bool {
+			for i, e := range electors {
+				if e.IsLeader() {
+					current = i
+					return true
+				}
+			}
+			return false
+		})
+		cancels[current]()
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected Run to return context.Canceled, got %v", err)
+		}
+		if electors[current].IsLeader() {
+			t.Error("expected a stopped elector not to be leader")
+		}
+	}
+
+	if m := maxLeaders.Load(); m != 1 {
+		t.Errorf("expected at most one leader at a time, got %d", m)
+	}
+	if n := terms.Load(); n != 3 {
+		t.Errorf("expected 3 terms, got %d", n)
+	}
+}
+
+// partitionedStore fails renewals while cut is set, as a leader cut off from
+// the store would see.
+type partitionedStore struct {
+	*MemoryStore
+	cut atomic.Bool
+}
+
+var errUnreachable = errors.New("store unreachable")
+
+func (p *partitionedStore) Renew(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error) {
+	if p.cut.Load() {
+		return Lease{}, errUnreachable
+	}
+	return p.MemoryStore.Renew(ctx, lease, ttl)
+}
+
+func TestElector_StepsDownBeforeLeaseExpires(t *testing.T) {
+	store := &partitionedStore{MemoryStore: NewMemoryStore()}
+	fenced := NewFencedStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type term struct {
+		lease     Lease
+		steppedAt time.Time
+	}
+	terms := make(chan term, 1)
+	old := NewElector(store, "job", "old", fastOptions...)
+	go old.Run(ctx, func(ctx context.Context, lease Lease) {
+		fenced.Put(lease.Token, "owner", "old")
+		<-ctx.Done()
+		terms <- term{lease: lease, steppedAt: time.Now()}
+	})
+	waitFor(t, "the first leader", old.IsLeader)
+
+	store.cut.Store(true)
+	got := <-terms
+	store.mu.Lock()
+	expires := store.leases["job"].Expires
+	store.mu.Unlock()
+	if !got.steppedAt.Before(expires) {
+		t.Errorf("expected the leader to stop before its lease expired at %v, stopped at %v", expires, got.steppedAt)
+	}
+
+	successor := NewElector(store, "job", "new", fastOptions...)
+	tokens := make(chan uint64, 1)
+	go successor.Run(ctx, func(ctx context.Context, lease Lease) {
+		fenced.Put(lease.Token, "owner", "new")
+		tokens <- lease.Token
+		<-ctx.Done()
+	})
+	if token := <-tokens; token <= got.lease.Token {
+		t.Errorf("expected the successor's token to be greater than %d, got %d", got.lease.Token, token)
+	}
+	if err := fenced.Put(got.lease.Token, "owner", "old again"); !errors.Is(err, ErrStaleToken) {
+		t.Errorf("expected the old leader's late write to be fenced off, got %v", err)
+	}
+}
+
+func TestElector_ReleasesOnCancel(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	e := NewElector(store, "job", "a", WithTTL(time.Hour))
+
+	var wg sync.WaitGroup
+	var err error
+	wg.Go(func() {
+		err = e.Run(ctx, func(ctx context.Context, _ Lease) { <-ctx.Done() })
+	})
+	waitFor(t, "leadership", e.IsLeader)
+	cancel()
+	wg.Wait()
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// The lease lasts an hour: only a release lets b in right away.
+	if _, err := store.Acquire(context.Background(), "job", "b", time.Hour); err != nil {
+		t.Errorf("expected the lease to be released, got %v", err)
+	}
+}
+
+func TestElector_LeadReturningStepsDown(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var terms atomic.Int32
+	e := NewElector(store, "job", "a", fastOptions...)
+	go e.Run(ctx, func(context.Context, Lease) { terms.Add(1) })
+	waitFor(t, "a second term", func() bool { return terms.Load() >= 2 })
+}
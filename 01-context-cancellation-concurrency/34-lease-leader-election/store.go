@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLeaseHeld  = errors.New("lease held by another candidate")
+	ErrLeaseLost  = errors.New("lease lost")
+	ErrStaleToken = errors.New("stale fencing token")
+)
+
+// Lease is the right to act as name's leader until Expires. Token grows
+// every time the lease changes hands, so whoever receives work from a
+// leader can tell an old leader, still running after losing its lease,
+// from the current one.
+type Lease struct {
+	Name    string
+	Holder  string
+	Token   uint64
+	Expires time.Time
+}
+
+// LockStore is where candidates compete for leases: etcd, a database row,
+// or MemoryStore in tests. Each method must be atomic.
+type LockStore interface {
+	// Acquire takes name for holder if it is free or expired, or returns
+	// ErrLeaseHeld.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error)
+	// Renew extends a lease still held, or returns ErrLeaseLost.
+	Renew(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error)
+	// Release gives up a lease still held, so a successor need not wait
+	// for it to expire.
+	Release(ctx context.Context, lease Lease) error
+}
+
+// MemoryStore is an in-process LockStore, safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	tokens map[string]uint64 // last token handed out per name, never reused
+	now    func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		leases: make(map[string]Lease),
+		tokens: make(map[string]uint64),
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryStore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return Lease{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if cur, ok := s.leases[name]; ok && now.Before(cur.Expires) {
+		if cur.Holder == holder {
+			return cur, nil
+		}
+		return Lease{}, fmt.Errorf("acquire %q: %w (%s)", name, ErrLeaseHeld, cur.Holder)
+	}
+	s.tokens[name]++
+	lease := Lease{Name: name, Holder: holder, Token: s.tokens[name], Expires: now.Add(ttl)}
+	s.leases[name] = lease
+	return lease, nil
+}
+
+func (s *MemoryStore) Renew(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return Lease{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.holds(lease, now) {
+		return Lease{}, fmt.Errorf("renew %q: %w", lease.Name, ErrLeaseLost)
+	}
+	lease.Expires = now.Add(ttl)
+	s.leases[lease.Name] = lease
+	return lease, nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, lease Lease) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.holds(lease, s.now()) {
+		return fmt.Errorf("release %q: %w", lease.Name, ErrLeaseLost)
+	}
+	delete(s.leases, lease.Name)
+	return nil
+}
+
+// holds reports whether lease is still the unexpired current one. s.mu must
+// be held.
+func (s *MemoryStore) holds(lease Lease, now time.Time) bool {
+	cur, ok := s.leases[lease.Name]
+	return ok && cur.Token == lease.Token && now.Before(cur.Expires)
+}
+
+// FencedStore is a resource a leader writes to. It remembers the highest
+// token it has seen and rejects writes carrying a lower one: a paused
+// leader waking up after its lease was taken cannot overwrite its
+// successor's work.
+type FencedStore struct {
+	mu      sync.Mutex
+	highest uint64
+	values  map[string]string
+}
+
+func NewFencedStore() *FencedStore {
+	return &FencedStore{values: make(map[string]string)}
+}
+
+func (f *FencedStore) Put(token uint64, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token < f.highest {
+		return fmt.Errorf("put %q with token %d, seen %d: %w", key, token, f.highest, ErrStaleToken)
+	}
+	f.highest = token
+	f.values[key] = value
+	return nil
+}
+
+func (f *FencedStore) Get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	return v, ok
+}
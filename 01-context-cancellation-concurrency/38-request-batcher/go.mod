@@ -0,0 +1,3 @@
+module request-batcher
+
+go 1.25.0
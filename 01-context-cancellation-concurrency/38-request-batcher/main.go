@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Result is one key's outcome in a batch.
+type Result[V any] struct {
+	Value V
+	Err   error
+}
+
+// BatchFunc loads many keys in one call, such as one SELECT ... WHERE id
+// IN (...). Keys it leaves out of the map fail with ErrNotFound; an error
+// it returns fails every key of the batch.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]Result[V], error)
+
+type config struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+type Option func(*config)
+
+// WithWait sets how long a batch collects keys after its first one. The
+// default is 2ms: long enough to catch the Gets of one request's fan-out,
+// short enough not to matter next to the backend call.
+func WithWait(d time.Duration) Option {
+	return func(c *config) {
+		c.wait = d
+	}
+}
+
+// WithMaxBatch dispatches a batch as soon as it holds n keys. The default
+// is 100.
+func WithMaxBatch(n int) Option {
+	return func(c *config) {
+		c.maxBatch = n
+	}
+}
+
+// Batcher turns concurrent Gets for single keys into batched backend calls,
+// the N+1 query problem's fix also known as a dataloader. Where
+// singleflight merges concurrent calls for the same key, Batcher merges
+// calls for different keys; duplicate keys within a batch are loaded once.
+type Batcher[K comparable, V any] struct {
+	fn  BatchFunc[K, V]
+	cfg config
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	index   map[K]struct{}
+	timer   *time.Timer
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int // Gets still waiting; guarded by Batcher.mu
+
+	once    sync.Once // a batch reaching its size limit as its timer fires is sent once
+	done    chan struct{}
+	results map[K]Result[V]
+	err     error
+}
+
+func NewBatcher[K comparable, V any](fn BatchFunc[K, V], opts ...Option) *Batcher[K, V] {
+	cfg := config{wait: 2 * time.Millisecond, maxBatch: 100}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Batcher[K, V]{fn: fn, cfg: cfg}
+}
+
+// Get adds key to the pending batch and waits for its result. If ctx ends
+// first Get returns its error; once every caller waiting on a batch has
+// gone, the batch's context is cancelled too, stopping the backend call.
+func (b *Batcher[K, V]) Get(ctx context.Context, key K) (V, error) {
+	var zero V
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	b.mu.Lock()
+	bt := b.pending
+	if bt == nil {
+		bt = b.newBatch()
+		b.pending = bt
+	}
+	if _, ok := bt.index[key]; !ok {
+		bt.index[key] = struct{}{}
+		bt.keys = append(bt.keys, key)
+	}
+	bt.waiters++
+	if len(bt.keys) >= b.cfg.maxBatch {
+		b.detach(bt)
+		go b.dispatch(bt)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-bt.done:
+		if bt.err != nil {
+			return zero, bt.err
+		}
+		res, ok := bt.results[key]
+		if !ok {
+			return zero, fmt.Errorf("get %v: %w", key, ErrNotFound)
+		}
+		return res.Value, res.Err
+	case <-ctx.Done():
+		b.mu.Lock()
+		bt.waiters--
+		if bt.waiters == 0 {
+			// New Gets must not join a batch nobody is waiting for.
+			b.detach(bt)
+			bt.cancel()
+		}
+		b.mu.Unlock()
+		return zero, ctx.Err()
+	}
+}
+
+// newBatch starts a batch whose timer dispatches it. b.mu must be held.
+func (b *Batcher[K, V]) newBatch() *batch[K, V] {
+	ctx, cancel := context.WithCancel(context.Background())
+	bt := &batch[K, V]{
+		index:  make(map[K]struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	bt.timer = time.AfterFunc(b.cfg.wait, func() {
+		b.mu.Lock()
+		b.detach(bt)
+		b.mu.Unlock()
+		b.dispatch(bt)
+	})
+	return bt
+}
+
+// detach stops bt from taking more keys. b.mu must be held.
+func (b *Batcher[K, V]) detach(bt *batch[K, V]) {
+	if b.pending == bt {
+		b.pending = nil
+	}
+	bt.timer.Stop()
+}
+
+func (b *Batcher[K, V]) dispatch(bt *batch[K, V]) {
+	bt.once.Do(func() {
+		defer bt.cancel()
+		if err := bt.ctx.Err(); err != nil {
+			bt.err = err // everyone left before it was sent
+		} else {
+			bt.results, bt.err = b.fn(bt.ctx, bt.keys)
+		}
+		close(bt.done)
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backend records the batches it was asked for.
+type backend struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (be *backend) load(_ context.Context, keys []int) (map[int]Result[string], error) {
+	be.mu.Lock()
+	be.batches = append(be.batches, slices.Clone(keys))
+	be.mu.Unlock()
+
+	results := make(map[int]Result[string], len(keys))
+	for _, k := range keys {
+		switch {
+		case k < 0:
+			results[k] = Result[string]{Err: fmt.Errorf("negative key %d", k)}
+		case k >= 1000:
+			// not found: left out
+		default:
+			results[k] = Result[string]{Value: fmt.Sprint("user-", k)}
+		}
+	}
+	return results, nil
+}
+
+func (be *backend) sizes() []int {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	var sizes []int
+	for _, b := range be.batches {
+		sizes = append(sizes, len(b))
+	}
+	slices.Sort(sizes)
+	return sizes
+}
+
+// getAll calls Get for every key at once and returns the results in order.
+func getAll(b *Batcher[int, string], keys ...int) ([]string, []error) {
+	values := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Go(func() {
+			values[i], errs[i] = b.Get(context.Background(), k)
+		})
+	}
+	wg.Wait()
+	return values, errs
+}
+
+func TestBatcher_CoalescesIntoOneCall(t *testing.T) {
+	var be backend
+	b := NewBatcher(be.load, WithWait(20*time.Millisecond))
+
+	values, errs := getAll(b, 1, 2, 3, 2, 1, -1, 1000)
+	if !slices.Equal(values[:5], []string{"user-1", "user-2", "user-3", "user-2", "user-1"}) {
+		t.Errorf("unexpected values %v", values)
+	}
+	if errs[5] == nil || errs[5].Error() != "negative key -1" {
+		t.Errorf("expected the key's own error, got %v", errs[5])
+	}
+	if !errors.Is(errs[6], ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a key left out, got %v", errs[6])
+	}
+	if sizes := be.sizes(); !slices.Equal(sizes, []int{5}) {
+		t.Errorf("expected one batch of 5 distinct keys, got sizes %v", sizes)
+	}
+}
+
+func TestBatcher_BatchError(t *testing.T) {
+	errDown := errors.New("database down")
+	b := NewBatcher(func(context.Context, []int) (map[int]Result[string], error) {
+		return nil, errDown
+	})
+	_, errs := getAll(b, 1, 2, 3)
+	for _, err := range errs {
+		if !errors.Is(err, errDown) {
+			t.Errorf("expected every key to fail with the batch error, got %v", err)
+		}
+	}
+}
+
+func TestBatcher_MaxBatch(t *testing.T) {
+	var be backend
+	b := NewBatcher(be.load, WithWait(50*time.Millisecond), WithMaxBatch(10))
+	keys := make([]int, 25)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	start := time.Now()
+	_, errs := getAll(b, keys...)
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sizes := be.sizes(); !slices.Equal(sizes, []int{5, 10, 10}) {
+		t.Errorf("expected batches of 10, 10 and 5, got %v", sizes)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected the partial batch to wait for the window, took %v", elapsed)
+	}
+}
+
+func TestBatcher_Cancellation(t *testing.T) {
+	started := make(chan struct{})
+	backendCanceled := make(chan struct{})
+	slow := func(ctx context.Context, keys []int) (map[int]Result[string], error) {
+		if keys[0] != 1 {
+			return map[int]Result[string]{keys[0]: {Value: "fresh"}}, nil
+		}
+		close(started)
+		<-ctx.Done()
+		close(backendCanceled)
+		return nil, ctx.Err()
+	}
+	b := NewBatcher(slow, WithWait(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := b.Get(ctx, 1)
+		errc <- err
+	}()
+	<-started
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-backendCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the backend call to be cancelled once nobody waits for it")
+	}
+
+	if v, err := b.Get(context.Background(), 2); err != nil || v != "fresh" {
+		t.Errorf("expected a new Get to start a fresh batch, got %q, %v", v, err)
+	}
+
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if _, err := b.Get(done, 3); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a done context to fail immediately, got %v", err)
+	}
+}
+
+func TestBatcher_LeavingCallerDoesNotCancelOthers(t *testing.T) {
+	var be backend
+	b := NewBatcher(be.load, WithWait(30*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	var wg sync.WaitGroup
+	wg.Go(func() { b.Get(ctx, 1) })
+	v, err := b.Get(context.Background(), 2)
+	wg.Wait()
+	if err != nil || v != "user-2" {
+		t.Errorf("expected the remaining caller to get its value, got %q, %v", v, err)
+	}
+}
+
+func TestBatcher_Concurrent(t *testing.T) {
+	var be backend
+	b := NewBatcher(be.load, WithWait(time.Millisecond), WithMaxBatch(16))
+	var wg sync.WaitGroup
+	for g := range 32 {
+		wg.Go(func() {
+			for i := range 50 {
+				k := (g*50 + i) % 300
+				if v, err := b.Get(context.Background(), k); err != nil || v != fmt.Sprint("user-", k) {
+					t.Errorf("key %d: got %q, %v", k, v, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	for _, size := range be.sizes() {
+		if size > 16 {
+			t.Fatalf("batch of %d keys exceeds the limit", size)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+)
+
+// Retrier runs fn again, with backoff, until it succeeds, ctx is done or
+// the Retrier gives up. The Retryer of the retry-backoff-policy kata has
+// this method; configure it to retry the failures you want restarted, for
+// example WithRetryIf(func(error) bool { return true }).
+type Retrier interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// backoff is the default Retrier: it never gives up, doubling its delay
+// from base up to max.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+func (b backoff) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	for delay := b.base; ; delay = min(2*delay, b.max) {
+		err := fn(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
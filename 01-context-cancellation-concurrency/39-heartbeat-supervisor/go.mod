@@ -0,0 +1,9 @@
+module heartbeat-supervisor
+
+go 1.25.0
+
+require panic-safe-errgroup v0.0.0
+
+require golang.org/x/sync v0.19.0 // indirect
+
+replace panic-safe-errgroup => ../27-panic-safe-errgroup
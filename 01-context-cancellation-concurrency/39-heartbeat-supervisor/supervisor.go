@@ -0,0 +1,299 @@
+// Package supervisor keeps long-running workers alive. Each worker proves
+// it is making progress by calling beat; one that stops beating is
+// cancelled as stuck, and one that fails, stuck or not, is restarted with
+// backoff. A panic counts as a failure, not a crash of the process.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"panic-safe-errgroup"
+)
+
+// ErrStuck is the failure of a worker that missed its heartbeat.
+var ErrStuck = errors.New("missed heartbeat")
+
+// Worker runs until ctx is done, calling beat at least once per heartbeat
+// timeout. Returning nil means its job is done and it is not restarted;
+// returning an error, or panicking, restarts it.
+type Worker func(ctx context.Context, beat func()) error
+
+// State is where a worker is in its life.
+type State int
+
+const (
+	Idle       State = iota // Run has not started it yet
+	Running                 // beating
+	Stuck                   // missed its heartbeat, cancelled but not yet returned
+	Restarting              // failed, waiting out the backoff
+	Stopped                 // finished its job, or the supervisor stopped
+	Failed                  // the Retrier gave up on it
+)
+
+func (s State) String() string {
+	switch s {
+	case Idle:
+		return "idle"
+	case Running:
+		return "running"
+	case Stuck:
+		return "stuck"
+	case Restarting:
+		return "restarting"
+	case Stopped:
+		return "stopped"
+	case Failed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Status is a snapshot of one worker.
+type Status struct {
+	Name     string
+	State    State
+	Restarts int
+	LastBeat time.Time // zero before it first started
+	Err      error     // the last failure, if any
+}
+
+type config struct {
+	timeout     time.Duration
+	stableAfter time.Duration
+	retrier     Retrier
+}
+
+type Option func(*config)
+
+// WithHeartbeatTimeout sets how long a worker may go without calling beat
+// before it is cancelled as stuck. The default is 10 seconds.
+func WithHeartbeatTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithStableAfter sets how long a worker must run before a failure counts
+// as a fresh one, restarted at once with the backoff reset, instead of one
+// more in a crash loop. The default is a minute.
+func WithStableAfter(d time.Duration) Option {
+	return func(c *config) {
+		c.stableAfter = d
+	}
+}
+
+// WithRetrier sets what restarts failed workers. The default never gives
+// up, backing off from 100ms to 30s.
+func WithRetrier(r Retrier) Option {
+	return func(c *config) {
+		c.retrier = r
+	}
+}
+
+// Supervisor runs a fixed set of workers, restarting them as needed, and
+// reports their health.
+type Supervisor struct {
+	cfg   config
+	epoch time.Time // beats are stored relative to it, on the monotonic clock
+
+	mu      sync.Mutex
+	workers []*worker
+	started bool
+}
+
+type worker struct {
+	name     string
+	fn       Worker
+	lastBeat atomic.Int64 // time since epoch; 0 before the first start
+
+	mu       sync.Mutex
+	state    State
+	restarts int
+	err      error
+}
+
+func New(opts ...Option) *Supervisor {
+	cfg := config{
+		timeout:     10 * time.Second,
+		stableAfter: time.Minute,
+		retrier:     backoff{base: 100 * time.Millisecond, max: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Supervisor{cfg: cfg, epoch: time.Now()}
+}
+
+// Add registers a worker. It panics if name is taken or Run has started,
+// as registering routes on a running server would.
+func (s *Supervisor) Add(name string, fn Worker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		panic("supervisor: Add after Run")
+	}
+	for _, w := range s.workers {
+		if w.name == name {
+			panic(fmt.Sprintf("supervisor: worker %q registered twice", name))
+		}
+	}
+	s.workers = append(s.workers, &worker{name: name, fn: fn})
+}
+
+// Run supervises every worker until ctx is done and they have all
+// returned. It returns the failures the Retrier gave up on, joined, or nil.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("supervisor: Run called twice")
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.workers))
+	for i, w := range s.workers {
+		wg.Go(func() {
+			errs[i] = s.supervise(ctx, w)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// supervise restarts w until it finishes, ctx is done or the Retrier gives
+// up. Each round of the Retrier ends when w fails after running stably, so
+// the next failure starts again from the shortest backoff.
+func (s *Supervisor) supervise(ctx context.Context, w *worker) error {
+	for {
+		finished := false
+		err := s.cfg.retrier.Do(ctx, func(ctx context.Context) error {
+			started := time.Now()
+			err := s.runOnce(ctx, w)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err == nil:
+				finished = true
+				return nil
+			}
+			w.set(Restarting, err)
+			if time.Since(started) >= s.cfg.stableAfter {
+				return nil
+			}
+			return err
+		})
+		switch {
+		case ctx.Err() != nil, finished:
+			w.set(Stopped, nil)
+			return nil
+		case err != nil:
+			w.set(Failed, err)
+			return fmt.Errorf("worker %q: %w", w.name, err)
+		}
+	}
+}
+
+// runOnce runs w once, cancelling it if it misses its heartbeat. Go cannot
+// kill a goroutine, so a stuck worker that ignores its context stays stuck,
+// and unhealthy, until it returns.
+func (s *Supervisor) runOnce(ctx context.Context, w *worker) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	beat := func() {
+		w.lastBeat.Store(int64(time.Since(s.epoch)))
+	}
+	beat()
+	w.start()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &group.PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		done <- w.fn(ctx, beat)
+	}()
+
+	ticker := time.NewTicker(s.cfg.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if errors.Is(context.Cause(ctx), ErrStuck) {
+				return fmt.Errorf("%w for %v", ErrStuck, s.cfg.timeout)
+			}
+			return err
+		case <-ticker.C:
+			last := time.Duration(w.lastBeat.Load())
+			if ctx.Err() == nil && time.Since(s.epoch)-last > s.cfg.timeout {
+				w.set(Stuck, ErrStuck)
+				cancel(ErrStuck)
+			}
+		}
+	}
+}
+
+func (w *worker) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state != Idle {
+		w.restarts++
+	}
+	w.state = Running
+}
+
+func (w *worker) set(state State, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state = state
+	if err != nil {
+		w.err = err
+	}
+}
+
+// Statuses returns a snapshot of every worker, in the order they were
+// added.
+func (s *Supervisor) Statuses() []Status {
+	s.mu.Lock()
+	workers := s.workers
+	s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(workers))
+	for _, w := range workers {
+		w.mu.Lock()
+		status := Status{Name: w.name, State: w.state, Restarts: w.restarts, Err: w.err}
+		w.mu.Unlock()
+		if last := w.lastBeat.Load(); last != 0 {
+			status.LastBeat = s.epoch.Add(time.Duration(last))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Check reports every worker that is not running or done, as a health
+// check: nil means healthy. A worker restarting after a failure counts as
+// unhealthy until it is running again.
+func (s *Supervisor) Check(context.Context) error {
+	var errs []error
+	for _, status := range s.Statuses() {
+		switch status.State {
+		case Running, Stopped:
+		case Idle:
+			errs = append(errs, fmt.Errorf("worker %q not started", status.Name))
+		default:
+			errs = append(errs, fmt.Errorf("worker %q %v: %w", status.Name, status.State, status.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,250 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"panic-safe-errgroup"
+)
+
+var errBoom = errors.New("boom")
+
+// waitState waits until the worker added first is in state.
+func waitState(t *testing.T, s *Supervisor, state State) Status {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status := s.Statuses()[0]
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected state %v, got %+v", state, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// beatUntilDone is a healthy worker.
+func beatUntilDone(ctx context.Context, beat func()) error {
+	ticker := time.NewTicker(2 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			beat()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// start runs s until the test ends, and returns Run's error channel.
+func start(t *testing.T, s *Supervisor) (context.CancelFunc, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		errc <- s.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cancel, errc
+}
+
+func fastBackoff() Option {
+	return WithRetrier(backoff{base: time.Millisecond, max: 5 * time.Millisecond})
+}
+
+func TestSupervisor_RestartsFailedWorker(t *testing.T) {
+	s := New(fastBackoff())
+	var runs atomic.Int32
+	s.Add("consumer", func(ctx context.Context, beat func()) error {
+		switch runs.Add(1) {
+		case 1:
+			return errBoom
+		case 2:
+			panic("nil map")
+		}
+		return beatUntilDone(ctx, beat)
+	})
+	cancel, errc := start(t, s)
+
+	status := waitState(t, s, Running)
+	for status.Restarts < 2 {
+		status = waitState(t, s, Running)
+	}
+	var panicErr *group.PanicError
+	if !errors.As(status.Err, &panicErr) || panicErr.Value != "nil map" {
+		t.Errorf("expected the last failure to be the recovered panic, got %v", status.Err)
+	}
+	if err := s.Check(context.Background()); err != nil {
+		t.Errorf("expected a running worker to be healthy, got %v", err)
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Errorf("expected a clean stop, got %v", err)
+	}
+	if status := s.Statuses()[0]; status.State != Stopped || status.Restarts != 2 {
+		t.Errorf("unexpected status after stop %+v", status)
+	}
+}
+
+func TestSupervisor_RestartsStuckWorker(t *testing.T) {
+	s := New(fastBackoff(), WithHeartbeatTimeout(20*time.Millisecond))
+	var runs atomic.Int32
+	causes := make(chan error, 1)
+	s.Add("indexer", func(ctx context.Context, beat func()) error {
+		if runs.Add(1) == 1 {
+			<-ctx.Done() // never beats
+			causes <- context.Cause(ctx)
+			return ctx.Err()
+		}
+		return beatUntilDone(ctx, beat)
+	})
+	start(t, s)
+
+	if cause := <-causes; !errors.Is(cause, ErrStuck) {
+		t.Errorf("expected the stuck worker's context to be cancelled with ErrStuck, got %v", cause)
+	}
+	status := waitState(t, s, Running)
+	if status.Restarts != 1 || !errors.Is(status.Err, ErrStuck) {
+		t.Errorf("unexpected status %+v", status)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status := s.Statuses()[0]; status.State != Running || status.Restarts != 1 {
+		t.Errorf("expected a beating worker to keep running, got %+v", status)
+	}
+}
+
+func TestSupervisor_StuckWorkerIsUnhealthy(t *testing.T) {
+	s := New(fastBackoff(), WithHeartbeatTimeout(20*time.Millisecond))
+	release := make(chan struct{})
+	s.Add("exporter", func(ctx context.Context, beat func()) error {
+		<-release // ignores its context
+		return beatUntilDone(ctx, beat)
+	})
+	start(t, s)
+
+	waitState(t, s, Stuck)
+	err := s.Check(context.Background())
+	if !errors.Is(err, ErrStuck) || !strings.Contains(err.Error(), `"exporter" stuck`) {
+		t.Errorf("expected the stuck worker to fail the health check, got %v", err)
+	}
+	close(release)
+	waitState(t, s, Running)
+}
+
+// limited gives up after attempts calls of fn, like a Retryer with
+// WithMaxAttempts.
+type limited struct {
+	attempts int
+	calls    atomic.Int32
+}
+
+func (l *limited) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	l.calls.Add(1)
+	var err error
+	for range l.attempts {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func TestSupervisor_RetrierGivesUp(t *testing.T) {
+	s := New(WithRetrier(&limited{attempts: 3}))
+	var runs atomic.Int32
+	s.Add("mailer", func(context.Context, func()) error {
+		runs.Add(1)
+		return errBoom
+	})
+	s.Add("healthy", beatUntilDone)
+	_, errc := start(t, s)
+
+	status := waitState(t, s, Failed)
+	if runs.Load() != 3 || status.Restarts != 2 || !errors.Is(status.Err, errBoom) {
+		t.Errorf("unexpected status %+v after %d runs", status, runs.Load())
+	}
+	err := s.Check(context.Background())
+	if !errors.Is(err, errBoom) || strings.Contains(err.Error(), "healthy") {
+		t.Errorf("expected only the failed worker in the health check, got %v", err)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("expected Run to keep supervising the other workers, got %v", err)
+	default:
+	}
+}
+
+func TestSupervisor_RunReturnsFailures(t *testing.T) {
+	s := New(WithRetrier(&limited{attempts: 1}))
+	s.Add("mailer", func(context.Context, func()) error { return errBoom })
+	if err := s.Run(context.Background()); !errors.Is(err, errBoom) || !strings.Contains(err.Error(), `"mailer"`) {
+		t.Errorf("expected the worker's failure, got %v", err)
+	}
+}
+
+func TestSupervisor_StableRunResetsBackoff(t *testing.T) {
+	retrier := &limited{attempts: 2}
+	s := New(WithRetrier(retrier), WithStableAfter(5*time.Millisecond))
+	var runs atomic.Int32
+	s.Add("poller", func(ctx context.Context, beat func()) error {
+		if runs.Add(1) <= 3 {
+			time.Sleep(10 * time.Millisecond)
+			return errBoom
+		}
+		return beatUntilDone(ctx, beat)
+	})
+	start(t, s)
+
+	status := waitState(t, s, Running)
+	for status.Restarts < 3 {
+		status = waitState(t, s, Running)
+	}
+	if calls := retrier.calls.Load(); calls != 4 {
+		t.Errorf("expected each stable failure to start a fresh retry, got %d", calls)
+	}
+}
+
+func TestSupervisor_FinishedWorkerIsNotRestarted(t *testing.T) {
+	s := New(fastBackoff())
+	var runs atomic.Int32
+	s.Add("migration", func(context.Context, func()) error {
+		runs.Add(1)
+		return nil
+	})
+	start(t, s)
+
+	waitState(t, s, Stopped)
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != 1 {
+		t.Errorf("expected one run, got %d", runs.Load())
+	}
+	if err := s.Check(context.Background()); err != nil {
+		t.Errorf("expected a finished worker to be healthy, got %v", err)
+	}
+}
+
+func TestSupervisor_Add(t *testing.T) {
+	s := New()
+	s.Add("a", beatUntilDone)
+	if err := s.Check(context.Background()); err == nil {
+		t.Error("expected a worker not started yet to be unhealthy")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a duplicate name to panic")
+		}
+	}()
+	s.Add("a", beatUntilDone)
+}
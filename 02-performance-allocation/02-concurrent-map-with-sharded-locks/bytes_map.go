@@ -0,0 +1,68 @@
+package concurrentmapwithshardedlocks
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// BytesShardedMap is a sharded map keyed by byte slices, for network code
+// that holds keys in reused read buffers. Lookups hash the bytes directly
+// and index the shard with m[string(key)], which the compiler performs
+// without allocating, so Get and Delete never copy the key. Set has to copy
+// it once, because the caller is free to overwrite its buffer afterwards.
+type BytesShardedMap[V any] struct {
+	shards []map[string]V
+	locks  []sync.RWMutex
+	seed   maphash.Seed
+}
+
+func NewBytesShardedMap[V any](numShards uint) *BytesShardedMap[V] {
+	if numShards == 0 {
+		panic("concurrentmapwithshardedlocks: numShards must be greater than zero")
+	}
+	shards := make([]map[string]V, numShards)
+	for i := range shards {
+		shards[i] = make(map[string]V)
+	}
+	return &BytesShardedMap[V]{
+		shards: shards,
+		locks:  make([]sync.RWMutex, numShards),
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+func (b *BytesShardedMap[V]) Get(key []byte) (V, bool) {
+	shardIndex := b.shardIndex(key)
+	b.locks[shardIndex].RLock()
+	defer b.locks[shardIndex].RUnlock()
+	value, ok := b.shards[shardIndex][string(key)]
+	return value, ok
+}
+
+func (b *BytesShardedMap[V]) Set(key []byte, value V) {
+	shardIndex := b.shardIndex(key)
+	b.locks[shardIndex].Lock()
+	defer b.locks[shardIndex].Unlock()
+	b.shards[shardIndex][string(key)] = value
+}
+
+func (b *BytesShardedMap[V]) Delete(key []byte) {
+	shardIndex := b.shardIndex(key)
+	b.locks[shardIndex].Lock()
+	defer b.locks[shardIndex].Unlock()
+	delete(b.shards[shardIndex], string(key))
+}
+
+func (b *BytesShardedMap[V]) Len() int {
+	n := 0
+	for i := range b.shards {
+		b.locks[i].RLock()
+		n += len(b.shards[i])
+		b.locks[i].RUnlock()
+	}
+	return n
+}
+
+func (b *BytesShardedMap[V]) shardIndex(key []byte) int {
+	return int(maphash.Bytes(b.seed, key) % uint64(len(b.shards)))
+}
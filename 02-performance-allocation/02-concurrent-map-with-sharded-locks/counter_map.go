@@ -0,0 +1,38 @@
+package concurrentmapwithshardedlocks
+
+import "consistent-hash-ring"
+
+// Number is the set of value types CounterMap can add to.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// CounterMap is a ShardedMap of numbers with in-place arithmetic, for
+// high-frequency counters such as per-endpoint hit counts.
+type CounterMap[K comparable, N Number] struct {
+	*shardedMap[K, N]
+}
+
+func NewCounterMap[K comparable, N Number](numShards uint, opts ...Option) *CounterMap[K, N] {
+	return &CounterMap[K, N]{newShardedMap[K, N](numShards, hashring.Hash[K], opts...)}
+}
+
+// IncrBy adds delta to key (starting from zero if absent) under the shard
+// lock and returns the new value. Unlike Update it takes no closure, so it
+// adds no allocation of its own to the hot path.
+func (c *CounterMap[K, N]) IncrBy(key K, delta N) N {
+	shardIndex := c.shardIndex(key)
+	c.lock(shardIndex)
+	defer c.locks[shardIndex].Unlock()
+	value, _ := c.lookup(shardIndex, key)
+	value += delta
+	c.store(shardIndex, key, value)
+	return value
+}
+
+// DecrBy subtracts delta from key and returns the new value.
+func (c *CounterMap[K, N]) DecrBy(key K, delta N) N {
+	return c.IncrBy(key, -delta)
+}
@@ -1,3 +1,7 @@
 module concurrent-map-with-sharded-locks
 
-go 1.24.3
+go 1.25.0
+
+require consistent-hash-ring v0.0.0
+
+replace consistent-hash-ring => ../35-consistent-hash-ring
@@ -0,0 +1,85 @@
+package concurrentmapwithshardedlocks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	opSet    = "set"
+	opDelete = "del"
+)
+
+type journalEntry[K comparable, V any] struct {
+	Op       string `json:"op"`
+	Key      K      `json:"key"`
+	Value    V      `json:"value,omitempty"`
+	Deadline int64  `json:"deadline,omitempty"`
+}
+
+// journal serializes entries from all shards onto one writer. After the
+// first write error it stops writing and keeps the error for JournalErr.
+type journal struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func newJournal(w io.Writer) *journal {
+	return &journal{enc: json.NewEncoder(w)}
+}
+
+func (j *journal) write(entry any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return
+	}
+	if err := j.enc.Encode(entry); err != nil {
+		j.err = fmt.Errorf("write journal: %w", err)
+	}
+}
+
+// JournalErr returns the error that stopped journaling, if any.
+func (s *shardedMap[K, V]) JournalErr() error {
+	if s.journal == nil {
+		return nil
+	}
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	return s.journal.err
+}
+
+// Replay applies a journal written by WithJournal. Entries are not written
+// to this map's own journal again, so Replay is meant to run at startup,
+// before the map is shared; claims whose deadline has passed are dropped.
+func (s *shardedMap[K, V]) Replay(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	now := time.Now().UnixNano()
+	for n := 1; ; n++ {
+		var entry journalEntry[K, V]
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("replay entry %d: %w", n, err)
+		}
+
+		shardIndex := s.shardIndex(entry.Key)
+		s.lock(shardIndex)
+		switch {
+		case entry.Op == opDelete, entry.Op == opSet && entry.Deadline > 0 && entry.Deadline <= now:
+			s.drop(shardIndex, entry.Key, false)
+		case entry.Op == opSet:
+			s.put(shardIndex, entry.Key, entry.Value, entry.Deadline, false)
+		default:
+			s.locks[shardIndex].Unlock()
+			return fmt.Errorf("replay entry %d: unknown op %q", n, entry.Op)
+		}
+		s.locks[shardIndex].Unlock()
+	}
+}
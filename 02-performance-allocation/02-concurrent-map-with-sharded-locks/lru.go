@@ -0,0 +1,50 @@
+package concurrentmapwithshardedlocks
+
+// entry is what a shard's map holds for each key. On a bounded map entries
+// are also linked, intrusively, into their shard's recency list, so a
+// lookup finds the list node with no second index and a new key costs one
+// allocation.
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *entry[K, V] // nil unless bounded by WithMaxEntriesPerShard
+}
+
+// lruList orders the entries of one shard by recency. A sentinel root
+// closes the ring, so moving an entry to the front never allocates. It is
+// guarded by its shard's lock.
+type lruList[K comparable, V any] struct {
+	root entry[K, V] // root.next is the most recent, root.prev the oldest
+}
+
+func newLRUList[K comparable, V any]() *lruList[K, V] {
+	l := &lruList[K, V]{}
+	l.root.prev = &l.root
+	l.root.next = &l.root
+	return l
+}
+
+// touch marks e as most recently used, linking it in if needed.
+func (l *lruList[K, V]) touch(e *entry[K, V]) {
+	if e.next != nil {
+		l.unlink(e)
+	}
+	e.prev = &l.root
+	e.next = l.root.next
+	l.root.next.prev = e
+	l.root.next = e
+}
+
+// oldest returns the least recently used entry, or nil if there is none.
+func (l *lruList[K, V]) oldest() *entry[K, V] {
+	if l.root.prev == &l.root {
+		return nil
+	}
+	return l.root.prev
+}
+
+func (l *lruList[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
@@ -0,0 +1,47 @@
+package concurrentmapwithshardedlocks
+
+import "sync"
+
+// ValuePool recycles *T values stored in a ShardedMap[K, *T]. It suits
+// workloads that churn millions of short-lived structs through the map.
+//
+// The contract is the one of sync.Pool: once a value is released, nobody may
+// keep using it. The map cannot know whether a reader still holds a pointer
+// returned by Get, so it never releases anything itself: SetPooled hands the
+// replaced value back, and only a caller that owns it exclusively (say,
+// because the map is never read concurrently, or readers are known to be
+// done) may Release it.
+type ValuePool[T any] struct {
+	p sync.Pool
+}
+
+func NewValuePool[T any]() *ValuePool[T] {
+	return &ValuePool[T]{
+		p: sync.Pool{New: func() any { return new(T) }},
+	}
+}
+
+// Acquire returns a zeroed *T, reused when possible.
+func (p *ValuePool[T]) Acquire() *T {
+	return p.p.Get().(*T)
+}
+
+// Release zeroes v, so it doesn't pin anything it referenced, and returns it
+// to the pool.
+func (p *ValuePool[T]) Release(v *T) {
+	if v == nil {
+		return
+	}
+	*v = *new(T)
+	p.p.Put(v)
+}
+
+// SetPooled stores a pooled value for key, filled by fill, and returns the
+// value it replaced, if any. fill runs before any lock is taken. The old
+// value is not released: readers that loaded it earlier may still be using
+// it.
+func SetPooled[K comparable, T any](m ShardedMap[K, *T], pool *ValuePool[T], key K, fill func(v *T)) (old *T, loaded bool) {
+	v := pool.Acquire()
+	fill(v)
+	return m.Swap(key, v)
+}
@@ -1,9 +1,15 @@
 package concurrentmapwithshardedlocks
 
 import (
-	"fmt"
-	"hash/fnv"
+	"io"
+	"iter"
+	"math/bits"
+	"runtime"
+	"slices"
 	"sync"
+	"time"
+
+	"consistent-hash-ring"
 )
 
 type ShardedMap[K comparable, V any] interface {
@@ -11,43 +17,159 @@ type ShardedMap[K comparable, V any] interface {
 	Set(key K, value V)
 	Delete(key K)
 	Keys() []K
+	KeysSeq() iter.Seq[K]
+	SortedKeys(less func(a, b K) bool) []K
+	RangeOrdered(less func(a, b K) bool, fn func(key K, value V) bool)
+	Items() map[K]V
+	CloneInto(dst map[K]V)
+	GetOrSet(key K, value V) (actual V, loaded bool)
+	GetOrCompute(key K, compute func() V) (actual V, loaded bool)
+	Update(key K, fn func(old V, exists bool) (newValue V, keep bool)) (V, bool)
+	Swap(key K, value V) (previous V, loaded bool)
+	LoadAndDelete(key K) (value V, loaded bool)
+	SetIfAbsentTTL(key K, value V, ttl time.Duration) bool
+	Replay(r io.Reader) error
+	JournalErr() error
+	Len() int
+	ShardSizes() []int
+	Stats() Stats
 }
 
 type shardedMap[K comparable, V any] struct {
-	shards []map[K]V
-	locks  []sync.RWMutex
+	shards   []map[K]*entry[K, V]
+	locks    []sync.RWMutex
+	counters []shardCounters
+	hasher   func(K) uint64
+	lrus     []*lruList[K, V] // nil unless bounded by WithMaxEntriesPerShard
+	// expiries holds deadlines (unix nanos) of keys claimed with
+	// SetIfAbsentTTL; keys written any other way never expire.
+	expiries []map[K]int64
+	// mask is numShards-1 when numShards is a power of two, letting
+	// shardIndex use a bitwise AND instead of a modulo.
+	mask    uint64
+	useMask bool
+	options
+}
+
+type options struct {
+	lockTiming         bool
+	maxEntriesPerShard int
+	journal            *journal
+}
+
+type Option func(o *options)
+
+// WithLockTiming records how long each lock acquisition waited. It costs two
+// clock reads per operation, so it is off by default.
+func WithLockTiming() Option {
+	return func(o *options) {
+		o.lockTiming = true
+	}
+}
+
+// WithMaxEntriesPerShard bounds every shard to n entries, evicting the least
+// recently used key of a shard when a write would exceed it. Recency is
+// updated by Get, so on a bounded map Get takes the shard's write lock.
+func WithMaxEntriesPerShard(n int) Option {
+	return func(o *options) {
+		o.maxEntriesPerShard = n
+	}
+}
+
+// WithJournal appends every Set/Delete-style mutation, including LRU
+// evictions, to w as a JSON line, so Replay can rebuild the map after a
+// restart. Writes happen under the shard lock, so per-key order is exact.
+// K and V must be JSON-encodable.
+func WithJournal(w io.Writer) Option {
+	return func(o *options) {
+		o.journal = newJournal(w)
+	}
+}
+
+// NewShardedMap panics if numShards is zero, like make does for a negative size.
+func NewShardedMap[K comparable, V any](numShards uint, opts ...Option) ShardedMap[K, V] {
+	return NewShardedMapWithHasher[K, V](numShards, hashring.Hash[K], opts...)
+}
+
+// NewShardedMapAuto sizes the map for the current machine: four shards per
+// GOMAXPROCS, rounded up to a power of two so that indexing is a mask.
+func NewShardedMapAuto[K comparable, V any](opts ...Option) ShardedMap[K, V] {
+	return NewShardedMap[K, V](autoShardCount(), opts...)
+}
+
+func autoShardCount() uint {
+	n := uint(runtime.GOMAXPROCS(0)) * 4
+	return 1 << bits.Len(n-1)
+}
+
+// NewShardedMapWithHasher lets callers with custom key types (UUID structs,
+// byte arrays, pointers) supply their own hash instead of the default one,
+// hashring.Hash, which hashes the key's fmt representation and is shared
+// with the consistent hash ring.
+func NewShardedMapWithHasher[K comparable, V any](numShards uint, hasher func(K) uint64, opts ...Option) ShardedMap[K, V] {
+	return newShardedMap[K, V](numShards, hasher, opts...)
 }
 
-func NewShardedMap[K comparable, V any](numShards uint) ShardedMap[K, V] {
-	shards := make([]map[K]V, numShards)
+func newShardedMap[K comparable, V any](numShards uint, hasher func(K) uint64, opts ...Option) *shardedMap[K, V] {
+	if numShards == 0 {
+		panic("concurrentmapwithshardedlocks: numShards must be greater than zero")
+	}
+	shards := make([]map[K]*entry[K, V], numShards)
+	expiries := make([]map[K]int64, numShards)
 	for i := range shards {
-		shards[i] = make(map[K]V)
+		shards[i] = make(map[K]*entry[K, V])
+		expiries[i] = make(map[K]int64)
+	}
+	m := &shardedMap[K, V]{
+		shards:   shards,
+		expiries: expiries,
+		locks:    make([]sync.RWMutex, numShards),
+		counters: make([]shardCounters, numShards),
+		hasher:   hasher,
+		mask:     uint64(numShards - 1),
+		useMask:  numShards&(numShards-1) == 0,
+	}
+	for _, opt := range opts {
+		opt(&m.options)
 	}
-	return &shardedMap[K, V]{
-		shards: shards,
-		locks:  make([]sync.RWMutex, numShards),
+	if m.maxEntriesPerShard > 0 {
+		m.lrus = make([]*lruList[K, V], numShards)
+		for i := range m.lrus {
+			m.lrus[i] = newLRUList[K, V]()
+		}
 	}
+	return m
 }
 
 func (s *shardedMap[K, V]) Delete(key K) {
 	shardIndex := s.shardIndex(key)
-	s.locks[shardIndex].Lock()
+	s.lock(shardIndex)
 	defer s.locks[shardIndex].Unlock()
-	delete(s.shards[shardIndex], key)
+	s.remove(shardIndex, key)
 }
 
 func (s *shardedMap[K, V]) Get(key K) (V, bool) {
 	shardIndex := s.shardIndex(key)
-	s.locks[shardIndex].RLock()
+	if s.lrus != nil {
+		s.lock(shardIndex)
+		defer s.locks[shardIndex].Unlock()
+		e := s.find(shardIndex, key)
+		if e == nil {
+			return *new(V), false
+		}
+		s.lrus[shardIndex].touch(e)
+		return e.value, true
+	}
+	s.rlock(shardIndex)
 	defer s.locks[shardIndex].RUnlock()
-	value, ok := s.shards[shardIndex][key]
+	value, ok := s.lookup(shardIndex, key)
 	return value, ok
 }
 
 func (s *shardedMap[K, V]) Keys() []K {
 	keys := make([]K, 0)
 	for i := range s.shards {
-		s.locks[i].RLock()
+		s.rlock(i)
 		for key := range s.shards[i] {
 			keys = append(keys, key)
 		}
@@ -55,15 +177,306 @@ func (s *shardedMap[K, V]) Keys() []K {
 	}
 	return keys
 }
+
+// GetOrSet returns the existing value for key if present (loaded is true).
+// Otherwise it stores value and returns it. Both steps run under one shard lock.
+func (s *shardedMap[K, V]) GetOrSet(key K, value V) (V, bool) {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	if e := s.find(shardIndex, key); e != nil {
+		s.accessed(shardIndex, e)
+		return e.value, true
+	}
+	s.store(shardIndex, key, value)
+	return value, false
+}
+
+// GetOrCompute is like GetOrSet but only calls compute when key is absent.
+// compute runs while the shard is locked, so it must be cheap and must not
+// touch the map.
+func (s *shardedMap[K, V]) GetOrCompute(key K, compute func() V) (V, bool) {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	if e := s.find(shardIndex, key); e != nil {
+		s.accessed(shardIndex, e)
+		return e.value, true
+	}
+	value := compute()
+	s.store(shardIndex, key, value)
+	return value, false
+}
+
+// Update reads, transforms and writes key under the shard lock. fn receives
+// the current value (or zero) and whether it exists; it returns the value to
+// store and keep=false to delete the key instead. Update returns what is
+// stored afterwards and whether the key is present. Like GetOrCompute, fn
+// must not touch the map.
+func (s *shardedMap[K, V]) Update(key K, fn func(old V, exists bool) (V, bool)) (V, bool) {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	old, exists := s.lookup(shardIndex, key)
+	value, keep := fn(old, exists)
+	if !keep {
+		s.remove(shardIndex, key)
+		return *new(V), false
+	}
+	s.store(shardIndex, key, value)
+	return value, true
+}
+
+// Swap stores value for key and returns the previous value, if any.
+func (s *shardedMap[K, V]) Swap(key K, value V) (V, bool) {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	previous, loaded := s.lookup(shardIndex, key)
+	s.store(shardIndex, key, value)
+	return previous, loaded
+}
+
+// LoadAndDelete removes key and returns the value it held, if any.
+func (s *shardedMap[K, V]) LoadAndDelete(key K) (V, bool) {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	value, loaded := s.lookup(shardIndex, key)
+	s.remove(shardIndex, key)
+	return value, loaded
+}
+
+// SetIfAbsentTTL stores value only if key is absent or its previous claim
+// has expired, and reports whether it did. The claim lasts ttl; afterwards
+// lookups treat the key as absent and the next SetIfAbsentTTL may take it
+// over. This gives lightweight in-process leases: Delete releases a lease
+// early, and a plain Set turns it into a permanent entry.
+//
+// Expired claims are reclaimed lazily by writes to the same key, so until
+// then they still show up in Keys, Items and Len.
+func (s *shardedMap[K, V]) SetIfAbsentTTL(key K, value V, ttl time.Duration) bool {
+	shardIndex := s.shardIndex(key)
+	s.lock(shardIndex)
+	defer s.locks[shardIndex].Unlock()
+	if _, ok := s.lookup(shardIndex, key); ok {
+		return false
+	}
+	s.put(shardIndex, key, value, time.Now().Add(ttl).UnixNano(), true)
+	return true
+}
+
+// Len returns the total number of entries. Shards are counted one at a time,
+// so under concurrent writes the result is a close estimate, not a snapshot.
+func (s *shardedMap[K, V]) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.rlock(i)
+		n += len(s.shards[i])
+		s.locks[i].RUnlock()
+	}
+	return n
+}
+
+// ShardSizes returns the entry count of every shard, useful to detect skew.
+func (s *shardedMap[K, V]) ShardSizes() []int {
+	sizes := make([]int, len(s.shards))
+	for i := range s.shards {
+		s.rlock(i)
+		sizes[i] = len(s.shards[i])
+		s.locks[i].RUnlock()
+	}
+	return sizes
+}
+
+// KeysSeq yields keys shard by shard. Only one shard's keys are buffered at a
+// time, and no lock is held while yielding, so the loop body may freely call
+// back into the map. Stopping the loop early skips the remaining shards.
+func (s *shardedMap[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		var buf []K
+		for i := range s.shards {
+			s.rlock(i)
+			buf = buf[:0]
+			for key := range s.shards[i] {
+				buf = append(buf, key)
+			}
+			s.locks[i].RUnlock()
+
+			for _, key := range buf {
+				if !yield(key) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SortedKeys returns all keys ordered by less.
+func (s *shardedMap[K, V]) SortedKeys(less func(a, b K) bool) []K {
+	keys := s.Keys()
+	slices.SortFunc(keys, compareFunc(less))
+	return keys
+}
+
+// RangeOrdered calls fn for every entry in key order until fn returns false.
+// Entries are copied out shard by shard first, so fn runs without any lock
+// held and sees the values as they were when their shard was copied.
+func (s *shardedMap[K, V]) RangeOrdered(less func(a, b K) bool, fn func(key K, value V) bool) {
+	type item struct {
+		key   K
+		value V
+	}
+	entries := make([]item, 0, s.Len())
+	for i := range s.shards {
+		s.rlock(i)
+		for key, e := range s.shards[i] {
+			entries = append(entries, item{key, e.value})
+		}
+		s.locks[i].RUnlock()
+	}
+
+	cmp := compareFunc(less)
+	slices.SortFunc(entries, func(a, b item) int { return cmp(a.key, b.key) })
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+func compareFunc[K any](less func(a, b K) bool) func(a, b K) int {
+	return func(a, b K) int {
+		switch {
+		case less(a, b):
+			return -1
+		case less(b, a):
+			return 1
+		default:
+			return 0
+		}
+	}
+}
+
+// Items returns a copy of every entry. Each shard is copied atomically, but
+// shards are visited in turn, so concurrent writes to other shards may or may
+// not be reflected.
+func (s *shardedMap[K, V]) Items() map[K]V {
+	items := make(map[K]V, s.Len())
+	s.CloneInto(items)
+	return items
+}
+
+// CloneInto copies every entry into dst with the same per-shard consistency
+// as Items. Existing entries in dst are kept unless overwritten; call
+// clear(dst) first to reuse a map across checkpoints.
+func (s *shardedMap[K, V]) CloneInto(dst map[K]V) {
+	for i := range s.shards {
+		s.rlock(i)
+		for key, e := range s.shards[i] {
+			dst[key] = e.value
+		}
+		s.locks[i].RUnlock()
+	}
+}
+
 func (s *shardedMap[K, V]) Set(key K, value V) {
 	shardIndex := s.shardIndex(key)
-	s.locks[shardIndex].Lock()
+	s.lock(shardIndex)
 	defer s.locks[shardIndex].Unlock()
-	s.shards[shardIndex][key] = value
+	s.store(shardIndex, key, value)
+}
+
+// store writes key into shard i and, on a bounded map, marks it as most
+// recent and evicts the shard's oldest keys beyond the limit. The caller must
+// hold the shard's write lock; so must the callers of accessed and remove.
+func (s *shardedMap[K, V]) store(i int, key K, value V) {
+	s.put(i, key, value, 0, true)
+}
+
+// put is store with an optional claim deadline (unix nanos, 0 for none) and
+// the choice to skip the journal, which Replay needs.
+func (s *shardedMap[K, V]) put(i int, key K, value V, deadline int64, journal bool) {
+	e, ok := s.shards[i][key]
+	if ok {
+		e.value = value
+	} else {
+		e = &entry[K, V]{key: key, value: value}
+		s.shards[i][key] = e
+	}
+	if deadline > 0 {
+		s.expiries[i][key] = deadline
+	} else if len(s.expiries[i]) > 0 {
+		delete(s.expiries[i], key)
+	}
+	if journal && s.journal != nil {
+		s.journal.write(journalEntry[K, V]{Op: opSet, Key: key, Value: value, Deadline: deadline})
+	}
+	if s.lrus == nil {
+		return
+	}
+	s.lrus[i].touch(e)
+	for len(s.shards[i]) > s.maxEntriesPerShard {
+		s.drop(i, s.lrus[i].oldest().key, journal)
+		s.counters[i].evictions.Add(1)
+	}
+}
+
+// lookup reads key from shard i, hiding claims whose TTL has passed. The
+// caller must hold the shard's lock (read or write).
+func (s *shardedMap[K, V]) lookup(i int, key K) (V, bool) {
+	if e := s.find(i, key); e != nil {
+		return e.value, true
+	}
+	return *new(V), false
+}
+
+// find is lookup returning the entry itself, or nil.
+func (s *shardedMap[K, V]) find(i int, key K) *entry[K, V] {
+	e, ok := s.shards[i][key]
+	if !ok {
+		return nil
+	}
+	if len(s.expiries[i]) > 0 {
+		if deadline, claimed := s.expiries[i][key]; claimed && time.Now().UnixNano() >= deadline {
+			return nil
+		}
+	}
+	return e
+}
+
+func (s *shardedMap[K, V]) accessed(i int, e *entry[K, V]) {
+	if s.lrus != nil {
+		s.lrus[i].touch(e)
+	}
+}
+
+func (s *shardedMap[K, V]) remove(i int, key K) {
+	s.drop(i, key, true)
+}
+
+// drop removes key from shard i. Absent keys are not journaled, so deleting
+// keys that were never set does not grow the journal.
+func (s *shardedMap[K, V]) drop(i int, key K, journal bool) {
+	e, ok := s.shards[i][key]
+	if !ok {
+		return
+	}
+	if journal && s.journal != nil {
+		s.journal.write(journalEntry[K, V]{Op: opDelete, Key: key})
+	}
+	delete(s.shards[i], key)
+	if len(s.expiries[i]) > 0 {
+		delete(s.expiries[i], key)
+	}
+	if s.lrus != nil {
+		s.lrus[i].unlink(e)
+	}
 }
 
 func (s *shardedMap[K, V]) shardIndex(key K) int {
-	hashFn := fnv.New64a()
-	hashFn.Write([]byte(fmt.Sprintf("%v", key)))
-	return int(hashFn.Sum64() % uint64(len(s.shards)))
+	if s.useMask {
+		return int(s.hasher(key) & s.mask)
+	}
+	return int(s.hasher(key) % uint64(len(s.shards)))
 }
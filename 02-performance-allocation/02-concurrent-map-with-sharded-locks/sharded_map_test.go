@@ -1,9 +1,18 @@
 package concurrentmapwithshardedlocks
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // =============================================================================
@@ -83,6 +92,711 @@ func TestShardedMap_IntKeys(t *testing.T) {
 	}
 }
 
+func TestShardedMap_WithHasher(t *testing.T) {
+	type uuid [16]byte
+	var calls int
+	m := NewShardedMapWithHasher[uuid, int](16, func(k uuid) uint64 {
+		calls++
+		return binary.LittleEndian.Uint64(k[:8])
+	})
+
+	for i := 0; i < 100; i++ {
+		var k uuid
+		k[0] = byte(i)
+		m.Set(k, i)
+	}
+	for i := 0; i < 100; i++ {
+		var k uuid
+		k[0] = byte(i)
+		if val, ok := m.Get(k); !ok || val != i {
+			t.Errorf("Get(%v) = %v, %v; want %d, true", k, val, ok, i)
+		}
+	}
+	if calls != 200 {
+		t.Errorf("hasher called %d times; want 200", calls)
+	}
+}
+
+func TestShardedMap_LenAndShardSizes(t *testing.T) {
+	m := NewShardedMap[int, int](8)
+	if m.Len() != 0 {
+		t.Errorf("Len() on empty map = %d; want 0", m.Len())
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	m.Set(0, 42) // update must not change the count
+	m.Delete(1)
+
+	if m.Len() != 999 {
+		t.Errorf("Len() = %d; want 999", m.Len())
+	}
+
+	sizes := m.ShardSizes()
+	if len(sizes) != 8 {
+		t.Fatalf("ShardSizes() returned %d shards; want 8", len(sizes))
+	}
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	if total != 999 {
+		t.Errorf("sum of ShardSizes() = %d; want 999", total)
+	}
+}
+
+func TestShardedMap_GetOrSet(t *testing.T) {
+	m := NewShardedMap[string, int](8)
+
+	actual, loaded := m.GetOrSet("a", 1)
+	if loaded || actual != 1 {
+		t.Errorf("first GetOrSet = %v, %v; want 1, false", actual, loaded)
+	}
+	actual, loaded = m.GetOrSet("a", 2)
+	if !loaded || actual != 1 {
+		t.Errorf("second GetOrSet = %v, %v; want 1, true", actual, loaded)
+	}
+
+	calls := 0
+	compute := func() int { calls++; return 10 }
+	actual, loaded = m.GetOrCompute("b", compute)
+	if loaded || actual != 10 {
+		t.Errorf("first GetOrCompute = %v, %v; want 10, false", actual, loaded)
+	}
+	actual, loaded = m.GetOrCompute("b", compute)
+	if !loaded || actual != 10 {
+		t.Errorf("second GetOrCompute = %v, %v; want 10, true", actual, loaded)
+	}
+	if calls != 1 {
+		t.Errorf("compute called %d times; want 1", calls)
+	}
+}
+
+func TestShardedMap_GetOrSet_Concurrent(t *testing.T) {
+	m := NewShardedMap[int, int](16)
+	const numGoroutines = 64
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	wg.Add(numGoroutines)
+	for g := 0; g < numGoroutines; g++ {
+		go func(id int) {
+			defer wg.Done()
+			if _, loaded := m.GetOrSet(7, id); !loaded {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if winners != 1 {
+		t.Errorf("%d goroutines stored the key; want exactly 1", winners)
+	}
+}
+
+func TestShardedMap_AtomicUpdate(t *testing.T) {
+	m := NewShardedMap[string, int](8)
+	incr := func(old int, exists bool) (int, bool) { return old + 1, true }
+
+	if v, ok := m.Update("hits", incr); !ok || v != 1 {
+		t.Errorf("Update on missing key = %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := m.Update("hits", incr); !ok || v != 2 {
+		t.Errorf("Update on existing key = %v, %v; want 2, true", v, ok)
+	}
+
+	// Returning keep=false deletes the key.
+	v, ok := m.Update("hits", func(old int, exists bool) (int, bool) {
+		if !exists || old != 2 {
+			t.Errorf("fn got %v, %v; want 2, true", old, exists)
+		}
+		return 0, false
+	})
+	if ok || v != 0 {
+		t.Errorf("deleting Update = %v, %v; want 0, false", v, ok)
+	}
+	if _, ok := m.Get("hits"); ok {
+		t.Error("key still present after deleting Update")
+	}
+}
+
+func TestShardedMap_AtomicUpdate_ConcurrentCounter(t *testing.T) {
+	m := NewShardedMap[string, int](8)
+	const numGoroutines = 50
+	const numOperations = 200
+
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for g := 0; g < numGoroutines; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numOperations; i++ {
+				m.Update("counter", func(old int, _ bool) (int, bool) { return old + 1, true })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := m.Get("counter"); v != numGoroutines*numOperations {
+		t.Errorf("counter = %d; want %d", v, numGoroutines*numOperations)
+	}
+}
+
+func TestShardedMap_Items(t *testing.T) {
+	m := NewShardedMap[int, string](16)
+	for i := 0; i < 100; i++ {
+		m.Set(i, fmt.Sprint(i))
+	}
+
+	items := m.Items()
+	if len(items) != 100 {
+		t.Fatalf("Items() returned %d entries; want 100", len(items))
+	}
+	for i := 0; i < 100; i++ {
+		if items[i] != fmt.Sprint(i) {
+			t.Errorf("items[%d] = %q; want %q", i, items[i], fmt.Sprint(i))
+		}
+	}
+
+	// The snapshot is a copy: later writes must not leak into it.
+	m.Set(0, "changed")
+	if items[0] != "0" {
+		t.Errorf("snapshot mutated by later Set: %q", items[0])
+	}
+
+	dst := map[int]string{-1: "kept"}
+	m.CloneInto(dst)
+	if len(dst) != 101 || dst[-1] != "kept" || dst[0] != "changed" {
+		t.Errorf("CloneInto produced unexpected map (len %d)", len(dst))
+	}
+}
+
+func TestShardedMap_Stats(t *testing.T) {
+	m := NewShardedMap[int, int](4)
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+		m.Get(i)
+	}
+
+	stats := m.Stats()
+	if len(stats.Shards) != 4 {
+		t.Fatalf("Stats() returned %d shards; want 4", len(stats.Shards))
+	}
+	var entries int
+	var acquisitions uint64
+	for _, sh := range stats.Shards {
+		entries += sh.Entries
+		acquisitions += sh.LockAcquisitions
+		if sh.LockWait != 0 {
+			t.Errorf("LockWait = %v without WithLockTiming; want 0", sh.LockWait)
+		}
+	}
+	if entries != 100 {
+		t.Errorf("total entries = %d; want 100", entries)
+	}
+	if acquisitions != 200 {
+		t.Errorf("total lock acquisitions = %d; want 200", acquisitions)
+	}
+}
+
+func TestShardedMap_Stats_LockTiming(t *testing.T) {
+	m := NewShardedMap[int, int](1, WithLockTiming())
+
+	var wg sync.WaitGroup
+	wg.Add(8)
+	for g := 0; g < 8; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.Set(i, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sh := m.Stats().Shards[0]
+	if sh.LockAcquisitions != 8000 {
+		t.Errorf("LockAcquisitions = %d; want 8000", sh.LockAcquisitions)
+	}
+	if sh.LockWait <= 0 {
+		t.Errorf("LockWait = %v with WithLockTiming; want > 0", sh.LockWait)
+	}
+}
+
+func TestShardedMap_KeysSeq(t *testing.T) {
+	m := NewShardedMap[int, int](16)
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	seen := make(map[int]bool)
+	for key := range m.KeysSeq() {
+		if seen[key] {
+			t.Errorf("key %d yielded twice", key)
+		}
+		seen[key] = true
+		// No lock is held while yielding, so re-entrant calls must not deadlock.
+		m.Set(key, key+1)
+	}
+	if len(seen) != 1000 {
+		t.Errorf("KeysSeq() yielded %d keys; want 1000", len(seen))
+	}
+
+	n := 0
+	for range m.KeysSeq() {
+		n++
+		if n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Errorf("early break yielded %d keys; want 10", n)
+	}
+}
+
+func TestShardedMap_Auto(t *testing.T) {
+	m := NewShardedMapAuto[int, int]()
+	n := len(m.ShardSizes())
+	if n < runtime.GOMAXPROCS(0) || n&(n-1) != 0 {
+		t.Errorf("auto shard count = %d; want a power of two >= GOMAXPROCS (%d)", n, runtime.GOMAXPROCS(0))
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 1000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Get(%d) = %v, %v; want %d, true", i, val, ok, i)
+		}
+	}
+}
+
+func TestShardedMap_ShardIndexInRange(t *testing.T) {
+	for _, numShards := range []uint{1, 3, 8, 10, 64} {
+		m := NewShardedMap[int, int](numShards).(*shardedMap[int, int])
+		for i := 0; i < 1000; i++ {
+			if idx := m.shardIndex(i); idx < 0 || idx >= int(numShards) {
+				t.Fatalf("shardIndex(%d) = %d with %d shards", i, idx, numShards)
+			}
+		}
+	}
+}
+
+func TestShardedMap_ZeroShardsPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewShardedMap(0) did not panic")
+		}
+	}()
+	NewShardedMap[int, int](0)
+}
+
+type pooledReading struct {
+	SensorID string
+	Values   [8]float64
+	Count    int
+}
+
+func TestShardedMap_SetPooled(t *testing.T) {
+	m := NewShardedMap[int, *pooledReading](8)
+	pool := NewValuePool[pooledReading]()
+
+	SetPooled(m, pool, 1, func(r *pooledReading) {
+		r.SensorID = "s1"
+		r.Count = 1
+	})
+	got, ok := m.Get(1)
+	if !ok || got.SensorID != "s1" || got.Count != 1 {
+		t.Fatalf("Get(1) = %+v, %v; want s1/1", got, ok)
+	}
+
+	first := got
+	old, loaded := SetPooled(m, pool, 1, func(r *pooledReading) {
+		r.SensorID = "s1"
+		r.Count = 2
+	})
+	if !loaded || old != first || first.Count != 1 {
+		t.Fatalf("SetPooled returned %+v, %v; want the untouched first value", old, loaded)
+	}
+
+	// Nothing else holds first, so it may go back to the pool.
+	pool.Release(first)
+	if first.SensorID != "" || first.Count != 0 {
+		t.Errorf("released value was not zeroed: %+v", first)
+	}
+	if r := pool.Acquire(); r.SensorID != "" || r.Count != 0 {
+		t.Errorf("Acquire returned a dirty value: %+v", r)
+	}
+}
+
+// Readers holding a value from Get must never see it recycled under them.
+func TestShardedMap_SetPooledConcurrentGet(t *testing.T) {
+	m := NewShardedMap[int, *pooledReading](8)
+	pool := NewValuePool[pooledReading]()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if r, ok := m.Get(1); ok && r.SensorID != "s" {
+					t.Errorf("reader saw a recycled value: %+v", *r)
+					return
+				}
+			}
+		}()
+	}
+	for i := range 10_000 {
+		SetPooled(m, pool, 1, func(r *pooledReading) {
+			r.SensorID = "s"
+			r.Count = i
+		})
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestShardedMap_SwapAndLoadAndDelete(t *testing.T) {
+	m := NewShardedMap[string, int](8)
+
+	if prev, loaded := m.Swap("a", 1); loaded || prev != 0 {
+		t.Errorf("Swap on missing key = %v, %v; want 0, false", prev, loaded)
+	}
+	if prev, loaded := m.Swap("a", 2); !loaded || prev != 1 {
+		t.Errorf("Swap on existing key = %v, %v; want 1, true", prev, loaded)
+	}
+	if v, loaded := m.LoadAndDelete("a"); !loaded || v != 2 {
+		t.Errorf("LoadAndDelete = %v, %v; want 2, true", v, loaded)
+	}
+	if _, loaded := m.LoadAndDelete("a"); loaded {
+		t.Error("LoadAndDelete on missing key reported loaded")
+	}
+}
+
+func TestShardedMap_SortedKeys(t *testing.T) {
+	m := NewShardedMap[int, string](16)
+	for _, i := range rand.Perm(100) {
+		m.Set(i, fmt.Sprint(i))
+	}
+
+	keys := m.SortedKeys(func(a, b int) bool { return a < b })
+	if len(keys) != 100 {
+		t.Fatalf("SortedKeys() returned %d keys; want 100", len(keys))
+	}
+	for i, key := range keys {
+		if key != i {
+			t.Fatalf("keys[%d] = %d; want %d", i, key, i)
+		}
+	}
+
+	var got []int
+	m.RangeOrdered(func(a, b int) bool { return a > b }, func(key int, value string) bool {
+		if value != fmt.Sprint(key) {
+			t.Errorf("value for %d = %q", key, value)
+		}
+		got = append(got, key)
+		return len(got) < 3
+	})
+	if want := []int{99, 98, 97}; !slices.Equal(got, want) {
+		t.Errorf("RangeOrdered visited %v; want %v", got, want)
+	}
+}
+
+func TestShardedMap_MaxEntriesPerShard(t *testing.T) {
+	m := NewShardedMap[string, int](1, WithMaxEntriesPerShard(3))
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Get("a")    // a is now the most recent
+	m.Set("d", 4) // evicts b, the least recently used
+	m.Update("c", func(old int, _ bool) (int, bool) { return old + 1, true })
+	m.Set("e", 5) // evicts a
+
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true, "e": true} {
+		if _, ok := m.Get(key); ok != want {
+			t.Errorf("Get(%q) present = %v; want %v", key, ok, want)
+		}
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len() = %d; want 3", m.Len())
+	}
+	if ev := m.Stats().Shards[0].Evictions; ev != 2 {
+		t.Errorf("Evictions = %d; want 2", ev)
+	}
+
+	// Deleting frees a slot without evicting.
+	m.Delete("c")
+	m.Set("f", 6)
+	if ev := m.Stats().Shards[0].Evictions; ev != 2 {
+		t.Errorf("Evictions after Delete+Set = %d; want 2", ev)
+	}
+}
+
+func TestShardedMap_MaxEntriesPerShard_Concurrent(t *testing.T) {
+	const maxEntries = 16
+	m := NewShardedMap[int, int](8, WithMaxEntriesPerShard(maxEntries))
+
+	var wg sync.WaitGroup
+	wg.Add(16)
+	for g := 0; g < 16; g++ {
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := id*1000 + i
+				m.Set(key, i)
+				m.Get(key - 1)
+				if i%10 == 0 {
+					m.Delete(key - 5)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i, n := range m.ShardSizes() {
+		if n > maxEntries {
+			t.Errorf("shard %d holds %d entries; want <= %d", i, n, maxEntries)
+		}
+	}
+}
+
+func TestShardedMap_SetIfAbsentTTL(t *testing.T) {
+	m := NewShardedMap[string, string](8)
+
+	if !m.SetIfAbsentTTL("lock", "worker-1", 20*time.Millisecond) {
+		t.Fatal("first claim failed")
+	}
+	if m.SetIfAbsentTTL("lock", "worker-2", 20*time.Millisecond) {
+		t.Error("second claim succeeded while the lease is held")
+	}
+	if v, ok := m.Get("lock"); !ok || v != "worker-1" {
+		t.Errorf("Get(lock) = %q, %v; want worker-1, true", v, ok)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := m.Get("lock"); ok {
+		t.Error("expired lease still visible to Get")
+	}
+	if !m.SetIfAbsentTTL("lock", "worker-2", time.Hour) {
+		t.Error("claim of an expired lease failed")
+	}
+
+	// Delete releases early.
+	m.Delete("lock")
+	if !m.SetIfAbsentTTL("lock", "worker-3", time.Millisecond) {
+		t.Error("claim after Delete failed")
+	}
+
+	// A plain Set makes the entry permanent.
+	m.Set("lock", "owner")
+	time.Sleep(5 * time.Millisecond)
+	if v, ok := m.Get("lock"); !ok || v != "owner" {
+		t.Errorf("Get after Set = %q, %v; want owner, true", v, ok)
+	}
+	if m.SetIfAbsentTTL("lock", "worker-4", time.Hour) {
+		t.Error("claim succeeded over a permanent entry")
+	}
+}
+
+func TestShardedMap_SetIfAbsentTTL_Concurrent(t *testing.T) {
+	m := NewShardedMap[string, int](8)
+	const numGoroutines = 64
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	wg.Add(numGoroutines)
+	for g := 0; g < numGoroutines; g++ {
+		go func(id int) {
+			defer wg.Done()
+			if m.SetIfAbsentTTL("job", id, time.Minute) {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if winners != 1 {
+		t.Errorf("%d goroutines claimed the key; want exactly 1", winners)
+	}
+}
+
+func TestCounterMap_IncrBy(t *testing.T) {
+	m := NewCounterMap[string, int64](8)
+
+	if v := m.IncrBy("/users", 5); v != 5 {
+		t.Errorf("IncrBy on missing key = %d; want 5", v)
+	}
+	if v := m.DecrBy("/users", 2); v != 3 {
+		t.Errorf("DecrBy = %d; want 3", v)
+	}
+	if v, ok := m.Get("/users"); !ok || v != 3 {
+		t.Errorf("Get(/users) = %d, %v; want 3, true", v, ok)
+	}
+
+	f := NewCounterMap[int, float64](4)
+	f.IncrBy(1, 0.5)
+	if v := f.IncrBy(1, 0.25); v != 0.75 {
+		t.Errorf("float IncrBy = %v; want 0.75", v)
+	}
+}
+
+func TestCounterMap_IncrBy_Concurrent(t *testing.T) {
+	m := NewCounterMap[string, int](8)
+	const numGoroutines = 50
+	const numOperations = 1000
+
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for g := 0; g < numGoroutines; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numOperations; i++ {
+				m.IncrBy("hits", 2)
+				m.DecrBy("hits", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := m.Get("hits"); v != numGoroutines*numOperations {
+		t.Errorf("hits = %d; want %d", v, numGoroutines*numOperations)
+	}
+}
+
+func TestBytesShardedMap(t *testing.T) {
+	m := NewBytesShardedMap[int](16)
+	buf := []byte("session-1")
+
+	m.Set(buf, 1)
+	// Reusing the caller's buffer must not corrupt the stored key.
+	copy(buf, "XXXXXXXXX")
+	if _, ok := m.Get(buf); ok {
+		t.Error("Get(XXXXXXXXX) found a key that was never set")
+	}
+	if v, ok := m.Get([]byte("session-1")); !ok || v != 1 {
+		t.Errorf("Get(session-1) = %d, %v; want 1, true", v, ok)
+	}
+
+	m.Set([]byte("session-2"), 2)
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", m.Len())
+	}
+	m.Delete([]byte("session-1"))
+	if _, ok := m.Get([]byte("session-1")); ok {
+		t.Error("key still present after Delete")
+	}
+}
+
+func TestBytesShardedMap_GetDoesNotAllocate(t *testing.T) {
+	m := NewBytesShardedMap[int](16)
+	key := []byte("sensor-42")
+	m.Set(key, 42)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		m.Get(key)
+	})
+	if allocs != 0 {
+		t.Errorf("Get allocated %.1f times per call; want 0", allocs)
+	}
+}
+
+func TestShardedMap_JournalReplay(t *testing.T) {
+	var journal bytes.Buffer
+	m := NewShardedMap[string, int](8, WithJournal(&journal))
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Update("a", func(old int, _ bool) (int, bool) { return old + 10, true })
+	m.Delete("b")
+	m.GetOrSet("c", 3)
+	m.SetIfAbsentTTL("lease", 4, time.Hour)
+	m.SetIfAbsentTTL("stale", 5, time.Nanosecond)
+	if err := m.JournalErr(); err != nil {
+		t.Fatalf("JournalErr() = %v", err)
+	}
+
+	restored := NewShardedMap[string, int](4)
+	if err := restored.Replay(bytes.NewReader(journal.Bytes())); err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+
+	want := map[string]int{"a": 11, "c": 3, "lease": 4}
+	if got := restored.Items(); !maps.Equal(got, want) {
+		t.Errorf("restored items = %v; want %v", got, want)
+	}
+	if restored.SetIfAbsentTTL("lease", 0, time.Hour) {
+		t.Error("replayed lease was not restored as a claim")
+	}
+}
+
+func TestShardedMap_JournalEvictions(t *testing.T) {
+	var journal bytes.Buffer
+	m := NewShardedMap[int, int](1, WithMaxEntriesPerShard(2), WithJournal(&journal))
+	for i := 0; i < 5; i++ {
+		m.Set(i, i)
+	}
+
+	restored := NewShardedMap[int, int](1)
+	if err := restored.Replay(&journal); err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	if want := map[int]int{3: 3, 4: 4}; !maps.Equal(restored.Items(), want) {
+		t.Errorf("restored items = %v; want %v", restored.Items(), want)
+	}
+}
+
+func TestShardedMap_JournalSkipsAbsentDeletes(t *testing.T) {
+	var journal bytes.Buffer
+	m := NewShardedMap[string, int](4, WithJournal(&journal))
+	m.Delete("missing")
+	m.LoadAndDelete("missing")
+	m.Update("missing", func(int, bool) (int, bool) { return 0, false })
+	if journal.Len() != 0 {
+		t.Errorf("journal after deleting absent keys = %q; want empty", journal.String())
+	}
+
+	m.Set("a", 1)
+	m.Delete("a")
+	if got := strings.Count(journal.String(), "\n"); got != 2 {
+		t.Errorf("journal has %d entries after Set and Delete; want 2", got)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestShardedMap_JournalErrors(t *testing.T) {
+	m := NewShardedMap[string, int](4, WithJournal(failingWriter{}))
+	m.Set("a", 1)
+	if err := m.JournalErr(); err == nil {
+		t.Error("JournalErr() = nil after a failed write")
+	}
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Errorf("journal failure affected the map: Get(a) = %v, %v", v, ok)
+	}
+
+	err := NewShardedMap[string, int](4).Replay(strings.NewReader(`{"op":"set","key":"a","value":1}` + "\n" + `{"op":"bogus","key":"a"}`))
+	if err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("Replay() of a bad journal = %v; want an error for entry 2", err)
+	}
+}
+
 // =============================================================================
 // Race Test - Run with `go test -race`
 // Tests concurrent read/write/delete operations for data races
@@ -200,6 +914,19 @@ func benchmarkContention(b *testing.B, numShards uint) {
 	})
 }
 
+func BenchmarkContention_Auto(b *testing.B) {
+	m := NewShardedMapAuto[int, int]()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i, i)
+			i++
+		}
+	})
+}
+
 // Benchmark for sequential key access pattern (worst case for sharding)
 func BenchmarkContention_SequentialKeys_1Shard(b *testing.B) {
 	benchmarkContentionSequential(b, 1)
@@ -292,6 +1019,38 @@ func TestShardedMap_MemoryUsage(t *testing.T) {
 	}
 }
 
+func BenchmarkKeys_Slice(b *testing.B) {
+	m := NewShardedMap[int, int](64)
+	for i := 0; i < 100_000; i++ {
+		m.Set(i, i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		for _, key := range m.Keys() {
+			_ = key
+		}
+	}
+}
+
+func BenchmarkKeys_Seq(b *testing.B) {
+	m := NewShardedMap[int, int](64)
+	for i := 0; i < 100_000; i++ {
+		m.Set(i, i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		for key := range m.KeysSeq() {
+			_ = key
+		}
+	}
+}
+
 func BenchmarkMemory_ShardedMap(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		m := NewShardedMap[int, any](64)
@@ -310,6 +1069,45 @@ func BenchmarkMemory_BaselineMap(b *testing.B) {
 	}
 }
 
+func intHasher(k int) uint64 {
+	return uint64(k) * 0x9E3779B97F4A7C15
+}
+
+func BenchmarkChurn_New(b *testing.B) {
+	m := NewShardedMapWithHasher[int, *pooledReading](64, intHasher)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			r := &pooledReading{SensorID: "s", Count: i}
+			m.Set(i%1024, r)
+			i++
+		}
+	})
+}
+
+func BenchmarkChurn_Pooled(b *testing.B) {
+	m := NewShardedMapWithHasher[int, *pooledReading](64, intHasher)
+	pool := NewValuePool[pooledReading]()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		fill := func(r *pooledReading) {
+			r.SensorID = "s"
+			r.Count = i
+		}
+		for pb.Next() {
+			// Nothing reads the map, so the swapped-out value is ours alone.
+			if old, loaded := SetPooled(m, pool, i%1024, fill); loaded {
+				pool.Release(old)
+			}
+			i++
+		}
+	})
+}
+
 // =============================================================================
 // Read-Heavy Workload Benchmark (95% reads, 5% writes as per README scenario)
 // =============================================================================
@@ -373,3 +1171,62 @@ func BenchmarkSet_Allocations(b *testing.B) {
 		m.Set(i%1000, i)
 	}
 }
+
+func BenchmarkGet_Allocations_WithHasher(b *testing.B) {
+	m := NewShardedMapWithHasher[int, int](64, func(k int) uint64 {
+		return uint64(k) * 0x9E3779B97F4A7C15
+	})
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m.Get(i % 1000)
+	}
+}
+
+func BenchmarkCounterMap_IncrBy(b *testing.B) {
+	m := NewCounterMap[int, int64](64)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m.IncrBy(i%1000, 1)
+	}
+}
+
+func BenchmarkBytesShardedMap_Get(b *testing.B) {
+	m := NewBytesShardedMap[int](64)
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+		m.Set(keys[i], i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m.Get(keys[i%1000])
+	}
+}
+
+func BenchmarkBytesKeys_StringConversion(b *testing.B) {
+	m := NewShardedMap[string, int](64)
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+		m.Set(string(keys[i]), i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m.Get(string(keys[i%1000]))
+	}
+}
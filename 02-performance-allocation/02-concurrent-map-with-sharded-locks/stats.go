@@ -0,0 +1,66 @@
+package concurrentmapwithshardedlocks
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a per-shard view of load and contention. A healthy key
+// distribution shows roughly equal Entries and LockAcquisitions everywhere.
+type Stats struct {
+	Shards []ShardStats
+}
+
+type ShardStats struct {
+	Entries          int
+	LockAcquisitions uint64
+	// LockWait is the cumulative time spent waiting for the shard lock.
+	// It stays zero unless the map was built WithLockTiming.
+	LockWait time.Duration
+	// Evictions counts keys dropped by WithMaxEntriesPerShard.
+	Evictions uint64
+}
+
+// shardCounters is padded to a cache line so that neighbouring shards don't
+// contend on the same line when their counters are bumped.
+type shardCounters struct {
+	acquisitions atomic.Uint64
+	waitNanos    atomic.Int64
+	evictions    atomic.Uint64
+	_            [40]byte
+}
+
+func (s *shardedMap[K, V]) Stats() Stats {
+	stats := Stats{Shards: make([]ShardStats, len(s.shards))}
+	for i := range s.shards {
+		s.locks[i].RLock()
+		stats.Shards[i].Entries = len(s.shards[i])
+		s.locks[i].RUnlock()
+		stats.Shards[i].LockAcquisitions = s.counters[i].acquisitions.Load()
+		stats.Shards[i].LockWait = time.Duration(s.counters[i].waitNanos.Load())
+		stats.Shards[i].Evictions = s.counters[i].evictions.Load()
+	}
+	return stats
+}
+
+func (s *shardedMap[K, V]) lock(i int) {
+	if s.lockTiming {
+		start := time.Now()
+		s.locks[i].Lock()
+		s.counters[i].waitNanos.Add(int64(time.Since(start)))
+	} else {
+		s.locks[i].Lock()
+	}
+	s.counters[i].acquisitions.Add(1)
+}
+
+func (s *shardedMap[K, V]) rlock(i int) {
+	if s.lockTiming {
+		start := time.Now()
+		s.locks[i].RLock()
+		s.counters[i].waitNanos.Add(int64(time.Since(start)))
+	} else {
+		s.locks[i].RLock()
+	}
+	s.counters[i].acquisitions.Add(1)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// WindowStats summarises every reading of one sensor within one window.
+type WindowStats struct {
+	SensorID string    `json:"sensor_id"`
+	Start    time.Time `json:"window_start"`
+	End      time.Time `json:"window_end"`
+	Count    int       `json:"count"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Mean     float64   `json:"mean"`
+}
+
+// WindowSink receives each closed window. Returning an error stops the
+// Aggregator.
+type WindowSink func(WindowStats) error
+
+// NewWriterSink writes each window to w as one JSON line.
+func NewWriterSink(w io.Writer) WindowSink {
+	enc := json.NewEncoder(w)
+	return func(ws WindowStats) error {
+		return enc.Encode(ws)
+	}
+}
+
+// Aggregator groups readings into per-sensor tumbling windows of a fixed
+// length, aligned to the Unix epoch and keyed by each record's Timestamp
+// (Unix seconds; records without one are placed by arrival time). All
+// sensors share one clock: the first record of a later window closes
+// every open window, and records for an already closed window are counted
+// as late and dropped. An Aggregator is not safe for concurrent use.
+type Aggregator struct {
+	window int64 // seconds
+	sink   WindowSink
+	now    func() time.Time
+
+	current int64 // start of the open window
+	open    map[string]*windowAcc
+	late    uint64
+}
+
+type windowAcc struct {
+	count         int
+	min, max, sum float64
+}
+
+// NewAggregator returns an Aggregator over windows of the given length,
+// which is truncated to whole seconds and must be at least one.
+func NewAggregator(window time.Duration, sink WindowSink) *Aggregator {
+	return &Aggregator{
+		window:  max(int64(window/time.Second), 1),
+		sink:    sink,
+		now:     time.Now,
+		current: math.MinInt64,
+		open:    make(map[string]*windowAcc),
+	}
+}
+
+// Add folds every reading of d into its sensor's window, first emitting the
+// windows d's timestamp has closed.
+func (a *Aggregator) Add(d *SensorData) error {
+	ts := d.Timestamp
+	if ts == 0 {
+		ts = a.now().Unix()
+	}
+	start := ts - mod(ts, a.window)
+
+	switch {
+	case start < a.current:
+		a.late++
+		return nil
+	case start > a.current:
+		if err := a.Flush(); err != nil {
+			return err
+		}
+		a.current = start
+	}
+
+	acc, ok := a.open[d.SensorID]
+	if !ok {
+		acc = &windowAcc{min: math.Inf(1), max: math.Inf(-1)}
+		a.open[d.SensorID] = acc
+	}
+	for _, v := range d.Readings {
+		acc.count++
+		acc.sum += v
+		acc.min = min(acc.min, v)
+		acc.max = max(acc.max, v)
+	}
+	return nil
+}
+
+// Flush emits the open windows, ordered by sensor ID, and clears them.
+func (a *Aggregator) Flush() error {
+	ids := make([]string, 0, len(a.open))
+	for id := range a.open {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, cmp.Compare)
+
+	start := time.Unix(a.current, 0)
+	end := time.Unix(a.current+a.window, 0)
+	for _, id := range ids {
+		acc := a.open[id]
+		delete(a.open, id)
+		if acc.count == 0 {
+			continue
+		}
+		err := a.sink(WindowStats{
+			SensorID: id,
+			Start:    start,
+			End:      end,
+			Count:    acc.count,
+			Min:      acc.min,
+			Max:      acc.max,
+			Mean:     acc.sum / float64(acc.count),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Late returns how many records arrived after their window had closed.
+func (a *Aggregator) Late() uint64 {
+	return a.late
+}
+
+// Run aggregates records, e.g. from ParseStream, until the channel is
+// closed, then flushes. Each record is released after it is added.
+func (a *Aggregator) Run(ctx context.Context, records <-chan *SensorData) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-records:
+			if !ok {
+				return a.Flush()
+			}
+			err := a.Add(d)
+			d.Release()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// mod is the floored modulus, so windows align for negative times too.
+func mod(a, b int64) int64 {
+	return (a%b + b) % b
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// WithBufferSize reads the input ahead of the parser into a ring of n bytes,
+// so a slow network feed is fetched while records are being processed, yet
+// never more than n bytes sit between the source and the parser. Reading
+// ahead pauses at the high watermark, a full ring, and resumes only once the
+// parser has drained it to the low watermark, a quarter of n, so a fast
+// producer is pushed back on in large steps rather than byte by byte.
+//
+// The ring is read on a helper goroutine, which exits whenever the ring is
+// full or the input ends; only a Read blocked in the source outlives the
+// parser. With WithInterruptibleReads the source is then interrupted
+// through that goroutine, not through SetReadDeadline. Zero means no ring.
+func WithBufferSize(n int) Option {
+	return func(sp *SensorParser) {
+		sp.bufferSize = n
+	}
+}
+
+// ringBuffer is the bounded buffer behind WithBufferSize. buf[head:] and
+// buf[:head+size-len(buf)] hold the unread bytes; the rest is free for fill.
+type ringBuffer struct {
+	r         io.Reader
+	low, high int
+
+	mu      sync.Mutex
+	ready   sync.Cond // signalled when fill adds data or stops
+	buf     []byte
+	head    int
+	size    int
+	err     error // sticky source error, returned once buf is drained
+	filling bool
+}
+
+func newRingBuffer(r io.Reader, n int) *ringBuffer {
+	b := &ringBuffer{r: r, buf: make([]byte, n), low: n / 4, high: n}
+	b.ready.L = &b.mu
+	return b
+}
+
+func (b *ringBuffer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.size == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.startFill()
+		b.ready.Wait()
+	}
+
+	n := copy(p, b.buf[b.head:min(len(b.buf), b.head+b.size)])
+	if n < len(p) && n < b.size {
+		n += copy(p[n:], b.buf[:b.size-n])
+	}
+	b.head = (b.head + n) % len(b.buf)
+	b.size -= n
+	if b.size <= b.low {
+		b.startFill()
+	}
+	return n, nil
+}
+
+// startFill starts reading ahead unless that is already under way. The
+// caller must hold b.mu.
+func (b *ringBuffer) startFill() {
+	if b.filling || b.err != nil {
+		return
+	}
+	b.filling = true
+	go b.fill()
+}
+
+// fill reads from the source until the ring reaches the high watermark or
+// the source fails. Only fill writes to the free part of buf, so it reads
+// into it without holding the lock.
+func (b *ringBuffer) fill() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.size < b.high && b.err == nil {
+		tail := (b.head + b.size) % len(b.buf)
+		free := b.buf[tail:min(len(b.buf), tail+b.high-b.size)]
+
+		b.mu.Unlock()
+		n, err := b.r.Read(free)
+		b.mu.Lock()
+
+		b.size += n
+		if err != nil {
+			b.err = err
+		} else if n == 0 {
+			b.err = io.ErrNoProgress
+		}
+		b.ready.Broadcast()
+	}
+	b.filling = false
+	b.ready.Broadcast()
+}
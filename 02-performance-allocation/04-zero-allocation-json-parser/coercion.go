@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Coercion decides whether a field may arrive as a different JSON type.
+type Coercion uint8
+
+const (
+	// CoercionStrict, the default, requires every field's own JSON type.
+	// Numbers may still be integers or use scientific notation.
+	CoercionStrict Coercion = iota
+	// CoercionLenient also accepts numeric strings ("22.1") for readings
+	// and timestamps, and a number for sensor_id, which is formatted
+	// without exponent or trailing zeros (exact up to 2^53).
+	CoercionLenient
+)
+
+// WithCoercion sets how tolerant field decoding is; real devices are rarely
+// consistent about quoting numbers.
+func WithCoercion(c Coercion) Option {
+	return func(sp *SensorParser) {
+		sp.coercion = c
+	}
+}
+
+func (sp *SensorParser) asFloat(t json.Token) (float64, bool) {
+	switch v := t.(type) {
+	case float64:
+		return v, true
+	case string:
+		return sp.quotedFloat(v)
+	}
+	return 0, false
+}
+
+// quotedFloat parses a number that arrived as a string.
+func (sp *SensorParser) quotedFloat(s string) (float64, bool) {
+	if sp.coercion != CoercionLenient {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+func (sp *SensorParser) asString(t json.Token) (string, bool) {
+	switch v := t.(type) {
+	case string:
+		return v, true
+	case float64:
+		return sp.numberString(v)
+	}
+	return "", false
+}
+
+// numberString formats a number that arrived where a string was expected.
+func (sp *SensorParser) numberString(f float64) (string, bool) {
+	if sp.coercion != CoercionLenient {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
@@ -0,0 +1,26 @@
+package main
+
+// maxFragmentBytes caps how much skipped input is handed to a
+// CorruptionHandler; a long run of garbage is still skipped in full.
+const maxFragmentBytes = 256
+
+// CorruptionHandler observes input the parser had to skip. offset is the
+// absolute stream position of the first skipped byte, fragment holds (up to
+// maxFragmentBytes of) the skipped bytes and err is what triggered the skip.
+// fragment is only valid for the duration of the call.
+type CorruptionHandler func(offset int64, fragment []byte, err error)
+
+// WithCorruptionHandler makes resync report what it discards instead of
+// dropping it silently. ParseStream calls the handler from its workers, so it
+// must be safe for concurrent use there.
+func WithCorruptionHandler(h CorruptionHandler) Option {
+	return func(sp *SensorParser) {
+		sp.onCorruption = h
+	}
+}
+
+// InputOffset returns the absolute stream offset of the parser: the number
+// of bytes consumed so far, including any skipped during resync.
+func (sp *SensorParser) InputOffset() int64 {
+	return sp.base + sp.dec.InputOffset()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CSVSensorSource reads SensorData from CSV with a header row. Columns are
+// matched to fields by header name through the same FieldSpec schema as the
+// JSON parser; several columns may feed TargetReadings (in column order),
+// and TargetMetadata columns become pairs keyed by the header.
+type CSVSensorSource struct {
+	r            *csv.Reader
+	fields       map[string]FieldSpec
+	required     targetSet
+	onCorruption CorruptionHandler
+
+	columns []csvColumn // resolved from the header on first use
+}
+
+type csvColumn struct {
+	name  string
+	spec  FieldSpec
+	known bool
+}
+
+type CSVOption func(s *CSVSensorSource)
+
+// WithCSVFields maps header names to fields. The default is DefaultFields.
+func WithCSVFields(fields map[string]FieldSpec) CSVOption {
+	return func(s *CSVSensorSource) {
+		s.fields = fields
+	}
+}
+
+// WithCSVComma sets the field delimiter, e.g. ';' or '\t'.
+func WithCSVComma(comma rune) CSVOption {
+	return func(s *CSVSensorSource) {
+		s.r.Comma = comma
+	}
+}
+
+// WithCSVCorruptionHandler reports skipped rows; err is a *LineError.
+func WithCSVCorruptionHandler(h CorruptionHandler) CSVOption {
+	return func(s *CSVSensorSource) {
+		s.onCorruption = h
+	}
+}
+
+func NewCSVSensorSource(r io.Reader, opts ...CSVOption) *CSVSensorSource {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	cr.FieldsPerRecord = -1 // short rows are checked against the schema instead
+
+	s := &CSVSensorSource{
+		r:      cr,
+		fields: DefaultFields(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.required = requiredTargets(s.fields)
+	return s
+}
+
+func (s *CSVSensorSource) Parse(ctx context.Context) (*SensorData, error) {
+	data := &SensorData{}
+	if err := s.ParseInto(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParseInto fills dst with the next valid row. Rows that are malformed CSV,
+// hold an unparsable number or miss a required column are skipped.
+func (s *CSVSensorSource) ParseInto(ctx context.Context, dst *SensorData) error {
+	if s.columns == nil {
+		if err := s.readHeader(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		offset := s.r.InputOffset()
+		record, err := s.r.Read()
+		if err == io.EOF {
+			return io.EOF
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			s.report(int64(perr.StartLine), offset, perr.Err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read csv: %w", err)
+		}
+
+		if err := s.fill(dst, record); err != nil {
+			line, _ := s.r.FieldPos(0)
+			s.report(int64(line), offset, err)
+			continue
+		}
+		return nil
+	}
+}
+
+func (s *CSVSensorSource) readHeader() error {
+	header, err := s.r.Read()
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("read csv header: %w", err)
+	}
+
+	// header is reused by the next Read, but its strings are not.
+	s.columns = make([]csvColumn, len(header))
+	for i, name := range header {
+		spec, ok := s.fields[name]
+		s.columns[i] = csvColumn{name: name, spec: spec, known: ok}
+	}
+	return nil
+}
+
+func (s *CSVSensorSource) fill(dst *SensorData, record []string) error {
+	dst.reset()
+	var seen targetSet
+
+	for i, field := range record[:min(len(record), len(s.columns))] {
+		col := s.columns[i]
+		if !col.known || field == "" {
+			continue
+		}
+
+		switch col.spec.Target {
+		case TargetSensorID:
+			dst.SensorID = field
+		case TargetReadings:
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %w", col.name, err)
+			}
+			dst.Readings = append(dst.Readings, v)
+		case TargetTimestamp:
+			ts, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %w", col.name, err)
+			}
+			dst.Timestamp = int64(ts)
+		case TargetMetadata:
+			dst.Metadata = append(dst.Metadata, MetadataPair{Key: col.name, Value: field})
+		default:
+			continue
+		}
+		seen.add(col.spec.Target)
+	}
+	if len(dst.Readings) > 0 {
+		dst.Value = dst.Readings[0]
+	}
+
+	if seen&s.required != s.required {
+		return missingField(s.fields, seen)
+	}
+	return nil
+}
+
+func (s *CSVSensorSource) report(line, offset int64, err error) {
+	if s.onCorruption != nil {
+		s.onCorruption(offset, nil, &LineError{Line: line, Offset: offset, Err: err})
+	}
+}
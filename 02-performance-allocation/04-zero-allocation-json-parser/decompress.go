@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression selects how the input is decompressed before parsing.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	// CompressionAuto sniffs the magic bytes and falls back to plain input.
+	// zstd input is only readable with WithZstdDecoder; without it the first
+	// read fails with ErrUnsupportedCompression.
+	CompressionAuto
+	CompressionGzip
+	// CompressionZstd requires WithZstdDecoder.
+	CompressionZstd
+)
+
+// ErrUnsupportedCompression is returned when the input needs a decoder the
+// parser does not have, i.e. zstd without WithZstdDecoder.
+var ErrUnsupportedCompression = errors.New("sensor parser: unsupported compression")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// WithDecompression wraps the input in a decompressor, so compressed sensor
+// archives go through the same streaming and resync path as plain ones.
+// Offsets reported by the parser refer to the decompressed stream.
+//
+// gzip works out of the box. zstd does not: the module ships no zstd
+// decoder, so zstd input, whether selected or detected, needs
+// WithZstdDecoder as well.
+func WithDecompression(c Compression) Option {
+	return func(sp *SensorParser) {
+		sp.compression = c
+	}
+}
+
+// WithZstdDecoder supplies the zstd implementation; the standard library has
+// none. newReader is typically a thin wrapper around a zstd package's reader.
+func WithZstdDecoder(newReader func(io.Reader) (io.Reader, error)) Option {
+	return func(sp *SensorParser) {
+		sp.newZstdReader = newReader
+	}
+}
+
+// decompressReader defers sniffing the input until the first Read, so that
+// NewSensorParser itself never blocks or fails on I/O.
+type decompressReader struct {
+	src           io.Reader
+	compression   Compression
+	newZstdReader func(io.Reader) (io.Reader, error)
+
+	r   io.Reader
+	err error
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.open()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *decompressReader) open() (io.Reader, error) {
+	br := bufio.NewReader(d.src)
+	c := d.compression
+	if c == CompressionAuto {
+		magic, err := br.Peek(len(zstdMagic))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		c = detectCompression(magic)
+	}
+
+	switch c {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip input: %w", err)
+		}
+		return zr, nil
+	case CompressionZstd:
+		if d.newZstdReader == nil {
+			return nil, fmt.Errorf("zstd input: %w", ErrUnsupportedCompression)
+		}
+		zr, err := d.newZstdReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("zstd input: %w", err)
+		}
+		return zr, nil
+	default:
+		return br, nil
+	}
+}
+
+func detectCompression(magic []byte) Compression {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
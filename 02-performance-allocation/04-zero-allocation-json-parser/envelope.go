@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// WithEnvelope reads records from the array at path inside each top-level
+// object: "events" for {"events": [...]}, "data.items" for
+// {"data": {"items": [...]}}. Other keys of the envelope are skipped. A bare
+// top-level array of records needs no option.
+//
+// Inside a batch a record that breaks the schema is skipped on its own, but
+// a JSON syntax error loses the rest of the batch, since the parser resyncs
+// past it to the next envelope. ModeStrict ignores the option.
+func WithEnvelope(path string) Option {
+	return func(sp *SensorParser) {
+		sp.envelope = nil
+		if path != "" {
+			sp.envelope = strings.Split(path, ".")
+		}
+	}
+}
+
+// schemaError marks a record that is well-formed JSON but does not fit the
+// schema. The decoder is unaffected, so the rest of the record can be
+// skipped token by token.
+type schemaError struct{ error }
+
+func (e schemaError) Unwrap() error { return e.error }
+
+var (
+	errNotEnvelope   = schemaError{errors.New("no record array at envelope path")}
+	errNotBatchEntry = schemaError{errors.New("batch entry is not an object")}
+)
+
+func (sp *SensorParser) parseEnvelope(ctx context.Context, dst *SensorData) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if sp.err != nil {
+			return sp.err
+		}
+
+		// A resync or interruption replaces the decoder, which ends the batch.
+		if sp.batch != sp.dec {
+			start := sp.InputOffset()
+			if err := sp.enterBatch(); err != nil {
+				if err := sp.envelopeError(start, err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		start := sp.InputOffset()
+		t, err := sp.dec.Token()
+		if err != nil {
+			if err := sp.envelopeError(start, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch t {
+		case json.Delim('{'):
+			sp.recordStart, sp.depth = sp.InputOffset()-1, 1
+			err := sp.parseObject(dst)
+			if err == nil {
+				if err := sp.validate(dst); err != nil {
+					if err := sp.reject(err); err != nil {
+						return err
+					}
+					continue
+				}
+				sp.stats.records.Add(1)
+				return nil
+			}
+			if errors.Is(err, ErrRecordTooLarge) {
+				return sp.recordTooLarge()
+			}
+			if isSchemaError(err) {
+				if err = sp.invalid(err); err == nil {
+					continue
+				} else if isRecordError(err) {
+					return err
+				}
+			}
+			if err := sp.envelopeError(sp.recordStart, err); err != nil {
+				return err
+			}
+		case json.Delim(']'):
+			sp.batch = nil
+			if err := sp.leaveEnvelope(); err != nil {
+				if err := sp.envelopeError(start, err); err != nil {
+					return err
+				}
+			}
+		default:
+			err := skipRawValue(sp.dec, t)
+			if err == nil {
+				err = errNotBatchEntry
+			}
+			if err := sp.envelopeError(start, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// envelopeError recovers from err, returning it only if the caller must
+// give up: at EOF, on an interruption or after a broken read.
+func (sp *SensorParser) envelopeError(start int64, err error) error {
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case isInterrupt(err):
+		return sp.interrupted(err)
+	case isSchemaError(err):
+		sp.corrupt(start, nil, err, sp.InputOffset()-start)
+		return nil
+	default:
+		sp.batch = nil
+		return sp.resync(err)
+	}
+}
+
+// enterBatch walks the next top-level value down the envelope path and
+// leaves the decoder just inside the record array.
+func (sp *SensorParser) enterBatch() error {
+	sp.envOpen = 0
+	t, err := sp.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range sp.envelope {
+		if t != json.Delim('{') {
+			return sp.notEnvelope(t)
+		}
+		sp.envOpen++
+		if err := sp.findKey(key); err != nil {
+			return err
+		}
+		if t, err = sp.dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	if t != json.Delim('[') {
+		return sp.notEnvelope(t)
+	}
+	sp.batch = sp.dec
+	return nil
+}
+
+// findKey advances to the value of key in the object being read.
+func (sp *SensorParser) findKey(key string) error {
+	for {
+		t, err := sp.dec.Token()
+		if err != nil {
+			return err
+		}
+		if t == json.Delim('}') {
+			sp.envOpen--
+			if err := sp.leaveEnvelope(); err != nil {
+				return err
+			}
+			return errNotEnvelope
+		}
+		if t == key {
+			return nil
+		}
+		if t, err = sp.dec.Token(); err != nil {
+			return err
+		}
+		if err := skipRawValue(sp.dec, t); err != nil {
+			return err
+		}
+	}
+}
+
+// notEnvelope skips value t, found where the path expected an object or
+// the record array, and closes the envelope around it.
+func (sp *SensorParser) notEnvelope(t json.Token) error {
+	if err := skipRawValue(sp.dec, t); err != nil {
+		return err
+	}
+	if err := sp.leaveEnvelope(); err != nil {
+		return err
+	}
+	return errNotEnvelope
+}
+
+// leaveEnvelope skips the remaining keys of every open envelope object.
+func (sp *SensorParser) leaveEnvelope() error {
+	for sp.envOpen > 0 {
+		t, err := sp.dec.Token()
+		if err != nil {
+			return err
+		}
+		if t == json.Delim('}') {
+			sp.envOpen--
+			continue
+		}
+		if t, err = sp.dec.Token(); err != nil {
+			return err
+		}
+		if err := skipRawValue(sp.dec, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipRawValue consumes the rest of the value that started with t. Unlike
+// skipValue it does not count toward the record size limit.
+func skipRawValue(dec *json.Decoder, t json.Token) error {
+	if t != json.Delim('{') && t != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// parseFast reads the next record in one Decode and fills dst straight from
+// its bytes: keys are matched against the field table without converting
+// them to strings, and values of other keys are skipped without being
+// tokenized. It only handles objects at the top level of the stream, and
+// only when no record size limit is set, since Decode buffers the whole
+// value.
+//
+// Anything it cannot take as is, be it a syntax error, an invalid record or
+// an out-of-range number, is rewound for the token path to parse again, so
+// both paths recover from bad input identically. Since that parses the bad
+// record twice, the fast path then sits out the next fastPathBackoff
+// attempts, which keeps dirty streams close to the token path's cost. ok
+// reports whether dst was filled; a non-nil error is for ParseInto to
+// return.
+func (sp *SensorParser) parseFast(dst *SensorData) (ok bool, err error) {
+	if sp.fastBackoff > 0 {
+		sp.fastBackoff--
+		return false, nil
+	}
+	if sp.maxRecordBytes > 0 || sp.nesting > 0 || !sp.nextIsObject() {
+		return false, nil
+	}
+
+	sp.raw = sp.raw[:0]
+	if err := sp.dec.Decode(&sp.raw); err != nil {
+		if isInterrupt(err) {
+			return false, sp.interrupted(err)
+		}
+		// The decoder gives up at the start of the value it failed on.
+		sp.rewind(nil)
+		return false, nil
+	}
+	sp.recordStart = sp.InputOffset() - int64(len(sp.raw))
+
+	if !sp.parseRaw(dst, sp.raw) {
+		sp.rewind(sp.raw)
+		return false, nil
+	}
+	if err := sp.validate(dst); err != nil {
+		return false, sp.reject(err)
+	}
+	sp.stats.records.Add(1)
+	return true, nil
+}
+
+// nextIsObject reports whether the decoder's buffered input continues with
+// an object. It never reads, so an empty buffer reports false.
+func (sp *SensorParser) nextIsObject() bool {
+	br, ok := sp.dec.Buffered().(*bytes.Reader)
+	if !ok {
+		return false
+	}
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c == '{'
+		}
+	}
+}
+
+// fastPathBackoff is how many attempts parseFast skips after a rewind.
+const fastPathBackoff = 64
+
+// rewind puts raw, a value already consumed, and everything the decoder has
+// buffered back in front of the input on a fresh decoder.
+func (sp *SensorParser) rewind(raw []byte) {
+	offset := sp.InputOffset() - int64(len(raw))
+	sp.src.unread(raw, sp.dec.Buffered())
+	sp.resetDecoder(offset)
+	sp.fastBackoff = fastPathBackoff
+}
+
+// parseRaw mirrors parseObject over raw, a complete object the decoder has
+// already validated. It reports false, without touching the stats, for any
+// record parseObject would reject.
+func (sp *SensorParser) parseRaw(dst *SensorData, raw []byte) bool {
+	dst.reset()
+	var (
+		seen      targetSet
+		malformed uint64
+	)
+
+	s := rawScanner{b: raw, i: 1}
+	for s.peek() != '}' {
+		key := s.next()
+		var (
+			spec FieldSpec
+			ok   bool
+		)
+		if bytes.IndexByte(key, '\\') < 0 {
+			spec, ok = sp.fields[string(key[1:len(key)-1])]
+		} else {
+			spec, ok = sp.fields[rawString(key)]
+		}
+		if !ok {
+			s.next()
+			continue
+		}
+
+		switch spec.Target {
+		case TargetSensorID:
+			if id, ok := sp.rawAsString(s.next()); ok {
+				dst.SensorID = id
+				seen.add(TargetSensorID)
+			} else if sp.failOnInvalid {
+				return false
+			} else {
+				malformed++
+			}
+		case TargetReadings:
+			if s.peek() != '[' {
+				return false
+			}
+			s.i++
+			for s.peek() != ']' {
+				v, ok := sp.rawAsFloat(s.next())
+				if !ok {
+					return false
+				}
+				dst.Readings = append(dst.Readings, v)
+			}
+			s.i++
+			if len(dst.Readings) > 0 {
+				dst.Value = dst.Readings[0]
+				seen.add(TargetReadings)
+			}
+		case TargetTimestamp:
+			if ts, ok := sp.rawAsFloat(s.next()); ok {
+				dst.Timestamp = int64(ts)
+				seen.add(TargetTimestamp)
+			} else if sp.failOnInvalid {
+				return false
+			} else {
+				malformed++
+			}
+		case TargetMetadata:
+			if s.peek() != '{' {
+				s.next()
+				if sp.failOnInvalid {
+					return false
+				}
+				malformed++
+				continue
+			}
+			s.i++
+			for s.peek() != '}' {
+				k := s.next()
+				if s.peek() != '"' {
+					s.next()
+					continue
+				}
+				dst.Metadata = append(dst.Metadata, MetadataPair{Key: rawString(k), Value: rawString(s.next())})
+			}
+			s.i++
+			seen.add(TargetMetadata)
+		default:
+			s.next()
+		}
+	}
+
+	if seen&sp.required != sp.required {
+		return false
+	}
+	sp.stats.malformed.Add(malformed)
+	return true
+}
+
+func (sp *SensorParser) rawAsFloat(v []byte) (float64, bool) {
+	switch v[0] {
+	case '"':
+		if sp.coercion != CoercionLenient {
+			return 0, false
+		}
+		return sp.quotedFloat(rawString(v))
+	case '{', '[', 't', 'f', 'n':
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(string(v), 64)
+	return f, err == nil
+}
+
+func (sp *SensorParser) rawAsString(v []byte) (string, bool) {
+	switch v[0] {
+	case '"':
+		return rawString(v), true
+	case '{', '[', 't', 'f', 'n':
+		return "", false
+	}
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return "", false
+	}
+	return sp.numberString(f)
+}
+
+// rawString returns the contents of q, a quoted JSON string the decoder
+// has validated. Escapes are decoded like encoding/json does, including
+// turning unpaired surrogates into U+FFFD.
+func rawString(q []byte) string {
+	q = q[1 : len(q)-1]
+	i := bytes.IndexByte(q, '\\')
+	if i < 0 {
+		return string(q)
+	}
+	b := make([]byte, 0, len(q))
+	for i >= 0 {
+		b = append(b, q[:i]...)
+		q = q[i+1:]
+		c := q[0]
+		q = q[1:]
+		switch c {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r := rawHex4(q)
+			q = q[4:]
+			if utf16.IsSurrogate(r) {
+				r2 := utf8.RuneError
+				if len(q) >= 6 && q[0] == '\\' && q[1] == 'u' {
+					r2 = rawHex4(q[2:])
+				}
+				if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+					q = q[6:]
+				}
+			}
+			b = utf8.AppendRune(b, r)
+		default: // '"', '\\' and '/' stand for themselves
+			b = append(b, c)
+		}
+		i = bytes.IndexByte(q, '\\')
+	}
+	return string(append(b, q...))
+}
+
+// rawHex4 decodes the four hex digits after a \u escape.
+func rawHex4(h []byte) rune {
+	var r rune
+	for _, c := range h[:4] {
+		switch {
+		case c <= '9':
+			c -= '0'
+		case c <= 'F':
+			c -= 'A' - 10
+		default:
+			c -= 'a' - 10
+		}
+		r = r<<4 | rune(c)
+	}
+	return r
+}
+
+// rawScanner walks JSON the decoder has already validated, so it needs no
+// error handling: commas and colons are skipped like whitespace.
+type rawScanner struct {
+	b []byte
+	i int
+}
+
+// peek returns the first byte of the next value or closing bracket.
+func (s *rawScanner) peek() byte {
+	for ; s.i < len(s.b); s.i++ {
+		switch s.b[s.i] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+		default:
+			return s.b[s.i]
+		}
+	}
+	return 0
+}
+
+// next consumes the next value and returns its bytes, quotes included.
+func (s *rawScanner) next() []byte {
+	c := s.peek()
+	start := s.i
+	switch c {
+	case '"':
+		s.skipString()
+	case '{', '[':
+		for depth := 0; ; {
+			switch s.b[s.i] {
+			case '"':
+				s.skipString()
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			s.i++
+			if depth == 0 {
+				break
+			}
+		}
+	default:
+		for s.i < len(s.b) {
+			switch s.b[s.i] {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return s.b[start:s.i]
+			}
+			s.i++
+		}
+	}
+	return s.b[start:s.i]
+}
+
+// skipString moves past the string starting at s.i.
+func (s *rawScanner) skipString() {
+	for s.i++; ; s.i++ {
+		j := bytes.IndexByte(s.b[s.i:], '"')
+		s.i += j
+		escapes := 0
+		for s.b[s.i-1-escapes] == '\\' {
+			escapes++
+		}
+		if escapes%2 == 0 {
+			s.i++
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "fmt"
+
+// Target is the SensorData field a JSON key is decoded into. The target
+// fixes the expected JSON type: a string for TargetSensorID, an array of
+// numbers for TargetReadings, a number for TargetTimestamp and an object
+// for TargetMetadata.
+type Target uint8
+
+const (
+	TargetSensorID Target = iota
+	TargetReadings
+	TargetTimestamp
+	TargetMetadata
+)
+
+func (t Target) String() string {
+	switch t {
+	case TargetSensorID:
+		return "sensor_id"
+	case TargetReadings:
+		return "readings"
+	case TargetTimestamp:
+		return "timestamp"
+	case TargetMetadata:
+		return "metadata"
+	default:
+		return fmt.Sprintf("Target(%d)", t)
+	}
+}
+
+// FieldSpec maps one JSON key onto a SensorData field. A record missing a
+// Required field (or, for TargetReadings, holding no reading) is skipped.
+type FieldSpec struct {
+	Target   Target
+	Required bool
+}
+
+// DefaultFields is the schema used when no WithFields option is given.
+func DefaultFields() map[string]FieldSpec {
+	return map[string]FieldSpec{
+		SensorIDKey:  {Target: TargetSensorID, Required: true},
+		ReadingsKey:  {Target: TargetReadings, Required: true},
+		TimestampKey: {Target: TargetTimestamp},
+		MetadataKey:  {Target: TargetMetadata},
+	}
+}
+
+// WithFields replaces the whole schema: keys not listed are skipped, so
+// the same parser can read payloads that name their fields differently.
+func WithFields(fields map[string]FieldSpec) Option {
+	return func(sp *SensorParser) {
+		sp.fields = fields
+	}
+}
+
+// targetSet is a bitmask of Targets.
+type targetSet uint8
+
+func (s targetSet) has(t Target) bool { return s&(1<<t) != 0 }
+
+func (s *targetSet) add(t Target) { *s |= 1 << t }
+
+// requiredTargets precomputes which targets every record must provide.
+func requiredTargets(fields map[string]FieldSpec) targetSet {
+	var required targetSet
+	for _, spec := range fields {
+		if spec.Required {
+			required.add(spec.Target)
+		}
+	}
+	return required
+}
+
+// missingField names the first required key whose target was not seen.
+func missingField(fields map[string]FieldSpec, seen targetSet) error {
+	for name, spec := range fields {
+		if spec.Required && !seen.has(spec.Target) {
+			return fmt.Errorf("missing required field %q", name)
+		}
+	}
+	return fmt.Errorf("missing required field")
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A frame is
+//
+//	magic (2 bytes) | payload length (uint32, big endian) | payload | CRC-32 (IEEE) of payload
+//
+// where the payload is a MsgPack map using the same keys as the JSON
+// records. The magic starts with 0xc1, a byte MsgPack never uses, and the
+// checksum lets the parser tell a real frame from a magic look-alike.
+var frameMagic = [2]byte{0xc1, 0x5d}
+
+const (
+	frameHeaderLen  = len(frameMagic) + 4
+	frameTrailerLen = 4
+
+	defaultMaxFrameBytes = 64 << 10
+)
+
+var (
+	errNoFrameMagic     = errors.New("frame: missing magic")
+	errFrameTooLarge    = errors.New("frame: length exceeds limit")
+	errFrameChecksum    = errors.New("frame: checksum mismatch")
+	errFrameTruncated   = errors.New("frame: truncated at end of input")
+	errFrameNotARecord  = errors.New("frame: payload is not a map")
+	errFrameBadReadings = errors.New("frame: readings must be a flat array of numbers")
+)
+
+// FrameParser reads SensorData from length-prefixed binary frames. Corrupt
+// frames are skipped by scanning for the next magic, mirroring the JSON
+// parser's resync, and whole frames are validated before any field is
+// decoded.
+type FrameParser struct {
+	br            *bufio.Reader
+	fields        map[string]FieldSpec
+	required      targetSet
+	maxFrameBytes int
+	onCorruption  CorruptionHandler
+
+	offset    int64 // absolute offset of br's next byte
+	skipStart int64
+	skipped   int
+	skipCause error
+	fragment  []byte
+	err       error
+}
+
+type FrameOption func(fp *FrameParser)
+
+// WithFrameFields maps payload keys to fields. The default is DefaultFields.
+func WithFrameFields(fields map[string]FieldSpec) FrameOption {
+	return func(fp *FrameParser) {
+		fp.fields = fields
+	}
+}
+
+// WithMaxFrameBytes caps the payload length; a larger length prefix is
+// treated as corruption. The parser buffers one whole frame, so this also
+// sizes its read buffer. The default is 64 KiB.
+func WithMaxFrameBytes(n int) FrameOption {
+	return func(fp *FrameParser) {
+		fp.maxFrameBytes = n
+	}
+}
+
+// WithFrameCorruptionHandler reports skipped bytes and rejected frames.
+func WithFrameCorruptionHandler(h CorruptionHandler) FrameOption {
+	return func(fp *FrameParser) {
+		fp.onCorruption = h
+	}
+}
+
+func NewFrameParser(r io.Reader, opts ...FrameOption) *FrameParser {
+	fp := &FrameParser{
+		fields:        DefaultFields(),
+		maxFrameBytes: defaultMaxFrameBytes,
+	}
+	for _, opt := range opts {
+		opt(fp)
+	}
+	fp.required = requiredTargets(fp.fields)
+	fp.br = bufio.NewReaderSize(r, frameHeaderLen+fp.maxFrameBytes+frameTrailerLen)
+	return fp
+}
+
+func (fp *FrameParser) Parse(ctx context.Context) (*SensorData, error) {
+	data := &SensorData{}
+	if err := fp.ParseInto(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParseInto fills dst from the next valid frame.
+func (fp *FrameParser) ParseInto(ctx context.Context, dst *SensorData) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if fp.err != nil {
+			return fp.err
+		}
+
+		payload, size, err := fp.nextFrame()
+		fp.flushSkipped()
+		if err != nil {
+			fp.err = err
+			return err
+		}
+
+		start := fp.offset
+		err = fp.decode(dst, payload)
+		if err != nil && fp.onCorruption != nil {
+			fp.onCorruption(start, payload[:min(len(payload), maxFragmentBytes)], err)
+		}
+		fp.discard(size)
+		if err == nil {
+			return nil
+		}
+	}
+}
+
+// InputOffset returns the absolute offset of the next unread byte.
+func (fp *FrameParser) InputOffset() int64 {
+	return fp.offset
+}
+
+// nextFrame skips to the next frame whose length and checksum are valid and
+// returns its payload, still in the read buffer, and its total size.
+func (fp *FrameParser) nextFrame() (payload []byte, size int, err error) {
+	for {
+		header, err := fp.br.Peek(frameHeaderLen)
+		if len(header) < frameHeaderLen {
+			if errors.Is(err, io.EOF) {
+				if len(header) > 0 {
+					fp.skip(len(header), errFrameTruncated)
+				}
+				return nil, 0, io.EOF
+			}
+			return nil, 0, fmt.Errorf("read frame: %w", err)
+		}
+
+		if header[0] != frameMagic[0] || header[1] != frameMagic[1] {
+			fp.skip(fp.distanceToMagic(), errNoFrameMagic)
+			continue
+		}
+
+		n := binary.BigEndian.Uint32(header[len(frameMagic):])
+		if n > uint32(fp.maxFrameBytes) {
+			fp.skip(1, errFrameTooLarge)
+			continue
+		}
+
+		size := frameHeaderLen + int(n) + frameTrailerLen
+		frame, err := fp.br.Peek(size)
+		if len(frame) < size {
+			if errors.Is(err, io.EOF) {
+				fp.skip(1, errFrameTruncated)
+				continue
+			}
+			return nil, 0, fmt.Errorf("read frame: %w", err)
+		}
+
+		payload := frame[frameHeaderLen : size-frameTrailerLen]
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(frame[size-frameTrailerLen:]) {
+			// Drop one byte only: a genuine frame may start inside this one.
+			fp.skip(1, errFrameChecksum)
+			continue
+		}
+		return payload, size, nil
+	}
+}
+
+// distanceToMagic returns how many buffered bytes certainly precede the
+// next possible magic; at least one.
+func (fp *FrameParser) distanceToMagic() int {
+	buf, _ := fp.br.Peek(fp.br.Buffered())
+	if i := bytes.IndexByte(buf[1:], frameMagic[0]); i >= 0 {
+		return i + 1
+	}
+	return max(len(buf), 1)
+}
+
+// skip discards n bytes as corruption. Consecutive skips are reported as
+// one run, attributed to the cause that started it.
+func (fp *FrameParser) skip(n int, cause error) {
+	if fp.skipped == 0 {
+		fp.skipStart, fp.skipCause = fp.offset, cause
+		fp.fragment = fp.fragment[:0]
+	}
+	if fp.onCorruption != nil && len(fp.fragment) < maxFragmentBytes {
+		b, _ := fp.br.Peek(min(n, maxFragmentBytes-len(fp.fragment)))
+		fp.fragment = append(fp.fragment, b...)
+	}
+	fp.skipped += n
+	fp.discard(n)
+}
+
+func (fp *FrameParser) flushSkipped() {
+	if fp.skipped == 0 {
+		return
+	}
+	if fp.onCorruption != nil {
+		fp.onCorruption(fp.skipStart, fp.fragment, fp.skipCause)
+	}
+	fp.skipped = 0
+}
+
+func (fp *FrameParser) discard(n int) {
+	d, _ := fp.br.Discard(n)
+	fp.offset += int64(d)
+}
+
+// decode fills dst from a MsgPack map payload.
+func (fp *FrameParser) decode(dst *SensorData, payload []byte) error {
+	dst.reset()
+	d := msgpackDecoder{b: payload}
+	var seen targetSet
+
+	n, ok, err := d.mapLen()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errFrameNotARecord
+	}
+
+	for range n {
+		key, ok, err := d.str()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("frame: expected string key")
+		}
+
+		spec, ok := fp.fields[string(key)]
+		if !ok {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch spec.Target {
+		case TargetSensorID:
+			s, ok, err := d.str()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			dst.SensorID = string(s)
+			seen.add(TargetSensorID)
+		case TargetReadings:
+			if err := decodeFrameReadings(&d, dst); err != nil {
+				return err
+			}
+			if len(dst.Readings) > 0 {
+				seen.add(TargetReadings)
+			}
+		case TargetTimestamp:
+			ts, ok, err := d.number()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			dst.Timestamp = int64(ts)
+			seen.add(TargetTimestamp)
+		case TargetMetadata:
+			isMap, err := decodeFrameMetadata(&d, dst)
+			if err != nil {
+				return err
+			}
+			if isMap {
+				seen.add(TargetMetadata)
+			}
+		default:
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if seen&fp.required != fp.required {
+		return missingField(fp.fields, seen)
+	}
+	return nil
+}
+
+func decodeFrameReadings(d *msgpackDecoder, dst *SensorData) error {
+	n, ok, err := d.arrayLen()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errFrameBadReadings
+	}
+	for range n {
+		v, ok, err := d.number()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errFrameBadReadings
+		}
+		dst.Readings = append(dst.Readings, v)
+	}
+	if len(dst.Readings) > 0 {
+		dst.Value = dst.Readings[0]
+	}
+	return nil
+}
+
+// decodeFrameMetadata keeps string values only, like the JSON parser.
+func decodeFrameMetadata(d *msgpackDecoder, dst *SensorData) (isMap bool, err error) {
+	n, ok, err := d.mapLen()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, d.skip()
+	}
+	for range n {
+		key, ok, err := d.str()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, errors.New("frame: expected metadata key")
+		}
+		value, ok, err := d.str()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			if err := d.skip(); err != nil {
+				return false, err
+			}
+			continue
+		}
+		dst.Metadata = append(dst.Metadata, MetadataPair{Key: string(key), Value: string(value)})
+	}
+	return true, nil
+}
+
+// AppendSensorFrame appends d to b as a frame FrameParser reads with the
+// default fields; it is what a sensor (or a test) writes.
+func AppendSensorFrame(b []byte, d *SensorData) []byte {
+	var payload []byte
+	entries := 2
+	if d.Timestamp != 0 {
+		entries++
+	}
+	if len(d.Metadata) > 0 {
+		entries++
+	}
+	payload = appendMsgpackMapHeader(payload, entries)
+	payload = appendMsgpackString(payload, SensorIDKey)
+	payload = appendMsgpackString(payload, d.SensorID)
+	payload = appendMsgpackString(payload, ReadingsKey)
+	payload = appendMsgpackArrayHeader(payload, len(d.Readings))
+	for _, v := range d.Readings {
+		payload = appendMsgpackFloat64(payload, v)
+	}
+	if d.Timestamp != 0 {
+		payload = appendMsgpackString(payload, TimestampKey)
+		payload = appendMsgpackInt64(payload, d.Timestamp)
+	}
+	if len(d.Metadata) > 0 {
+		payload = appendMsgpackString(payload, MetadataKey)
+		payload = appendMsgpackMapHeader(payload, len(d.Metadata))
+		for _, m := range d.Metadata {
+			payload = appendMsgpackString(payload, m.Key)
+			payload = appendMsgpackString(payload, m.Value)
+		}
+	}
+
+	b = append(b, frameMagic[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	b = append(b, payload...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+}
@@ -1,3 +1,12 @@
 module zero-allocation-json-parser
 
-go 1.24.3
+go 1.25.0
+
+require (
+	object-pool v0.0.0
+	slab-arena v0.0.0
+)
+
+replace object-pool => ../30-object-pool
+
+replace slab-arena => ../32-slab-arena
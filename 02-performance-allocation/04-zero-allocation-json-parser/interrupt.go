@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// WithReadDeadline bounds every read from the input by d and makes reads
+// honour the ctx passed to Parse, so a stalled network source returns
+// os.ErrDeadlineExceeded or ctx.Err() instead of blocking forever. It
+// implies WithInterruptibleReads.
+func WithReadDeadline(d time.Duration) Option {
+	return func(sp *SensorParser) {
+		sp.interruptible = true
+		sp.readTimeout = d
+	}
+}
+
+// WithInterruptibleReads makes reads honour the ctx passed to Parse (or
+// ParseStream). Without it ctx is only checked between tokens, so a Read
+// blocked on a silent peer outlives cancellation.
+//
+// A source with SetReadDeadline, such as a net.Conn, is interrupted through
+// its deadline. Any other reader is read from a helper goroutine that may
+// stay blocked after Parse returns; its data is kept for the next call.
+// A record interrupted halfway is read again by the next call if the fast
+// path was reading it whole (see parseFast); otherwise it is lost and
+// parsing resumes from the next one. Decompressors keep read errors, so with
+// WithDecompression an interruption ends the stream.
+func WithInterruptibleReads() Option {
+	return func(sp *SensorParser) {
+		sp.interruptible = true
+	}
+}
+
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+// interruptibleReader reads under the ctx of the current Parse call.
+type interruptibleReader struct {
+	r       io.Reader
+	timeout time.Duration
+	ctx     context.Context // set by the parser before each call
+
+	// Readers without deadlines are read into buf on a goroutine.
+	buf      []byte
+	data     []byte // unread part of buf
+	err      error
+	results  chan readResult
+	inflight bool
+}
+
+func newInterruptibleReader(r io.Reader, timeout time.Duration) *interruptibleReader {
+	return &interruptibleReader{
+		r:       r,
+		timeout: timeout,
+		ctx:     context.Background(),
+		results: make(chan readResult, 1),
+	}
+}
+
+func (ir *interruptibleReader) Read(p []byte) (int, error) {
+	if err := ir.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if d, ok := ir.r.(deadliner); ok {
+		return ir.readWithDeadline(d, p)
+	}
+	return ir.readAsync(p)
+}
+
+func (ir *interruptibleReader) readWithDeadline(d deadliner, p []byte) (int, error) {
+	var deadline time.Time
+	if ir.timeout > 0 {
+		deadline = time.Now().Add(ir.timeout)
+	}
+	if err := d.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	fired := make(chan struct{})
+	stop := context.AfterFunc(ir.ctx, func() {
+		d.SetReadDeadline(time.Unix(1, 0))
+		close(fired)
+	})
+	n, err := ir.r.Read(p)
+	if !stop() {
+		// Let the past deadline land before the next Read sets a new one.
+		<-fired
+		return n, ir.ctx.Err()
+	}
+	return n, err
+}
+
+func (ir *interruptibleReader) readAsync(p []byte) (int, error) {
+	if len(ir.data) == 0 && ir.err == nil {
+		if !ir.inflight {
+			if ir.buf == nil {
+				ir.buf = make([]byte, 32<<10)
+			}
+			ir.inflight = true
+			go func() {
+				n, err := ir.r.Read(ir.buf)
+				ir.results <- readResult{n, err}
+			}()
+		}
+
+		var timeout <-chan time.Time
+		if ir.timeout > 0 {
+			t := time.NewTimer(ir.timeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case res := <-ir.results:
+			ir.inflight = false
+			ir.data, ir.err = ir.buf[:res.n], res.err
+		case <-ir.ctx.Done():
+			return 0, ir.ctx.Err()
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+
+	n := copy(p, ir.data)
+	ir.data = ir.data[n:]
+	if len(ir.data) == 0 && ir.err != nil {
+		return n, ir.err
+	}
+	return n, nil
+}
+
+// isInterrupt reports whether err is a read cut short by a ctx or deadline,
+// as opposed to corrupt input or a broken source.
+func isInterrupt(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// interrupted rebuilds the decoder after an interrupted read: a
+// json.Decoder keeps read errors, but the bytes it had buffered are still
+// good. The record being read is abandoned.
+func (sp *SensorParser) interrupted(err error) error {
+	offset := sp.InputOffset()
+	sp.src.unread(nil, sp.dec.Buffered())
+	sp.resetDecoder(offset)
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WithFailOnInvalid turns records that break the schema into errors instead
+// of skipping them: a record missing a required field or holding a field of
+// the wrong type is returned as a *RecordError. The rest of the record is
+// consumed first, so the next call resumes after it. Corrupt JSON is still
+// recovered from as usual.
+//
+// In ModeStrict the *RecordError is wrapped in the *LineError. ParseStream
+// stops at the first invalid record and reports it on the error channel;
+// with WithOrderedStream every record before it is emitted first.
+func WithFailOnInvalid() Option {
+	return func(sp *SensorParser) {
+		sp.failOnInvalid = true
+	}
+}
+
+// RecordError reports a well-formed record rejected by WithFailOnInvalid,
+// either for breaking the schema or, wrapping a *ValidationError, for
+// failing WithValidation.
+type RecordError struct {
+	Offset int64 // absolute offset of the record's opening brace
+	Err    error // why the record was rejected
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("invalid record at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// invalid consumes the rest of the record rejected with cause, so that
+// nothing nested in it can be taken for the next record. Under
+// WithFailOnInvalid the record is returned as a *RecordError; otherwise it
+// is reported as skipped and invalid returns nil. If the rest cannot be
+// read, that error is returned instead for the caller to recover from.
+func (sp *SensorParser) invalid(cause error) error {
+	if err := sp.skipRecord(); err != nil {
+		return err
+	}
+	if sp.failOnInvalid {
+		return &RecordError{Offset: sp.recordStart, Err: cause}
+	}
+	sp.corrupt(sp.recordStart, nil, cause, sp.InputOffset()-sp.recordStart)
+	return nil
+}
+
+// isSchemaError runs on every parse error, so it walks the chain itself:
+// errors.As would allocate the target.
+func isSchemaError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if _, ok := err.(schemaError); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isRecordError(err error) bool {
+	var re *RecordError
+	return errors.As(err, &re)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrRecordTooLarge is returned by ParseInto for a record longer than the
+// WithMaxRecordBytes limit. The record has already been skipped, so the
+// caller may keep parsing.
+var ErrRecordTooLarge = errors.New("sensor parser: record too large")
+
+// WithMaxRecordBytes caps the encoded size of a single record. A record that
+// grows past n bytes is abandoned as soon as the limit is crossed and the
+// rest of it is skipped token by token, so a runaway readings array never
+// reaches memory. The check runs between tokens: one enormous string is
+// still read whole by the decoder. Zero means unlimited.
+func WithMaxRecordBytes(n int64) Option {
+	return func(sp *SensorParser) {
+		sp.maxRecordBytes = n
+	}
+}
+
+// token reads the next token of the current record, tracking its depth and
+// enforcing the record size limit when one is set.
+func (sp *SensorParser) token() (json.Token, error) {
+	t, err := sp.dec.Token()
+	if err != nil {
+		return t, err
+	}
+	sp.trackDepth(t)
+	if sp.maxRecordBytes > 0 && sp.InputOffset()-sp.recordStart > sp.maxRecordBytes {
+		return nil, ErrRecordTooLarge
+	}
+	return t, nil
+}
+
+func (sp *SensorParser) trackDepth(t json.Token) {
+	if delim, ok := t.(json.Delim); ok {
+		switch delim {
+		case '{', '[':
+			sp.depth++
+		case '}', ']':
+			sp.depth--
+		}
+	}
+}
+
+// skipRecord discards the rest of the record token by token, without
+// keeping any of it.
+func (sp *SensorParser) skipRecord() error {
+	for sp.depth > 0 {
+		t, err := sp.dec.Token()
+		if err != nil {
+			return err
+		}
+		sp.trackDepth(t)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of payload")
+
+// msgpackDecoder walks a MsgPack payload in place. It supports the subset a
+// sensor record needs (maps, arrays, strings, numbers) and can skip
+// anything else. The typed readers report ok=false, without consuming
+// anything, when the next value has another type.
+type msgpackDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *msgpackDecoder) peek() (byte, error) {
+	if d.off >= len(d.b) {
+		return 0, errMsgpackShort
+	}
+	return d.b[d.off], nil
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.b)-d.off {
+		return nil, errMsgpackShort
+	}
+	b := d.b[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// uint reads an n-byte big-endian unsigned integer.
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// length reads a header whose type byte has already been consumed: either
+// the low bits of a fix type or an n-byte length that follows.
+func (d *msgpackDecoder) length(n int) (int, error) {
+	v, err := d.uint(n)
+	return int(v), err
+}
+
+func (d *msgpackDecoder) mapLen() (int, bool, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case c >= 0x80 && c <= 0x8f:
+		d.off++
+		return int(c & 0x0f), true, nil
+	case c == 0xde:
+		d.off++
+		n, err := d.length(2)
+		return n, true, err
+	case c == 0xdf:
+		d.off++
+		n, err := d.length(4)
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+func (d *msgpackDecoder) arrayLen() (int, bool, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case c >= 0x90 && c <= 0x9f:
+		d.off++
+		return int(c & 0x0f), true, nil
+	case c == 0xdc:
+		d.off++
+		n, err := d.length(2)
+		return n, true, err
+	case c == 0xdd:
+		d.off++
+		n, err := d.length(4)
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+// str returns the bytes of a string, aliasing the payload.
+func (d *msgpackDecoder) str() ([]byte, bool, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, false, err
+	}
+	var n int
+	switch {
+	case c >= 0xa0 && c <= 0xbf:
+		d.off++
+		n = int(c & 0x1f)
+	case c == 0xd9, c == 0xda, c == 0xdb:
+		d.off++
+		n, err = d.length(1 << (c - 0xd9))
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	b, err := d.next(n)
+	return b, true, err
+}
+
+// number reads any integer or float as a float64.
+func (d *msgpackDecoder) number() (float64, bool, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case c <= 0x7f:
+		d.off++
+		return float64(c), true, nil
+	case c >= 0xe0:
+		d.off++
+		return float64(int8(c)), true, nil
+	case c == 0xca:
+		d.off++
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), true, err
+	case c == 0xcb:
+		d.off++
+		v, err := d.uint(8)
+		return math.Float64frombits(v), true, err
+	case c >= 0xcc && c <= 0xcf:
+		d.off++
+		v, err := d.uint(1 << (c - 0xcc))
+		return float64(v), true, err
+	case c >= 0xd0 && c <= 0xd3:
+		d.off++
+		size := 1 << (c - 0xd0)
+		v, err := d.uint(size)
+		// Sign-extend from size bytes.
+		shift := 64 - 8*size
+		return float64(int64(v<<shift) >> shift), true, err
+	}
+	return 0, false, nil
+}
+
+// skip consumes the next value, however deeply nested.
+func (d *msgpackDecoder) skip() error {
+	if n, ok, err := d.mapLen(); ok || err != nil {
+		return d.skipN(2*n, err)
+	}
+	if n, ok, err := d.arrayLen(); ok || err != nil {
+		return d.skipN(n, err)
+	}
+	if _, ok, err := d.str(); ok || err != nil {
+		return err
+	}
+	if _, ok, err := d.number(); ok || err != nil {
+		return err
+	}
+
+	c, _ := d.peek()
+	d.off++
+	switch {
+	case c == 0xc0, c == 0xc2, c == 0xc3: // nil, false, true
+		return nil
+	case c >= 0xc4 && c <= 0xc6: // bin 8/16/32
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		_, err = d.next(n)
+		return err
+	case c >= 0xc7 && c <= 0xc9: // ext 8/16/32
+		n, err := d.length(1 << (c - 0xc7))
+		if err != nil {
+			return err
+		}
+		_, err = d.next(n + 1)
+		return err
+	case c >= 0xd4 && c <= 0xd8: // fixext 1..16
+		_, err := d.next(1 + 1<<(c-0xd4))
+		return err
+	}
+	return errors.New("msgpack: invalid type byte")
+}
+
+func (d *msgpackDecoder) skipN(n int, err error) error {
+	for ; err == nil && n > 0; n-- {
+		err = d.skip()
+	}
+	return err
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	if n <= 0x0f {
+		return append(b, 0x80|byte(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	if n <= 0x0f {
+		return append(b, 0x90|byte(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	if len(s) <= 0x1f {
+		b = append(b, 0xa0|byte(len(s)))
+	} else {
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(len(s)))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackFloat64(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+func appendMsgpackInt64(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
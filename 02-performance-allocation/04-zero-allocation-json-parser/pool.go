@@ -0,0 +1,41 @@
+package main
+
+import "object-pool"
+
+// maxPooledCap bounds the slices a released record may keep. A record that
+// once held a huge readings array is dropped instead of pinning that memory
+// in the pool forever.
+const maxPooledCap = 1024
+
+var recordPool = pool.New(
+	func() *SensorData { return new(SensorData) },
+	pool.WithValidate(func(d *SensorData) bool {
+		return cap(d.Readings) <= maxPooledCap && cap(d.Metadata) <= maxPooledCap
+	}),
+	pool.WithReset(func(d *SensorData) {
+		d.reset()
+		// Drop the strings still referenced past len so pooled records don't
+		// keep old sensor IDs and metadata alive.
+		clear(d.Metadata[:cap(d.Metadata)])
+	}),
+)
+
+// Acquire returns an empty record from a shared pool, for pipelines where
+// records flow into channels and a single reused SensorData won't do. Fill
+// it with ParseInto and hand it back with Release once it is consumed.
+func (sp *SensorParser) Acquire() *SensorData {
+	d := recordPool.Get()
+	d.pooled = true
+	return d
+}
+
+// Release returns a record obtained from Acquire to the pool. The caller
+// must not touch d afterwards. Releasing twice, or releasing a record that
+// did not come from Acquire, is a no-op.
+func (d *SensorData) Release() {
+	if d == nil || !d.pooled {
+		return
+	}
+	d.pooled = false
+	recordPool.Put(d)
+}
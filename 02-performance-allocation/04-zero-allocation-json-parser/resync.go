@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const resyncChunkSize = 4 << 10
+
+// resyncSource is the reader behind every decoder of a parser. Bytes the
+// previous decoder had buffered but not parsed are served first, then the
+// input itself. Its buffers are reused across resyncs.
+type resyncSource struct {
+	r       io.Reader
+	pending []byte // unread bytes, aliasing buf
+	buf     []byte
+	spare   []byte
+}
+
+func (s *resyncSource) Read(p []byte) (int, error) {
+	if len(s.pending) > 0 {
+		n := copy(p, s.pending)
+		s.pending = s.pending[n:]
+		return n, nil
+	}
+	return s.r.Read(p)
+}
+
+// unread puts consumed, bytes already taken from the decoder, and then the
+// decoder's buffered bytes back in front of the pending ones.
+func (s *resyncSource) unread(consumed []byte, buffered io.Reader) {
+	next := append(s.spare[:0], consumed...)
+	for {
+		next = slices.Grow(next, 512)
+		n, err := buffered.Read(next[len(next):cap(next)])
+		next = next[:len(next)+n]
+		if err != nil {
+			break
+		}
+	}
+	next = append(next, s.pending...)
+	s.spare, s.buf = s.buf, next
+	s.pending = next
+}
+
+// fill replaces the (fully consumed) pending bytes with the next chunk of
+// the input.
+func (s *resyncSource) fill() error {
+	if cap(s.buf) < resyncChunkSize {
+		s.buf = make([]byte, resyncChunkSize)
+	}
+	n, err := s.r.Read(s.buf[:cap(s.buf)])
+	s.pending = s.buf[:n]
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
+
+// resync skips ahead to the next '{' after cause made the current record
+// unreadable, reporting the skipped bytes to the CorruptionHandler if any.
+// It scans the unparsed input in place; the only allocation left is the
+// replacement decoder, see resetDecoder. A non-nil result means a read was
+// interrupted while scanning.
+func (sp *SensorParser) resync(cause error) (interrupt error) {
+	start := sp.InputOffset()
+	var skipped int64
+	sp.fragment = sp.fragment[:0]
+
+	src := sp.src
+	src.unread(nil, sp.dec.Buffered())
+	for {
+		i := bytes.IndexByte(src.pending, '{')
+		junk := src.pending
+		if i >= 0 {
+			junk = src.pending[:i]
+		}
+		skipped += int64(len(junk))
+		if sp.onCorruption != nil && len(sp.fragment) < maxFragmentBytes {
+			sp.fragment = append(sp.fragment, junk[:min(len(junk), maxFragmentBytes-len(sp.fragment))]...)
+		}
+		if i >= 0 {
+			src.pending = src.pending[i:]
+			break
+		}
+
+		if err := src.fill(); err != nil {
+			if isInterrupt(err) {
+				interrupt = err
+			} else if !errors.Is(err, io.EOF) {
+				sp.err = fmt.Errorf("read input: %w", err)
+			}
+			break
+		}
+	}
+
+	sp.resetDecoder(start + skipped)
+	sp.corrupt(start, sp.fragment, cause, skipped)
+	return interrupt
+}
+
+// resetDecoder restarts decoding on src as if from the absolute offset. A
+// json.Decoder keeps the first error it hits and has no Reset, so this is
+// the one place a parser replaces it; everything it reads, src, is kept.
+// Any open batch ends with it.
+func (sp *SensorParser) resetDecoder(offset int64) {
+	sp.dec = json.NewDecoder(sp.src)
+	sp.base = offset
+	sp.nesting = 0
+	sp.batch = nil
+}
@@ -1,140 +1,449 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"time"
 )
 
 const (
-	SensorIDKey = "sensor_id"
-	ReadingsKey = "readings"
+	SensorIDKey  = "sensor_id"
+	ReadingsKey  = "readings"
+	TimestampKey = "timestamp"
+	MetadataKey  = "metadata"
 )
 
 type SensorData struct {
-	SensorID string
-	Value    float64 // first reading value
+	SensorID  string
+	Value     float64 // first reading value
+	Readings  []float64
+	Timestamp int64
+	Metadata  []MetadataPair
+
+	pooled bool // set between Acquire and Release
+}
+
+// MetadataPair is one flat string entry of the record's metadata object.
+type MetadataPair struct {
+	Key   string
+	Value string
+}
+
+// reset clears d for reuse, keeping the capacity of its slices.
+func (d *SensorData) reset() {
+	d.SensorID = ""
+	d.Value = 0
+	d.Readings = d.Readings[:0]
+	d.Timestamp = 0
+	d.Metadata = d.Metadata[:0]
 }
+
 type SensorParser struct {
-	r   io.Reader
-	dec *json.Decoder
+	r     io.Reader
+	src   *resyncSource // what dec reads from; see resync
+	dec   *json.Decoder
+	stats *parserStats // shared with ParseStream's per-line parsers
+	config
+
+	base     int64  // absolute offset at which dec started reading
+	fragment []byte // reused buffer for CorruptionHandler
+
+	recordStart int64 // absolute offset of the current record's '{'
+	depth       int   // nesting depth inside the current record
+
+	err error // sticky read error; corrupt input is skipped, a broken reader is not
+
+	ir *interruptibleReader // nil unless WithInterruptibleReads
+
+	arena   *RecordArena // nil unless WithArena
+	scratch SensorData   // what Parse fills before copying into arena
+
+	// Fast path state; see parseFast.
+	raw         json.RawMessage
+	nesting     int // arrays open around the token path's position
+	fastBackoff int // parseFast attempts left to skip after a rewind
+
+	// WithEnvelope state.
+	batch   *json.Decoder // the decoder positioned inside a batch, if any
+	envOpen int           // envelope objects opened around the batch
+
+	// ModeStrict state.
+	lines      *lineReader
+	lineNo     int64
+	lineOffset int64
+}
+
+// config is everything set through options; it is shared by the per-line
+// parsers ParseStream spins up.
+type config struct {
+	fields         map[string]FieldSpec
+	required       targetSet
+	orderedStream  bool
+	onCorruption   CorruptionHandler
+	maxRecordBytes int64
+	compression    Compression
+	newZstdReader  func(io.Reader) (io.Reader, error)
+	mode           Mode
+	coercion       Coercion
+	envelope       []string
+	failOnInvalid  bool
+	rules          []Rule
+	interruptible  bool
+	readTimeout    time.Duration
+	bufferSize     int
 }
 
-func NewSensorParser(r io.Reader) *SensorParser {
+type Option func(sp *SensorParser)
+
+func NewSensorParser(r io.Reader, opts ...Option) *SensorParser {
+	sp := &SensorParser{
+		stats: &parserStats{},
+		config: config{
+			fields: DefaultFields(),
+		},
+	}
+	for _, opt := range opts {
+		opt(sp)
+	}
+	sp.required = requiredTargets(sp.fields)
+	if sp.bufferSize > 0 {
+		r = newRingBuffer(r, sp.bufferSize)
+	}
+	if sp.interruptible {
+		sp.ir = newInterruptibleReader(r, sp.readTimeout)
+		r = sp.ir
+	}
+	if sp.compression != CompressionNone {
+		r = &decompressReader{src: r, compression: sp.compression, newZstdReader: sp.newZstdReader}
+	}
+	sp.r = &countingReader{r: r, n: &sp.stats.consumed}
+	sp.src = &resyncSource{r: sp.r}
+	sp.dec = json.NewDecoder(sp.src)
+	return sp
+}
+
+// withReader returns a parser over r that shares sp's configuration.
+func (sp *SensorParser) withReader(r io.Reader) *SensorParser {
+	src := &resyncSource{r: r}
 	return &SensorParser{
-		r:   r,
-		dec: json.NewDecoder(r),
+		r:      r,
+		src:    src,
+		dec:    json.NewDecoder(src),
+		stats:  sp.stats,
+		config: sp.config,
 	}
 }
 
 func (sp *SensorParser) Parse(ctx context.Context) (*SensorData, error) {
+	if sp.arena != nil {
+		if err := sp.ParseInto(ctx, &sp.scratch); err != nil {
+			return nil, err
+		}
+		return sp.arena.copy(&sp.scratch), nil
+	}
+	data := &SensorData{}
+	if err := sp.ParseInto(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParseInto fills dst with the next valid record. dst's slices are truncated
+// and refilled in place, so a caller looping over one SensorData stops paying
+// for a fresh record and fresh backing arrays on every call. dst is only
+// meaningful when the returned error is nil.
+func (sp *SensorParser) ParseInto(ctx context.Context, dst *SensorData) error {
+	if sp.ir != nil {
+		sp.ir.ctx = ctx
+	}
+	if sp.mode == ModeStrict {
+		return sp.parseStrict(ctx, dst)
+	}
+	if sp.envelope != nil {
+		return sp.parseEnvelope(ctx, dst)
+	}
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return ctx.Err()
 		default:
 		}
+		if sp.err != nil {
+			return sp.err
+		}
+
+		if ok, err := sp.parseFast(dst); ok || err != nil {
+			return err
+		}
 
 		t, err := sp.dec.Token()
 		if err == io.EOF {
-			return nil, io.EOF
+			return io.EOF
+		}
+		if isInterrupt(err) {
+			return sp.interrupted(err)
 		}
 		if err != nil {
-			sp.resync()
+			if err := sp.resync(err); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		if t != json.Delim('{') {
+			switch t {
+			case json.Delim('['):
+				sp.nesting++
+			case json.Delim(']'):
+				sp.nesting--
+			}
 			continue
 		}
+		sp.recordStart, sp.depth = sp.InputOffset()-1, 1
 
-		data, err := sp.parseObject()
-		if err != nil {
-			sp.resync()
+		if err := sp.parseObject(dst); err != nil {
+			if isSchemaError(err) {
+				if err = sp.invalid(err); err == nil {
+					continue
+				} else if isRecordError(err) {
+					return err
+				}
+			}
+			if isInterrupt(err) {
+				return sp.interrupted(err)
+			}
+			if errors.Is(err, ErrRecordTooLarge) {
+				return sp.recordTooLarge()
+			}
+			if err := sp.resync(err); err != nil {
+				return err
+			}
 			continue
 		}
+		if err := sp.validate(dst); err != nil {
+			if err := sp.reject(err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sp.stats.records.Add(1)
+		return nil
+	}
+}
 
-		return data, nil
+func (sp *SensorParser) recordTooLarge() error {
+	start := sp.recordStart
+	if err := sp.skipRecord(); isInterrupt(err) {
+		return sp.interrupted(err)
+	} else if err != nil {
+		if err := sp.resync(err); err != nil {
+			return err
+		}
 	}
+	sp.corrupt(start, nil, ErrRecordTooLarge, sp.InputOffset()-start)
+	return fmt.Errorf("record at offset %d: %w", start, ErrRecordTooLarge)
 }
 
-func (sp *SensorParser) resync() {
-	source := io.MultiReader(sp.dec.Buffered(), sp.r)
+// parseObject fills dst from the object whose '{' has just been consumed.
+// Only top-level keys are matched, so a nested "sensor_id" inside metadata
+// can never be mistaken for the record's own.
+func (sp *SensorParser) parseObject(dst *SensorData) error {
+	dst.reset()
+	var seen targetSet
 
-	buf := make([]byte, 1)
 	for {
-		_, err := source.Read(buf)
+		t, err := sp.token()
 		if err != nil {
-			return
+			return err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == '}' {
+			break
+		}
+		key, ok := t.(string)
+		if !ok {
+			return errors.New("expected object key")
 		}
 
-		if buf[0] == '{' {
-			sp.dec = json.NewDecoder(io.MultiReader(bytes.NewReader(buf), source))
-			return
+		spec, ok := sp.fields[key]
+		if !ok {
+			if err := sp.skipValue(); err != nil {
+				return err
+			}
+			continue
 		}
+
+		switch spec.Target {
+		case TargetSensorID:
+			t, err := sp.token()
+			if err != nil {
+				return err
+			}
+			if sensorID, ok := sp.asString(t); ok {
+				dst.SensorID = sensorID
+				seen.add(TargetSensorID)
+			} else if err := sp.malformed(key, t); err != nil {
+				return err
+			}
+		case TargetReadings:
+			if err := sp.parseReadings(dst); err != nil {
+				return err
+			}
+			if len(dst.Readings) > 0 {
+				seen.add(TargetReadings)
+			}
+		case TargetTimestamp:
+			t, err := sp.token()
+			if err != nil {
+				return err
+			}
+			if ts, ok := sp.asFloat(t); ok {
+				dst.Timestamp = int64(ts)
+				seen.add(TargetTimestamp)
+			} else if err := sp.malformed(key, t); err != nil {
+				return err
+			}
+		case TargetMetadata:
+			isObject, err := sp.parseMetadata(dst)
+			if err != nil {
+				return err
+			}
+			if isObject {
+				seen.add(TargetMetadata)
+			} else if err := sp.malformed(key, nil); err != nil {
+				return err
+			}
+		default:
+			if err := sp.skipValue(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if seen&sp.required != sp.required {
+		return schemaError{missingField(sp.fields, seen)}
 	}
+	return nil
 }
 
-func (sp *SensorParser) parseObject() (*SensorData, error) {
-	depth := 1
-	data := &SensorData{}
-	hasSensorID := false
-	hasReadings := false
+func (sp *SensorParser) parseReadings(dst *SensorData) error {
+	t, err := sp.token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		sp.stats.malformed.Add(1)
+		return schemaError{errors.New("readings must be an array")}
+	}
 
-	for depth > 0 {
-		t, err := sp.dec.Token()
+	for {
+		t, err := sp.token()
 		if err != nil {
-			return nil, err
+			return err
 		}
+		if delim, ok := t.(json.Delim); ok && delim == ']' {
+			if len(dst.Readings) > 0 {
+				dst.Value = dst.Readings[0]
+			}
+			return nil
+		}
+		v, ok := sp.asFloat(t)
+		if !ok {
+			sp.stats.malformed.Add(1)
+			return schemaError{errors.New("readings must be a flat array of numbers")}
+		}
+		dst.Readings = append(dst.Readings, v)
+	}
+}
+
+// parseMetadata keeps string values only; numbers, booleans and nested
+// values are skipped so the output stays a flat list of string pairs. It
+// reports whether the value was an object at all.
+func (sp *SensorParser) parseMetadata(dst *SensorData) (isObject bool, err error) {
+	t, err := sp.token()
+	if err != nil {
+		return false, err
+	}
+	delim, ok := t.(json.Delim)
+	if !ok {
+		return false, nil // scalar: nothing to keep, value already consumed
+	}
+	if delim != '{' {
+		return false, sp.skipNested()
+	}
 
+	for {
+		t, err := sp.token()
+		if err != nil {
+			return false, err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == '}' {
+			return true, nil
+		}
+		key, ok := t.(string)
+		if !ok {
+			return false, errors.New("expected metadata key")
+		}
+
+		t, err = sp.token()
+		if err != nil {
+			return false, err
+		}
 		switch v := t.(type) {
+		case string:
+			dst.Metadata = append(dst.Metadata, MetadataPair{Key: key, Value: v})
 		case json.Delim:
-			switch v {
-			case '{', '[':
-				depth++
-			case '}', ']':
-				depth--
+			if err := sp.skipNested(); err != nil {
+				return false, err
 			}
-		case string:
-			switch v {
-			case SensorIDKey:
-				t, err := sp.dec.Token()
-				if err != nil {
-					return nil, err
-				}
-				if sensorID, ok := t.(string); ok {
-					data.SensorID = sensorID
-					hasSensorID = true
-				}
-			case ReadingsKey:
-				t, err := sp.dec.Token()
-				if err != nil {
-					return nil, err
-				}
+		}
+	}
+}
 
-				if delim, ok := t.(json.Delim); !ok || delim != '[' {
-					return nil, errors.New("unexpected end of JSON input")
-				}
+// malformed counts a field of the wrong type, failing the record under
+// WithFailOnInvalid. Otherwise the field is dropped: a misplaced object or
+// array is skipped as well, so it does not derail the enclosing record.
+func (sp *SensorParser) malformed(key string, t json.Token) error {
+	sp.stats.malformed.Add(1)
+	if sp.failOnInvalid {
+		return schemaError{fmt.Errorf("field %q has the wrong type", key)}
+	}
+	if _, ok := t.(json.Delim); ok {
+		return sp.skipNested()
+	}
+	return nil
+}
+
+// skipValue consumes the next value, however deeply nested.
+func (sp *SensorParser) skipValue() error {
+	t, err := sp.token()
+	if err != nil {
+		return err
+	}
+	if _, ok := t.(json.Delim); ok {
+		return sp.skipNested()
+	}
+	return nil
+}
 
+// skipNested consumes tokens until the object or array just opened is closed.
+func (sp *SensorParser) skipNested() error {
+	for depth := 1; depth > 0; {
+		t, err := sp.token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := t.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
 				depth++
-				t, err = sp.dec.Token()
-				if err != nil {
-					return nil, err
-				}
-				if value, ok := t.(float64); ok {
-					data.Value = value
-					hasReadings = true
-				}
+			case '}', ']':
+				depth--
 			}
 		}
-		if depth == 0 {
-			break
-		}
-	}
-	if hasSensorID && hasReadings {
-		return data, nil
 	}
-	return nil, errors.New("no valid sensor data found")
+	return nil
 }
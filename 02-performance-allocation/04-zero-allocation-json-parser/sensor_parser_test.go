@@ -2,11 +2,24 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // 1. Functional & Corruption Tests (Table-Driven)
@@ -20,7 +33,7 @@ func TestSensorParser_Parse(t *testing.T) {
 			name:  "Single valid object",
 			input: `{"sensor_id": "temp-1", "readings": [22.1, 22.3]}`,
 			expected: []SensorData{
-				{SensorID: "temp-1", Value: 22.1},
+				{SensorID: "temp-1", Value: 22.1, Readings: []float64{22.1, 22.3}},
 			},
 		},
 		{
@@ -30,8 +43,8 @@ func TestSensorParser_Parse(t *testing.T) {
 				{"sensor_id": "temp-2", "readings": [23.1]}
 			`,
 			expected: []SensorData{
-				{SensorID: "temp-1", Value: 22.1},
-				{SensorID: "temp-2", Value: 23.1},
+				{SensorID: "temp-1", Value: 22.1, Readings: []float64{22.1}},
+				{SensorID: "temp-2", Value: 23.1, Readings: []float64{23.1}},
 			},
 		},
 		{
@@ -42,8 +55,8 @@ func TestSensorParser_Parse(t *testing.T) {
 				{"sensor_id": "good-2", "readings": [20.0]}
 			`,
 			expected: []SensorData{
-				{SensorID: "good-1", Value: 10.0},
-				{SensorID: "good-2", Value: 20.0},
+				{SensorID: "good-1", Value: 10.0, Readings: []float64{10.0}},
+				{SensorID: "good-2", Value: 20.0, Readings: []float64{20.0}},
 			},
 		},
 		{
@@ -54,7 +67,27 @@ func TestSensorParser_Parse(t *testing.T) {
 				{"sensor_id": "good-3", "readings": [30.0]}
 			`,
 			expected: []SensorData{
-				{SensorID: "good-3", Value: 30.0},
+				{SensorID: "good-3", Value: 30.0, Readings: []float64{30.0}},
+			},
+		},
+		{
+			name:  "All readings, timestamp and flat metadata",
+			input: `{"sensor_id": "temp-9", "timestamp": 1234567890, "readings": [1.5, 2.5, 3.5], "metadata": {"site": "north", "floor": 3, "tags": ["a"], "room": "b12"}}`,
+			expected: []SensorData{
+				{
+					SensorID:  "temp-9",
+					Value:     1.5,
+					Readings:  []float64{1.5, 2.5, 3.5},
+					Timestamp: 1234567890,
+					Metadata:  []MetadataPair{{Key: "site", Value: "north"}, {Key: "room", Value: "b12"}},
+				},
+			},
+		},
+		{
+			name:  "Nested keys do not shadow top-level fields",
+			input: `{"metadata": {"sensor_id": "nested"}, "extra": {"readings": [9.9]}, "sensor_id": "top", "readings": [1.0]}`,
+			expected: []SensorData{
+				{SensorID: "top", Value: 1.0, Readings: []float64{1.0}, Metadata: []MetadataPair{{Key: "sensor_id", Value: "nested"}}},
 			},
 		},
 	}
@@ -86,7 +119,7 @@ func TestSensorParser_Parse(t *testing.T) {
 				if i >= len(tt.expected) {
 					break
 				}
-				if results[i] != tt.expected[i] {
+				if !reflect.DeepEqual(results[i], tt.expected[i]) {
 					t.Errorf("Result %d: expected %+v, got %+v", i, tt.expected[i], results[i])
 				}
 			}
@@ -94,6 +127,79 @@ func TestSensorParser_Parse(t *testing.T) {
 	}
 }
 
+func TestSensorParser_WithFields(t *testing.T) {
+	input := `
+		{"id": "dev-1", "ts": 42, "values": [1.5, 2.5], "sensor_id": "ignored"}
+		{"id": "dev-2", "values": [3.5]}
+		{"id": "dev-3", "ts": 43, "values": [4.5], "metadata": [1, 2]}
+	`
+	parser := NewSensorParser(strings.NewReader(input), WithFields(map[string]FieldSpec{
+		"id":     {Target: TargetSensorID, Required: true},
+		"values": {Target: TargetReadings, Required: true},
+		"ts":     {Target: TargetTimestamp, Required: true},
+	}))
+
+	var results []SensorData
+	for {
+		data, err := parser.Parse(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error during parse: %v", err)
+		}
+		results = append(results, *data)
+	}
+
+	expected := []SensorData{
+		{SensorID: "dev-1", Value: 1.5, Readings: []float64{1.5, 2.5}, Timestamp: 42},
+		{SensorID: "dev-3", Value: 4.5, Readings: []float64{4.5}, Timestamp: 43},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestSensorParser_OptionalFieldsMayBeMissing(t *testing.T) {
+	parser := NewSensorParser(strings.NewReader(`{"sensor_id": "a", "readings": [1]}`), WithFields(map[string]FieldSpec{
+		"sensor_id": {Target: TargetSensorID, Required: true},
+		"readings":  {Target: TargetReadings},
+		"metadata":  {Target: TargetMetadata},
+	}))
+	data, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.SensorID != "a" || data.Value != 1 {
+		t.Errorf("unexpected record %+v", data)
+	}
+}
+
+// A record rejected mid-way must be skipped whole: resyncing to the next
+// '{' would land inside its metadata and return the nested object instead.
+func TestSensorParser_SchemaErrorSkipsWholeRecord(t *testing.T) {
+	const input = `{"sensor_id": "bad", "readings": "n/a", "metadata": {"sensor_id": "ghost", "readings": [9]}}` +
+		` {"sensor_id": "good", "readings": [1]}`
+	var offsets []int64
+	parser := NewSensorParser(strings.NewReader(input), WithCorruptionHandler(func(offset int64, _ []byte, _ error) {
+		offsets = append(offsets, offset)
+	}))
+
+	data, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.SensorID != "good" {
+		t.Errorf("expected sensor_id good, got %q", data.SensorID)
+	}
+	if _, err := parser.Parse(context.Background()); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if !reflect.DeepEqual(offsets, []int64{0}) {
+		t.Errorf("expected one corruption at offset 0, got %v", offsets)
+	}
+}
+
 // 2. The Allocation Test
 func BenchmarkSensorParser_Parse(b *testing.B) {
 	input := `{"sensor_id": "bench-1", "timestamp": 1234567890, "readings": [22.1, 22.3, 22.0], "metadata": {"foo": "bar"}}`
@@ -121,7 +227,1598 @@ func BenchmarkSensorParser_Parse(b *testing.B) {
 	}
 }
 
+func BenchmarkSensorParser_ParseInto(b *testing.B) {
+	input := `{"sensor_id": "bench-1", "timestamp": 1234567890, "readings": [22.1, 22.3, 22.0], "metadata": {"foo": "bar"}}`
+	data := []byte(strings.Repeat(input+"\n", b.N+1))
+	parser := NewSensorParser(bytes.NewReader(data))
+	ctx := context.Background()
+	var dst SensorData
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := parser.ParseInto(ctx, &dst); err != nil {
+			if err == io.EOF {
+				break
+			}
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSensorParser_ParseBatch parses batches of 1000 records with
+// Parse and drops them, as a batch job does, with records on the heap or in
+// a RecordArena reset after every batch. It reports garbage collections per
+// batch: gc/op.
+func BenchmarkSensorParser_ParseBatch(b *testing.B) {
+	const batch = 1000
+	record := `{"sensor_id": "bench-1", "timestamp": 1234567890, "readings": [22.1, 22.3, 22.0], "metadata": {"foo": "bar"}}` + "\n"
+	run := func(b *testing.B, arena *RecordArena) {
+		var opts []Option
+		if arena != nil {
+			opts = append(opts, WithArena(arena))
+		}
+		parser := NewSensorParser(&endlessReader{record: record}, opts...)
+		ctx := context.Background()
+		recs := make([]*SensorData, 0, batch)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		b.ReportAllocs()
+		for b.Loop() {
+			recs = recs[:0]
+			for range batch {
+				rec, err := parser.Parse(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				recs = append(recs, rec)
+			}
+			if arena != nil {
+				arena.Reset()
+			}
+		}
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+	}
+
+	b.Run("Heap", func(b *testing.B) { run(b, nil) })
+	b.Run("Arena", func(b *testing.B) { run(b, NewRecordArena(batch)) })
+}
+
+// BenchmarkSensorParser_LargeMetadata measures records dominated by a
+// payload the parser does not keep.
+func BenchmarkSensorParser_LargeMetadata(b *testing.B) {
+	var extra strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&extra, `, "attr_%d": {"unit": "celsius", "calibrated": true, "history": [1.5, 2.5, 3.5]}`, i)
+	}
+	input := `{"sensor_id": "bench-1", "timestamp": 1234567890` + extra.String() + `, "readings": [22.1, 22.3, 22.0]}`
+	data := []byte(strings.Repeat(input+"\n", b.N+1))
+	parser := NewSensorParser(bytes.NewReader(data))
+	ctx := context.Background()
+	var dst SensorData
+
+	b.SetBytes(int64(len(input) + 1))
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := parser.ParseInto(ctx, &dst); err != nil {
+			if err == io.EOF {
+				break
+			}
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSensorParser_Resync measures a stream where every record is
+// preceded by garbage, so each ParseInto goes through one resync.
+func BenchmarkSensorParser_Resync(b *testing.B) {
+	input := `#### garbage #### {"sensor_id": "bench-1", "readings": [22.1]} {"sensor_id": oops} `
+	data := []byte(strings.Repeat(input, b.N+1))
+	parser := NewSensorParser(bytes.NewReader(data))
+	ctx := context.Background()
+	var dst SensorData
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := parser.ParseInto(ctx, &dst); err != nil {
+			if err == io.EOF {
+				break
+			}
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSensorParser_ParseIntoReusesRecord(t *testing.T) {
+	input := `
+		{"sensor_id": "a", "readings": [1, 2, 3], "metadata": {"k": "v"}}
+		{"sensor_id": "b", "readings": [4]}
+	`
+	parser := NewSensorParser(strings.NewReader(input))
+	ctx := context.Background()
+	var dst SensorData
+
+	if err := parser.ParseInto(ctx, &dst); err != nil {
+		t.Fatalf("first ParseInto: %v", err)
+	}
+	backing := &dst.Readings[0]
+
+	if err := parser.ParseInto(ctx, &dst); err != nil {
+		t.Fatalf("second ParseInto: %v", err)
+	}
+	expected := SensorData{SensorID: "b", Value: 4, Readings: []float64{4}, Metadata: []MetadataPair{}}
+	if !reflect.DeepEqual(dst, expected) {
+		t.Errorf("expected %+v, got %+v (stale fields must be reset)", expected, dst)
+	}
+	if &dst.Readings[0] != backing {
+		t.Error("Readings backing array was reallocated instead of reused")
+	}
+
+	if err := parser.ParseInto(ctx, &dst); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestSensorParser_WithArena(t *testing.T) {
+	var sb strings.Builder
+	for i := range 100 {
+		fmt.Fprintf(&sb, `{"sensor_id": "s-%d", "timestamp": %d, "readings": [%d, 1.5], "metadata": {"n": "%d"}}`+"\n", i, 1700000000+i, i, i)
+	}
+	input := sb.String()
+	ctx := context.Background()
+
+	var heap []*SensorData
+	for parser := NewSensorParser(strings.NewReader(input)); ; {
+		rec, err := parser.Parse(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		heap = append(heap, rec)
+	}
+
+	arena := NewRecordArena(32)
+	parser := NewSensorParser(strings.NewReader(input), WithArena(arena))
+	for i, want := range heap {
+		rec, err := parser.Parse(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.SensorID != want.SensorID || rec.Value != want.Value || rec.Timestamp != want.Timestamp ||
+			!slices.Equal(rec.Readings, want.Readings) || !slices.Equal(rec.Metadata, want.Metadata) {
+			t.Fatalf("record %d: heap %+v, arena %+v", i, *want, *rec)
+		}
+		if cap(rec.Readings) != len(rec.Readings) {
+			t.Fatalf("record %d: readings have spare capacity %d, appending would overwrite the next record", i, cap(rec.Readings))
+		}
+	}
+	if _, err := parser.Parse(ctx); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+// TestSensorParser_WithArenaAllocs checks that records, readings and
+// metadata come from a warm arena: Parse then costs about as much as
+// ParseInto into a reused record, whose allocations are the decoder's
+// strings, rather than several more per record. The decoder's own count
+// drifts a little between runs, so the test allows less than one extra
+// allocation per record.
+func TestSensorParser_WithArenaAllocs(t *testing.T) {
+	const batch = 100
+	src := &endlessReader{record: `{"sensor_id": "bench-1", "timestamp": 1234567890, "readings": [22.1, 22.3, 22.0], "metadata": {"foo": "bar"}}` + "\n"}
+	arena := NewRecordArena(batch)
+	parser := NewSensorParser(src, WithArena(arena))
+	ctx := context.Background()
+	var dst SensorData
+
+	parseInto := func() {
+		for range batch {
+			if err := parser.ParseInto(ctx, &dst); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	parseArena := func() {
+		for range batch {
+			if _, err := parser.Parse(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		arena.Reset()
+	}
+	parseInto()
+	parseArena()
+
+	want := testing.AllocsPerRun(10, parseInto)
+	if got := testing.AllocsPerRun(10, parseArena); got >= want+batch {
+		t.Errorf("expected records to come from the warm arena, got %v allocs per batch against ParseInto's %v", got, want)
+	}
+}
+
+func TestSensorParser_AcquireRelease_OutOfOrder(t *testing.T) {
+	var sb strings.Builder
+	const numRecords = 200
+	for i := 0; i < numRecords; i++ {
+		fmt.Fprintf(&sb, `{"sensor_id": "s-%d", "readings": [%d, %d], "metadata": {"n": "%d"}}`+"\n", i, i, i+1, i)
+	}
+	parser := NewSensorParser(strings.NewReader(sb.String()))
+	ctx := context.Background()
+
+	records := make(chan *SensorData, 16)
+	go func() {
+		defer close(records)
+		for {
+			d := parser.Acquire()
+			if err := parser.ParseInto(ctx, d); err != nil {
+				d.Release()
+				return
+			}
+			records <- d
+		}
+	}()
+
+	// Hold records back and release them in a shuffled order; any record
+	// handed out twice, or reset while still held, shows up as a mismatch.
+	var held []*SensorData
+	seen := 0
+	check := func(d *SensorData) {
+		id := strings.TrimPrefix(d.SensorID, "s-")
+		if d.Metadata[0].Value != id || fmt.Sprint(d.Readings[0]) != id {
+			t.Errorf("record corrupted: %+v", d)
+		}
+	}
+	for d := range records {
+		held = append(held, d)
+		seen++
+		if len(held) == 7 {
+			for _, i := range []int{3, 0, 6, 1, 5, 2, 4} {
+				check(held[i])
+				held[i].Release()
+			}
+			held = held[:0]
+		}
+	}
+	for _, d := range held {
+		check(d)
+		d.Release()
+	}
+	if seen != numRecords {
+		t.Errorf("expected %d records, got %d", numRecords, seen)
+	}
+}
+
+func TestSensorData_ReleaseClearsAndIsIdempotent(t *testing.T) {
+	parser := NewSensorParser(strings.NewReader(`{"sensor_id": "a", "readings": [1], "metadata": {"k": "v"}}`))
+	d := parser.Acquire()
+	if err := parser.ParseInto(context.Background(), d); err != nil {
+		t.Fatalf("ParseInto: %v", err)
+	}
+	md := d.Metadata[:1]
+
+	d.Release()
+	d.Release() // must not put the record in the pool twice
+
+	if d.SensorID != "" || len(d.Readings) != 0 || md[0] != (MetadataPair{}) {
+		t.Errorf("released record still references old data: %+v, metadata %+v", d, md)
+	}
+
+	var notPooled SensorData
+	notPooled.Release() // no-op for records that did not come from Acquire
+}
+
 // 3. The Stream Test (Large Input)
+func TestSensorParser_ParseStream(t *testing.T) {
+	var sb strings.Builder
+	const n = 500
+	for i := range n {
+		fmt.Fprintf(&sb, `{"sensor_id": "s-%d", "readings": [%d]}`+"\n", i, i)
+		if i%50 == 0 {
+			sb.WriteString("{bad json\n\n")
+		}
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		parser := NewSensorParser(strings.NewReader(sb.String()), WithOrderedStream())
+		records, errc := parser.ParseStream(context.Background(), 8)
+
+		i := 0
+		for d := range records {
+			if want := fmt.Sprintf("s-%d", i); d.SensorID != want || d.Value != float64(i) {
+				t.Fatalf("record %d: got %s/%v, want %s/%d", i, d.SensorID, d.Value, want, i)
+			}
+			d.Release()
+			i++
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if i != n {
+			t.Errorf("expected %d records, got %d", n, i)
+		}
+	})
+
+	t.Run("unordered", func(t *testing.T) {
+		parser := NewSensorParser(strings.NewReader(sb.String()))
+		records, errc := parser.ParseStream(context.Background(), 8)
+
+		seen := make(map[string]bool)
+		for d := range records {
+			seen[d.SensorID] = true
+			d.Release()
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(seen) != n {
+			t.Errorf("expected %d distinct records, got %d", n, len(seen))
+		}
+	})
+}
+
+func TestSensorParser_ParseStream_Cancel(t *testing.T) {
+	line := []byte(`{"sensor_id": "s", "readings": [1]}` + "\n")
+	parser := NewSensorParser(&RepeatingReader{Data: line, Count: 1_000_000})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errc := parser.ParseStream(ctx, 4)
+
+	for range 10 {
+		(<-records).Release()
+	}
+	cancel()
+	for d := range records {
+		d.Release()
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSensorParser_CorruptionHandler(t *testing.T) {
+	input := `{"sensor_id": "a", "readings": [1]} garbage!! {"sensor_id": "b", "readings": [2]}` +
+		"\n" + strings.Repeat("x", 1000) + `{"sensor_id": "c", "readings": [3]}`
+
+	type report struct {
+		offset   int64
+		fragment string
+	}
+	var reports []report
+	parser := NewSensorParser(strings.NewReader(input), WithCorruptionHandler(func(offset int64, fragment []byte, err error) {
+		if err == nil {
+			t.Errorf("report at %d has no cause", offset)
+		}
+		reports = append(reports, report{offset, string(fragment)})
+	}))
+
+	var ids []string
+	for {
+		d, err := parser.Parse(context.Background())
+		if err != nil {
+			break
+		}
+		ids = append(ids, d.SensorID)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected records %v, got %v", want, ids)
+	}
+	if parser.InputOffset() != int64(len(input)) {
+		t.Errorf("expected final offset %d, got %d", len(input), parser.InputOffset())
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 corruption reports, got %d: %+v", len(reports), reports)
+	}
+	if r := reports[0]; r.offset != int64(strings.Index(input, " garbage")) || r.fragment != " garbage!! " {
+		t.Errorf("unexpected first report: %+v", r)
+	}
+	if r := reports[1]; r.offset != int64(strings.Index(input, "\n")) || len(r.fragment) != maxFragmentBytes {
+		t.Errorf("expected second report at %d capped to %d bytes, got offset %d, %d bytes",
+			strings.Index(input, "\n"), maxFragmentBytes, r.offset, len(r.fragment))
+	}
+}
+
+func TestSensorParser_ParseStream_CorruptionOffsets(t *testing.T) {
+	input := `{"sensor_id": "a", "readings": [1]}` + "\n" + `oops{"sensor_id": "b", "readings": [2]}` + "\n"
+
+	offsets := make(chan int64, 1)
+	parser := NewSensorParser(strings.NewReader(input), WithCorruptionHandler(func(offset int64, _ []byte, _ error) {
+		offsets <- offset
+	}))
+	records, errc := parser.ParseStream(context.Background(), 2)
+	for d := range records {
+		d.Release()
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := <-offsets, int64(strings.Index(input, "oops")); got != want {
+		t.Errorf("expected absolute offset %d, got %d", want, got)
+	}
+}
+
+func TestSensorParser_MaxRecordBytes(t *testing.T) {
+	huge := `{"sensor_id": "big", "readings": [` + strings.Repeat("1.5, ", 10_000) +
+		`1], "metadata": {"nested": {"sensor_id": "phantom", "readings": [9]}}}`
+	input := `{"sensor_id": "a", "readings": [1]}` + huge + `{"sensor_id": "c", "readings": [3]}`
+
+	var reported int
+	parser := NewSensorParser(strings.NewReader(input), WithMaxRecordBytes(200),
+		WithCorruptionHandler(func(offset int64, _ []byte, err error) {
+			if errors.Is(err, ErrRecordTooLarge) && offset == int64(strings.Index(input, huge)) {
+				reported++
+			}
+		}))
+	ctx := context.Background()
+
+	d, err := parser.Parse(ctx)
+	if err != nil || d.SensorID != "a" {
+		t.Fatalf("expected record a, got %+v, %v", d, err)
+	}
+	if _, err := parser.Parse(ctx); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+	if d, err := parser.Parse(ctx); err != nil || d.SensorID != "c" {
+		t.Fatalf("expected record c after the oversized one, got %+v, %v", d, err)
+	}
+	if _, err := parser.Parse(ctx); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if reported != 1 {
+		t.Errorf("expected the oversized record to be reported once, got %d", reported)
+	}
+}
+
+func TestSensorParser_MaxRecordBytesInMalformedField(t *testing.T) {
+	// The oversized value is a field of the wrong type, which the parser
+	// skips; running out of room while skipping must still fail the record.
+	huge := `{"sensor_id": [` + strings.Repeat(`"x", `, 10_000) + `"x"], "readings": [2]}`
+	input := huge + `{"sensor_id": "c", "readings": [3]}`
+
+	parser := NewSensorParser(strings.NewReader(input), WithMaxRecordBytes(200))
+	ctx := context.Background()
+	if d, err := parser.Parse(ctx); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %+v, %v", d, err)
+	}
+	if d, err := parser.Parse(ctx); err != nil || d.SensorID != "c" {
+		t.Fatalf("expected record c after the oversized one, got %+v, %v", d, err)
+	}
+}
+
+func TestSensorParser_ParseStream_MaxRecordBytes(t *testing.T) {
+	input := `{"sensor_id": "a", "readings": [1]}` + "\n" +
+		`{"sensor_id": "big", "readings": [` + strings.Repeat("1, ", 100_000) + "1]}\n" +
+		`{"sensor_id": "c", "readings": [3]}` + "\n"
+
+	var reported atomic.Int32
+	parser := NewSensorParser(strings.NewReader(input), WithOrderedStream(), WithMaxRecordBytes(64),
+		WithCorruptionHandler(func(_ int64, _ []byte, err error) {
+			if errors.Is(err, ErrRecordTooLarge) {
+				reported.Add(1)
+			}
+		}))
+	records, errc := parser.ParseStream(context.Background(), 2)
+
+	var ids []string
+	for d := range records {
+		ids = append(ids, d.SensorID)
+		d.Release()
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"a", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected %v, got %v", want, ids)
+	}
+	if reported.Load() != 1 {
+		t.Errorf("expected one oversized line to be reported, got %d", reported.Load())
+	}
+}
+
+func TestSensorParser_WithDecompression(t *testing.T) {
+	const input = `{"sensor_id": "a", "readings": [1]} {"sensor_id": oops} {"sensor_id": "b", "readings": [2]}`
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(input))
+	zw.Close()
+
+	// A fake zstd decoder: the frame is the magic bytes followed by plain JSON.
+	fakeZstd := func(r io.Reader) (io.Reader, error) {
+		if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	zst := append([]byte{0x28, 0xb5, 0x2f, 0xfd}, input...)
+
+	tests := []struct {
+		name  string
+		input []byte
+		opts  []Option
+	}{
+		{"gzip", gz.Bytes(), []Option{WithDecompression(CompressionGzip)}},
+		{"auto gzip", gz.Bytes(), []Option{WithDecompression(CompressionAuto)}},
+		{"auto plain", []byte(input), []Option{WithDecompression(CompressionAuto)}},
+		{"auto zstd", zst, []Option{WithDecompression(CompressionAuto), WithZstdDecoder(fakeZstd)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewSensorParser(bytes.NewReader(tt.input), tt.opts...)
+			var ids []string
+			for {
+				d, err := parser.Parse(context.Background())
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				ids = append(ids, d.SensorID)
+			}
+			if want := []string{"a", "b"}; !reflect.DeepEqual(ids, want) {
+				t.Errorf("expected %v, got %v", want, ids)
+			}
+		})
+	}
+
+	t.Run("zstd without decoder", func(t *testing.T) {
+		parser := NewSensorParser(bytes.NewReader(zst), WithDecompression(CompressionAuto))
+		if _, err := parser.Parse(context.Background()); !errors.Is(err, ErrUnsupportedCompression) {
+			t.Errorf("expected ErrUnsupportedCompression, got %v", err)
+		}
+	})
+
+	t.Run("truncated gzip", func(t *testing.T) {
+		parser := NewSensorParser(bytes.NewReader(gz.Bytes()[:gz.Len()/2]), WithDecompression(CompressionGzip))
+		var err error
+		for err == nil {
+			_, err = parser.Parse(context.Background())
+		}
+		if err == io.EOF {
+			t.Errorf("expected a read error for a truncated archive, got EOF")
+		}
+	})
+}
+
+const strictInput = `{"sensor_id": "a", "readings": [1]}
+{"sensor_id": "b", "readings": [2]} {"sensor_id": "x", "readings": [9]}
+
+not json {"sensor_id": "y", "readings": [9]}
+{"sensor_id": "c", "readings": [3], "metadata": {"geo": {"lat": 1}, "zone": "{north}"}}
+`
+
+func TestSensorParser_StrictMode(t *testing.T) {
+	parser := NewSensorParser(strings.NewReader(strictInput), WithMode(ModeStrict))
+	ctx := context.Background()
+
+	d, err := parser.Parse(ctx)
+	if err != nil || d.SensorID != "a" {
+		t.Fatalf("expected record a, got %+v, %v", d, err)
+	}
+
+	for _, wantLine := range []int64{2, 4} {
+		_, err := parser.Parse(ctx)
+		var lerr *LineError
+		if !errors.As(err, &lerr) {
+			t.Fatalf("expected *LineError for line %d, got %v", wantLine, err)
+		}
+		wantOffset := int64(len(strings.Join(strings.SplitAfter(strictInput, "\n")[:wantLine-1], "")))
+		if lerr.Line != wantLine || lerr.Offset != wantOffset {
+			t.Errorf("expected line %d at offset %d, got line %d at offset %d", wantLine, wantOffset, lerr.Line, lerr.Offset)
+		}
+	}
+
+	d, err = parser.Parse(ctx)
+	if err != nil || d.SensorID != "c" {
+		t.Fatalf("expected record c, got %+v, %v", d, err)
+	}
+	if want := []MetadataPair{{"zone", "{north}"}}; !reflect.DeepEqual(d.Metadata, want) {
+		t.Errorf("expected metadata %v, got %v", want, d.Metadata)
+	}
+	if _, err := parser.Parse(ctx); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestSensorParser_ParseStream_StrictMode(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		badLines []int64
+	)
+	parser := NewSensorParser(strings.NewReader(strictInput), WithMode(ModeStrict), WithOrderedStream(),
+		WithCorruptionHandler(func(_ int64, _ []byte, err error) {
+			var lerr *LineError
+			if errors.As(err, &lerr) {
+				mu.Lock()
+				badLines = append(badLines, lerr.Line)
+				mu.Unlock()
+			}
+		}))
+	records, errc := parser.ParseStream(context.Background(), 4)
+
+	var ids []string
+	for d := range records {
+		ids = append(ids, d.SensorID)
+		d.Release()
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"a", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected %v, got %v", want, ids)
+	}
+	slices.Sort(badLines)
+	if want := []int64{2, 4}; !reflect.DeepEqual(badLines, want) {
+		t.Errorf("expected bad lines %v, got %v", want, badLines)
+	}
+}
+
+// collectIDs drains any RecordSource, so JSON and CSV go through the same
+// consumer.
+func collectIDs(t *testing.T, src RecordSource) []string {
+	t.Helper()
+	var (
+		ids []string
+		d   SensorData
+	)
+	for {
+		err := src.ParseInto(context.Background(), &d)
+		if err == io.EOF {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, d.SensorID)
+	}
+}
+
+func TestCSVSensorSource(t *testing.T) {
+	const input = `id,ts,r1,r2,site,ignored
+a,1700000000,1.5,2.5,north,x
+b,1700000001,oops,2,south,x
+c,1700000002,3,,"bad"quote,x
+,1700000003,4,5,east,x
+d,1700000004,,6,west,x
+`
+	fields := map[string]FieldSpec{
+		"id":   {Target: TargetSensorID, Required: true},
+		"ts":   {Target: TargetTimestamp},
+		"r1":   {Target: TargetReadings, Required: true},
+		"r2":   {Target: TargetReadings, Required: true},
+		"site": {Target: TargetMetadata},
+	}
+
+	var badLines []int64
+	src := NewCSVSensorSource(strings.NewReader(input), WithCSVFields(fields),
+		WithCSVCorruptionHandler(func(_ int64, _ []byte, err error) {
+			var lerr *LineError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected *LineError, got %v", err)
+			}
+			badLines = append(badLines, lerr.Line)
+		}))
+	ctx := context.Background()
+
+	d, err := src.Parse(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &SensorData{
+		SensorID:  "a",
+		Value:     1.5,
+		Readings:  []float64{1.5, 2.5},
+		Timestamp: 1700000000,
+		Metadata:  []MetadataPair{{"site", "north"}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("expected %+v, got %+v", want, d)
+	}
+
+	d, err = src.Parse(ctx)
+	if err != nil || d.SensorID != "d" || !reflect.DeepEqual(d.Readings, []float64{6}) {
+		t.Fatalf("expected record d with readings [6], got %+v, %v", d, err)
+	}
+	if _, err := src.Parse(ctx); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if want := []int64{3, 4, 5}; !reflect.DeepEqual(badLines, want) {
+		t.Errorf("expected bad lines %v, got %v", want, badLines)
+	}
+}
+
+func TestRecordSource_SameConsumer(t *testing.T) {
+	jsonSrc := NewSensorParser(strings.NewReader(`{"sensor_id": "a", "readings": [1]} {"sensor_id": "b", "readings": [2]}`))
+	csvSrc := NewCSVSensorSource(strings.NewReader("sensor_id;readings\na;1\nb;2\n"), WithCSVComma(';'))
+
+	for name, src := range map[string]RecordSource{"json": jsonSrc, "csv": csvSrc} {
+		if got, want := collectIDs(t, src), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+// rawFrame wraps a hand-written MsgPack payload in a valid frame.
+func rawFrame(payload ...byte) []byte {
+	b := append([]byte{0xc1, 0x5d}, binary.BigEndian.AppendUint32(nil, uint32(len(payload)))...)
+	b = append(b, payload...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+}
+
+func TestFrameParser(t *testing.T) {
+	a := &SensorData{
+		SensorID:  "a",
+		Value:     1.5,
+		Readings:  []float64{1.5, -2},
+		Timestamp: 1700000000,
+		Metadata:  []MetadataPair{{"site", "north"}},
+	}
+	b := &SensorData{SensorID: "b", Value: 7, Readings: []float64{7}}
+
+	var input []byte
+	input = AppendSensorFrame(input, a)
+	input = append(input, "garbage\xc1\x5d\xff\xff\xff\xff"...) // look-alike magic, absurd length
+	corrupt := AppendSensorFrame(nil, &SensorData{SensorID: "x", Readings: []float64{9}})
+	corrupt[10] ^= 0xff // break the checksum
+	input = append(input, corrupt...)
+	input = append(input, rawFrame(0x81, 0xa9, 's', 'e', 'n', 's', 'o', 'r', '_', 'i', 'd', 0xa1, 'y')...) // no readings
+	input = AppendSensorFrame(input, b)
+	input = append(input, AppendSensorFrame(nil, a)[:12]...) // truncated
+
+	var reports []error
+	parser := NewFrameParser(bytes.NewReader(input), WithFrameCorruptionHandler(func(_ int64, _ []byte, err error) {
+		reports = append(reports, err)
+	}))
+
+	var got []*SensorData
+	for {
+		d, err := parser.Parse(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, d)
+	}
+	if want := []*SensorData{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if parser.InputOffset() != int64(len(input)) {
+		t.Errorf("expected to consume %d bytes, consumed %d", len(input), parser.InputOffset())
+	}
+	// The garbage run and the corrupt frame are one skipped run, then the
+	// record without readings, then the truncated tail.
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 corruption reports, got %d: %v", len(reports), reports)
+	}
+	if !errors.Is(reports[0], errNoFrameMagic) || !errors.Is(reports[2], errFrameTruncated) {
+		t.Errorf("unexpected reports: %v", reports)
+	}
+}
+
+func TestFrameParser_MsgpackTypes(t *testing.T) {
+	payload := []byte{
+		0x85,                                                            // map of 5
+		0xa9, 's', 'e', 'n', 's', 'o', 'r', '_', 'i', 'd', 0xd9, 1, 'z', // str8
+		0xa8, 'r', 'e', 'a', 'd', 'i', 'n', 'g', 's',
+		0x94, 0xff, 0xcd, 0x01, 0x00, 0xd0, 0x80, 0xca, 0x3f, 0xc0, 0x00, 0x00, // -1, 256, -128, 1.5
+		0xa5, 'e', 'x', 't', 'r', 'a',
+		0x93, 0xc0, 0xc3, 0xc4, 0x02, 0xaa, 0xbb, // [nil, true, bin]
+		0xa9, 't', 'i', 'm', 'e', 's', 't', 'a', 'm', 'p', 0xce, 0x65, 0x53, 0xf1, 0x00,
+		0xa8, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+		0x82, 0xa1, 'k', 0xa1, 'v', 0xa1, 'n', 0xd4, 0x01, 0x02, // fixext1 value is skipped
+	}
+	parser := NewFrameParser(bytes.NewReader(rawFrame(payload...)))
+
+	d, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &SensorData{
+		SensorID:  "z",
+		Value:     -1,
+		Readings:  []float64{-1, 256, -128, 1.5},
+		Timestamp: 0x6553f100,
+		Metadata:  []MetadataPair{{"k", "v"}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("expected %+v, got %+v", want, d)
+	}
+}
+
+func TestSensorParser_Stats(t *testing.T) {
+	const input = `{"sensor_id": "a", "readings": [1]} ### {"sensor_id": "b", "readings": [2], "timestamp": "soon"}` +
+		` {"sensor_id": 7, "readings": [3]} {"sensor_id": "c", "readings": "none"} {"sensor_id": "d", "readings": [4]}`
+	parser := NewSensorParser(strings.NewReader(input))
+	for {
+		if _, err := parser.Parse(context.Background()); err != nil {
+			break
+		}
+	}
+
+	got := parser.Stats()
+	want := Stats{
+		Records:       3, // a, b (bad timestamp is optional), d
+		BytesConsumed: int64(len(input)),
+		// The garbage and both rejected records, whole.
+		BytesSkipped:    int64(len(" ### ") + len(`{"sensor_id": 7, "readings": [3]}`) + len(`{"sensor_id": "c", "readings": "none"}`)),
+		Resyncs:         3, // garbage, sensor_id 7, readings "none"
+		MalformedFields: 3, // timestamp, sensor_id, readings
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestSensorParser_ParseStream_Stats(t *testing.T) {
+	input := strings.Repeat(`{"sensor_id": "a", "readings": [1]}`+"\n"+"garbage\n", 100)
+	parser := NewSensorParser(strings.NewReader(input))
+	records, errc := parser.ParseStream(context.Background(), 4)
+	for d := range records {
+		d.Release()
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := parser.Stats()
+	if stats.Records != 100 || stats.Resyncs != 100 || stats.BytesConsumed != int64(len(input)) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.BytesSkipped != int64(100*len("garbage\n")) {
+		t.Errorf("expected %d bytes skipped, got %d", 100*len("garbage\n"), stats.BytesSkipped)
+	}
+}
+
+func TestSensorParser_ResyncAcrossChunks(t *testing.T) {
+	garbage := strings.Repeat("garbage ", 3*resyncChunkSize/8)
+	input := `{"sensor_id": "a", "readings": [1]} ` + garbage + `{"sensor_id": "b", "readings": [2]}`
+	// oneByteReader makes every refill return a single byte.
+	for name, r := range map[string]io.Reader{
+		"bulk":     strings.NewReader(input),
+		"one byte": &oneByteReader{r: strings.NewReader(input)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parser := NewSensorParser(r)
+			if got := collectIDs(t, parser); !reflect.DeepEqual(got, []string{"a", "b"}) {
+				t.Fatalf("expected [a b], got %v", got)
+			}
+			if got, want := parser.Stats().BytesSkipped, int64(len(garbage)+1); got != want {
+				t.Errorf("expected %d bytes skipped, got %d", want, got)
+			}
+		})
+	}
+}
+
+// resync scans in place with buffers it keeps, so all it may allocate is
+// the replacement decoder, which json.Decoder cannot do without, and the
+// reader over what the old one had buffered. Resync is therefore not
+// allocation-free; this pins it to a constant per corruption event.
+func TestSensorParser_ResyncAllocs(t *testing.T) {
+	parser := NewSensorParser(strings.NewReader(`### {"sensor_id": "a", "readings": [1]}`))
+	cause := errors.New("test")
+	parser.resync(cause) // grow the reusable buffers
+	allocs := testing.AllocsPerRun(100, func() {
+		parser.resync(cause)
+	})
+	if allocs > 3 {
+		t.Errorf("resync allocated %.1f times; want at most 3", allocs)
+	}
+
+	// rewind puts a consumed record back the same way.
+	raw := []byte(`{"sensor_id": "a", "readings": [1]}`)
+	allocs = testing.AllocsPerRun(100, func() {
+		parser.rewind(raw)
+	})
+	if allocs > 3 {
+		t.Errorf("rewind allocated %.1f times; want at most 3", allocs)
+	}
+}
+
+type oneByteReader struct{ r io.Reader }
+
+func (o *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+func TestAggregator(t *testing.T) {
+	var got []WindowStats
+	agg := NewAggregator(10*time.Second, func(ws WindowStats) error {
+		got = append(got, ws)
+		return nil
+	})
+
+	for _, d := range []SensorData{
+		{SensorID: "s2", Timestamp: 101, Readings: []float64{5}},
+		{SensorID: "s1", Timestamp: 100, Readings: []float64{1, 4}},
+		{SensorID: "s1", Timestamp: 109, Readings: []float64{-2}},
+		{SensorID: "s1", Timestamp: 112, Readings: []float64{7}},  // closes [100, 110)
+		{SensorID: "s2", Timestamp: 99, Readings: []float64{100}}, // late
+	} {
+		if err := agg.Add(&d); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := func(id string, start int64, count int, lo, hi, mean float64) WindowStats {
+		return WindowStats{id, time.Unix(start, 0), time.Unix(start+10, 0), count, lo, hi, mean}
+	}
+	want := []WindowStats{
+		w("s1", 100, 3, -2, 4, 1),
+		w("s2", 100, 1, 5, 5, 5),
+		w("s1", 110, 1, 7, 7, 7),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if agg.Late() != 1 {
+		t.Errorf("expected 1 late record, got %d", agg.Late())
+	}
+}
+
+func TestAggregator_RunWithWriterSink(t *testing.T) {
+	input := `{"sensor_id": "a", "timestamp": 60, "readings": [1, 3]}
+{"sensor_id": "a", "timestamp": 61, "readings": [2]}
+{"sensor_id": "a", "timestamp": 125, "readings": [10]}
+`
+	var out bytes.Buffer
+	agg := NewAggregator(time.Minute, NewWriterSink(&out))
+	records, errc := NewSensorParser(strings.NewReader(input), WithOrderedStream()).ParseStream(context.Background(), 2)
+
+	if err := agg.Run(context.Background(), records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 windows, got %d:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[0], `"count":3,"min":1,"max":3,"mean":2`) ||
+		!strings.Contains(lines[1], `"count":1,"min":10,"max":10,"mean":10`) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestSensorParser_WithCoercion(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		strict  *SensorData // nil: record is skipped
+		lenient *SensorData
+	}{
+		{
+			name:    "integers and scientific notation",
+			input:   `{"sensor_id": "a", "readings": [22, 2.21e1, -1E-1]}`,
+			strict:  &SensorData{SensorID: "a", Value: 22, Readings: []float64{22, 22.1, -0.1}},
+			lenient: &SensorData{SensorID: "a", Value: 22, Readings: []float64{22, 22.1, -0.1}},
+		},
+		{
+			name:    "quoted readings and timestamp",
+			input:   `{"sensor_id": "a", "readings": ["22.1", " 1e2 ", 3], "timestamp": "1700000000"}`,
+			lenient: &SensorData{SensorID: "a", Value: 22.1, Readings: []float64{22.1, 100, 3}, Timestamp: 1700000000},
+		},
+		{
+			name:    "numeric sensor_id",
+			input:   `{"sensor_id": 1234567, "readings": [1]}`,
+			lenient: &SensorData{SensorID: "1234567", Value: 1, Readings: []float64{1}},
+		},
+		{
+			name:  "non-numeric string reading",
+			input: `{"sensor_id": "a", "readings": ["warm"]}`,
+		},
+		{
+			name:  "NaN is not a number",
+			input: `{"sensor_id": "a", "readings": ["NaN"]}`,
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []struct {
+			coercion Coercion
+			want     *SensorData
+		}{{CoercionStrict, tt.strict}, {CoercionLenient, tt.lenient}} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, mode.coercion), func(t *testing.T) {
+				parser := NewSensorParser(strings.NewReader(tt.input), WithCoercion(mode.coercion))
+				d, err := parser.Parse(context.Background())
+				if mode.want == nil {
+					if err != io.EOF {
+						t.Errorf("expected the record to be skipped, got %+v, %v", d, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(d, mode.want) {
+					t.Errorf("expected %+v, got %+v", mode.want, d)
+				}
+			})
+		}
+	}
+}
+
+func TestSensorParser_WithBufferSize(t *testing.T) {
+	var input strings.Builder
+	var want []string
+	for i := range 200 {
+		fmt.Fprintf(&input, `{"sensor_id": "s%d", "readings": [%d]} `, i, i)
+		want = append(want, fmt.Sprintf("s%d", i))
+	}
+	input.WriteString(`### {"sensor_id": "last", "readings": [1]}`)
+	want = append(want, "last")
+
+	// The ring is smaller than a record, and the one-byte reader makes it
+	// fill in as many steps as possible.
+	for name, r := range map[string]func() io.Reader{
+		"bulk":     func() io.Reader { return strings.NewReader(input.String()) },
+		"one byte": func() io.Reader { return &oneByteReader{r: strings.NewReader(input.String())} },
+	} {
+		for _, size := range []int{7, 64, 1 << 16} {
+			t.Run(fmt.Sprintf("%s, size %d", name, size), func(t *testing.T) {
+				parser := NewSensorParser(r(), WithBufferSize(size))
+				if got := collectIDs(t, parser); !reflect.DeepEqual(got, want) {
+					t.Errorf("expected %d records, got %v", len(want), got)
+				}
+			})
+		}
+	}
+}
+
+// endlessReader serves the same record forever and counts what it served.
+type endlessReader struct {
+	record string
+	n      atomic.Int64
+}
+
+func (e *endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = e.record[(e.n.Load()+int64(i))%int64(len(e.record))]
+	}
+	e.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func TestRingBuffer_Watermarks(t *testing.T) {
+	src := &endlessReader{record: `{"sensor_id": "a", "readings": [1]}` + "\n"}
+	b := newRingBuffer(src, 100)
+	buffered := func() (size int, filling bool) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.size, b.filling
+	}
+	waitFor := func(want int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			if size, filling := buffered(); size == want && !filling {
+				return
+			}
+			if time.Now().After(deadline) {
+				size, _ := buffered()
+				t.Fatalf("ring holds %d bytes; want %d", size, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	read := func(n int) {
+		t.Helper()
+		if _, err := io.ReadFull(b, make([]byte, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read(10) // the first fill stops at a full ring
+	waitFor(90)
+
+	read(60) // 30 left, above the low watermark of 25: no refill
+	if size, filling := buffered(); size != 30 || filling {
+		t.Fatalf("size %d, filling %v above the low watermark; want 30, false", size, filling)
+	}
+
+	read(10) // 20 left: refill up to the high watermark
+	waitFor(100)
+	if got := src.n.Load(); got != 180 {
+		t.Errorf("source read %d bytes; want the 80 consumed plus a full ring", got)
+	}
+}
+
+func TestSensorParser_WithBufferSize_BoundsReadAhead(t *testing.T) {
+	const size = 4 << 10
+	src := &endlessReader{record: `{"sensor_id": "a", "readings": [1]}` + "\n"}
+	parser := NewSensorParser(src, WithBufferSize(size))
+	var d SensorData
+	for range 1000 {
+		if err := parser.ParseInto(context.Background(), &d); err != nil {
+			t.Fatal(err)
+		}
+		// A slow consumer: the ring fills up behind it, but no further.
+		if ahead := src.n.Load() - parser.Stats().BytesConsumed; ahead > size {
+			t.Fatalf("source is %d bytes ahead of the parser; want at most %d", ahead, size)
+		}
+	}
+}
+
+func TestSensorParser_InterruptibleReads(t *testing.T) {
+	timeoutCtx := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	write := func(w io.Writer, s string) {
+		go func() { io.WriteString(w, s) }()
+	}
+
+	t.Run("stalled pipe", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		parser := NewSensorParser(pr, WithInterruptibleReads())
+
+		write(pw, `{"sensor_id": "a", "readings": [1]} {"sensor_id": "b", "rea`)
+		if d, err := parser.Parse(context.Background()); err != nil || d.SensorID != "a" {
+			t.Fatalf("expected record a, got %+v, %v", d, err)
+		}
+		if _, err := parser.Parse(timeoutCtx()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded from a stalled read, got %v", err)
+		}
+
+		// b was being decoded whole, so it is read again from its start.
+		write(pw, `dings": [2]} {"sensor_id": "c", "readings": [3]}`)
+		for _, want := range []string{"b", "c"} {
+			if d, err := parser.Parse(context.Background()); err != nil || d.SensorID != want {
+				t.Fatalf("expected record %s after the interruption, got %+v, %v", want, d, err)
+			}
+		}
+	})
+
+	t.Run("strict mode keeps the partial line", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		parser := NewSensorParser(pr, WithInterruptibleReads(), WithMode(ModeStrict))
+
+		write(pw, `{"sensor_id": "a", `)
+		if _, err := parser.Parse(timeoutCtx()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+		write(pw, `"readings": [1]}`+"\n")
+		if d, err := parser.Parse(context.Background()); err != nil || d.SensorID != "a" {
+			t.Fatalf("expected record a, got %+v, %v", d, err)
+		}
+	})
+
+	t.Run("conn deadline", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+		parser := NewSensorParser(client, WithReadDeadline(20*time.Millisecond))
+
+		write(server, `{"sensor_id": "a", "readings": [1]}`)
+		if d, err := parser.Parse(context.Background()); err != nil || d.SensorID != "a" {
+			t.Fatalf("expected record a, got %+v, %v", d, err)
+		}
+		if _, err := parser.Parse(context.Background()); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+		}
+		write(server, `{"sensor_id": "b", "readings": [2]}`)
+		if d, err := parser.Parse(context.Background()); err != nil || d.SensorID != "b" {
+			t.Fatalf("expected record b, got %+v, %v", d, err)
+		}
+	})
+
+	t.Run("conn cancel", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+		parser := NewSensorParser(client, WithInterruptibleReads())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		start := time.Now()
+		if _, err := parser.Parse(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("cancellation took %v", elapsed)
+		}
+	})
+}
+
+func TestSensorParser_WithEnvelope(t *testing.T) {
+	t.Run("batches", func(t *testing.T) {
+		const input = `{"meta": {"v": [1, {"x": 2}]}, "events": [
+			{"sensor_id": "a", "readings": [1]},
+			{"sensor_id": "bad", "readings": "nope"},
+			{"sensor_id": "b", "readings": [2]}
+		], "trailer": true}
+		{"events": []}
+		{"other": 1}
+		{"events": [{"sensor_id": "c", "readings": [3]}, 42]}`
+
+		var reported []string
+		parser := NewSensorParser(strings.NewReader(input), WithEnvelope("events"),
+			WithCorruptionHandler(func(_ int64, _ []byte, err error) {
+				reported = append(reported, err.Error())
+			}))
+		if got, want := collectIDs(t, parser), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		want := []string{
+			"readings must be an array",
+			"no record array at envelope path",
+			"batch entry is not an object",
+		}
+		if !reflect.DeepEqual(reported, want) {
+			t.Errorf("expected reports %q, got %q", want, reported)
+		}
+		if s := parser.Stats(); s.Records != 3 || s.Resyncs != 3 {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	})
+
+	t.Run("nested path", func(t *testing.T) {
+		const input = `{"data": {"page": 1, "items": [{"sensor_id": "a", "readings": [0]}]}, "next": null}` +
+			`{"data": {"items": {"sensor_id": "x"}}}` +
+			`{"data": {"items": [{"sensor_id": "b", "readings": [0]}]}}`
+		parser := NewSensorParser(strings.NewReader(input), WithEnvelope("data.items"))
+		if got, want := collectIDs(t, parser), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("syntax error resyncs to next envelope", func(t *testing.T) {
+		const input = `{"events": [{"sensor_id": "a", "readings": [1]}, {"sensor_id": "b", "readings": [,]}, {"sensor_id": "lost", "readings": [0]}]}` + "\n" +
+			`{"events": [{"sensor_id": "c", "readings": [0]}]}`
+		parser := NewSensorParser(strings.NewReader(input), WithEnvelope("events"))
+		if got, want := collectIDs(t, parser), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if parser.Stats().Resyncs == 0 {
+			t.Error("expected a resync")
+		}
+	})
+
+	t.Run("top-level array without option", func(t *testing.T) {
+		const input = `[{"sensor_id": "a", "readings": [0]}, {"sensor_id": "b", "readings": [0]}]`
+		if got, want := collectIDs(t, NewSensorParser(strings.NewReader(input))), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestSensorParser_WithFailOnInvalid(t *testing.T) {
+	const input = `{"sensor_id": "a", "readings": [1]}
+{"sensor_id": "b", "meta": {"x": [1, 2]}}
+{"sensor_id": "c", "readings": [1], "timestamp": "soon", "extra": {"y": []}}
+{"sensor_id": "d", "readings": [2]}
+`
+	type result struct {
+		id     string
+		offset int64
+		reason string
+	}
+	collect := func(src RecordSource) []result {
+		var (
+			got []result
+			d   SensorData
+		)
+		for {
+			err := src.ParseInto(context.Background(), &d)
+			if err == io.EOF {
+				return got
+			}
+			var re *RecordError
+			switch {
+			case errors.As(err, &re):
+				got = append(got, result{offset: re.Offset, reason: re.Err.Error()})
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			default:
+				got = append(got, result{id: d.SensorID})
+			}
+		}
+	}
+	want := []result{
+		{id: "a"},
+		{offset: 36, reason: `missing required field "readings"`},
+		{offset: 78, reason: `field "timestamp" has the wrong type`},
+		{id: "d"},
+	}
+
+	for _, mode := range []Mode{ModeLenient, ModeStrict} {
+		parser := NewSensorParser(strings.NewReader(input), WithFailOnInvalid(), WithMode(mode))
+		if got := collect(parser); !reflect.DeepEqual(got, want) {
+			t.Errorf("mode %v: expected %+v, got %+v", mode, want, got)
+		}
+	}
+
+	t.Run("envelope", func(t *testing.T) {
+		const input = `{"events": [{"sensor_id": "a", "readings": [1]}, {"sensor_id": "b", "readings": {}}, {"sensor_id": "c", "readings": [2]}]}`
+		parser := NewSensorParser(strings.NewReader(input), WithEnvelope("events"), WithFailOnInvalid())
+		want := []result{{id: "a"}, {offset: 49, reason: "readings must be an array"}, {id: "c"}}
+		if got := collect(parser); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		parser := NewSensorParser(strings.NewReader(input), WithFailOnInvalid(), WithOrderedStream())
+		records, errc := parser.ParseStream(context.Background(), 4)
+		var ids []string
+		for d := range records {
+			ids = append(ids, d.SensorID)
+		}
+		var re *RecordError
+		if err := <-errc; !errors.As(err, &re) || re.Offset != 36 {
+			t.Fatalf("expected *RecordError at offset 36, got %v", err)
+		}
+		if !reflect.DeepEqual(ids, []string{"a"}) {
+			t.Errorf("expected [a] before the error, got %v", ids)
+		}
+	})
+}
+
+func TestSensorParser_FastPathMatchesTokenPath(t *testing.T) {
+	// The first record always goes through the token path, since nothing
+	// is buffered yet; the rest fit in the decoder's buffer.
+	const input = `{"sensor_id": "first", "readings": [0]}
+{"sensor_id": "a", "readings": [1, 2.5e1, -3], "timestamp": 17, "metadata": {"k": "v", "n": 1, "o": {"x": "y"}}}
+{"note": "brace } and \"quote\" {\\", "sensor_id": "bé\"", "deep": [[{"readings": "x"}], {}], "readings": [4]}
+{"sensor_id": "\u00e9\ud83d\ude00\ud800\u0041\udc00\t\/\\", "readings": [4.5], "metadata": {"\u006b": "\"v\""}}
+{"sensor_id": "c", "readings": [5], "timestamp": "soon", "metadata": [1]}
+{"sensor_id": "d", "readings": ["6"]}
+{"sensor_id": 7, "readings": [7]}
+{"sensor_id": "e", "readings": [8], "metadata": {"a": "1"}, "readings": [9]}
+{"sensor_id": "f"}
+{"sensor_id": "g", "readings": [1e400]}
+{"sensor_id": "h", "readings": [oops]}
+{"sensor_id": "i", "readings": [10]} [{"sensor_id": "j", "readings": [11]}]
+`
+	type run struct {
+		records []SensorData
+		offsets []int64
+		stats   Stats
+	}
+	parse := func(opts ...Option) run {
+		var r run
+		opts = append(opts, WithCorruptionHandler(func(offset int64, _ []byte, _ error) {
+			r.offsets = append(r.offsets, offset)
+		}))
+		parser := NewSensorParser(strings.NewReader(input), opts...)
+		for {
+			d, err := parser.Parse(context.Background())
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			r.records = append(r.records, *d)
+		}
+		r.stats = parser.Stats()
+		return r
+	}
+
+	for _, c := range []Coercion{CoercionStrict, CoercionLenient} {
+		fast := parse(WithCoercion(c))
+		// A record size limit keeps every record on the token path.
+		slow := parse(WithCoercion(c), WithMaxRecordBytes(1<<20))
+		if !reflect.DeepEqual(fast, slow) {
+			t.Errorf("coercion %v: fast path gave\n%+v\ntoken path gave\n%+v", c, fast, slow)
+		}
+	}
+}
+
+func TestRawString(t *testing.T) {
+	for _, q := range []string{
+		`""`,
+		`"plain"`,
+		`"\"\\\/\b\f\n\r\t"`,
+		`"caf\u00e9 \u00E9"`,
+		`"\ud83d\ude00"`,
+		`"lone \ud800 and \udc00"`,
+		`"\ud800\ud800\udc00"`,
+		`"\ud83dx"`,
+	} {
+		var want string
+		if err := json.Unmarshal([]byte(q), &want); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if got := rawString([]byte(q)); got != want {
+			t.Errorf("rawString(%s) = %q; want %q", q, got, want)
+		}
+	}
+}
+
+func TestSensorParser_WithValidation(t *testing.T) {
+	now := time.Now().Unix()
+	input := fmt.Sprintf(`{"sensor_id": "a", "readings": [1, 50], "timestamp": %d}
+{"sensor_id": "b", "readings": [-5, 20, 120]}
+{"sensor_id": "  ", "readings": [1]}
+{"sensor_id": "c", "readings": [1], "timestamp": %d}
+{"sensor_id": "d", "readings": [1], "timestamp": 1}
+{"sensor_id": "e", "readings": [2]}
+`, now, now+3600)
+	rules := WithValidation(ReadingsWithin(0, 100), NonEmptySensorID(), TimestampWithin(24*time.Hour, time.Minute))
+
+	wantViolations := [][]Violation{
+		{
+			{Field: TargetReadings, Index: 0, Reason: "-5 outside [0, 100]"},
+			{Field: TargetReadings, Index: 2, Reason: "120 outside [0, 100]"},
+		},
+		{{Field: TargetSensorID, Index: -1, Reason: "empty"}},
+		{{Field: TargetTimestamp, Index: -1, Reason: fmt.Sprintf("%d is more than 1m0s ahead", now+3600)}},
+		{{Field: TargetTimestamp, Index: -1, Reason: "1 is more than 24h0m0s old"}},
+	}
+
+	for _, mode := range []Mode{ModeLenient, ModeStrict} {
+		t.Run(fmt.Sprintf("skip, mode %v", mode), func(t *testing.T) {
+			var got [][]Violation
+			parser := NewSensorParser(strings.NewReader(input), rules, WithMode(mode),
+				WithCorruptionHandler(func(_ int64, _ []byte, err error) {
+					var ve *ValidationError
+					if !errors.As(err, &ve) {
+						t.Errorf("expected *ValidationError, got %v", err)
+						return
+					}
+					got = append(got, ve.Violations)
+				}))
+			var ids []string
+			for {
+				d, err := parser.Parse(context.Background())
+				if err == io.EOF {
+					break
+				}
+				if err == nil {
+					ids = append(ids, d.SensorID)
+				}
+			}
+			if !reflect.DeepEqual(ids, []string{"a", "e"}) {
+				t.Errorf("expected [a e], got %v", ids)
+			}
+			if !reflect.DeepEqual(got, wantViolations) {
+				t.Errorf("expected violations %+v, got %+v", wantViolations, got)
+			}
+			// Rejections are not corruption.
+			if s := parser.Stats(); s.Rejected != 4 || s.Resyncs != 0 || s.BytesSkipped != 0 {
+				t.Errorf("expected 4 rejected and nothing skipped, got %+v", s)
+			}
+		})
+	}
+
+	for _, mode := range []Mode{ModeLenient, ModeStrict} {
+		t.Run(fmt.Sprintf("fail on invalid, mode %v", mode), func(t *testing.T) {
+			parser := NewSensorParser(strings.NewReader(input), rules, WithFailOnInvalid(), WithMode(mode))
+			var (
+				d       SensorData
+				ids     []string
+				offsets []int64
+			)
+			for {
+				err := parser.ParseInto(context.Background(), &d)
+				if err == io.EOF {
+					break
+				}
+				var (
+					re *RecordError
+					ve *ValidationError
+				)
+				switch {
+				case errors.As(err, &re) && errors.As(err, &ve):
+					offsets = append(offsets, re.Offset)
+				case err != nil:
+					t.Fatalf("unexpected error: %v", err)
+				default:
+					ids = append(ids, d.SensorID)
+				}
+			}
+			if !reflect.DeepEqual(ids, []string{"a", "e"}) {
+				t.Errorf("expected [a e], got %v", ids)
+			}
+			if len(offsets) != 4 || offsets[0] != int64(strings.Index(input, `{"sensor_id": "b"`)) {
+				t.Errorf("unexpected record offsets %v", offsets)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	const input = `garbage {"sensor_id": "a", "readings": ["1.5", 2e-7, 3e21], "timestamp": 1700000000, "metadata": {"site": "north, \"b\"", "n": 1}}
+{"sensor_id": 42, "readings": [4], "junk": {"x": [1]}}
+{"sensor_id": "tab\there\u0001", "readings": [7], "timestamp": 1}
+{"sensor_id": "c", "readings": [5, 6]}
+`
+	parse := func(input string) RecordSource {
+		return NewSensorParser(strings.NewReader(input), WithCoercion(CoercionLenient))
+	}
+	records := func(src RecordSource) []SensorData {
+		var all []SensorData
+		for {
+			d, err := src.Parse(context.Background())
+			if err == io.EOF {
+				return all
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			all = append(all, *d)
+		}
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		var out strings.Builder
+		n, err := Convert(context.Background(), NewNDJSONSink(&out), parse(input))
+		if err != nil || n != 4 {
+			t.Fatalf("expected 4 records, got %d, %v", n, err)
+		}
+		want := `{"sensor_id":"a","readings":[1.5,2e-7,3e+21],"timestamp":1700000000,"metadata":{"site":"north, \"b\""}}
+{"sensor_id":"42","readings":[4]}
+{"sensor_id":"tab\there\u0001","readings":[7],"timestamp":1}
+{"sensor_id":"c","readings":[5,6]}
+`
+		if out.String() != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+		}
+		if before, after := records(parse(input)), records(parse(out.String())); !reflect.DeepEqual(before, after) {
+			t.Errorf("round trip changed records:\n%+v\n%+v", before, after)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var out strings.Builder
+		n, err := Convert(context.Background(), NewCSVSink(&out, WithCSVSinkMetadata("site", "missing")), parse(input))
+		if err != nil || n != 4 {
+			t.Fatalf("expected 4 records, got %d, %v", n, err)
+		}
+		want := "sensor_id,timestamp,readings,site,missing\n" +
+			"a,1700000000,1.5,\"north, \"\"b\"\"\",\n" +
+			"a,1700000000,2e-7,\"north, \"\"b\"\"\",\n" +
+			"a,1700000000,3e+21,\"north, \"\"b\"\"\",\n" +
+			"42,,4,,\n" +
+			"tab\there\x01,1,7,,\n" +
+			"c,,5,,\n" +
+			"c,,6,,\n"
+		if out.String() != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+		}
+		fields := DefaultFields()
+		fields["site"] = FieldSpec{Target: TargetMetadata}
+		got := records(NewCSVSensorSource(strings.NewReader(out.String()), WithCSVFields(fields)))
+		if len(got) != 7 || !reflect.DeepEqual(got[0].Metadata, []MetadataPair{{Key: "site", Value: `north, "b"`}}) || got[6].Value != 6 {
+			t.Errorf("unexpected records read back: %+v", got)
+		}
+	})
+
+	t.Run("empty csv", func(t *testing.T) {
+		var out strings.Builder
+		n, err := Convert(context.Background(), NewCSVSink(&out, WithCSVSinkComma(';')), parse(""))
+		if err != nil || n != 0 || out.String() != "sensor_id;timestamp;readings\n" {
+			t.Errorf("expected a lone header, got %d, %v, %q", n, err, out.String())
+		}
+	})
+
+	t.Run("no allocations", func(t *testing.T) {
+		d := &SensorData{SensorID: "a", Readings: []float64{1, 2}, Timestamp: 1, Metadata: []MetadataPair{{Key: "site", Value: "x"}}}
+		for _, sink := range []Sink{NewNDJSONSink(io.Discard), NewCSVSink(io.Discard, WithCSVSinkMetadata("site"))} {
+			if allocs := testing.AllocsPerRun(100, func() { sink.Write(d) }); allocs != 0 {
+				t.Errorf("%T: expected no allocations per record, got %v", sink, allocs)
+			}
+		}
+	})
+}
+
 func TestSensorParser_LargeStream(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping large stream test in short mode")
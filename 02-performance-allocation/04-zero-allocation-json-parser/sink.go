@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Sink receives normalized records, the counterpart of RecordSource. Write
+// must not keep d; Flush pushes out anything buffered.
+type Sink interface {
+	Write(d *SensorData) error
+	Flush() error
+}
+
+var (
+	_ Sink = (*NDJSONSink)(nil)
+	_ Sink = (*CSVSink)(nil)
+)
+
+// Convert copies every record from src to dst and flushes dst, so dirty
+// input in any supported format streams out clean. It returns the number
+// of records written; what was written before an error is still flushed.
+func Convert(ctx context.Context, dst Sink, src RecordSource) (int64, error) {
+	var (
+		d SensorData
+		n int64
+	)
+	for {
+		err := src.ParseInto(ctx, &d)
+		if err == io.EOF {
+			return n, dst.Flush()
+		}
+		if err == nil {
+			err = dst.Write(&d)
+		}
+		if err != nil {
+			return n, errors.Join(err, dst.Flush())
+		}
+		n++
+	}
+}
+
+// NDJSONSink writes one JSON object per line with the default keys in a
+// fixed order: sensor_id, readings, then timestamp and metadata when set.
+type NDJSONSink struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{w: bufio.NewWriter(w)}
+}
+
+func (s *NDJSONSink) Write(d *SensorData) error {
+	s.buf = appendNDJSON(s.buf[:0], d)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *NDJSONSink) Flush() error {
+	return s.w.Flush()
+}
+
+func appendNDJSON(b []byte, d *SensorData) []byte {
+	b = append(b, `{"`+SensorIDKey+`":`...)
+	b = appendJSONString(b, d.SensorID)
+	b = append(b, `,"`+ReadingsKey+`":[`...)
+	for i, r := range d.Readings {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONFloat(b, r)
+	}
+	b = append(b, ']')
+	if d.Timestamp != 0 {
+		b = append(b, `,"`+TimestampKey+`":`...)
+		b = strconv.AppendInt(b, d.Timestamp, 10)
+	}
+	if len(d.Metadata) > 0 {
+		b = append(b, `,"`+MetadataKey+`":{`...)
+		for i, m := range d.Metadata {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONString(b, m.Key)
+			b = append(b, ':')
+			b = appendJSONString(b, m.Value)
+		}
+		b = append(b, '}')
+	}
+	return append(b, "}\n"...)
+}
+
+// CSVSink writes a header row and then one row per reading, so the output
+// reads back through CSVSensorSource with the default schema. A record
+// without readings still gets one row, with an empty readings column.
+type CSVSink struct {
+	w        *bufio.Writer
+	buf      []byte
+	comma    rune
+	metadata []string
+
+	wroteHeader bool
+}
+
+type CSVSinkOption func(s *CSVSink)
+
+// WithCSVSinkComma sets the field delimiter, e.g. ';' or '\t'.
+func WithCSVSinkComma(comma rune) CSVSinkOption {
+	return func(s *CSVSink) {
+		s.comma = comma
+	}
+}
+
+// WithCSVSinkMetadata adds a column per metadata key, holding the record's
+// first value for that key. Other metadata is dropped.
+func WithCSVSinkMetadata(keys ...string) CSVSinkOption {
+	return func(s *CSVSink) {
+		s.metadata = keys
+	}
+}
+
+func NewCSVSink(w io.Writer, opts ...CSVSinkOption) *CSVSink {
+	s := &CSVSink{w: bufio.NewWriter(w), comma: ','}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *CSVSink) Write(d *SensorData) error {
+	s.buf = s.appendHeader(s.buf[:0])
+	for i := 0; i == 0 || i < len(d.Readings); i++ {
+		s.buf = s.appendCSVField(s.buf, d.SensorID)
+		s.buf = utf8.AppendRune(s.buf, s.comma)
+		if d.Timestamp != 0 {
+			s.buf = strconv.AppendInt(s.buf, d.Timestamp, 10)
+		}
+		s.buf = utf8.AppendRune(s.buf, s.comma)
+		if i < len(d.Readings) {
+			s.buf = appendJSONFloat(s.buf, d.Readings[i])
+		}
+		for _, key := range s.metadata {
+			s.buf = utf8.AppendRune(s.buf, s.comma)
+			for _, m := range d.Metadata {
+				if m.Key == key {
+					s.buf = s.appendCSVField(s.buf, m.Value)
+					break
+				}
+			}
+		}
+		s.buf = append(s.buf, '\n')
+	}
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// Flush writes the header too if no record came, so empty input still
+// converts to a well-formed file.
+func (s *CSVSink) Flush() error {
+	if !s.wroteHeader {
+		s.buf = s.appendHeader(s.buf[:0])
+		if _, err := s.w.Write(s.buf); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *CSVSink) appendHeader(b []byte) []byte {
+	if s.wroteHeader {
+		return b
+	}
+	s.wroteHeader = true
+	for i, name := range append([]string{SensorIDKey, TimestampKey, ReadingsKey}, s.metadata...) {
+		if i > 0 {
+			b = utf8.AppendRune(b, s.comma)
+		}
+		b = s.appendCSVField(b, name)
+	}
+	return append(b, '\n')
+}
+
+// appendCSVField quotes f only when encoding/csv would.
+func (s *CSVSink) appendCSVField(b []byte, f string) []byte {
+	quote := f == `\.` || strings.ContainsRune(f, s.comma) || strings.ContainsAny(f, "\"\r\n") ||
+		f != "" && (f[0] == ' ' || f[0] == '\t')
+	if !quote {
+		return append(b, f...)
+	}
+	b = append(b, '"')
+	for i := 0; i < len(f); i++ {
+		if f[i] == '"' {
+			b = append(b, '"')
+		}
+		b = append(b, f[i])
+	}
+	return append(b, '"')
+}
+
+// appendJSONFloat formats f like encoding/json: plain digits unless the
+// exponent is extreme.
+func appendJSONFloat(b []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string, replacing invalid UTF-8
+// with U+FFFD like encoding/json.
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
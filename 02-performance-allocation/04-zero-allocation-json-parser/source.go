@@ -0,0 +1,17 @@
+package main
+
+import "context"
+
+// RecordSource is anything that yields SensorData records: the JSON
+// SensorParser, CSVSensorSource, FrameParser and so on. Both methods return
+// io.EOF once the input is exhausted; corrupt input is skipped, not returned.
+type RecordSource interface {
+	Parse(ctx context.Context) (*SensorData, error)
+	ParseInto(ctx context.Context, dst *SensorData) error
+}
+
+var (
+	_ RecordSource = (*SensorParser)(nil)
+	_ RecordSource = (*CSVSensorSource)(nil)
+	_ RecordSource = (*FrameParser)(nil)
+)
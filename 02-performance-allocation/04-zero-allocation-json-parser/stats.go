@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of a SensorParser's counters. It is safe to take
+// while parsing is in progress, including during ParseStream.
+type Stats struct {
+	// Records is the number of records returned.
+	Records uint64
+	// BytesConsumed counts bytes read from the (decompressed) input,
+	// including read-ahead that has not been parsed yet.
+	BytesConsumed int64
+	// BytesSkipped counts bytes discarded as corrupt or oversized.
+	BytesSkipped int64
+	// Resyncs counts recoveries from bad input: one per skipped run,
+	// invalid record, rejected line or oversized record.
+	Resyncs uint64
+	// MalformedFields counts known fields whose value had the wrong type.
+	MalformedFields uint64
+	// Rejected counts well-formed records that failed WithValidation. They
+	// are not counted as resyncs or skipped bytes.
+	Rejected uint64
+}
+
+type parserStats struct {
+	records, resyncs, malformed, rejected atomic.Uint64
+	consumed, skipped                     atomic.Int64
+}
+
+func (sp *SensorParser) Stats() Stats {
+	return Stats{
+		Records:         sp.stats.records.Load(),
+		BytesConsumed:   sp.stats.consumed.Load(),
+		BytesSkipped:    sp.stats.skipped.Load(),
+		Resyncs:         sp.stats.resyncs.Load(),
+		MalformedFields: sp.stats.malformed.Load(),
+		Rejected:        sp.stats.rejected.Load(),
+	}
+}
+
+// corrupt records one recovery from bad input and reports it to the
+// CorruptionHandler, if any.
+func (sp *SensorParser) corrupt(offset int64, fragment []byte, err error, skipped int64) {
+	sp.stats.resyncs.Add(1)
+	sp.stats.skipped.Add(skipped)
+	if sp.onCorruption != nil {
+		sp.onCorruption(offset, fragment, err)
+	}
+}
+
+// rejected records one record that failed validation and reports it to
+// the CorruptionHandler, if any.
+func (sp *SensorParser) rejected(offset int64, fragment []byte, err error) {
+	sp.stats.rejected.Add(1)
+	if sp.onCorruption != nil {
+		sp.onCorruption(offset, fragment, err)
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WithOrderedStream makes ParseStream emit records in input order. Without
+// it records are emitted as soon as any worker finishes, which is faster
+// but may interleave lines.
+func WithOrderedStream() Option {
+	return func(sp *SensorParser) {
+		sp.orderedStream = true
+	}
+}
+
+type streamLine struct {
+	seq    uint64
+	number int64 // 1-based line number
+	offset int64 // absolute offset of the line's first byte
+	line   []byte
+}
+
+type streamResult struct {
+	seq     uint64
+	records []*SensorData
+	err     error // a *RecordError ending the stream after records
+}
+
+// ParseStream reads the input sequentially, one NDJSON line at a time, and
+// parses lines concurrently on workers goroutines. Corrupt lines are skipped
+// just like in Parse, but resync never crosses a line boundary, so a record
+// must not span lines. In ModeStrict rejected lines go to the
+// CorruptionHandler as *LineError.
+//
+// Records come from the Acquire pool; consumers may Release them. Both
+// channels are closed once the input is exhausted or ctx is cancelled; the
+// error channel carries at most one error (a read failure, a *RecordError
+// under WithFailOnInvalid, or ctx.Err()).
+// The caller must drain the record channel or cancel ctx.
+func (sp *SensorParser) ParseStream(ctx context.Context, workers int) (<-chan *SensorData, <-chan error) {
+	workers = max(workers, 1)
+	out := make(chan *SensorData, workers)
+	errc := make(chan error, 1)
+	lines := make(chan streamLine, workers)
+	results := make(chan streamResult, workers)
+
+	ctx, cancel := context.WithCancel(ctx)
+	if sp.ir != nil {
+		sp.ir.ctx = ctx
+	}
+	fail := func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+		cancel()
+	}
+
+	go func() {
+		defer close(lines)
+		if err := sp.readLines(ctx, lines); err != nil {
+			fail(err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			sp.parseLines(ctx, lines, results)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+		defer cancel()
+		emit := emitUnordered
+		if sp.orderedStream {
+			emit = emitOrdered
+		}
+		if err := emit(ctx, results, out); err != nil {
+			fail(err)
+			// Unblock the workers so they exit, releasing what they made.
+			for res := range results {
+				releaseAll(res.records)
+			}
+		}
+		// Workers stop early on cancellation, so emit can finish cleanly
+		// over a truncated stream; report that it was cut short.
+		if err := ctx.Err(); err != nil {
+			fail(err)
+		}
+	}()
+
+	return out, errc
+}
+
+func (sp *SensorParser) readLines(ctx context.Context, lines chan<- streamLine) error {
+	lr := sp.newLineReader()
+	var (
+		seq    uint64
+		number int64
+		offset int64
+	)
+	for {
+		line, n, err := lr.next()
+		lineOffset := offset
+		offset += n
+		if n > 0 {
+			number++
+		}
+		if line == nil && n > 0 {
+			if sp.mode == ModeStrict {
+				_ = sp.lineError(number, lineOffset, nil, n, ErrRecordTooLarge)
+			} else {
+				sp.corrupt(lineOffset, nil, ErrRecordTooLarge, n)
+			}
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			select {
+			case lines <- streamLine{seq: seq, number: number, offset: lineOffset, line: line}:
+				seq++
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read stream: %w", err)
+		}
+	}
+}
+
+// lineReader splits the input into lines. A line that cannot hold a record
+// within the WithMaxRecordBytes limit is consumed without being buffered and
+// returned as nil; the parsers enforce the exact limit. An interrupted read
+// keeps the partial line for the next call.
+type lineReader struct {
+	br    *bufio.Reader
+	limit int64
+	line  []byte
+	n     int64
+}
+
+func (sp *SensorParser) newLineReader() *lineReader {
+	limit := sp.maxRecordBytes
+	if limit > 0 {
+		limit += int64(len("\r\n"))
+	}
+	return &lineReader{br: bufio.NewReader(sp.r), limit: limit}
+}
+
+// next returns the next line and its length n.
+func (lr *lineReader) next() (line []byte, n int64, err error) {
+	for {
+		chunk, err := lr.br.ReadSlice('\n')
+		lr.n += int64(len(chunk))
+		if lr.limit > 0 && lr.n > lr.limit {
+			lr.line = nil
+		} else {
+			lr.line = append(lr.line, chunk...)
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case isInterrupt(err):
+			return nil, 0, err
+		}
+
+		line, n = lr.line, lr.n
+		lr.line, lr.n = nil, 0
+		return line, n, err
+	}
+}
+
+func (sp *SensorParser) parseLines(ctx context.Context, lines <-chan streamLine, results chan<- streamResult) {
+	for l := range lines {
+		records, err := sp.parseStreamLine(ctx, l)
+		select {
+		case results <- streamResult{seq: l.seq, records: records, err: err}:
+		case <-ctx.Done():
+			releaseAll(records)
+		}
+	}
+}
+
+func (sp *SensorParser) parseStreamLine(ctx context.Context, l streamLine) ([]*SensorData, error) {
+	lp := sp.withReader(bytes.NewReader(l.line))
+	lp.base = l.offset
+	d := sp.Acquire()
+
+	if sp.mode == ModeStrict {
+		if err := lp.parseLine(d, l.line, l.offset); err != nil {
+			lerr := lp.lineError(l.number, l.offset, l.line, int64(len(l.line)), err)
+			d.Release()
+			if isRecordError(err) {
+				return nil, lerr
+			}
+			return nil, nil
+		}
+		return []*SensorData{d}, nil
+	}
+
+	var records []*SensorData
+	for {
+		err := lp.ParseInto(ctx, d)
+		if errors.Is(err, ErrRecordTooLarge) {
+			continue
+		}
+		if err != nil {
+			d.Release()
+			if isRecordError(err) {
+				return records, err
+			}
+			return records, nil
+		}
+		records = append(records, d)
+		d = sp.Acquire()
+	}
+}
+
+func emitUnordered(ctx context.Context, results <-chan streamResult, out chan<- *SensorData) error {
+	for res := range results {
+		if !send(ctx, out, res.records) {
+			return ctx.Err()
+		}
+		if res.err != nil {
+			return res.err
+		}
+	}
+	return nil
+}
+
+// emitOrdered buffers out-of-order results until every earlier line has
+// been emitted. Every line sent to the workers produces exactly one result,
+// even when it holds no records, so the sequence has no gaps.
+func emitOrdered(ctx context.Context, results <-chan streamResult, out chan<- *SensorData) error {
+	pending := make(map[uint64]streamResult)
+	var next uint64
+	flush := func() error {
+		for {
+			res, ok := pending[next]
+			if !ok {
+				return nil
+			}
+			delete(pending, next)
+			next++
+			if !send(ctx, out, res.records) {
+				return ctx.Err()
+			}
+			if res.err != nil {
+				return res.err
+			}
+		}
+	}
+	for res := range results {
+		pending[res.seq] = res
+		if err := flush(); err != nil {
+			for _, res := range pending {
+				releaseAll(res.records)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func send(ctx context.Context, out chan<- *SensorData, records []*SensorData) bool {
+	for i, d := range records {
+		select {
+		case out <- d:
+		case <-ctx.Done():
+			releaseAll(records[i:])
+			return false
+		}
+	}
+	return true
+}
+
+func releaseAll(records []*SensorData) {
+	for _, d := range records {
+		d.Release()
+	}
+}
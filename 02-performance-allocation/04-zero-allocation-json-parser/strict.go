@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Mode decides how the parser recovers from bad input.
+type Mode uint8
+
+const (
+	// ModeLenient skips corrupt input by scanning for the next '{', which
+	// may lie on a later line. It is the default.
+	ModeLenient Mode = iota
+	// ModeStrict treats the input as NDJSON: every non-blank line must hold
+	// exactly one record, and a bad line is reported as a *LineError without
+	// affecting its neighbours. Use it when records legally contain nested
+	// '{', where a lenient resync could land inside a record.
+	ModeStrict
+)
+
+func WithMode(m Mode) Option {
+	return func(sp *SensorParser) {
+		sp.mode = m
+	}
+}
+
+// LineError reports a line rejected in ModeStrict. Parsing resumes at the
+// next line.
+type LineError struct {
+	Line   int64 // 1-based
+	Offset int64 // absolute offset of the line's first byte
+	Err    error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d (offset %d): %v", e.Line, e.Offset, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+func (sp *SensorParser) parseStrict(ctx context.Context, dst *SensorData) error {
+	if sp.lines == nil {
+		sp.lines = sp.newLineReader()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if sp.err != nil {
+			return sp.err
+		}
+
+		line, n, err := sp.lines.next()
+		if isInterrupt(err) {
+			return err
+		}
+		offset := sp.lineOffset
+		sp.lineOffset += n
+		if n > 0 {
+			sp.lineNo++
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			sp.err = fmt.Errorf("read input: %w", err)
+		}
+
+		switch {
+		case line == nil && n > 0:
+			return sp.lineError(sp.lineNo, offset, line, n, ErrRecordTooLarge)
+		case len(bytes.TrimSpace(line)) > 0:
+			if perr := sp.parseLine(dst, line, offset); perr != nil {
+				return sp.lineError(sp.lineNo, offset, line, n, perr)
+			}
+			return nil
+		case errors.Is(err, io.EOF):
+			return io.EOF
+		}
+	}
+}
+
+// parseLine fills dst from line, which must hold exactly one JSON object.
+func (sp *SensorParser) parseLine(dst *SensorData, line []byte, offset int64) error {
+	sp.dec = json.NewDecoder(bytes.NewReader(line))
+	sp.base = offset
+
+	t, err := sp.dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return errors.New("line does not hold a JSON object")
+	}
+	sp.recordStart, sp.depth = sp.InputOffset()-1, 1
+
+	if err := sp.parseObject(dst); err != nil {
+		if sp.failOnInvalid && isSchemaError(err) {
+			return &RecordError{Offset: sp.recordStart, Err: err}
+		}
+		return err
+	}
+	if _, err := sp.dec.Token(); err != io.EOF {
+		return errors.New("trailing data after record")
+	}
+	if err := sp.validate(dst); err != nil {
+		if sp.failOnInvalid {
+			return &RecordError{Offset: sp.recordStart, Err: err}
+		}
+		return err
+	}
+	sp.stats.records.Add(1)
+	return nil
+}
+
+// lineError builds the error for a rejected line of n bytes and reports
+// the line as skipped, or as Rejected if it only failed validation.
+func (sp *SensorParser) lineError(lineNo, offset int64, line []byte, n int64, err error) error {
+	lerr := &LineError{Line: lineNo, Offset: offset, Err: err}
+	fragment := line[:min(len(line), maxFragmentBytes)]
+	if isValidationError(err) {
+		sp.rejected(offset, fragment, lerr)
+	} else {
+		sp.corrupt(offset, fragment, lerr, n)
+	}
+	return lerr
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule checks a parsed record, appending a Violation to v for every
+// constraint it breaks. Rules must not keep d.
+type Rule func(v []Violation, d *SensorData) []Violation
+
+// Violation is one broken constraint of a record.
+type Violation struct {
+	Field  Target
+	Index  int // position in Readings, or -1
+	Reason string
+}
+
+func (v Violation) String() string {
+	if v.Index >= 0 {
+		return fmt.Sprintf("%s[%d]: %s", v.Field, v.Index, v.Reason)
+	}
+	return fmt.Sprintf("%s: %s", v.Field, v.Reason)
+}
+
+// ValidationError lists every constraint a record broke.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("validation failed: ")
+	for i, v := range e.Violations {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(v.String())
+	}
+	return b.String()
+}
+
+// WithValidation checks every parsed record against rules, all of which
+// run so that one report lists everything wrong with a record. A record
+// that breaks any of them is counted in Stats.Rejected, skipped and
+// reported to the CorruptionHandler as a *ValidationError, or returned as a *RecordError wrapping it under
+// WithFailOnInvalid; in ModeStrict it is rejected as a *LineError like any
+// other bad line.
+func WithValidation(rules ...Rule) Option {
+	return func(sp *SensorParser) {
+		sp.rules = rules
+	}
+}
+
+// ReadingsWithin requires every reading to lie in [lo, hi].
+func ReadingsWithin(lo, hi float64) Rule {
+	return func(v []Violation, d *SensorData) []Violation {
+		for i, r := range d.Readings {
+			if r < lo || r > hi {
+				v = append(v, Violation{
+					Field:  TargetReadings,
+					Index:  i,
+					Reason: fmt.Sprintf("%g outside [%g, %g]", r, lo, hi),
+				})
+			}
+		}
+		return v
+	}
+}
+
+// NonEmptySensorID rejects IDs that are empty or only whitespace.
+func NonEmptySensorID() Rule {
+	return func(v []Violation, d *SensorData) []Violation {
+		if strings.TrimSpace(d.SensorID) == "" {
+			v = append(v, Violation{Field: TargetSensorID, Index: -1, Reason: "empty"})
+		}
+		return v
+	}
+}
+
+// TimestampWithin requires a timestamp no older than maxAge and no further
+// ahead than maxSkew, relative to the clock at validation time. Timestamps
+// are taken as Unix seconds, like Aggregator does; a stream in milliseconds
+// would have every record rejected as ahead of the clock. A zero bound is
+// not checked. Records without a timestamp pass; mark the field Required to
+// demand one.
+func TimestampWithin(maxAge, maxSkew time.Duration) Rule {
+	return func(v []Violation, d *SensorData) []Violation {
+		if d.Timestamp == 0 {
+			return v
+		}
+		now := time.Now()
+		ts := time.Unix(d.Timestamp, 0)
+		switch {
+		case maxAge > 0 && ts.Before(now.Add(-maxAge)):
+			v = append(v, Violation{
+				Field:  TargetTimestamp,
+				Index:  -1,
+				Reason: fmt.Sprintf("%d is more than %v old", d.Timestamp, maxAge),
+			})
+		case maxSkew > 0 && ts.After(now.Add(maxSkew)):
+			v = append(v, Violation{
+				Field:  TargetTimestamp,
+				Index:  -1,
+				Reason: fmt.Sprintf("%d is more than %v ahead", d.Timestamp, maxSkew),
+			})
+		}
+		return v
+	}
+}
+
+// validate runs the rules over the record just parsed into dst.
+func (sp *SensorParser) validate(dst *SensorData) error {
+	var v []Violation
+	for _, rule := range sp.rules {
+		v = rule(v, dst)
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: v}
+}
+
+// reject handles a record that parsed but failed validation: it is returned
+// as a *RecordError under WithFailOnInvalid and skipped otherwise. Either
+// way it counts as Rejected.
+func (sp *SensorParser) reject(err error) error {
+	if sp.failOnInvalid {
+		sp.stats.rejected.Add(1)
+		return &RecordError{Offset: sp.recordStart, Err: err}
+	}
+	sp.rejected(sp.recordStart, nil, err)
+	return nil
+}
+
+func isValidationError(err error) bool {
+	var ve *ValidationError
+	return errors.As(err, &ve)
+}
@@ -0,0 +1,3 @@
+module mpsc-ring-buffer
+
+go 1.25.0
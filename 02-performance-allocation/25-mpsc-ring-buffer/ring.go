@@ -0,0 +1,149 @@
+// Package ring provides a bounded lock-free queue for many producers and a
+// single consumer. The middleware chain's buffered stages queue their events
+// on it.
+package ring
+
+import (
+	"context"
+	"math/bits"
+	"sync/atomic"
+)
+
+// cacheLinePad keeps the fields producers hammer away from the ones the
+// consumer owns, so they do not invalidate each other's cache lines.
+type cacheLinePad [64]byte
+
+// Ring is a bounded multi-producer, single-consumer queue. Enqueue is
+// lock-free: producers claim slots with a compare-and-swap on the tail and
+// publish them through a per-slot sequence number, as in Dmitry Vyukov's
+// bounded queue. Dequeue must only ever be called from one goroutine at a
+// time; it needs no atomic read-modify-write at all.
+type Ring[T any] struct {
+	slots []slot[T]
+	mask  uint64
+
+	_    cacheLinePad
+	tail atomic.Uint64 // next slot producers claim
+	_    cacheLinePad
+	head atomic.Uint64 // next slot the consumer reads; only it writes
+	_    cacheLinePad
+
+	notEmpty chan struct{} // wakes the blocked consumer
+	notFull  chan struct{} // wakes a blocked producer
+}
+
+// slot's seq says whose turn it is: equal to the position a producer may
+// claim, one past it once the value is published for the consumer.
+type slot[T any] struct {
+	seq   atomic.Uint64
+	value T
+}
+
+// NewRing holds up to capacity items, rounded up to a power of two.
+func NewRing[T any](capacity int) *Ring[T] {
+	size := uint64(1) << bits.Len64(uint64(max(capacity, 2)-1))
+	r := &Ring[T]{
+		slots:    make([]slot[T], size),
+		mask:     size - 1,
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// TryEnqueue adds value unless the ring is full. Safe for any number of
+// concurrent producers.
+func (r *Ring[T]) TryEnqueue(value T) bool {
+	for {
+		pos := r.tail.Load()
+		s := &r.slots[pos&r.mask]
+		switch diff := int64(s.seq.Load() - pos); {
+		case diff == 0:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				s.value = value
+				s.seq.Store(pos + 1)
+				signal(r.notEmpty)
+				return true
+			}
+		case diff < 0:
+			// The consumer has not freed this slot since the last lap.
+			return false
+		}
+		// Another producer claimed pos first; try the next one.
+	}
+}
+
+// TryDequeue removes the oldest published item, if any. Only one goroutine
+// may dequeue at a time.
+func (r *Ring[T]) TryDequeue() (T, bool) {
+	var zero T
+	pos := r.head.Load()
+	s := &r.slots[pos&r.mask]
+	if s.seq.Load() != pos+1 {
+		return zero, false
+	}
+	value := s.value
+	s.value = zero // do not keep the item reachable
+	s.seq.Store(pos + r.mask + 1)
+	r.head.Store(pos + 1)
+	signal(r.notFull)
+	return value, true
+}
+
+// Enqueue adds value, waiting for room until ctx is done.
+func (r *Ring[T]) Enqueue(ctx context.Context, value T) error {
+	waited := false
+	for !r.TryEnqueue(value) {
+		waited = true
+		select {
+		case <-r.notFull:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if waited && r.Len() < r.Cap() {
+		// Only one producer is woken per dequeue; pass the wakeup on in
+		// case others are still waiting for the room left.
+		signal(r.notFull)
+	}
+	return nil
+}
+
+// Dequeue removes the oldest item, waiting for one until ctx is done. Only
+// one goroutine may dequeue at a time.
+func (r *Ring[T]) Dequeue(ctx context.Context) (T, error) {
+	for {
+		if value, ok := r.TryDequeue(); ok {
+			return value, nil
+		}
+		select {
+		case <-r.notEmpty:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Len is the number of items queued, including ones being published.
+func (r *Ring[T]) Len() int {
+	// head first: it never passes tail, so loading it earlier cannot make
+	// the difference negative.
+	head := r.head.Load()
+	return int(r.tail.Load() - head)
+}
+
+func (r *Ring[T]) Cap() int {
+	return len(r.slots)
+}
+
+// signal wakes one waiter, or leaves a token for the next one to wait.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+package ring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRing_FIFO(t *testing.T) {
+	r := NewRing[int](3)
+	if r.Cap() != 4 {
+		t.Fatalf("expected capacity rounded up to 4, got %d", r.Cap())
+	}
+	if _, ok := r.TryDequeue(); ok {
+		t.Error("expected an empty ring")
+	}
+
+	// Several laps, so slots are reused.
+	for lap := range 3 {
+		for i := range 4 {
+			if !r.TryEnqueue(lap*10 + i) {
+				t.Fatalf("lap %d: expected room for item %d", lap, i)
+			}
+		}
+		if r.TryEnqueue(-1) {
+			t.Fatalf("lap %d: expected a full ring", lap)
+		}
+		if r.Len() != 4 {
+			t.Errorf("lap %d: expected Len 4, got %d", lap, r.Len())
+		}
+		for i := range 4 {
+			if v, ok := r.TryDequeue(); !ok || v != lap*10+i {
+				t.Errorf("lap %d: expected %d, got %d, %v", lap, lap*10+i, v, ok)
+			}
+		}
+	}
+}
+
+func TestRing_MultiProducer(t *testing.T) {
+	const producers, perProducer = 8, 10000
+	r := NewRing[[2]int](64)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Go(func() {
+			for i := range perProducer {
+				if err := r.Enqueue(ctx, [2]int{p, i}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	next := make([]int, producers)
+	for range producers * perProducer {
+		item, err := r.Dequeue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, i := item[0], item[1]
+		if i != next[p] {
+			t.Fatalf("producer %d: expected item %d, got %d", p, next[p], i)
+		}
+		next[p]++
+	}
+	wg.Wait()
+	if r.Len() != 0 {
+		t.Errorf("expected an empty ring, got Len %d", r.Len())
+	}
+}
+
+func TestRing_Blocking(t *testing.T) {
+	t.Run("EnqueueWaitsForRoom", func(t *testing.T) {
+		r := NewRing[int](2)
+		r.TryEnqueue(1)
+		r.TryEnqueue(2)
+
+		done := make(chan error, 1)
+		go func() { done <- r.Enqueue(context.Background(), 3) }()
+		select {
+		case <-done:
+			t.Fatal("expected Enqueue to wait on a full ring")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		r.TryDequeue()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("DequeueWaitsForItem", func(t *testing.T) {
+		r := NewRing[int](2)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			r.TryEnqueue(42)
+		}()
+		if v, err := r.Dequeue(context.Background()); err != nil || v != 42 {
+			t.Errorf("expected 42, got %d, %v", v, err)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		r := NewRing[int](2)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := r.Dequeue(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+		r.TryEnqueue(1)
+		r.TryEnqueue(2)
+		if err := r.Enqueue(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func BenchmarkMPSC(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("Ring", func(b *testing.B) {
+		r := NewRing[int](1024)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range b.N {
+				r.Dequeue(ctx)
+			}
+		}()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				r.Enqueue(ctx, 1)
+			}
+		})
+		<-done
+	})
+
+	b.Run("Channel", func(b *testing.B) {
+		ch := make(chan int, 1024)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range b.N {
+				<-ch
+			}
+		}()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				ch <- 1
+			}
+		})
+		<-done
+	})
+}
@@ -0,0 +1,3 @@
+module object-pool
+
+go 1.25.0
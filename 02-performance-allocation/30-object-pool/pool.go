@@ -0,0 +1,243 @@
+// Package pool reuses objects whose allocation is expensive or frequent,
+// with hooks to reset them between uses and to refuse ones that should not
+// be reused: buffers that grew too large, connections that went stale.
+//
+// Pool is backed by sync.Pool: the runtime may drop idle objects at any GC,
+// which makes it right for memory. Bounded keeps at most a fixed number of
+// idle objects until it is closed, which makes it right for resources that
+// must be released explicitly, like connections.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrClosed = errors.New("pool closed")
+
+type hooks[T any] struct {
+	reset    func(T)
+	validate func(T) bool
+	destroy  func(T)
+}
+
+type Option[T any] func(*hooks[T])
+
+// WithReset clears an object as it is put back, so the next Get never sees
+// state left by a previous user.
+func WithReset[T any](reset func(T)) Option[T] {
+	return func(h *hooks[T]) {
+		h.reset = reset
+	}
+}
+
+// WithValidate refuses to keep objects for which ok returns false, such as
+// a buffer that grew past the size worth pinning in memory. Bounded also
+// checks idle objects again as it hands them out.
+func WithValidate[T any](ok func(T) bool) Option[T] {
+	return func(h *hooks[T]) {
+		h.validate = ok
+	}
+}
+
+// WithDestroy releases an object the pool refuses or drops when closed. A
+// Pool cannot call it for objects the garbage collector takes.
+func WithDestroy[T any](destroy func(T)) Option[T] {
+	return func(h *hooks[T]) {
+		h.destroy = destroy
+	}
+}
+
+func (h *hooks[T]) valid(v T) bool {
+	return h.validate == nil || h.validate(v)
+}
+
+func (h *hooks[T]) drop(v T) {
+	if h.destroy != nil {
+		h.destroy(v)
+	}
+}
+
+// Stats counts what a pool did. Gets minus Puts is the number of objects
+// handed out and not returned yet: in a test that released everything it
+// got, anything else is a leak.
+type Stats struct {
+	Gets    int64
+	Puts    int64
+	News    int64 // objects created because none was idle
+	Dropped int64 // objects refused by Validate, or dropped by a full or closed pool
+}
+
+func (s Stats) Outstanding() int64 {
+	return s.Gets - s.Puts
+}
+
+type counters struct {
+	gets, puts, news, dropped atomic.Int64
+}
+
+func (c *counters) stats() Stats {
+	return Stats{
+		Gets:    c.gets.Load(),
+		Puts:    c.puts.Load(),
+		News:    c.news.Load(),
+		Dropped: c.dropped.Load(),
+	}
+}
+
+// Pool is a typed sync.Pool. T should be a pointer: anything else is copied
+// into an interface, and allocated, on every Put.
+type Pool[T any] struct {
+	hooks[T]
+	counters
+	p sync.Pool
+}
+
+func New[T any](newFn func() T, opts ...Option[T]) *Pool[T] {
+	p := &Pool[T]{}
+	for _, opt := range opts {
+		opt(&p.hooks)
+	}
+	p.p.New = func() any {
+		p.news.Add(1)
+		return newFn()
+	}
+	return p
+}
+
+// Get returns an idle object, or a new one.
+func (p *Pool[T]) Get() T {
+	p.gets.Add(1)
+	return p.p.Get().(T)
+}
+
+// Put returns v to the pool. The caller must not use v afterwards.
+func (p *Pool[T]) Put(v T) {
+	p.puts.Add(1)
+	if !p.valid(v) {
+		p.dropped.Add(1)
+		p.drop(v)
+		return
+	}
+	if p.reset != nil {
+		p.reset(v)
+	}
+	p.p.Put(v)
+}
+
+func (p *Pool[T]) Stats() Stats {
+	return p.stats()
+}
+
+// Bounded keeps up to size idle objects until it is closed, destroying any
+// object it has no room for. It does not limit how many objects are in use
+// at once; pair it with a semaphore for that.
+type Bounded[T any] struct {
+	hooks[T]
+	counters
+	newFn func(ctx context.Context) (T, error)
+
+	mu     sync.Mutex // orders Put against Close
+	idle   chan T
+	closed bool
+}
+
+func NewBounded[T any](size int, newFn func(ctx context.Context) (T, error), opts ...Option[T]) *Bounded[T] {
+	b := &Bounded[T]{newFn: newFn, idle: make(chan T, size)}
+	for _, opt := range opts {
+		opt(&b.hooks)
+	}
+	return b
+}
+
+// Get returns an idle object that still passes Validate, destroying the
+// ones that do not, or creates a new one.
+func (b *Bounded[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	for {
+		select {
+		case v, ok := <-b.idle:
+			if !ok {
+				return zero, ErrClosed
+			}
+			if !b.valid(v) {
+				b.dropped.Add(1)
+				b.drop(v)
+				continue
+			}
+			b.gets.Add(1)
+			return v, nil
+		default:
+		}
+
+		b.mu.Lock()
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			return zero, ErrClosed
+		}
+		v, err := b.newFn(ctx)
+		if err != nil {
+			return zero, err
+		}
+		b.news.Add(1)
+		b.gets.Add(1)
+		return v, nil
+	}
+}
+
+// Put returns v to the pool, or destroys it if v fails Validate or the pool
+// is full or closed. The caller must not use v afterwards.
+func (b *Bounded[T]) Put(v T) {
+	b.puts.Add(1)
+	if !b.valid(v) {
+		b.dropped.Add(1)
+		b.drop(v)
+		return
+	}
+	if b.reset != nil {
+		b.reset(v)
+	}
+
+	b.mu.Lock()
+	kept := false
+	if !b.closed {
+		select {
+		case b.idle <- v:
+			kept = true
+		default:
+		}
+	}
+	b.mu.Unlock()
+	if !kept {
+		b.dropped.Add(1)
+		b.drop(v)
+	}
+}
+
+// Close destroys the idle objects. Objects still in use are destroyed as
+// they are put back, and Get fails with ErrClosed.
+func (b *Bounded[T]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.idle)
+	b.mu.Unlock()
+
+	for v := range b.idle {
+		b.dropped.Add(1)
+		b.drop(v)
+	}
+}
+
+func (b *Bounded[T]) Stats() Stats {
+	return b.stats()
+}
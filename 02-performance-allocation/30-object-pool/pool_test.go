@@ -0,0 +1,233 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// checkNoLeaks fails the test if, once it is over, objects taken from the
+// pool were not all put back.
+func checkNoLeaks(t *testing.T, stats func() Stats) {
+	t.Helper()
+	t.Cleanup(func() {
+		if n := stats().Outstanding(); n != 0 {
+			t.Errorf("%d objects were never put back", n)
+		}
+	})
+}
+
+const maxBufCap = 1 << 10
+
+func newBufferPool() *Pool[*bytes.Buffer] {
+	return New(
+		func() *bytes.Buffer { return new(bytes.Buffer) },
+		WithReset(func(b *bytes.Buffer) { b.Reset() }),
+		WithValidate(func(b *bytes.Buffer) bool { return b.Cap() <= maxBufCap }),
+	)
+}
+
+func TestPool(t *testing.T) {
+	p := newBufferPool()
+	checkNoLeaks(t, p.Stats)
+
+	buf := p.Get()
+	buf.WriteString("secret")
+	p.Put(buf)
+	if got := p.Get(); got.Len() != 0 {
+		t.Errorf("expected a reset buffer, got %q", got.String())
+	} else {
+		p.Put(got)
+	}
+
+	big := p.Get()
+	big.Grow(2 * maxBufCap)
+	p.Put(big)
+	if s := p.Stats(); s.Dropped != 1 {
+		t.Errorf("expected the oversized buffer to be dropped, got %+v", s)
+	}
+}
+
+func TestPool_LeakDetection(t *testing.T) {
+	p := newBufferPool()
+	p.Put(p.Get())
+	p.Get()
+	if n := p.Stats().Outstanding(); n != 1 {
+		t.Errorf("expected 1 outstanding object, got %d", n)
+	}
+}
+
+type conn struct {
+	id     int
+	broken bool
+	closed atomic.Bool
+}
+
+func newConnPool(size int) (*Bounded[*conn], *atomic.Int32) {
+	var created atomic.Int32
+	b := NewBounded(size,
+		func(context.Context) (*conn, error) {
+			return &conn{id: int(created.Add(1))}, nil
+		},
+		WithValidate(func(c *conn) bool { return !c.broken }),
+		WithDestroy(func(c *conn) { c.closed.Store(true) }),
+	)
+	return b, &created
+}
+
+func TestBounded(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reuses idle objects up to its size", func(t *testing.T) {
+		b, created := newConnPool(2)
+		checkNoLeaks(t, b.Stats)
+		defer b.Close()
+
+		conns := make([]*conn, 3)
+		for i := range conns {
+			conns[i], _ = b.Get(ctx)
+		}
+		for _, c := range conns {
+			b.Put(c)
+		}
+		if !conns[2].closed.Load() {
+			t.Error("expected the connection the pool had no room for to be closed")
+		}
+		b.Put(mustGet(t, b))
+		b.Put(mustGet(t, b))
+		if n := created.Load(); n != 3 {
+			t.Errorf("expected idle connections to be reused, created %d", n)
+		}
+	})
+
+	t.Run("drops objects that fail validation", func(t *testing.T) {
+		b, _ := newConnPool(2)
+		checkNoLeaks(t, b.Stats)
+		defer b.Close()
+
+		c := mustGet(t, b)
+		b.Put(c)
+		c.broken = true // went stale while idle
+		got := mustGet(t, b)
+		if got == c || !c.closed.Load() {
+			t.Error("expected the stale connection to be closed, not handed out")
+		}
+		b.Put(got)
+	})
+
+	t.Run("close destroys idle and returned objects", func(t *testing.T) {
+		b, _ := newConnPool(2)
+		checkNoLeaks(t, b.Stats)
+
+		idle, inUse := mustGet(t, b), mustGet(t, b)
+		b.Put(idle)
+		b.Close()
+		b.Close()
+		if !idle.closed.Load() {
+			t.Error("expected the idle connection to be closed")
+		}
+		b.Put(inUse)
+		if !inUse.closed.Load() {
+			t.Error("expected a connection put back after Close to be closed")
+		}
+		if _, err := b.Get(ctx); !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("get fails with the context or the constructor", func(t *testing.T) {
+		errDial := errors.New("dial failed")
+		b := NewBounded(1, func(context.Context) (*conn, error) { return nil, errDial })
+		checkNoLeaks(t, b.Stats)
+
+		if _, err := b.Get(ctx); !errors.Is(err, errDial) {
+			t.Errorf("expected the dial error, got %v", err)
+		}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := b.Get(canceled); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		b, _ := newConnPool(4)
+		checkNoLeaks(t, b.Stats)
+
+		var wg sync.WaitGroup
+		for range 16 {
+			wg.Go(func() {
+				for range 100 {
+					c, err := b.Get(ctx)
+					if err != nil {
+						return
+					}
+					if c.closed.Load() {
+						t.Error("got a closed connection")
+					}
+					b.Put(c)
+				}
+			})
+		}
+		wg.Wait()
+		b.Close()
+	})
+}
+
+func mustGet(t *testing.T, b *Bounded[*conn]) *conn {
+	t.Helper()
+	c, err := b.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var sink *bytes.Buffer
+
+func BenchmarkBuffer(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), 512)
+
+	b.Run("NoPool", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			buf := new(bytes.Buffer)
+			buf.Write(payload)
+			sink = buf
+		}
+	})
+	b.Run("Pool", func(b *testing.B) {
+		p := newBufferPool()
+		b.ReportAllocs()
+		for b.Loop() {
+			buf := p.Get()
+			buf.Write(payload)
+			p.Put(buf)
+		}
+	})
+	b.Run("Bounded", func(b *testing.B) {
+		p := NewBounded(1, func(context.Context) (*bytes.Buffer, error) { return new(bytes.Buffer), nil },
+			WithReset(func(b *bytes.Buffer) { b.Reset() }))
+		ctx := context.Background()
+		b.ReportAllocs()
+		for b.Loop() {
+			buf, _ := p.Get(ctx)
+			buf.Write(payload)
+			p.Put(buf)
+		}
+	})
+	b.Run("PoolParallel", func(b *testing.B) {
+		p := newBufferPool()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buf := p.Get()
+				buf.Write(payload)
+				p.Put(buf)
+			}
+		})
+	})
+}
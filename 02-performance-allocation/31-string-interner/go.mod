@@ -0,0 +1,3 @@
+module string-interner
+
+go 1.25.0
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"hash/maphash"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Interner hands out one shared copy of every distinct string it sees. A
+// parser reading millions of records from a few thousand sensors then keeps
+// a few thousand sensor ID strings alive instead of one per record, and
+// equal IDs share a pointer.
+//
+// It never forgets a string: intern values from a bounded set, not request
+// bodies or user input.
+type Interner struct {
+	shards []shard
+	seed   maphash.Seed
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type shard struct {
+	mu      sync.RWMutex
+	strings map[string]string
+	_       [64]byte // keeps neighbouring shards' locks off one cache line
+}
+
+type Stats struct {
+	Strings int   // distinct strings held
+	Bytes   int   // their total length
+	Hits    int64 // lookups answered with a string already held
+	Misses  int64 // lookups that had to copy a new one
+}
+
+// NewInterner spreads the strings over numShards independently locked maps,
+// so concurrent parsers rarely contend.
+func NewInterner(numShards uint) *Interner {
+	if numShards == 0 {
+		panic("interner: numShards must be greater than zero")
+	}
+	in := &Interner{
+		shards: make([]shard, numShards),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range in.shards {
+		in.shards[i].strings = make(map[string]string)
+	}
+	return in
+}
+
+// Bytes returns the interned copy of b. When b was seen before, it does not
+// allocate: the lookup m[string(b)] is done without converting b, so b can
+// be a slice of a reused read buffer.
+func (in *Interner) Bytes(b []byte) string {
+	sh := &in.shards[maphash.Bytes(in.seed, b)%uint64(len(in.shards))]
+	sh.mu.RLock()
+	s, ok := sh.strings[string(b)]
+	sh.mu.RUnlock()
+	if ok {
+		in.hits.Add(1)
+		return s
+	}
+	return in.store(sh, string(b))
+}
+
+// String returns the interned copy of s. The copy kept is a fresh one, so
+// interning a substring does not keep the larger string it was cut from
+// alive.
+func (in *Interner) String(s string) string {
+	sh := &in.shards[maphash.String(in.seed, s)%uint64(len(in.shards))]
+	sh.mu.RLock()
+	interned, ok := sh.strings[s]
+	sh.mu.RUnlock()
+	if ok {
+		in.hits.Add(1)
+		return interned
+	}
+	return in.store(sh, strings.Clone(s))
+}
+
+// store adds s, which must be a copy the caller owns, unless another
+// goroutine stored it since the read-locked lookup.
+func (in *Interner) store(sh *shard, s string) string {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if interned, ok := sh.strings[s]; ok {
+		in.hits.Add(1)
+		return interned
+	}
+	sh.strings[s] = s
+	in.misses.Add(1)
+	return s
+}
+
+func (in *Interner) Stats() Stats {
+	st := Stats{Hits: in.hits.Load(), Misses: in.misses.Load()}
+	for i := range in.shards {
+		sh := &in.shards[i]
+		sh.mu.RLock()
+		st.Strings += len(sh.strings)
+		for s := range sh.strings {
+			st.Bytes += len(s)
+		}
+		sh.mu.RUnlock()
+	}
+	return st
+}
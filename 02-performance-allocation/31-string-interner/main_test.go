@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func sameString(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+func TestInterner(t *testing.T) {
+	in := NewInterner(8)
+
+	buf := []byte("sensor-0001")
+	a := in.Bytes(buf)
+	copy(buf, "XXXXXXXXXXX") // the caller reuses its buffer
+	if a != "sensor-0001" {
+		t.Fatalf("expected the interned string to be a copy, got %q", a)
+	}
+
+	b := in.String(fmt.Sprintf("sensor-%04d", 1))
+	c := in.Bytes([]byte("sensor-0001"))
+	if !sameString(a, b) || !sameString(a, c) {
+		t.Error("expected equal strings to share their bytes")
+	}
+
+	line := "sensor-0002;12.5"
+	d := in.String(line[:11])
+	if sameString(d, line) {
+		t.Error("expected an interned substring to be a copy of it")
+	}
+
+	st := in.Stats()
+	if st.Strings != 2 || st.Bytes != 22 || st.Hits != 2 || st.Misses != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestInterner_HitsDoNotAllocate(t *testing.T) {
+	in := NewInterner(8)
+	key := []byte("sensor-0001")
+	in.Bytes(key)
+	s := "sensor-0001"
+	allocs := testing.AllocsPerRun(100, func() {
+		in.Bytes(key)
+		in.String(s)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestInterner_Concurrent(t *testing.T) {
+	in := NewInterner(4)
+	const ids = 100
+	results := make([][]string, 8)
+
+	var wg sync.WaitGroup
+	for g := range results {
+		wg.Go(func() {
+			buf := make([]byte, 0, 16)
+			for i := range ids {
+				buf = fmt.Appendf(buf[:0], "sensor-%04d", i)
+				results[g] = append(results[g], in.Bytes(buf))
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := in.Stats().Strings; n != ids {
+		t.Errorf("expected %d strings, got %d", ids, n)
+	}
+	for g := range results {
+		for i := range ids {
+			if !sameString(results[g][i], results[0][i]) {
+				t.Fatalf("goroutines %d and 0 got different copies of %q", g, results[0][i])
+			}
+		}
+	}
+}
+
+// sensorLog is one million readings from 2000 sensors, one per line.
+var sensorLog = sync.OnceValue(func() []byte {
+	var buf bytes.Buffer
+	for i := range 1_000_000 {
+		fmt.Fprintf(&buf, "sensor-%04d;%d.5\n", i%2000, i%100)
+	}
+	return buf.Bytes()
+})
+
+// parseIDs keeps the sensor ID of every line, as a parser building records
+// would, converting it with toString.
+func parseIDs(toString func([]byte) string) []string {
+	ids := make([]string, 0, 1_000_000)
+	sc := bufio.NewScanner(bytes.NewReader(sensorLog()))
+	for sc.Scan() {
+		id, _, _ := bytes.Cut(sc.Bytes(), []byte(";"))
+		ids = append(ids, toString(id))
+	}
+	return ids
+}
+
+// heapInUse measures the live heap after a full collection.
+func heapInUse() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// BenchmarkParseSensorIDs reports, besides allocations, how much memory the
+// parsed IDs keep alive: retained-MB.
+func BenchmarkParseSensorIDs(b *testing.B) {
+	run := func(b *testing.B, toString func() func([]byte) string) {
+		b.ReportAllocs()
+		var retained uint64
+		for b.Loop() {
+			before := heapInUse()
+			ids := parseIDs(toString())
+			retained = heapInUse() - before
+			runtime.KeepAlive(ids)
+		}
+		b.ReportMetric(float64(retained)/(1<<20), "retained-MB")
+	}
+
+	sensorLog()
+	b.Run("Convert", func(b *testing.B) {
+		run(b, func() func([]byte) string {
+			return func(id []byte) string { return string(id) }
+		})
+	})
+	b.Run("Intern", func(b *testing.B) {
+		run(b, func() func([]byte) string {
+			return NewInterner(16).Bytes
+		})
+	})
+}
+
+func BenchmarkInterner_Parallel(b *testing.B) {
+	in := NewInterner(16)
+	keys := make([][]byte, 2000)
+	for i := range keys {
+		keys[i] = fmt.Appendf(nil, "sensor-%04d", i)
+		in.Bytes(keys[i])
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			in.Bytes(keys[i%len(keys)])
+			i++
+		}
+	})
+}
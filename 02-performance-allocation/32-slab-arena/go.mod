@@ -0,0 +1,3 @@
+module slab-arena
+
+go 1.25.0
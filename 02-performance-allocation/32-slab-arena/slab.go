@@ -0,0 +1,113 @@
+// Package slab allocates short-lived values in chunks, so a batch job that
+// builds many records and drops them all at once costs the garbage
+// collector a few large objects instead of millions of small ones. The
+// sensor parser (kata 04) allocates its records from it under WithArena.
+package slab
+
+// Slab hands out values of T carved from large chunks instead of one heap
+// allocation each, for batch jobs that build many short-lived records and
+// drop them all at once. Reset makes every chunk available again: the
+// garbage collector sees a few big objects instead of millions of small
+// ones, and the next batch allocates nothing.
+//
+// Values must not be used after Reset. Pointers cannot enforce that, so
+// AllocRef returns a Ref that knows the generation it was allocated in and
+// refuses to resolve once the slab has moved past it.
+//
+// A Slab is not safe for concurrent use: give each worker its own.
+type Slab[T any] struct {
+	chunks    [][]T
+	chunkSize int
+	chunk     int // index in chunks of the chunk being filled
+	used      int // values handed out from that chunk
+	gen       uint64
+}
+
+// NewSlab allocates chunkSize values at a time. Chunks are kept across
+// Reset, so a slab sized for the largest batch stops allocating.
+func NewSlab[T any](chunkSize int) *Slab[T] {
+	if chunkSize <= 0 {
+		panic("slab: chunkSize must be greater than zero")
+	}
+	return &Slab[T]{chunkSize: chunkSize}
+}
+
+// Alloc returns a pointer to a zero T owned by the slab.
+func (s *Slab[T]) Alloc() *T {
+	return &s.AllocN(1)[0]
+}
+
+// AllocN returns n contiguous zero values. The slice's capacity is n, so
+// appending to it copies instead of overwriting the next allocation. Larger
+// requests than the chunk size get a chunk of their own.
+func (s *Slab[T]) AllocN(n int) []T {
+	if n == 0 {
+		return nil
+	}
+	if len(s.chunks) == 0 || s.used+n > len(s.chunks[s.chunk]) {
+		s.nextChunk(n)
+	}
+	start := s.used
+	s.used += n
+	return s.chunks[s.chunk][start:s.used:s.used]
+}
+
+// nextChunk moves on to a chunk with room for n values, reusing the ones
+// kept by Reset before allocating.
+func (s *Slab[T]) nextChunk(n int) {
+	for i := s.chunk + 1; i < len(s.chunks); i++ {
+		if len(s.chunks[i]) >= n {
+			// Swap it into place so the chunks before s.chunk stay the used ones.
+			s.chunk++
+			s.chunks[s.chunk], s.chunks[i] = s.chunks[i], s.chunks[s.chunk]
+			s.used = 0
+			return
+		}
+	}
+	s.chunks = append(s.chunks, make([]T, max(n, s.chunkSize)))
+	if len(s.chunks) > 1 {
+		s.chunk++
+		last := len(s.chunks) - 1
+		s.chunks[s.chunk], s.chunks[last] = s.chunks[last], s.chunks[s.chunk]
+	}
+	s.used = 0
+}
+
+// Reset frees every value at once and starts a new generation. Used values
+// are zeroed, so strings and slices they referenced can be collected.
+func (s *Slab[T]) Reset() {
+	if len(s.chunks) > 0 {
+		for i := range s.chunk {
+			clear(s.chunks[i])
+		}
+		clear(s.chunks[s.chunk][:s.used])
+	}
+	s.chunk, s.used = 0, 0
+	s.gen++
+}
+
+// Generation counts the calls to Reset.
+func (s *Slab[T]) Generation() uint64 {
+	return s.gen
+}
+
+// Ref is a checked reference to a value allocated by AllocRef, for values
+// that may be held past the batch that created them by mistake.
+type Ref[T any] struct {
+	slab *Slab[T]
+	ptr  *T
+	gen  uint64
+}
+
+func (s *Slab[T]) AllocRef() Ref[T] {
+	return Ref[T]{slab: s, ptr: s.Alloc(), gen: s.gen}
+}
+
+// Get returns the value, or false if the slab was reset since it was
+// allocated and the memory now belongs to someone else.
+func (r Ref[T]) Get() (*T, bool) {
+	if r.slab == nil || r.slab.gen != r.gen {
+		return nil, false
+	}
+	return r.ptr, true
+}
@@ -0,0 +1,84 @@
+package slab
+
+import (
+	"slices"
+	"testing"
+)
+
+// record stands in for a parsed record holding a string.
+type record struct {
+	ID string
+}
+
+func TestSlab(t *testing.T) {
+	s := NewSlab[int](4)
+
+	a := s.AllocN(3)
+	b := s.AllocN(3) // does not fit in the first chunk's remaining one
+	if len(a) != 3 || cap(a) != 3 || len(b) != 3 {
+		t.Fatalf("unexpected slices a=%d/%d b=%d", len(a), cap(a), len(b))
+	}
+	copy(a, []int{1, 2, 3})
+	copy(b, []int{4, 5, 6})
+	_ = append(a, 99) // must not overwrite the next allocation
+	if !slices.Equal(b, []int{4, 5, 6}) {
+		t.Errorf("append to one allocation overwrote another: %v", b)
+	}
+
+	big := s.AllocN(10)
+	if len(big) != 10 {
+		t.Errorf("expected a chunk of its own for a large request, got %d", len(big))
+	}
+	if s.AllocN(0) != nil {
+		t.Error("expected nil for an empty request")
+	}
+
+	s.Reset()
+	c := s.AllocN(3)
+	if !slices.Equal(c, []int{0, 0, 0}) {
+		t.Errorf("expected zeroed values after Reset, got %v", c)
+	}
+	if s.Generation() != 1 {
+		t.Errorf("expected generation 1, got %d", s.Generation())
+	}
+}
+
+func TestSlab_ReusesChunksAfterReset(t *testing.T) {
+	s := NewSlab[record](64)
+	fill := func() {
+		for range 200 {
+			s.Alloc().ID = "sensor"
+		}
+	}
+	fill()
+	s.Reset()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		fill()
+		s.Reset()
+	})
+	if allocs != 0 {
+		t.Errorf("expected a warm slab not to allocate, got %v allocs", allocs)
+	}
+}
+
+func TestRef_DetectsUseAfterReset(t *testing.T) {
+	s := NewSlab[record](8)
+	ref := s.AllocRef()
+	rec, ok := ref.Get()
+	if !ok {
+		t.Fatal("expected a fresh ref to resolve")
+	}
+	rec.ID = "sensor-0001"
+
+	s.Reset()
+	if _, ok := ref.Get(); ok {
+		t.Error("expected a ref from an old generation not to resolve")
+	}
+	if rec.ID != "" {
+		t.Error("expected Reset to zero the record, dropping its references")
+	}
+	if _, ok := (Ref[record]{}).Get(); ok {
+		t.Error("expected the zero Ref not to resolve")
+	}
+}
@@ -0,0 +1,3 @@
+module consistent-hash-ring
+
+go 1.25.0
@@ -0,0 +1,75 @@
+package hashring
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Hash is FNV-1a over the key's fmt representation (%v), the same value on
+// every machine and every run, which placement across processes requires
+// and maphash's random seed rules out. Strings, booleans and integers, the
+// usual keys, are hashed without formatting or allocating; other types go
+// through fmt.
+func Hash[K comparable](key K) uint64 {
+	var buf [24]byte
+	switch k := any(key).(type) {
+	case string:
+		return fnvString(fnvOffset64, k)
+	case bool:
+		return fnvBytes(fnvOffset64, strconv.AppendBool(buf[:0], k))
+	case int:
+		return fnvBytes(fnvOffset64, strconv.AppendInt(buf[:0], int64(k), 10))
+	case int8:
+		return fnvBytes(fnvOffset64, strconv.AppendInt(buf[:0], int64(k), 10))
+	case int16:
+		return fnvBytes(fnvOffset64, strconv.AppendInt(buf[:0], int64(k), 10))
+	case int32:
+		return fnvBytes(fnvOffset64, strconv.AppendInt(buf[:0], int64(k), 10))
+	case int64:
+		return fnvBytes(fnvOffset64, strconv.AppendInt(buf[:0], k, 10))
+	case uint:
+		return fnvBytes(fnvOffset64, strconv.AppendUint(buf[:0], uint64(k), 10))
+	case uint8:
+		return fnvBytes(fnvOffset64, strconv.AppendUint(buf[:0], uint64(k), 10))
+	case uint16:
+		return fnvBytes(fnvOffset64, strconv.AppendUint(buf[:0], uint64(k), 10))
+	case uint32:
+		return fnvBytes(fnvOffset64, strconv.AppendUint(buf[:0], uint64(k), 10))
+	case uint64:
+		return fnvBytes(fnvOffset64, strconv.AppendUint(buf[:0], k, 10))
+	}
+	return fnvString(fnvOffset64, fmt.Sprint(key))
+}
+
+func fnvString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func fnvBytes(h uint64, b []byte) uint64 {
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// mix spreads the bits of h (the splitmix64 finalizer). FNV of short,
+// similar strings such as "node-1#17" and "node-1#18" differs mostly in low
+// bits; mixed, virtual nodes land all over the ring.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
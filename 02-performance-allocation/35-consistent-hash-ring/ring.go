@@ -0,0 +1,169 @@
+// Package hashring assigns keys to nodes with consistent hashing: adding or
+// removing a node only moves the keys that node gains or loses, about 1/N
+// of them, instead of reshuffling everything as hash(key) % N does.
+//
+// Each node is placed on the ring at many points (virtual nodes), in
+// proportion to its weight, so load evens out and a bigger node takes a
+// bigger share. A key belongs to the first point clockwise from its hash.
+package hashring
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrInvalidWeight = errors.New("node weight must be positive")
+
+const DefaultReplicas = 128
+
+type config[K comparable] struct {
+	replicas int
+	hash     func(K) uint64
+}
+
+type Option[K comparable] func(*config[K])
+
+// WithReplicas sets the virtual nodes per unit of weight. More spread load
+// more evenly, at the cost of memory and slower membership changes.
+func WithReplicas[K comparable](n int) Option[K] {
+	return func(c *config[K]) {
+		c.replicas = n
+	}
+}
+
+// WithHasher replaces Hash for keys. It must give the same value for a key
+// on every process sharing the ring.
+func WithHasher[K comparable](hash func(K) uint64) Option[K] {
+	return func(c *config[K]) {
+		c.hash = hash
+	}
+}
+
+// Ring maps keys of type K to nodes of type N. Lookups read an immutable
+// snapshot without locking; membership changes build a new one. It is safe
+// for concurrent use.
+type Ring[K comparable, N comparable] struct {
+	cfg config[K]
+
+	mu      sync.Mutex // serialises membership changes
+	weights map[N]int
+	points  atomic.Pointer[[]point[N]]
+}
+
+type point[N comparable] struct {
+	hash  uint64
+	label string // the node's %v, to order points deterministically on collisions
+	node  N
+}
+
+func New[K comparable, N comparable](opts ...Option[K]) *Ring[K, N] {
+	cfg := config[K]{replicas: DefaultReplicas, hash: Hash[K]}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	r := &Ring[K, N]{cfg: cfg, weights: make(map[N]int)}
+	r.points.Store(new([]point[N]))
+	return r
+}
+
+// Add places node on the ring with weight times the replicas, or changes
+// its weight if it is already there. Nodes are identified on the ring by
+// their %v, which must be unique and the same on every process.
+func (r *Ring[K, N]) Add(node N, weight int) error {
+	if weight <= 0 {
+		return fmt.Errorf("add %v: %w", node, ErrInvalidWeight)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.weights[node] = weight
+	r.rebuild()
+	return nil
+}
+
+// Remove takes node off the ring, reporting whether it was there.
+func (r *Ring[K, N]) Remove(node N) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.weights[node]; !ok {
+		return false
+	}
+	delete(r.weights, node)
+	r.rebuild()
+	return true
+}
+
+// rebuild computes the points of every node. Each node's points depend only
+// on its label and weight, which is what keeps other nodes' keys in place.
+// r.mu must be held.
+func (r *Ring[K, N]) rebuild() {
+	var points []point[N]
+	buf := make([]byte, 0, 64)
+	for node, weight := range r.weights {
+		label := fmt.Sprint(node)
+		for i := range weight * r.cfg.replicas {
+			buf = strconv.AppendInt(append(append(buf[:0], label...), '#'), int64(i), 10)
+			points = append(points, point[N]{hash: mix(fnvBytes(fnvOffset64, buf)), label: label, node: node})
+		}
+	}
+	slices.SortFunc(points, func(a, b point[N]) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.label, b.label))
+	})
+	r.points.Store(&points)
+}
+
+// Locate returns the node owning key, or false if the ring is empty.
+func (r *Ring[K, N]) Locate(key K) (N, bool) {
+	points := *r.points.Load()
+	if len(points) == 0 {
+		var zero N
+		return zero, false
+	}
+	return points[r.search(points, key)].node, true
+}
+
+// LocateN returns up to n distinct nodes for key, the owner first and then
+// the next ones clockwise, for placing replicas.
+func (r *Ring[K, N]) LocateN(key K, n int) []N {
+	points := *r.points.Load()
+	if len(points) == 0 || n <= 0 {
+		return nil
+	}
+	nodes := make([]N, 0, n)
+	start := r.search(points, key)
+	for i := range points {
+		node := points[(start+i)%len(points)].node
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+			if len(nodes) == n {
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// search returns the index of the first point at or after key's hash,
+// wrapping around to the first point.
+func (r *Ring[K, N]) search(points []point[N], key K) int {
+	h := mix(r.cfg.hash(key))
+	i, _ := slices.BinarySearchFunc(points, h, func(p point[N], h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(points) {
+		return 0
+	}
+	return i
+}
+
+// Nodes returns the members and their weights.
+func (r *Ring[K, N]) Nodes() map[N]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.weights)
+}
@@ -0,0 +1,210 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type point2D struct{ X, Y int }
+
+func TestHash_MatchesFNVOfFormattedKey(t *testing.T) {
+	want := func(key any) uint64 {
+		h := fnv.New64a()
+		fmt.Fprint(h, key)
+		return h.Sum64()
+	}
+	check := func(got uint64, key any) {
+		t.Helper()
+		if got != want(key) {
+			t.Errorf("Hash(%#v) = %d, want %d", key, got, want(key))
+		}
+	}
+	check(Hash("sensor-42"), "sensor-42")
+	check(Hash(""), "")
+	check(Hash(true), true)
+	check(Hash(-12345), -12345)
+	check(Hash(int8(-128)), int8(-128))
+	check(Hash(uint64(math.MaxUint64)), uint64(math.MaxUint64))
+	check(Hash(point2D{1, 2}), point2D{1, 2})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		Hash("sensor-42")
+		Hash(1 << 40)
+	})
+	if allocs != 0 {
+		t.Errorf("expected strings and integers to hash without allocating, got %v", allocs)
+	}
+}
+
+// owners maps keys 0..n-1 to their node.
+func owners(r *Ring[int, string], n int) []string {
+	out := make([]string, n)
+	for k := range out {
+		out[k], _ = r.Locate(k)
+	}
+	return out
+}
+
+func newRing(t *testing.T, nodes ...string) *Ring[int, string] {
+	t.Helper()
+	r := New[int, string]()
+	for _, n := range nodes {
+		if err := r.Add(n, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+const keys = 20000
+
+func TestRing_AddMovesKeysOnlyToTheNewNode(t *testing.T) {
+	r := newRing(t, "a", "b", "c", "d")
+	before := owners(r, keys)
+	r.Add("e", 1)
+	after := owners(r, keys)
+
+	moved := 0
+	for k := range keys {
+		if before[k] != after[k] {
+			moved++
+			if after[k] != "e" {
+				t.Fatalf("key %d moved from %s to %s, not to the new node", k, before[k], after[k])
+			}
+		}
+	}
+	// The new node should take about a fifth of the keys.
+	if share := float64(moved) / keys; share < 0.15 || share > 0.25 {
+		t.Errorf("expected about 20%% of keys to move, moved %.1f%%", 100*share)
+	}
+}
+
+func TestRing_RemoveMovesOnlyTheRemovedNodesKeys(t *testing.T) {
+	r := newRing(t, "a", "b", "c", "d")
+	before := owners(r, keys)
+	if !r.Remove("b") || r.Remove("b") {
+		t.Fatal("expected Remove to report whether the node was there")
+	}
+	after := owners(r, keys)
+	for k := range keys {
+		if before[k] != "b" && before[k] != after[k] {
+			t.Fatalf("key %d moved from %s to %s though its node stayed", k, before[k], after[k])
+		}
+		if after[k] == "b" {
+			t.Fatalf("key %d still on the removed node", k)
+		}
+	}
+}
+
+func TestRing_SameMembershipSamePlacement(t *testing.T) {
+	a := newRing(t, "a", "b", "c")
+	b := newRing(t, "c", "a", "b")
+	if !slices.Equal(owners(a, keys), owners(b, keys)) {
+		t.Error("expected placement not to depend on the order nodes were added")
+	}
+}
+
+func TestRing_Weights(t *testing.T) {
+	r := New[int, string]()
+	r.Add("small", 1)
+	r.Add("big", 3)
+	if err := r.Add("zero", 0); !errors.Is(err, ErrInvalidWeight) {
+		t.Errorf("expected ErrInvalidWeight, got %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, n := range owners(r, keys) {
+		counts[n]++
+	}
+	if ratio := float64(counts["big"]) / float64(counts["small"]); ratio < 2.4 || ratio > 3.6 {
+		t.Errorf("expected the weight 3 node to get about 3 times the keys, got %v", counts)
+	}
+	if w := r.Nodes(); len(w) != 2 || w["big"] != 3 {
+		t.Errorf("unexpected nodes %v", w)
+	}
+}
+
+func TestRing_Balance(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	r := newRing(t, nodes...)
+	counts := map[string]int{}
+	for _, n := range owners(r, keys) {
+		counts[n]++
+	}
+	mean := float64(keys) / float64(len(nodes))
+	for _, n := range nodes {
+		if dev := math.Abs(float64(counts[n])-mean) / mean; dev > 0.25 {
+			t.Errorf("node %s has %d keys, %.0f%% off the mean", n, counts[n], 100*dev)
+		}
+	}
+}
+
+func TestRing_LocateN(t *testing.T) {
+	r := newRing(t, "a", "b", "c")
+	owner, _ := r.Locate(7)
+	replicas := r.LocateN(7, 5)
+	if len(replicas) != 3 || replicas[0] != owner {
+		t.Errorf("expected 3 distinct nodes starting with the owner %s, got %v", owner, replicas)
+	}
+	slices.Sort(replicas)
+	if !slices.Equal(replicas, []string{"a", "b", "c"}) {
+		t.Errorf("expected every node once, got %v", replicas)
+	}
+
+	empty := New[int, string]()
+	if _, ok := empty.Locate(1); ok || empty.LocateN(1, 2) != nil {
+		t.Error("expected an empty ring to locate nothing")
+	}
+}
+
+func TestRing_GenericKeysAndHasher(t *testing.T) {
+	r := New[point2D, int](WithReplicas[point2D](16), WithHasher(func(p point2D) uint64 {
+		return uint64(p.X)<<32 | uint64(p.Y)
+	}))
+	r.Add(1, 1)
+	r.Add(2, 1)
+	if n, ok := r.Locate(point2D{3, 4}); !ok || (n != 1 && n != 2) {
+		t.Errorf("unexpected owner %d", n)
+	}
+}
+
+func TestRing_ConcurrentLookups(t *testing.T) {
+	r := newRing(t, "a", "b")
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for k := range 1000 {
+				if _, ok := r.Locate(k); !ok {
+					t.Error("expected an owner")
+					return
+				}
+			}
+		})
+	}
+	for i := range 10 {
+		r.Add(fmt.Sprint("n", i), 1)
+	}
+	wg.Wait()
+}
+
+func BenchmarkLocate(b *testing.B) {
+	r := New[string, string]()
+	for i := range 16 {
+		r.Add(fmt.Sprint("node-", i), 1)
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprint("sensor-", i)
+	}
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		r.Locate(keys[i%len(keys)])
+		i++
+	}
+}
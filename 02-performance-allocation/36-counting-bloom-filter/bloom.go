@@ -0,0 +1,198 @@
+// Package bloom answers "have I seen this before?" in a few bits per item:
+// a Bloom filter never says no for an item it was given, and says yes for
+// one it was not with a probability chosen when it is created. It suits a
+// cheap check in front of an expensive exact one, such as a database of
+// processed event IDs: most new IDs never reach the database.
+//
+// Filter only grows. CountingFilter spends four bits per slot instead of
+// one so items can also be removed.
+package bloom
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// sizing returns the slots m and hash functions k giving false positive
+// rate p for n items: m = -n·ln p / ln²2, k = m/n·ln 2.
+func sizing(n int, p float64) (m uint64, k int) {
+	if n < 1 {
+		n = 1
+	}
+	p = min(max(p, 1e-12), 0.5)
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = max(1, int(math.Round(float64(m)/float64(n)*math.Ln2)))
+	return m, k
+}
+
+// hasher derives the k slots of an item from one 64-bit hash by double
+// hashing (Kirsch and Mitzenmacher): slot i is h1 + i·h2 mod m.
+type hasher struct {
+	seed maphash.Seed
+	m    uint64
+	k    int
+}
+
+func (h *hasher) hashes(sum uint64) (h1, h2 uint64) {
+	// h2 must be odd so the k slots differ; derive it by mixing the sum.
+	h2 = sum * 0x9e3779b97f4a7c15
+	h2 ^= h2 >> 32
+	return sum, h2 | 1
+}
+
+// estimate is the false positive rate after n insertions.
+func (h *hasher) estimate(n uint64) float64 {
+	return math.Pow(1-math.Exp(-float64(h.k)*float64(n)/float64(h.m)), float64(h.k))
+}
+
+// Filter is a Bloom filter, safe for concurrent use without locks. Add and
+// Test do not allocate.
+type Filter struct {
+	hasher
+	bits  []atomic.Uint64
+	added atomic.Uint64
+}
+
+// New sizes a filter for n items at false positive rate fpRate, such as
+// 0.01 for 1%. Adding more than n items raises the rate.
+func New(n int, fpRate float64) *Filter {
+	m, k := sizing(n, fpRate)
+	words := (m + 63) / 64
+	return &Filter{
+		hasher: hasher{seed: maphash.MakeSeed(), m: words * 64, k: k},
+		bits:   make([]atomic.Uint64, words),
+	}
+}
+
+func (f *Filter) Add(b []byte)       { f.add(maphash.Bytes(f.seed, b)) }
+func (f *Filter) AddString(s string) { f.add(maphash.String(f.seed, s)) }
+
+// Test reports whether b may have been added. False is certain; true is
+// wrong at about the configured rate.
+func (f *Filter) Test(b []byte) bool       { return f.test(maphash.Bytes(f.seed, b)) }
+func (f *Filter) TestString(s string) bool { return f.test(maphash.String(f.seed, s)) }
+
+func (f *Filter) add(sum uint64) {
+	h1, h2 := f.hashes(sum)
+	for i := range uint64(f.k) {
+		slot := (h1 + i*h2) % f.m
+		f.bits[slot/64].Or(1 << (slot % 64))
+	}
+	f.added.Add(1)
+}
+
+func (f *Filter) test(sum uint64) bool {
+	h1, h2 := f.hashes(sum)
+	for i := range uint64(f.k) {
+		slot := (h1 + i*h2) % f.m
+		if f.bits[slot/64].Load()&(1<<(slot%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FalsePositiveRate estimates the current rate from the number of Adds.
+func (f *Filter) FalsePositiveRate() float64 {
+	return f.estimate(f.added.Load())
+}
+
+const (
+	counterBits = 4
+	counterMax  = 1<<counterBits - 1
+	perWord     = 64 / counterBits
+)
+
+// CountingFilter is a Bloom filter whose slots are 4-bit counters, so
+// Remove can undo an Add. A counter that reaches 15 sticks there: removing
+// would risk false negatives, so that slot just stays set. It is safe for
+// concurrent use without locks, and Add, Remove and Test do not allocate.
+type CountingFilter struct {
+	hasher
+	counters []atomic.Uint64 // 16 counters per word
+	items    atomic.Int64
+}
+
+// NewCounting sizes a counting filter for n items at the same time, at
+// false positive rate fpRate. It takes four times the memory of New.
+func NewCounting(n int, fpRate float64) *CountingFilter {
+	m, k := sizing(n, fpRate)
+	words := (m + perWord - 1) / perWord
+	return &CountingFilter{
+		hasher:   hasher{seed: maphash.MakeSeed(), m: words * perWord, k: k},
+		counters: make([]atomic.Uint64, words),
+	}
+}
+
+func (f *CountingFilter) Add(b []byte)       { f.add(maphash.Bytes(f.seed, b)) }
+func (f *CountingFilter) AddString(s string) { f.add(maphash.String(f.seed, s)) }
+
+// Remove undoes one Add of b. Removing an item that was never added would
+// corrupt the filter; Remove refuses, returning false, when the filter is
+// sure b is absent, but cannot catch a false positive.
+func (f *CountingFilter) Remove(b []byte) bool       { return f.remove(maphash.Bytes(f.seed, b)) }
+func (f *CountingFilter) RemoveString(s string) bool { return f.remove(maphash.String(f.seed, s)) }
+
+func (f *CountingFilter) Test(b []byte) bool       { return f.test(maphash.Bytes(f.seed, b)) }
+func (f *CountingFilter) TestString(s string) bool { return f.test(maphash.String(f.seed, s)) }
+
+func (f *CountingFilter) add(sum uint64) {
+	h1, h2 := f.hashes(sum)
+	for i := range uint64(f.k) {
+		f.update((h1+i*h2)%f.m, 1)
+	}
+	f.items.Add(1)
+}
+
+func (f *CountingFilter) remove(sum uint64) bool {
+	if !f.test(sum) {
+		return false
+	}
+	h1, h2 := f.hashes(sum)
+	for i := range uint64(f.k) {
+		f.update((h1+i*h2)%f.m, -1)
+	}
+	f.items.Add(-1)
+	return true
+}
+
+func (f *CountingFilter) test(sum uint64) bool {
+	h1, h2 := f.hashes(sum)
+	for i := range uint64(f.k) {
+		slot := (h1 + i*h2) % f.m
+		if counter(f.counters[slot/perWord].Load(), slot) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// update adds delta to slot's counter with a compare-and-swap, leaving
+// saturated and (when decrementing) empty counters alone.
+func (f *CountingFilter) update(slot uint64, delta int) {
+	word := &f.counters[slot/perWord]
+	shift := (slot % perWord) * counterBits
+	for {
+		old := word.Load()
+		c := counter(old, slot)
+		if c == counterMax || (delta < 0 && c == 0) {
+			return
+		}
+		next := old &^ (counterMax << shift)
+		next |= uint64(int(c)+delta) << shift
+		if word.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func counter(word, slot uint64) uint64 {
+	return word >> ((slot % perWord) * counterBits) & counterMax
+}
+
+// FalsePositiveRate estimates the current rate from the items added and
+// not removed.
+func (f *CountingFilter) FalsePositiveRate() float64 {
+	return f.estimate(uint64(max(0, f.items.Load())))
+}
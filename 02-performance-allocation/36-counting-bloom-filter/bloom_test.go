@@ -0,0 +1,207 @@
+package bloom
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSizing(t *testing.T) {
+	m, k := sizing(1000, 0.01)
+	// About 9.6 bits per item and 7 hash functions for 1%.
+	if m < 9500 || m > 9700 || k != 7 {
+		t.Errorf("expected m≈9585 and k=7, got m=%d k=%d", m, k)
+	}
+	if m, k := sizing(0, 2); m == 0 || k < 1 {
+		t.Errorf("expected nonsense inputs to be clamped, got m=%d k=%d", m, k)
+	}
+}
+
+// measureFP returns the share of n never-added IDs a filter claims to have.
+func measureFP(n int, test func(string) bool) float64 {
+	fp := 0
+	for i := range n {
+		if test("absent-" + strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	return float64(fp) / float64(n)
+}
+
+func TestFilter(t *testing.T) {
+	const n = 10000
+	f := New(n, 0.01)
+	for i := range n {
+		f.AddString("event-" + strconv.Itoa(i))
+	}
+	for i := range n {
+		if !f.Test([]byte("event-" + strconv.Itoa(i))) {
+			t.Fatalf("false negative for event-%d", i)
+		}
+	}
+	if rate := measureFP(100000, f.TestString); rate > 0.015 {
+		t.Errorf("expected a false positive rate near 1%%, got %.2f%%", 100*rate)
+	}
+	if est := f.FalsePositiveRate(); est < 0.005 || est > 0.015 {
+		t.Errorf("expected an estimate near 1%%, got %.2f%%", 100*est)
+	}
+}
+
+func TestCountingFilter(t *testing.T) {
+	const n = 10000
+	f := NewCounting(n, 0.01)
+	for i := range n {
+		f.AddString("event-" + strconv.Itoa(i))
+	}
+	if rate := measureFP(100000, f.TestString); rate > 0.015 {
+		t.Errorf("expected a false positive rate near 1%%, got %.2f%%", 100*rate)
+	}
+
+	for i := range n / 2 {
+		if !f.RemoveString("event-" + strconv.Itoa(i)) {
+			t.Fatalf("expected event-%d to be removable", i)
+		}
+	}
+	for i := n / 2; i < n; i++ {
+		if !f.TestString("event-" + strconv.Itoa(i)) {
+			t.Fatalf("removing other items caused a false negative for event-%d", i)
+		}
+	}
+	if rate := measureFP(100000, f.TestString); rate > 0.005 {
+		t.Errorf("expected removals to lower the false positive rate, got %.2f%%", 100*rate)
+	}
+	if f.Remove([]byte("never-added")) && !f.TestString("never-added") {
+		t.Error("Remove reported success for an item the filter knows is absent")
+	}
+}
+
+func TestCountingFilter_SaturatedCountersStick(t *testing.T) {
+	f := NewCounting(10, 0.01)
+	for range 20 {
+		f.AddString("hot")
+	}
+	for range 20 {
+		f.RemoveString("hot")
+	}
+	// The counters stopped at 15, so removing 20 times must not empty them:
+	// that would be a false negative for any other item sharing a slot.
+	if !f.TestString("hot") {
+		t.Error("expected saturated counters to stay set")
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	f := New(8000, 0.01)
+	c := NewCounting(8000, 0.01)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 1000 {
+				id := fmt.Sprintf("g%d-%d", g, i)
+				f.AddString(id)
+				c.AddString(id)
+				if !f.TestString(id) || !c.TestString(id) {
+					t.Errorf("false negative for %s", id)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	for g := range 8 {
+		for i := range 1000 {
+			if id := fmt.Sprintf("g%d-%d", g, i); !f.TestString(id) || !c.TestString(id) {
+				t.Fatalf("lost an update for %s", id)
+			}
+		}
+	}
+}
+
+func TestNoAllocations(t *testing.T) {
+	f := New(1000, 0.01)
+	c := NewCounting(1000, 0.01)
+	id := []byte("event-42")
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Add(id)
+		f.Test(id)
+		c.Add(id)
+		c.Test(id)
+		c.Remove(id)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func benchIDs() [][]byte {
+	ids := make([][]byte, 4096)
+	for i := range ids {
+		ids[i] = fmt.Appendf(nil, "event-%08d", i)
+	}
+	return ids
+}
+
+func BenchmarkFilter(b *testing.B) {
+	ids := benchIDs()
+	f := New(len(ids), 0.01)
+
+	b.Run("Add", func(b *testing.B) {
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			f.Add(ids[i%len(ids)])
+			i++
+		}
+	})
+	b.Run("Test", func(b *testing.B) {
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			f.Test(ids[i%len(ids)])
+			i++
+		}
+	})
+	b.Run("TestParallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				f.Test(ids[i%len(ids)])
+				i++
+			}
+		})
+	})
+	// The exact alternative: a map of every ID behind a lock.
+	b.Run("MapParallel", func(b *testing.B) {
+		var mu sync.RWMutex
+		seen := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			seen[string(id)] = struct{}{}
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				mu.RLock()
+				_ = seen[string(ids[i%len(ids)])]
+				mu.RUnlock()
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkCountingFilter(b *testing.B) {
+	ids := benchIDs()
+	f := NewCounting(len(ids), 0.01)
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		id := ids[i%len(ids)]
+		f.Add(id)
+		f.Test(id)
+		f.Remove(id)
+		i++
+	}
+}
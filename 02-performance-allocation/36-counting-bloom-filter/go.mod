@@ -0,0 +1,3 @@
+module counting-bloom-filter
+
+go 1.25.0
@@ -0,0 +1,3 @@
+module generic-lru-cache
+
+go 1.25.0
@@ -0,0 +1,263 @@
+// Package lru is a fixed-capacity cache that evicts the least recently
+// used entry, with an optional time to live and eviction callbacks.
+//
+// The recency list is intrusive: its links live in the entries the map
+// points to, so there is one allocation per entry instead of a list
+// element, a boxed value and a map entry. An entry evicted to make room is
+// reused for the entry replacing it, so a full cache stops allocating.
+package lru
+
+import (
+	"sync"
+	"time"
+)
+
+// Reason says why an entry left the cache.
+type Reason int
+
+const (
+	Evicted  Reason = iota // pushed out by a newer entry at capacity
+	Expired                // older than the TTL
+	Deleted                // removed by Delete
+	Replaced               // overwritten by Put
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	case Deleted:
+		return "deleted"
+	case Replaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+type config[K comparable, V any] struct {
+	ttl     time.Duration
+	onEvict func(K, V, Reason)
+	now     func() time.Time
+}
+
+type Option[K comparable, V any] func(*config[K, V])
+
+// WithTTL expires entries d after they were last Put. Expired entries are
+// dropped when they are next looked up or by RemoveExpired.
+func WithTTL[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.ttl = d
+	}
+}
+
+// WithOnEvict calls fn for every entry leaving the cache, after the cache's
+// lock is released, so fn may use the cache.
+func WithOnEvict[K comparable, V any](fn func(key K, value V, reason Reason)) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.onEvict = fn
+	}
+}
+
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.now = now
+	}
+}
+
+type entry[K comparable, V any] struct {
+	prev, next *entry[K, V]
+	key        K
+	value      V
+	expires    time.Time // zero without a TTL
+}
+
+// Cache is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	cfg      config[K, V]
+	capacity int
+
+	mu    sync.Mutex
+	items map[K]*entry[K, V]
+	root  entry[K, V] // root.next is the most recent, root.prev the oldest
+}
+
+func New[K comparable, V any](capacity int, opts ...Option[K, V]) *Cache[K, V] {
+	if capacity <= 0 {
+		panic("lru: capacity must be greater than zero")
+	}
+	cfg := config[K, V]{now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c := &Cache[K, V]{cfg: cfg, capacity: capacity, items: make(map[K]*entry[K, V], capacity)}
+	c.root.prev = &c.root
+	c.root.next = &c.root
+	return c
+}
+
+// Get returns key's value and marks it most recently used.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		var zero V
+		return zero, false
+	}
+	if c.expired(e) {
+		c.unlink(e)
+		delete(c.items, key)
+		c.mu.Unlock()
+		c.notify(e.key, e.value, Expired)
+		var zero V
+		return zero, false
+	}
+	c.moveToFront(e)
+	value := e.value
+	c.mu.Unlock()
+	return value, true
+}
+
+// Peek returns key's value without changing its recency.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok && !c.expired(e) {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put adds or replaces key's value, evicting the least recently used entry
+// if the cache is full.
+func (c *Cache[K, V]) Put(key K, value V) {
+	var expires time.Time
+	if c.cfg.ttl > 0 {
+		expires = c.cfg.now().Add(c.cfg.ttl)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		old := e.value
+		e.value, e.expires = value, expires
+		c.moveToFront(e)
+		c.mu.Unlock()
+		c.notify(key, old, Replaced)
+		return
+	}
+
+	var (
+		e       *entry[K, V]
+		evicted bool
+		oldKey  K
+		oldVal  V
+		reason  Reason
+	)
+	if len(c.items) >= c.capacity {
+		e = c.root.prev
+		evicted, oldKey, oldVal, reason = true, e.key, e.value, Evicted
+		if c.expired(e) {
+			reason = Expired
+		}
+		c.unlink(e)
+		delete(c.items, e.key)
+	} else {
+		e = new(entry[K, V])
+	}
+	e.key, e.value, e.expires = key, value, expires
+	c.items[key] = e
+	c.pushFront(e)
+	c.mu.Unlock()
+
+	if evicted {
+		c.notify(oldKey, oldVal, reason)
+	}
+}
+
+// Delete removes key, reporting whether it was present.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if ok {
+		c.unlink(e)
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.notify(e.key, e.value, Deleted)
+	}
+	return ok
+}
+
+// RemoveExpired drops every expired entry, for callers that want memory
+// back without waiting for lookups, and returns how many it dropped.
+func (c *Cache[K, V]) RemoveExpired() int {
+	if c.cfg.ttl <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	var expired []*entry[K, V]
+	for e := c.root.prev; e != &c.root; {
+		prev := e.prev
+		if c.expired(e) {
+			c.unlink(e)
+			delete(c.items, e.key)
+			expired = append(expired, e)
+		}
+		e = prev
+	}
+	c.mu.Unlock()
+	for _, e := range expired {
+		c.notify(e.key, e.value, Expired)
+	}
+	return len(expired)
+}
+
+// Len counts the entries, including expired ones not dropped yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Keys returns the keys from most to least recently used.
+func (c *Cache[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]K, 0, len(c.items))
+	for e := c.root.next; e != &c.root; e = e.next {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+func (c *Cache[K, V]) expired(e *entry[K, V]) bool {
+	return !e.expires.IsZero() && !c.cfg.now().Before(e.expires)
+}
+
+func (c *Cache[K, V]) notify(key K, value V, reason Reason) {
+	if c.cfg.onEvict != nil {
+		c.cfg.onEvict(key, value, reason)
+	}
+}
+
+func (c *Cache[K, V]) pushFront(e *entry[K, V]) {
+	e.prev = &c.root
+	e.next = c.root.next
+	c.root.next.prev = e
+	c.root.next = e
+}
+
+func (c *Cache[K, V]) moveToFront(e *entry[K, V]) {
+	c.unlink(e)
+	c.pushFront(e)
+}
+
+func (c *Cache[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
@@ -0,0 +1,209 @@
+package lru
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eviction struct {
+	key    string
+	value  int
+	reason Reason
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	var evicted []eviction
+	c := New(3, WithOnEvict(func(k string, v int, r Reason) {
+		evicted = append(evicted, eviction{k, v, r})
+	}))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	c.Get("a")      // a is now the most recent
+	c.Peek("b")     // Peek does not count as use
+	c.Put("d", 4)   // evicts b
+	c.Put("c", 30)  // replaces c
+	c.Delete("a")   // deletes a
+	c.Delete("zzz") // absent: no callback
+
+	if keys := c.Keys(); !slices.Equal(keys, []string{"c", "d"}) {
+		t.Errorf("expected keys [c d], got %v", keys)
+	}
+	want := []eviction{{"b", 2, Evicted}, {"c", 3, Replaced}, {"a", 1, Deleted}}
+	if !slices.Equal(evicted, want) {
+		t.Errorf("expected evictions %v, got %v", want, evicted)
+	}
+	if v, ok := c.Get("c"); !ok || v != 30 {
+		t.Errorf("expected c=30, got %d, %v", v, ok)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	var evicted []eviction
+	c := New(10,
+		WithTTL[string, int](time.Minute),
+		WithClock[string, int](func() time.Time { return now }),
+		WithOnEvict(func(k string, v int, r Reason) { evicted = append(evicted, eviction{k, v, r}) }),
+	)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	now = now.Add(30 * time.Second)
+	c.Put("a", 10) // refreshes a's TTL
+	now = now.Add(45 * time.Second)
+
+	if _, ok := c.Peek("b"); ok {
+		t.Error("expected b to have expired")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to have expired")
+	}
+	if v, ok := c.Get("a"); !ok || v != 10 {
+		t.Errorf("expected the refreshed a=10, got %d, %v", v, ok)
+	}
+
+	c.Put("c", 3)
+	now = now.Add(time.Hour)
+	if n := c.RemoveExpired(); n != 2 || c.Len() != 0 {
+		t.Errorf("expected 2 expired entries removed, got %d, %d left", n, c.Len())
+	}
+	want := []eviction{{"a", 1, Replaced}, {"b", 2, Expired}, {"a", 10, Expired}, {"c", 3, Expired}}
+	if !slices.Equal(evicted, want) {
+		t.Errorf("expected evictions %v, got %v", want, evicted)
+	}
+}
+
+func TestCache_CallbackMayUseTheCache(t *testing.T) {
+	var c *Cache[int, int]
+	c = New(1, WithOnEvict(func(k, v int, r Reason) {
+		if r == Evicted {
+			c.Get(k) // would deadlock if called under the lock
+		}
+	}))
+	c.Put(1, 1)
+	c.Put(2, 2)
+}
+
+func TestCache_FullCacheDoesNotAllocate(t *testing.T) {
+	c := New[int, int](100)
+	for i := range 100 {
+		c.Put(i, i)
+	}
+	i := 100
+	allocs := testing.AllocsPerRun(1000, func() {
+		c.Put(i, i)
+		c.Get(i)
+		i++
+	})
+	if allocs != 0 {
+		t.Errorf("expected eviction to recycle entries, got %v allocs", allocs)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := New[int, int](64)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 1000 {
+				k := (g*1000 + i) % 200
+				c.Put(k, i)
+				c.Get(k)
+				if i%10 == 0 {
+					c.Delete(k)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if n := c.Len(); n > 64 || len(c.Keys()) != n {
+		t.Errorf("expected at most 64 consistent entries, got %d", n)
+	}
+}
+
+// listCache is the usual non-intrusive LRU: container/list plus a map of
+// list elements, boxing every entry in an interface.
+type listCache struct {
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type listEntry struct {
+	key   string
+	value int
+}
+
+func (c *listCache) Put(key string, value int) {
+	if e, ok := c.items[key]; ok {
+		e.Value.(*listEntry).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	if c.ll.Len() >= c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*listEntry).key)
+	}
+	c.items[key] = c.ll.PushFront(&listEntry{key, value})
+}
+
+func (c *listCache) Get(key string) (int, bool) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*listEntry).value, true
+	}
+	return 0, false
+}
+
+// BenchmarkChurn puts keys drawn from twice the capacity, so half the puts
+// evict, and reads each back.
+func BenchmarkChurn(b *testing.B) {
+	const capacity = 1024
+	keys := make([]string, 2*capacity)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+
+	b.Run("Intrusive", func(b *testing.B) {
+		c := New[string, int](capacity)
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			k := keys[(i*7919)%len(keys)]
+			c.Put(k, i)
+			c.Get(k)
+			i++
+		}
+	})
+	b.Run("ContainerList", func(b *testing.B) {
+		c := &listCache{capacity: capacity, ll: list.New(), items: make(map[string]*list.Element, capacity)}
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			k := keys[(i*7919)%len(keys)]
+			c.Put(k, i)
+			c.Get(k)
+			i++
+		}
+	})
+}
+
+func ExampleCache() {
+	c := New(2, WithOnEvict(func(k string, v int, r Reason) {
+		fmt.Println(r, k)
+	}))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")
+	c.Put("c", 3)
+	fmt.Println(c.Keys())
+	// Output:
+	// evicted b
+	// [c a]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrPoolFull   = errors.New("worker pool queue is full")
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
+
+// Executor runs tasks in the background. Go must not block: when the task
+// cannot be queued it returns an error instead. WorkerPool implements it;
+// other pools, such as the one from the worker-pool kata, can be adapted.
+type Executor interface {
+	Go(task func()) error
+}
+
+// Future is the eventual result of an asynchronous call.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+func (f *Future[T]) resolve(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+// Done is closed once the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the result is available or ctx ends. Giving up on a
+// future does not cancel the work behind it.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// AsyncPipeline runs a built pipeline on an Executor.
+type AsyncPipeline struct {
+	processor Processor
+	executor  Executor
+}
+
+// BuildAsync builds the pipeline like Build and runs it on executor, so a
+// caller such as an HTTP handler can hand an event off and return at once.
+func (p *Pipeline) BuildAsync(executor Executor, finals ...ConsumerBuilder) *AsyncPipeline {
+	return &AsyncPipeline{processor: p.Build(finals...), executor: executor}
+}
+
+// Submit queues event and returns its future result. If the executor
+// refuses the event, the future is already resolved with its error.
+//
+// The event is processed under ctx, so a caller that returns before the
+// work is done, like a handler whose request context ends with the
+// response, should pass context.WithoutCancel(ctx) to keep its values
+// without its cancellation.
+func (a *AsyncPipeline) Submit(ctx context.Context, event Event) *Future[[]Event] {
+	future := newFuture[[]Event]()
+	err := a.executor.Go(func() {
+		future.resolve(a.processor.Process(ctx, event))
+	})
+	if err != nil {
+		future.resolve(nil, err)
+	}
+	return future
+}
+
+// WorkerPool is an Executor with a fixed number of workers and a bounded
+// queue.
+type WorkerPool struct {
+	tasks chan func()
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	pool := &WorkerPool{tasks: make(chan func(), queueSize)}
+	pool.wg.Add(workers)
+	for range workers {
+		go func() {
+			defer pool.wg.Done()
+			for task := range pool.tasks {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+// Go queues task, failing with ErrPoolFull rather than waiting for room.
+func (p *WorkerPool) Go(task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrPoolFull
+	}
+}
+
+// Close stops accepting tasks and waits for the queued ones to finish.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrNoPipeline = errors.New("no pipeline installed")
+
+// AtomicPipeline is a Processor whose pipeline can be replaced while it
+// serves traffic, to apply new split rules or timeouts without a restart.
+// Events already in the old pipeline finish there; new ones go to the new
+// one as soon as Swap installs it.
+type AtomicPipeline struct {
+	current atomic.Pointer[generation]
+	check   func(ctx context.Context, candidate Processor) error
+
+	swapMu sync.Mutex // one Swap at a time
+}
+
+// generation is one installed pipeline and the events inside it.
+type generation struct {
+	processor Processor
+	pipeline  *Pipeline
+
+	mu      sync.RWMutex // held for reading by every event in flight
+	retired bool
+}
+
+type AtomicOption func(*AtomicPipeline)
+
+// WithSwapCheck runs check against every candidate before it is installed,
+// for instance to push a few canary events through it. A failing check
+// aborts the Swap.
+func WithSwapCheck(check func(ctx context.Context, candidate Processor) error) AtomicOption {
+	return func(a *AtomicPipeline) {
+		a.check = check
+	}
+}
+
+// NewAtomicPipeline returns an AtomicPipeline with no pipeline; events fail
+// with ErrNoPipeline until the first Swap.
+func NewAtomicPipeline(opts ...AtomicOption) *AtomicPipeline {
+	a := &AtomicPipeline{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *AtomicPipeline) Process(ctx context.Context, event Event) ([]Event, error) {
+	for {
+		g := a.current.Load()
+		if g == nil {
+			return nil, ErrNoPipeline
+		}
+		g.mu.RLock()
+		if g.retired {
+			// Swapped out between Load and RLock; use its successor.
+			g.mu.RUnlock()
+			continue
+		}
+		events, err := g.processor.Process(ctx, event)
+		g.mu.RUnlock()
+		return events, err
+	}
+}
+
+// Swap builds next and, if it is configured correctly and passes the swap
+// check, installs it in place of the current pipeline. It then waits for the
+// events still in the old pipeline and closes it, draining its buffered
+// stages. If next is rejected the current pipeline stays, and the error says
+// why.
+func (a *AtomicPipeline) Swap(ctx context.Context, next *Pipeline, finals ...ConsumerBuilder) error {
+	candidate := next.Build(finals...)
+	if err := next.Err(); err != nil {
+		next.Close()
+		return fmt.Errorf("swap: %w", err)
+	}
+	if a.check != nil {
+		if err := a.check(ctx, candidate); err != nil {
+			next.Close()
+			return fmt.Errorf("swap: %w", err)
+		}
+	}
+
+	a.swapMu.Lock()
+	defer a.swapMu.Unlock()
+	old := a.current.Swap(&generation{processor: candidate, pipeline: next})
+	if old == nil {
+		return nil
+	}
+	old.mu.Lock()
+	old.retired = true
+	old.mu.Unlock()
+	old.pipeline.Close()
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is one line of the audit log: an event as it entered the
+// stage, what the rest of the pipeline made of it, and how long that took.
+type AuditRecord struct {
+	Time      time.Time    `json:"time"`
+	Input     auditEvent   `json:"input"`
+	Output    []auditEvent `json:"output,omitempty"`
+	Outcome   Outcome      `json:"outcome"`
+	Error     string       `json:"error,omitempty"`
+	LatencyMS float64      `json:"latency_ms"`
+}
+
+type auditEvent struct {
+	ID       string            `json:"id,omitempty"`
+	UserID   string            `json:"user_id"`
+	Action   string            `json:"action"`
+	Version  int               `json:"version,omitempty"`
+	Payload  any               `json:"payload,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func newAuditEvent(event Event) auditEvent {
+	return auditEvent{ID: event.ID, UserID: event.UserID, Action: event.Action.String(), Version: event.Version, Payload: event.Payload, Metadata: event.Metadata}
+}
+
+type AuditConfig struct {
+	now func() time.Time
+}
+
+type AuditOption func(*AuditConfig)
+
+// WithAuditClock replaces time.Now for the records' timestamps.
+func WithAuditClock(now func() time.Time) AuditOption {
+	return func(cfg *AuditConfig) {
+		cfg.now = now
+	}
+}
+
+// NewAuditProcessorBuilder appends an AuditRecord for every event to w as a
+// line of JSON, written with a single Write so an append-only store never
+// sees half a record. The audit fails closed: if the record cannot be
+// written, the event fails with that error too, even though the stages
+// after this one have already run. Use a RotatingFile for w to get rotation
+// and fsync.
+func NewAuditProcessorBuilder(w io.Writer, opts ...AuditOption) ProcessBuilder {
+	return func(next Processor) Processor {
+		cfg := &AuditConfig{
+			now: time.Now,
+		}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		var mu sync.Mutex // serializes writes to w
+
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Audit] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			start := cfg.now()
+			events, err := next.Process(ctx, event)
+			record := AuditRecord{
+				Time:      start.UTC(),
+				Input:     newAuditEvent(event),
+				Outcome:   outcomeOf(err),
+				LatencyMS: float64(cfg.now().Sub(start)) / float64(time.Millisecond),
+			}
+			for _, evt := range events {
+				record.Output = append(record.Output, newAuditEvent(evt))
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+
+			line, auditErr := json.Marshal(record)
+			if auditErr == nil {
+				mu.Lock()
+				_, auditErr = w.Write(append(line, '\n'))
+				mu.Unlock()
+			}
+			if auditErr != nil {
+				return events, errors.Join(err, fmt.Errorf("audit: %w", auditErr))
+			}
+			return events, err
+		})
+	}
+}
+
+// RotatingFile is an append-only file that is renamed aside and replaced
+// once it reaches a size limit. It is safe for concurrent use.
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	fsync    bool
+	now      func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+type FileOption func(*RotatingFile)
+
+// WithMaxBytes rotates the file before a write would take it past n bytes;
+// zero, the default, never rotates.
+func WithMaxBytes(n int64) FileOption {
+	return func(f *RotatingFile) {
+		f.maxBytes = n
+	}
+}
+
+// WithFsync syncs the file after every write, so an acknowledged record
+// survives a crash, at the price of a disk flush per event.
+func WithFsync() FileOption {
+	return func(f *RotatingFile) {
+		f.fsync = true
+	}
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed.
+func OpenRotatingFile(path string, opts ...FileOption) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, now: time.Now}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil && f.fsync {
+		err = f.file.Sync()
+	}
+	return n, err
+}
+
+// rotate renames the current file to path.<UTC timestamp> and starts a new
+// one. The caller must hold f.mu.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	rotated := f.path + "." + f.now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
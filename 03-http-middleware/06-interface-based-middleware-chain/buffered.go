@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"mpsc-ring-buffer"
+)
+
+var ErrPipelineClosed = errors.New("pipeline is closed")
+
+// ThenBuffered appends next behind a bounded queue served by workers
+// goroutines. Upstream hands an event over and returns as soon as it is
+// queued, reporting it as accepted; only when the queue is full does it
+// wait, until there is room or its context ends, so a slow stage pushes
+// back on its producers instead of adding its latency to every call. The
+// queue holds queueSize events rounded up to a power of two.
+//
+// Queued events are processed with their caller's context values but not
+// its cancellation, since the caller has moved on. For the same reason a
+// failure in or after the stage is not returned: it goes to the pipeline's
+// dead-letter processor if it has one, and to the log. Call Close to drain
+// the queues and stop the workers.
+func (p *Pipeline) ThenBuffered(next ProcessBuilder, queueSize, workers int) *Pipeline {
+	p.stages = append(p.stages, stage{
+		build: func(downstream Processor) Processor {
+			return p.buffer(next(downstream), queueSize, workers)
+		},
+		kind:    "buffered",
+		details: []string{fmt.Sprintf("queue %d", queueSize), fmt.Sprintf("workers %d", workers)},
+	})
+	return p
+}
+
+type bufferedJob struct {
+	ctx   context.Context
+	event Event
+}
+
+type bufferedStage struct {
+	jobs *ring.Ring[bufferedJob]
+	wg   sync.WaitGroup
+
+	// The ring has a single consumer: workers take turns dequeuing under
+	// consume, and process what they got outside it.
+	consume sync.Mutex
+	stop    context.Context // done once no more jobs can be queued
+	cancel  context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (p *Pipeline) buffer(processor Processor, queueSize, workers int) Processor {
+	if p.deadLetter != nil {
+		processor = NewDeadLetterProcessor(p.deadLetter, processor)
+	}
+	b := &bufferedStage{jobs: ring.NewRing[bufferedJob](queueSize)}
+	b.stop, b.cancel = context.WithCancel(context.Background())
+	b.wg.Add(workers)
+	for range workers {
+		go func() {
+			defer b.wg.Done()
+			for {
+				job, ok := b.next()
+				if !ok {
+					return
+				}
+				if _, err := processor.Process(job.ctx, job.event); err != nil {
+					log.Default().Println("[Buffered] Failed to process event:", job.event.String(), "reason:", err)
+				}
+			}
+		}()
+	}
+	p.closers = append(p.closers, b.close)
+
+	return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+		if IsCtxDone(ctx) {
+			log.Default().Println("[Buffered] Context done before processing event:", event.String())
+			return nil, ctx.Err()
+		}
+
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		if b.closed {
+			return nil, ErrPipelineClosed
+		}
+		if err := b.jobs.Enqueue(ctx, bufferedJob{ctx: context.WithoutCancel(ctx), event: event}); err != nil {
+			return nil, err
+		}
+		return []Event{event}, nil
+	})
+}
+
+// next waits for the next queued job. It reports false once the stage is
+// closed and the queue drained.
+func (b *bufferedStage) next() (bufferedJob, bool) {
+	b.consume.Lock()
+	defer b.consume.Unlock()
+	job, err := b.jobs.Dequeue(b.stop)
+	if err != nil {
+		// Every enqueue finished before stop was cancelled, so whatever is
+		// still queued is visible now.
+		return b.jobs.TryDequeue()
+	}
+	return job, true
+}
+
+func (b *bufferedStage) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		b.cancel()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// Close stops the buffered stages of every processor built from p, after
+// the events already queued have been processed. Events handed to them
+// afterwards fail with ErrPipelineClosed.
+func (p *Pipeline) Close() {
+	// Stages are built from the consumer outwards; closing upstream stages
+	// first lets them drain into the stages below before those stop.
+	for _, closeStage := range slices.Backward(p.closers) {
+		closeStage()
+	}
+	p.closers = nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"counting-bloom-filter"
+)
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found in event log")
+
+// CheckpointStore remembers, per source, the ID of the last event that was
+// processed successfully. Load returns "" for a source with no checkpoint.
+type CheckpointStore interface {
+	Load(ctx context.Context, source string) (string, error)
+	Save(ctx context.Context, source, id string) error
+}
+
+// EventLog is a source's events in order, readable again after a crash.
+// After returns the events following the one with ID id, or all of them for
+// an empty id; it fails with ErrCheckpointNotFound if id is not in the log.
+type EventLog interface {
+	After(ctx context.Context, id string) ([]Event, error)
+}
+
+// NewCheckpointProcessorBuilder saves each event's ID as source's checkpoint
+// once the rest of the pipeline has processed it. Events must reach it in
+// source order for the checkpoint to mean that everything before it is
+// done. If the save fails the event fails too, so it is retried rather than
+// silently skipped by the next replay.
+func NewCheckpointProcessorBuilder(source string, store CheckpointStore) ProcessBuilder {
+	return func(next Processor) Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Checkpoint] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			events, err := next.Process(ctx, event)
+			if err != nil || event.ID == "" {
+				return events, err
+			}
+			if err := store.Save(ctx, source, event.ID); err != nil {
+				return events, fmt.Errorf("checkpoint %q: %w", event.ID, err)
+			}
+			return events, nil
+		})
+	}
+}
+
+// Replay reprocesses source's events logged after its checkpoint, in order,
+// advancing the checkpoint after each. It stops at the first failure, which
+// is then replayed first next time, so every event is processed at least
+// once; put a NewDedupProcessorBuilder stage in pipeline to process each
+// effectively once. It returns how many events it processed.
+func Replay(ctx context.Context, source string, events EventLog, store CheckpointStore, pipeline Processor) (int, error) {
+	checkpoint, err := store.Load(ctx, source)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := events.After(ctx, checkpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, event := range pending {
+		if _, err := pipeline.Process(ctx, event); err != nil {
+			return i, fmt.Errorf("replay %q: %w", event.ID, err)
+		}
+		if err := store.Save(ctx, source, event.ID); err != nil {
+			return i, fmt.Errorf("checkpoint %q: %w", event.ID, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// SeenStore records which event IDs have been processed.
+type SeenStore interface {
+	Seen(ctx context.Context, id string) (bool, error)
+	MarkSeen(ctx context.Context, id string) error
+}
+
+// NewDedupProcessorBuilder drops events whose ID store has seen, returning
+// no events and no error for them, and marks each event seen once the rest
+// of the pipeline has processed it. Events without an ID always pass.
+// Two copies of one event processed at the same time can both get through;
+// dedup is for redelivery and replay, not for concurrent duplicates.
+func NewDedupProcessorBuilder(store SeenStore) ProcessBuilder {
+	return func(next Processor) Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Dedup] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+			if event.ID == "" {
+				return next.Process(ctx, event)
+			}
+
+			seen, err := store.Seen(ctx, event.ID)
+			if err != nil {
+				return nil, err
+			}
+			if seen {
+				log.Default().Println("[Dedup] Dropping duplicate event:", event.ID)
+				return nil, nil
+			}
+
+			events, err := next.Process(ctx, event)
+			if err != nil {
+				return events, err
+			}
+			if err := store.MarkSeen(ctx, event.ID); err != nil {
+				return events, err
+			}
+			return events, nil
+		})
+	}
+}
+
+// BloomSeenStore puts a Bloom filter in front of a SeenStore. Most IDs a
+// dedup stage sees are new, and the filter answers those in memory, without
+// a round trip to store; only IDs the filter may have seen are looked up,
+// so the answers stay exact. filter must hold every ID store already has:
+// fill it from store's backing table when the process starts.
+type BloomSeenStore struct {
+	store  SeenStore
+	filter *bloom.Filter
+}
+
+func NewBloomSeenStore(store SeenStore, filter *bloom.Filter) *BloomSeenStore {
+	return &BloomSeenStore{store: store, filter: filter}
+}
+
+func (s *BloomSeenStore) Seen(ctx context.Context, id string) (bool, error) {
+	if !s.filter.TestString(id) {
+		return false, nil
+	}
+	return s.store.Seen(ctx, id)
+}
+
+func (s *BloomSeenStore) MarkSeen(ctx context.Context, id string) error {
+	if err := s.store.MarkSeen(ctx, id); err != nil {
+		return err
+	}
+	s.filter.AddString(id)
+	return nil
+}
+
+// MemoryStore is an in-memory CheckpointStore and SeenStore. It does not
+// survive the process, so it suits tests and single-process replays.
+type MemoryStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]string
+	seen        map[string]struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		checkpoints: make(map[string]string),
+		seen:        make(map[string]struct{}),
+	}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, source string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[source], nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, source, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[source] = id
+	return nil
+}
+
+func (s *MemoryStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.seen[id]
+	return ok, nil
+}
+
+func (s *MemoryStore) MarkSeen(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[id] = struct{}{}
+	return nil
+}
+
+// MemoryEventLog is an in-memory EventLog.
+type MemoryEventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *MemoryEventLog) Append(events ...Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, events...)
+}
+
+func (l *MemoryEventLog) After(ctx context.Context, id string) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id == "" {
+		return slices.Clone(l.events), nil
+	}
+	i := slices.IndexFunc(l.events, func(e Event) bool { return e.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q", ErrCheckpointNotFound, id)
+	}
+	return slices.Clone(l.events[i+1:]), nil
+}
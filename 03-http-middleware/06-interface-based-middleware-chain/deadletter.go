@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+type deadLetterKey struct{}
+
+// WithDeadLetter routes every event the pipeline fails to process to dlq
+// instead of only returning the error, so a poison event is parked for
+// inspection rather than lost. dlq receives the original event; the failure
+// is attached to its context, see DeadLetterReason.
+//
+// If dlq accepts the event, Process returns dlq's events, after any the
+// pipeline produced before failing, and no error. If dlq fails too, both
+// errors are returned. Events abandoned because the caller's context ended
+// are not dead-lettered: they did not fail, they were never finished.
+func (p *Pipeline) WithDeadLetter(dlq Processor) *Pipeline {
+	p.deadLetter = dlq
+	return p
+}
+
+// DeadLetterReason returns the error that sent the event to the dead-letter
+// processor whose context this is, or nil outside of one.
+func DeadLetterReason(ctx context.Context) error {
+	err, _ := ctx.Value(deadLetterKey{}).(error)
+	return err
+}
+
+func NewDeadLetterProcessor(dlq Processor, next Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+		events, err := next.Process(ctx, event)
+		if err == nil || ctx.Err() != nil {
+			return events, err
+		}
+
+		log.Default().Println("[DeadLetter] Routing failed event:", event.String(), "reason:", err)
+		parked, dlqErr := dlq.Process(context.WithValue(ctx, deadLetterKey{}, err), event)
+		if dlqErr != nil {
+			return events, errors.Join(err, dlqErr)
+		}
+		return append(events, parked...), nil
+	})
+}
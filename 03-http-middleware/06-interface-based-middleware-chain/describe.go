@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ThenSplit appends an event splitter, like Then with
+// NewEventSplitterProcessorBuilder, and lets Describe show its rules.
+func (p *Pipeline) ThenSplit(opts ...SplitterOption) *Pipeline {
+	cfg := &SplitterConfig{
+		splitRules: make(map[Action][]Action),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var details []string
+	for _, action := range slices.Sorted(maps.Keys(cfg.splitRules)) {
+		splits := make([]string, len(cfg.splitRules[action]))
+		for i, split := range cfg.splitRules[action] {
+			splits[i] = split.String()
+		}
+		details = append(details, action.String()+" -> "+strings.Join(splits, ", "))
+	}
+	if cfg.concurrency > 1 {
+		details = append(details, fmt.Sprintf("concurrency %d", cfg.concurrency))
+	}
+	p.stages = append(p.stages, stage{build: NewEventSplitterProcessorBuilder(opts...), kind: "splitter", details: details})
+	return p
+}
+
+// ThenRoute appends a router, like Then with NewRouterProcessorBuilder, and
+// lets Describe show where each action goes.
+func (p *Pipeline) ThenRoute(routes map[Action]Processor, fallback Processor) *Pipeline {
+	var branches []string
+	for _, action := range slices.Sorted(maps.Keys(routes)) {
+		branches = append(branches, action.String())
+	}
+	details := []string{"unrouted -> next stage"}
+	if fallback != nil {
+		details = []string{"unrouted -> fallback"}
+	}
+	p.stages = append(p.stages, stage{
+		build:    NewRouterProcessorBuilder(routes, fallback),
+		kind:     "router",
+		details:  details,
+		branches: branches,
+	})
+	return p
+}
+
+// StageDescription is what Describe reports about one stage.
+type StageDescription struct {
+	Name     string   // as in metrics: the stage's label, or its position
+	Kind     string   // "stage", "splitter", "router", "buffered" or "registered <name>"
+	Details  []string // configuration, such as split rules and timeouts
+	Branches []string // actions a router sends to a sub-pipeline
+}
+
+// PipelineDescription lists a pipeline's stages in the order events pass
+// through them, and the settings that apply to all of them.
+type PipelineDescription struct {
+	Stages   []StageDescription
+	Settings []string
+}
+
+// Describe reports what the pipeline does, without building it. Stages
+// added with Then are opaque functions, so only their name is known; use
+// ThenSplit and ThenRoute to have rules and routes described too.
+func (p *Pipeline) Describe() PipelineDescription {
+	var d PipelineDescription
+	for i, s := range p.stages {
+		desc := StageDescription{
+			Name:     s.name,
+			Kind:     s.kind,
+			Details:  slices.Clone(s.details),
+			Branches: slices.Clone(s.branches),
+		}
+		if desc.Name == "" {
+			desc.Name = strconv.Itoa(len(p.stages) - i + 1)
+		}
+		if timeout, ok := p.timeouts[s.name]; ok && s.name != "" {
+			desc.Details = append(desc.Details, "timeout "+timeout.String())
+		}
+		d.Stages = append(d.Stages, desc)
+	}
+
+	if p.metrics != nil {
+		d.Settings = append(d.Settings, "metrics")
+	}
+	if p.deadLetter != nil {
+		d.Settings = append(d.Settings, "dead-letter")
+	}
+	d.Settings = append(d.Settings, "delivery "+p.delivery.String())
+	return d
+}
+
+// DOT renders the description as a Graphviz digraph.
+func (d PipelineDescription) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	fmt.Fprintf(&b, "\tconsumer [label=%s];\n", strconv.Quote(d.consumerLabel("\n")))
+	for i, s := range d.Stages {
+		fmt.Fprintf(&b, "\ts%d [label=%s];\n", i, strconv.Quote(s.label("\n")))
+		for _, branch := range s.Branches {
+			fmt.Fprintf(&b, "\ts%d_%s [label=%s, shape=ellipse];\n", i, branch, strconv.Quote("route "+branch))
+			fmt.Fprintf(&b, "\ts%d -> s%d_%s [label=%s];\n", i, i, branch, strconv.Quote(branch))
+		}
+	}
+	for i := range d.Stages {
+		fmt.Fprintf(&b, "\ts%d -> %s;\n", i, d.nextNode(i))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the description as a Mermaid flowchart.
+func (d PipelineDescription) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	fmt.Fprintf(&b, "\tconsumer[%s]\n", mermaidText(d.consumerLabel("<br/>")))
+	for i, s := range d.Stages {
+		fmt.Fprintf(&b, "\ts%d[%s]\n", i, mermaidText(s.label("<br/>")))
+		for _, branch := range s.Branches {
+			fmt.Fprintf(&b, "\ts%d -- %s --> s%d_%s([%s])\n", i, branch, i, branch, mermaidText("route "+branch))
+		}
+	}
+	for i := range d.Stages {
+		fmt.Fprintf(&b, "\ts%d --> %s\n", i, d.nextNode(i))
+	}
+	return b.String()
+}
+
+func (d PipelineDescription) nextNode(i int) string {
+	if i+1 < len(d.Stages) {
+		return "s" + strconv.Itoa(i+1)
+	}
+	return "consumer"
+}
+
+func (d PipelineDescription) consumerLabel(sep string) string {
+	return strings.Join(append([]string{"consumer"}, d.Settings...), sep)
+}
+
+func (s StageDescription) label(sep string) string {
+	return strings.Join(append([]string{s.Name + " (" + s.Kind + ")"}, s.Details...), sep)
+}
+
+func mermaidText(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrNoConsumer = errors.New("pipeline has no consumer")
+
+// DeliveryPolicy decides what it takes for an event handed to several
+// consumers to count as delivered.
+type DeliveryPolicy int
+
+const (
+	// DeliverAll hands the event to every consumer and fails if any of them
+	// does, returning the events of those that succeeded with the joined
+	// errors, like the splitter.
+	DeliverAll DeliveryPolicy = iota
+	// DeliverBestEffort hands the event to every consumer and only fails if
+	// all of them do; other failures are logged and dropped.
+	DeliverBestEffort
+	// DeliverFirstSuccess tries the consumers in order and stops at the first
+	// that succeeds, so later ones act as fallbacks.
+	DeliverFirstSuccess
+)
+
+func (policy DeliveryPolicy) String() string {
+	switch policy {
+	case DeliverAll:
+		return "DeliverAll"
+	case DeliverBestEffort:
+		return "DeliverBestEffort"
+	case DeliverFirstSuccess:
+		return "DeliverFirstSuccess"
+	default:
+		return "UnknownDeliveryPolicy"
+	}
+}
+
+// WithDeliveryPolicy sets how Build delivers to several consumers; the
+// default is DeliverAll. A single consumer is called directly whatever the
+// policy.
+func (p *Pipeline) WithDeliveryPolicy(policy DeliveryPolicy) *Pipeline {
+	p.delivery = policy
+	return p
+}
+
+func (p *Pipeline) consumer(finals []ConsumerBuilder) Processor {
+	if len(finals) == 1 {
+		return finals[0]()
+	}
+	consumers := make([]Processor, len(finals))
+	for i, final := range finals {
+		consumers[i] = final()
+	}
+	return NewFanOutProcessor(p.delivery, consumers...)
+}
+
+// NewFanOutProcessor delivers each event to consumers, one after another,
+// under policy.
+func NewFanOutProcessor(policy DeliveryPolicy, consumers ...Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+		if IsCtxDone(ctx) {
+			log.Default().Println("[FanOut] Context done before processing event:", event.String())
+			return nil, ctx.Err()
+		}
+
+		var resultEvents []Event
+		var resultErrors []error
+		for i, consumer := range consumers {
+			events, err := consumer.Process(ctx, event)
+			if err != nil {
+				resultErrors = append(resultErrors, fmt.Errorf("consumer %d: %w", i+1, err))
+				if ctx.Err() != nil {
+					break
+				}
+				continue
+			}
+			resultEvents = append(resultEvents, events...)
+			if policy == DeliverFirstSuccess {
+				return resultEvents, nil
+			}
+		}
+
+		switch {
+		case len(resultErrors) == 0:
+			return resultEvents, nil
+		case policy == DeliverBestEffort && len(resultErrors) < len(consumers):
+			log.Default().Println("[FanOut] Dropped failed deliveries for event:", event.String(), errors.Join(resultErrors...))
+			return resultEvents, nil
+		default:
+			return resultEvents, errors.Join(resultErrors...)
+		}
+	})
+}
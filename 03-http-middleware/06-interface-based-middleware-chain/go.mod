@@ -1,3 +1,16 @@
 module interface-based-middleware-chain
 
 go 1.25.0
+
+require (
+	counting-bloom-filter v0.0.0
+	golang.org/x/sync v0.20.0
+	mpsc-ring-buffer v0.0.0
+	panic-safe-errgroup v0.0.0
+)
+
+replace panic-safe-errgroup => ../../01-context-cancellation-concurrency/27-panic-safe-errgroup
+
+replace counting-bloom-filter => ../../02-performance-allocation/36-counting-bloom-filter
+
+replace mpsc-ring-buffer => ../../02-performance-allocation/25-mpsc-ring-buffer
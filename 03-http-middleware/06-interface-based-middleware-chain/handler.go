@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// maxRequestBytes bounds the body DecodeJSONRequest reads.
+const maxRequestBytes = 1 << 20
+
+// NewPipelineHandler serves p over HTTP: each request is decoded into an
+// event, processed with the request's context, and the outcome written by
+// encode. A nil decode reads the body with DecodeJSONRequest and a nil
+// encode writes JSON with EncodeJSONResponse. Decoding errors are reported
+// to encode wrapped in ErrInvalidEvent.
+func NewPipelineHandler(
+	p Processor,
+	decode func(*http.Request) (Event, error),
+	encode func(w http.ResponseWriter, events []Event, err error),
+) http.Handler {
+	if decode == nil {
+		decode = DecodeJSONRequest
+	}
+	if encode == nil {
+		encode = EncodeJSONResponse
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := decode(r)
+		if err != nil {
+			encode(w, nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err))
+			return
+		}
+		events, err := p.Process(r.Context(), event)
+		encode(w, events, err)
+	})
+}
+
+// DecodeJSONRequest decodes a request body as DecodeJSONEvent does.
+func DecodeJSONRequest(r *http.Request) (Event, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBytes))
+	if err != nil {
+		return Event{}, err
+	}
+	return DecodeJSONEvent(data)
+}
+
+// EncodeJSONResponse writes {"events": [...]} on success and {"error": ...}
+// otherwise, with the status StatusCode picks for err.
+func EncodeJSONResponse(w http.ResponseWriter, events []Event, err error) {
+	var body any
+	if err != nil {
+		body = struct {
+			Error string `json:"error"`
+		}{err.Error()}
+	} else {
+		wire := make([]jsonEvent, len(events))
+		for i, event := range events {
+			wire[i] = jsonEvent{ID: event.ID, UserID: event.UserID, Action: event.Action.String(), Version: event.Version, Metadata: event.Metadata}
+		}
+		body = struct {
+			Events []jsonEvent `json:"events"`
+		}{wire}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(StatusCode(err))
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Default().Println("[Handler] Failed to write response:", err)
+	}
+}
+
+// StatusCode maps a pipeline error to an HTTP status: bad events are the
+// client's fault, a stage running out of time is a gateway timeout, and a
+// canceled request, which usually means the server is shutting down or the
+// client left, is unavailable.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrInvalidEvent), errors.Is(err, ErrInvalidPayload):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -3,24 +3,35 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"time"
 )
 
 func main() {
+	metrics := NewPrometheusSink()
 	processor := NewPipeline().
-		WithMetrics().
+		WithMetricsSink(metrics).
 		Then(NewTimeoutProcessorBuilder(10 * time.Second)).
 		Then(NewValidatorProcessorBuilder()).
+		Then(NewSchemaValidatorProcessorBuilder(
+			WithSchema(ActionUploadFile, PayloadSchema(FilePayload.Validate)),
+		)).
 		Then(NewLoggerProcessorBuilder()).
 		Then(NewEventSplitterProcessorBuilder(
 			WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata}),
 		)).
 		Build(NewStorageProcessor)
-	event := NewEvent("user123", ActionUploadFile)
+	event := NewEvent("user123", ActionUploadFile, WithPayload(FilePayload{
+		Bucket:   "uploads",
+		Key:      "user123/report.pdf",
+		Size:     1024,
+		Checksum: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}))
 	resultEvents, err := processor.Process(context.Background(), event)
 	if err != nil {
 		log.Default().Println("Error processing event:", err)
 		return
 	}
 	log.Default().Println("Successfully processed events:", resultEvents)
+	_ = metrics.WriteText(os.Stdout)
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome is how a stage finished with an event.
+type Outcome string
+
+const (
+	OutcomeOK       Outcome = "ok"
+	OutcomeError    Outcome = "error"
+	OutcomeCanceled Outcome = "canceled"
+)
+
+// MetricsSink receives one observation per event per stage. Implementations
+// decide what to aggregate; PrometheusSink keeps a counter and a latency
+// histogram for every stage, action and outcome.
+type MetricsSink interface {
+	ObserveStage(stage string, action Action, outcome Outcome, duration time.Duration)
+}
+
+// WithMetricsSink reports every stage of the pipeline, the final consumer
+// included, to sink. Named stages are labelled by name, the others by
+// position, the consumer being "1".
+func (p *Pipeline) WithMetricsSink(sink MetricsSink) *Pipeline {
+	p.metrics = sink
+	return p
+}
+
+func NewMetricsProcessor(stage string, sink MetricsSink, next Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+		if IsCtxDone(ctx) {
+			sink.ObserveStage(stage, event.Action, OutcomeCanceled, 0)
+			return nil, ctx.Err()
+		}
+
+		start := time.Now()
+		events, err := next.Process(ctx, event)
+		sink.ObserveStage(stage, event.Action, outcomeOf(err), time.Since(start))
+		return events, err
+	})
+}
+
+func outcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCanceled
+	default:
+		return OutcomeError
+	}
+}
+
+// PrometheusSink aggregates observations in memory and serves them in the
+// Prometheus text exposition format, so it can be mounted as a /metrics
+// handler and scraped as is.
+type PrometheusSink struct {
+	buckets []float64 // upper bounds in seconds, ascending
+
+	mu     sync.Mutex
+	series map[seriesKey]*stageSeries
+}
+
+type seriesKey struct {
+	stage   string
+	action  Action
+	outcome Outcome
+}
+
+type stageSeries struct {
+	buckets []uint64 // cumulative counts, one per upper bound
+	count   uint64
+	sum     float64 // seconds
+}
+
+type PrometheusOption func(*PrometheusSink)
+
+// WithBuckets replaces the latency histogram's upper bounds, in seconds.
+func WithBuckets(buckets ...float64) PrometheusOption {
+	return func(s *PrometheusSink) {
+		s.buckets = slices.Sorted(slices.Values(buckets))
+	}
+}
+
+func NewPrometheusSink(opts ...PrometheusOption) *PrometheusSink {
+	s := &PrometheusSink{
+		buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		series:  make(map[seriesKey]*stageSeries),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *PrometheusSink) ObserveStage(stage string, action Action, outcome Outcome, duration time.Duration) {
+	key := seriesKey{stage: stage, action: action, outcome: outcome}
+	seconds := duration.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series, ok := s.series[key]
+	if !ok {
+		series = &stageSeries{buckets: make([]uint64, len(s.buckets))}
+		s.series[key] = series
+	}
+	for i, le := range s.buckets {
+		if seconds <= le {
+			series.buckets[i]++
+		}
+	}
+	series.count++
+	series.sum += seconds
+}
+
+// WriteText writes every series in the Prometheus text exposition format.
+func (s *PrometheusSink) WriteText(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]seriesKey, 0, len(s.series))
+	for key := range s.series {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b seriesKey) int {
+		return strings.Compare(a.labels(), b.labels())
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP pipeline_events_total Events processed, by stage, action and outcome.\n")
+	b.WriteString("# TYPE pipeline_events_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "pipeline_events_total{%s} %d\n", key.labels(), s.series[key].count)
+	}
+	b.WriteString("# HELP pipeline_stage_duration_seconds Time spent in a stage and the stages after it.\n")
+	b.WriteString("# TYPE pipeline_stage_duration_seconds histogram\n")
+	for _, key := range keys {
+		series, labels := s.series[key], key.labels()
+		for i, le := range s.buckets {
+			fmt.Fprintf(&b, "pipeline_stage_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le, series.buckets[i])
+		}
+		fmt.Fprintf(&b, "pipeline_stage_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, series.count)
+		fmt.Fprintf(&b, "pipeline_stage_duration_seconds_sum{%s} %g\n", labels, series.sum)
+		fmt.Fprintf(&b, "pipeline_stage_duration_seconds_count{%s} %d\n", labels, series.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.WriteText(w)
+}
+
+func (k seriesKey) labels() string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `stage="` + escape.Replace(k.stage) +
+		`",action="` + escape.Replace(k.action.String()) +
+		`",outcome="` + escape.Replace(string(k.outcome)) + `"`
+}
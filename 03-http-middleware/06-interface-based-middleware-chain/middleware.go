@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"strconv"
 	"time"
+
+	"panic-safe-errgroup"
 )
 
 type Processor interface {
@@ -19,62 +23,87 @@ func (f ProcessorFunc) Process(ctx context.Context, event Event) ([]Event, error
 }
 
 type Pipeline struct {
-	builders      []ProcessBuilder
-	enableMetrics bool
+	stages     []stage
+	registry   *Registry
+	metrics    MetricsSink
+	deadLetter Processor
+	delivery   DeliveryPolicy
+	timeouts   map[string]time.Duration // by stage name
+	closers    []func()                 // stops the buffered stages, see Close
+	err        error                    // sticky configuration error, see Err
+}
+
+type stage struct {
+	name  string // metrics label; empty means the stage's position
+	build ProcessBuilder
+
+	// What Describe reports besides the name.
+	kind     string
+	details  []string
+	branches []string // actions a router sends out of the chain
 }
 
 func NewPipeline() *Pipeline {
 	return &Pipeline{
-		builders: []ProcessBuilder{},
+		stages: []stage{},
 	}
 }
 
-func (p *Pipeline) WithMetrics() *Pipeline {
-	p.enableMetrics = true
+func (p *Pipeline) Then(next ProcessBuilder, opts ...StageOption) *Pipeline {
+	cfg := &StageConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	p.stages = append(p.stages, stage{name: cfg.label, build: next, kind: "stage"})
 	return p
 }
 
-func (p *Pipeline) Then(next ProcessBuilder) *Pipeline {
-	p.builders = append(p.builders, next)
-	return p
-}
+// Build chains the stages in front of the consumers, which receive each
+// event according to the pipeline's DeliveryPolicy. If the pipeline was
+// configured wrongly, see Err, the processor it returns fails every event
+// with that error.
+func (p *Pipeline) Build(finals ...ConsumerBuilder) Processor {
+	if len(finals) == 0 {
+		p.err = errors.Join(p.err, ErrNoConsumer)
+	}
+	p.checkStageTimeouts()
+	if p.err != nil {
+		err := p.err
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			return nil, err
+		})
+	}
 
-func (p *Pipeline) Build(final ConsumerBuilder) Processor {
 	stageID := 0
-	processor := p.wrapWithMetrics(&stageID, final())
-	for i := len(p.builders) - 1; i >= 0; i-- {
-		processor = p.wrapWithMetrics(&stageID, p.builders[i](processor))
+	processor := p.wrapWithMetrics(&stageID, "", p.consumer(finals))
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		s := p.stages[i]
+		built := s.build(processor)
+		if timeout, ok := p.timeouts[s.name]; ok {
+			built = NewTimeoutProcessorBuilder(timeout)(built)
+		}
+		processor = p.wrapWithMetrics(&stageID, s.name, built)
+	}
+	if p.deadLetter != nil {
+		processor = NewDeadLetterProcessor(p.deadLetter, processor)
 	}
 	return processor
 }
 
-func (p *Pipeline) wrapWithMetrics(stageID *int, processor Processor) Processor {
+func (p *Pipeline) wrapWithMetrics(stageID *int, name string, processor Processor) Processor {
 	*(stageID) = *stageID + 1
-	if !p.enableMetrics {
+	if p.metrics == nil {
 		return processor
 	}
-	return NewMetricsProcessor(*stageID, processor)
+	if name == "" {
+		name = strconv.Itoa(*stageID)
+	}
+	return NewMetricsProcessor(name, p.metrics, processor)
 }
 
 type ProcessBuilder func(next Processor) Processor
 type ConsumerBuilder func() Processor
 
-func NewMetricsProcessor(stageID int, next Processor) Processor {
-	return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
-		if IsCtxDone(ctx) {
-			log.Default().Println("[Metrics] Context done before processing event:", event.String())
-			return nil, ctx.Err()
-		}
-
-		start := time.Now()
-		events, err := next.Process(ctx, event)
-		duration := time.Since(start)
-
-		log.Default().Printf("[%d] Processed event in %v\n", stageID, duration)
-		return events, err
-	})
-}
-
 func NewValidatorProcessorBuilder() ProcessBuilder {
 	return func(next Processor) Processor {
 		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
@@ -127,7 +156,8 @@ func NewLoggerProcessorBuilder() ProcessBuilder {
 }
 
 type SplitterConfig struct {
-	splitRules map[Action][]Action
+	splitRules  map[Action][]Action
+	concurrency int
 }
 
 type SplitterOption func(*SplitterConfig)
@@ -138,6 +168,16 @@ func WithSplitRule(action Action, splits []Action) SplitterOption {
 	}
 }
 
+// WithConcurrency processes up to n split events at once instead of one
+// after another, so a split costs the latency of its slowest event rather
+// than the sum. next must then be safe for concurrent use. Results keep the
+// order of the split rule and every failure is still returned.
+func WithConcurrency(n int) SplitterOption {
+	return func(cfg *SplitterConfig) {
+		cfg.concurrency = n
+	}
+}
+
 func NewEventSplitterProcessorBuilder(opts ...SplitterOption) ProcessBuilder {
 	return func(next Processor) Processor {
 		cfg := &SplitterConfig{
@@ -156,25 +196,39 @@ func NewEventSplitterProcessorBuilder(opts ...SplitterOption) ProcessBuilder {
 			events := make([]Event, 0)
 			if splitActions, ok := cfg.splitRules[event.Action]; ok {
 				for _, action := range splitActions {
-					events = append(events, NewEvent(event.UserID, action))
+					events = append(events, event.withAction(action))
 				}
 			} else {
 				events = append(events, event)
 			}
 
-			var resultEvents []Event
-			var resultErrors []error
-
-			for _, evt := range events {
-				processedEvents, err := next.Process(ctx, evt)
-				if err != nil {
-					resultErrors = append(resultErrors, err)
-				} else {
-					resultEvents = append(resultEvents, processedEvents...)
+			processed := make([][]Event, len(events))
+			resultErrors := make([]error, len(events))
+
+			var panicErr error
+			if cfg.concurrency > 1 {
+				g, _ := group.New(ctx, group.WithLimit(cfg.concurrency))
+				for i, evt := range events {
+					g.Go(func(ctx context.Context) error {
+						processed[i], resultErrors[i] = next.Process(ctx, evt)
+						return nil
+					})
+				}
+				// Only a panicking stage fails the group.
+				panicErr = g.Wait()
+			} else {
+				for i, evt := range events {
+					processed[i], resultErrors[i] = next.Process(ctx, evt)
 				}
 			}
 
-			return resultEvents, errors.Join(resultErrors...)
+			var resultEvents []Event
+			for i, err := range resultErrors {
+				if err == nil {
+					resultEvents = append(resultEvents, processed[i]...)
+				}
+			}
+			return resultEvents, errors.Join(append(resultErrors, panicErr)...)
 		})
 	}
 }
@@ -191,15 +245,60 @@ func NewStorageProcessor() Processor {
 }
 
 type Event struct {
-	UserID string
-	Action Action
+	ID       string // unique per event at its source; see NewDedupProcessorBuilder
+	UserID   string
+	Action   Action
+	Version  int               // schema version; 0 predates versioning and means 1
+	Payload  any               // action-specific data, e.g. FilePayload; see WithSchema
+	Metadata map[string]string // free-form attributes such as trace or request IDs
+}
+
+type EventOption func(*Event)
+
+func WithPayload(payload any) EventOption {
+	return func(e *Event) {
+		e.Payload = payload
+	}
 }
 
-func NewEvent(userID string, action Action) Event {
-	return Event{
+func WithID(id string) EventOption {
+	return func(e *Event) {
+		e.ID = id
+	}
+}
+
+func WithVersion(version int) EventOption {
+	return func(e *Event) {
+		e.Version = version
+	}
+}
+
+func WithMetadata(key, value string) EventOption {
+	return func(e *Event) {
+		if e.Metadata == nil {
+			e.Metadata = make(map[string]string)
+		}
+		e.Metadata[key] = value
+	}
+}
+
+func NewEvent(userID string, action Action, opts ...EventOption) Event {
+	event := Event{
 		UserID: userID,
 		Action: action,
 	}
+	for _, opt := range opts {
+		opt(&event)
+	}
+	return event
+}
+
+// withAction derives an event for action that carries e's payload and its
+// own copy of e's metadata.
+func (e Event) withAction(action Action) Event {
+	e.Action = action
+	e.Metadata = maps.Clone(e.Metadata)
+	return e
 }
 
 func (e Event) String() string {
@@ -227,8 +326,19 @@ func (action Action) String() string {
 	}
 }
 
+// ParseAction is the inverse of Action.String.
+func ParseAction(s string) (Action, error) {
+	for action := ActionUploadFile; action <= ActionUploadMetadata; action++ {
+		if action.String() == s {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown action %q", s)
+}
+
 var (
-	ErrInvalidEvent = errors.New("invalid event")
+	ErrInvalidEvent   = errors.New("invalid event")
+	ErrInvalidPayload = errors.New("invalid payload")
 )
 
 func IsCtxDone(ctx context.Context) bool {
@@ -1,10 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"counting-bloom-filter"
+	"panic-safe-errgroup"
 )
 
 // Mock processor for testing
@@ -370,6 +382,21 @@ func TestPipelineComposition(t *testing.T) {
 	})
 }
 
+// recordingSink keeps every observation for inspection.
+type recordingSink struct {
+	observations []observation
+}
+
+type observation struct {
+	stage   string
+	action  Action
+	outcome Outcome
+}
+
+func (s *recordingSink) ObserveStage(stage string, action Action, outcome Outcome, duration time.Duration) {
+	s.observations = append(s.observations, observation{stage, action, outcome})
+}
+
 // Test Metrics Processor
 func TestMetricsProcessor(t *testing.T) {
 	t.Run("wraps processor with metrics", func(t *testing.T) {
@@ -378,7 +405,8 @@ func TestMetricsProcessor(t *testing.T) {
 			return []Event{event}, nil
 		})
 
-		metrics := NewMetricsProcessor(1, mockNext)
+		sink := &recordingSink{}
+		metrics := NewMetricsProcessor("1", sink, mockNext)
 		event := NewEvent("user123", ActionUploadFile)
 
 		result, err := metrics.Process(context.Background(), event)
@@ -392,11 +420,33 @@ func TestMetricsProcessor(t *testing.T) {
 		if mockNext.callCount != 1 {
 			t.Errorf("expected next processor called once, got %d", mockNext.callCount)
 		}
+		want := []observation{{"1", ActionUploadFile, OutcomeOK}}
+		if !slices.Equal(sink.observations, want) {
+			t.Errorf("expected %v, got %v", want, sink.observations)
+		}
+	})
+
+	t.Run("records errors as their outcome", func(t *testing.T) {
+		mockNext := newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			return nil, ErrInvalidEvent
+		})
+		sink := &recordingSink{}
+		metrics := NewMetricsProcessor("1", sink, mockNext)
+
+		_, err := metrics.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("expected ErrInvalidEvent, got %v", err)
+		}
+		if len(sink.observations) != 1 || sink.observations[0].outcome != OutcomeError {
+			t.Errorf("expected one error observation, got %v", sink.observations)
+		}
 	})
 
 	t.Run("metrics processor respects context", func(t *testing.T) {
 		mockNext := newMockProcessor(nil)
-		metrics := NewMetricsProcessor(1, mockNext)
+		sink := &recordingSink{}
+		metrics := NewMetricsProcessor("1", sink, mockNext)
 
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
@@ -410,14 +460,18 @@ func TestMetricsProcessor(t *testing.T) {
 		if result != nil {
 			t.Errorf("expected nil result, got %v", result)
 		}
+		if len(sink.observations) != 1 || sink.observations[0].outcome != OutcomeCanceled {
+			t.Errorf("expected one canceled observation, got %v", sink.observations)
+		}
 	})
 }
 
 // Test Pipeline with Metrics
 func TestPipelineWithMetrics(t *testing.T) {
 	t.Run("pipeline with metrics enabled", func(t *testing.T) {
+		sink := &recordingSink{}
 		pipeline := NewPipeline().
-			WithMetrics().
+			WithMetricsSink(sink).
 			Then(NewValidatorProcessorBuilder()).
 			Build(NewStorageProcessor)
 
@@ -430,9 +484,48 @@ func TestPipelineWithMetrics(t *testing.T) {
 		if len(result) != 1 {
 			t.Fatalf("expected 1 event, got %d", len(result))
 		}
+		want := []observation{
+			{"1", ActionUploadFile, OutcomeOK},
+			{"2", ActionUploadFile, OutcomeOK},
+		}
+		if !slices.Equal(sink.observations, want) {
+			t.Errorf("expected %v, got %v", want, sink.observations)
+		}
 	})
 }
 
+// Test Prometheus Sink
+func TestPrometheusSink(t *testing.T) {
+	sink := NewPrometheusSink(WithBuckets(1, 0.1))
+	sink.ObserveStage("1", ActionUploadFile, OutcomeOK, 50*time.Millisecond)
+	sink.ObserveStage("1", ActionUploadFile, OutcomeOK, 500*time.Millisecond)
+	sink.ObserveStage(`odd "stage"`, ActionUploadMetadata, OutcomeError, 2*time.Second)
+
+	rec := httptest.NewRecorder()
+	sink.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE pipeline_events_total counter",
+		`pipeline_events_total{stage="1",action="UploadFile",outcome="ok"} 2`,
+		`pipeline_events_total{stage="odd \"stage\"",action="UploadMetadata",outcome="error"} 1`,
+		"# TYPE pipeline_stage_duration_seconds histogram",
+		`pipeline_stage_duration_seconds_bucket{stage="1",action="UploadFile",outcome="ok",le="0.1"} 1`,
+		`pipeline_stage_duration_seconds_bucket{stage="1",action="UploadFile",outcome="ok",le="1"} 2`,
+		`pipeline_stage_duration_seconds_bucket{stage="1",action="UploadFile",outcome="ok",le="+Inf"} 2`,
+		`pipeline_stage_duration_seconds_sum{stage="1",action="UploadFile",outcome="ok"} 0.55`,
+		`pipeline_stage_duration_seconds_count{stage="1",action="UploadFile",outcome="ok"} 2`,
+		`pipeline_stage_duration_seconds_bucket{stage="odd \"stage\"",action="UploadMetadata",outcome="error",le="1"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, body)
+		}
+	}
+}
+
 // Test Interface Pollution (Test Yourself #3)
 func TestInterfacePollution(t *testing.T) {
 	t.Run("add database middleware without modifying Processor interface", func(t *testing.T) {
@@ -525,3 +618,1380 @@ func TestZeroGlobalState(t *testing.T) {
 		t.Log("✅ No global state - each instance maintains its own configuration")
 	})
 }
+
+// Test Dead-Letter Queue
+func TestPipelineWithDeadLetter(t *testing.T) {
+	storageErr := errors.New("storage down")
+	failingStorage := func() Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if event.Action == ActionUploadToStorage {
+				return nil, storageErr
+			}
+			return []Event{event}, nil
+		})
+	}
+
+	t.Run("failed event is routed with its reason", func(t *testing.T) {
+		var reasons []error
+		dlq := newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			reasons = append(reasons, DeadLetterReason(ctx))
+			return nil, nil
+		})
+		pipeline := NewPipeline().
+			WithDeadLetter(dlq).
+			Then(NewEventSplitterProcessorBuilder(
+				WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata}),
+			)).
+			Build(failingStorage)
+
+		event := NewEvent("user123", ActionUploadFile)
+		result, err := pipeline.Process(context.Background(), event)
+
+		if err != nil {
+			t.Fatalf("expected no error once dead-lettered, got %v", err)
+		}
+		if len(result) != 1 || result[0].Action != ActionUploadMetadata {
+			t.Errorf("expected the successful split event, got %v", result)
+		}
+		if dlq.callCount != 1 || !errors.Is(reasons[0], storageErr) {
+			t.Errorf("expected one dead letter caused by storage error, got %d calls, reasons %v", dlq.callCount, reasons)
+		}
+	})
+
+	t.Run("dead-letter failure returns both errors", func(t *testing.T) {
+		dlqErr := errors.New("dlq full")
+		dlq := newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			return nil, dlqErr
+		})
+		pipeline := NewPipeline().WithDeadLetter(dlq).Build(failingStorage)
+
+		_, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadToStorage))
+
+		if !errors.Is(err, storageErr) || !errors.Is(err, dlqErr) {
+			t.Errorf("expected both errors, got %v", err)
+		}
+	})
+
+	t.Run("cancelled events are not dead-lettered", func(t *testing.T) {
+		dlq := newMockProcessor(nil)
+		pipeline := NewPipeline().WithDeadLetter(dlq).Build(NewStorageProcessor)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := pipeline.Process(ctx, NewEvent("user123", ActionUploadFile))
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if dlq.callCount != 0 {
+			t.Errorf("expected dlq not called, got %d calls", dlq.callCount)
+		}
+	})
+}
+
+// Test Router Processor
+func TestRouterProcessor(t *testing.T) {
+	storage := newMockProcessor(nil)
+	metadata := newMockProcessor(nil)
+	routes := map[Action]Processor{
+		ActionUploadToStorage: storage,
+		ActionUploadMetadata:  metadata,
+	}
+
+	t.Run("routes split events to their sub-pipelines", func(t *testing.T) {
+		storage.callCount, metadata.callCount = 0, 0
+		mockNext := newMockProcessor(nil)
+		pipeline := NewEventSplitterProcessorBuilder(
+			WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata}),
+		)(NewRouterProcessorBuilder(routes, nil)(mockNext))
+
+		result, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(result) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(result))
+		}
+		if storage.callCount != 1 || metadata.callCount != 1 {
+			t.Errorf("expected each route called once, got storage=%d metadata=%d", storage.callCount, metadata.callCount)
+		}
+		if mockNext.callCount != 0 {
+			t.Errorf("expected next not called for routed events, got %d calls", mockNext.callCount)
+		}
+	})
+
+	t.Run("unrouted events go to fallback", func(t *testing.T) {
+		fallback := newMockProcessor(nil)
+		mockNext := newMockProcessor(nil)
+		router := NewRouterProcessorBuilder(routes, fallback)(mockNext)
+
+		if _, err := router.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if fallback.callCount != 1 || mockNext.callCount != 0 {
+			t.Errorf("expected fallback only, got fallback=%d next=%d", fallback.callCount, mockNext.callCount)
+		}
+	})
+
+	t.Run("unrouted events continue down the chain without fallback", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+		router := NewRouterProcessorBuilder(routes, nil)(mockNext)
+
+		if _, err := router.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if mockNext.callCount != 1 {
+			t.Errorf("expected next called once, got %d", mockNext.callCount)
+		}
+	})
+
+	t.Run("respects context cancellation", func(t *testing.T) {
+		storage.callCount = 0
+		router := NewRouterProcessorBuilder(routes, nil)(newMockProcessor(nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := router.Process(ctx, NewEvent("user123", ActionUploadToStorage))
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if storage.callCount != 0 {
+			t.Errorf("expected route not called, got %d calls", storage.callCount)
+		}
+	})
+}
+
+// Test Concurrent Splitting
+func TestEventSplitterConcurrency(t *testing.T) {
+	splitAll := WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata, ActionUploadToStorage})
+
+	t.Run("processes split events in parallel up to the bound", func(t *testing.T) {
+		var running, peak atomic.Int32
+		slowNext := ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			return []Event{event}, nil
+		})
+		splitter := NewEventSplitterProcessorBuilder(splitAll, WithConcurrency(2))(slowNext)
+
+		result, err := splitter.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []Action{ActionUploadToStorage, ActionUploadMetadata, ActionUploadToStorage}
+		if len(result) != len(want) {
+			t.Fatalf("expected %d events, got %d", len(want), len(result))
+		}
+		for i, action := range want {
+			if result[i].Action != action {
+				t.Errorf("event %d: expected %v, got %v", i, action, result[i].Action)
+			}
+		}
+		if got := peak.Load(); got != 2 {
+			t.Errorf("expected 2 events in flight at most and at best, got %d", got)
+		}
+	})
+
+	t.Run("joins errors from parallel events", func(t *testing.T) {
+		storageErr := errors.New("storage error")
+		failingNext := ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if event.Action == ActionUploadToStorage {
+				return nil, storageErr
+			}
+			return []Event{event}, nil
+		})
+		splitter := NewEventSplitterProcessorBuilder(splitAll, WithConcurrency(3))(failingNext)
+
+		result, err := splitter.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if !errors.Is(err, storageErr) {
+			t.Fatalf("expected storage error, got %v", err)
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+			t.Errorf("expected both failures joined, got %v", err)
+		}
+		if len(result) != 1 || result[0].Action != ActionUploadMetadata {
+			t.Errorf("expected the successful event, got %v", result)
+		}
+	})
+
+	t.Run("returns a panicking event as an error", func(t *testing.T) {
+		panickingNext := ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if event.Action == ActionUploadMetadata {
+				panic("metadata store gone")
+			}
+			return []Event{event}, nil
+		})
+		splitter := NewEventSplitterProcessorBuilder(splitAll, WithConcurrency(3))(panickingNext)
+
+		_, err := splitter.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		var panicErr *group.PanicError
+		if !errors.As(err, &panicErr) || panicErr.Value != "metadata store gone" {
+			t.Errorf("expected the panic as an error, got %v", err)
+		}
+	})
+}
+
+// Test Payloads and Schema Validation
+func TestSchemaValidatorProcessor(t *testing.T) {
+	validFile := FilePayload{
+		Bucket:   "uploads",
+		Key:      "user123/report.pdf",
+		Size:     1024,
+		Checksum: strings.Repeat("ab", 32),
+	}
+	newValidator := func(next Processor) Processor {
+		return NewSchemaValidatorProcessorBuilder(
+			WithSchema(ActionUploadFile, PayloadSchema(FilePayload.Validate)),
+		)(next)
+	}
+
+	t.Run("valid payload passes through", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+		event := NewEvent("user123", ActionUploadFile, WithPayload(validFile))
+
+		result, err := newValidator(mockNext).Process(context.Background(), event)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if payload, ok := PayloadOf[FilePayload](result[0]); !ok || payload != validFile {
+			t.Errorf("expected payload to survive, got %v", result[0].Payload)
+		}
+	})
+
+	t.Run("invalid payload rejected", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+		invalid := validFile
+		invalid.Size = 0
+		event := NewEvent("user123", ActionUploadFile, WithPayload(invalid))
+
+		_, err := newValidator(mockNext).Process(context.Background(), event)
+
+		if !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), "size") {
+			t.Fatalf("expected ErrInvalidPayload about size, got %v", err)
+		}
+		if mockNext.callCount != 0 {
+			t.Errorf("expected next processor not called, got %d calls", mockNext.callCount)
+		}
+	})
+
+	t.Run("payload of the wrong type rejected", func(t *testing.T) {
+		event := NewEvent("user123", ActionUploadFile, WithPayload("report.pdf"))
+
+		_, err := newValidator(newMockProcessor(nil)).Process(context.Background(), event)
+
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Fatalf("expected ErrInvalidPayload, got %v", err)
+		}
+	})
+
+	t.Run("actions without a schema pass unchecked", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+
+		_, err := newValidator(mockNext).Process(context.Background(), NewEvent("user123", ActionUploadMetadata))
+
+		if err != nil || mockNext.callCount != 1 {
+			t.Fatalf("expected event passed on, got err=%v calls=%d", err, mockNext.callCount)
+		}
+	})
+
+	t.Run("split events inherit payload and their own metadata", func(t *testing.T) {
+		var seen []Event
+		mockNext := newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			event.Metadata["stage"] = event.Action.String()
+			seen = append(seen, event)
+			return []Event{event}, nil
+		})
+		splitter := NewEventSplitterProcessorBuilder(
+			WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata}),
+		)(mockNext)
+		event := NewEvent("user123", ActionUploadFile, WithPayload(validFile), WithMetadata("trace_id", "t-1"))
+
+		if _, err := splitter.Process(context.Background(), event); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, evt := range seen {
+			if evt.Payload != validFile || evt.Metadata["trace_id"] != "t-1" {
+				t.Errorf("expected payload and metadata copied, got %v %v", evt.Payload, evt.Metadata)
+			}
+			if evt.Metadata["stage"] != evt.Action.String() {
+				t.Errorf("expected metadata not shared between split events, got %v", evt.Metadata)
+			}
+		}
+		if _, ok := event.Metadata["stage"]; ok {
+			t.Errorf("expected original metadata untouched, got %v", event.Metadata)
+		}
+	})
+}
+
+// Test Registry
+func TestRegistry(t *testing.T) {
+	newRegistry := func(t *testing.T) *Registry {
+		t.Helper()
+		r := NewRegistry()
+		if err := r.RegisterBuilder("validator", NewValidatorProcessorBuilder()); err != nil {
+			t.Fatalf("register validator: %v", err)
+		}
+		if err := r.RegisterBuilder("logger", NewLoggerProcessorBuilder()); err != nil {
+			t.Fatalf("register logger: %v", err)
+		}
+		return r
+	}
+
+	t.Run("duplicate names are rejected", func(t *testing.T) {
+		r := newRegistry(t)
+		err := r.RegisterBuilder("validator", NewLoggerProcessorBuilder())
+		if !errors.Is(err, ErrDuplicateStage) {
+			t.Fatalf("expected ErrDuplicateStage, got %v", err)
+		}
+		if got := r.Names(); !slices.Equal(got, []string{"logger", "validator"}) {
+			t.Errorf("unexpected names %v", got)
+		}
+	})
+
+	t.Run("named stages build and label metrics", func(t *testing.T) {
+		sink := &recordingSink{}
+		pipeline := NewPipeline().
+			WithRegistry(newRegistry(t)).
+			WithMetricsSink(sink).
+			ThenNamed("validator").
+			ThenNamed("logger", WithStageLabel("audit")).
+			Build(NewStorageProcessor)
+
+		if _, err := pipeline.Process(context.Background(), NewEvent("", ActionUploadFile)); !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("expected the registered validator to run, got %v", err)
+		}
+		if _, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []observation{
+			{"validator", ActionUploadFile, OutcomeError},
+			{"1", ActionUploadFile, OutcomeOK},
+			{"audit", ActionUploadFile, OutcomeOK},
+			{"validator", ActionUploadFile, OutcomeOK},
+		}
+		if !slices.Equal(sink.observations, want) {
+			t.Errorf("expected %v, got %v", want, sink.observations)
+		}
+	})
+
+	t.Run("unknown stage fails the pipeline", func(t *testing.T) {
+		p := NewPipeline().WithRegistry(newRegistry(t)).ThenNamed("enricher")
+		if !errors.Is(p.Err(), ErrUnknownStage) {
+			t.Fatalf("expected ErrUnknownStage, got %v", p.Err())
+		}
+
+		storage := newMockProcessor(nil)
+		_, err := p.Build(func() Processor { return storage }).Process(context.Background(), NewEvent("user123", ActionUploadFile))
+		if !errors.Is(err, ErrUnknownStage) {
+			t.Fatalf("expected ErrUnknownStage, got %v", err)
+		}
+		if storage.callCount != 0 {
+			t.Errorf("expected consumer not called, got %d calls", storage.callCount)
+		}
+	})
+}
+
+// Test Fan-Out Delivery
+func TestPipelineFanOut(t *testing.T) {
+	indexErr := errors.New("indexer down")
+	newConsumers := func() (storage, indexer, analytics *mockProcessor) {
+		storage = newMockProcessor(nil)
+		indexer = newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			return nil, indexErr
+		})
+		analytics = newMockProcessor(nil)
+		return storage, indexer, analytics
+	}
+	consumer := func(p Processor) ConsumerBuilder {
+		return func() Processor { return p }
+	}
+	event := NewEvent("user123", ActionUploadToStorage)
+
+	t.Run("all must succeed", func(t *testing.T) {
+		storage, indexer, analytics := newConsumers()
+		pipeline := NewPipeline().Build(consumer(storage), consumer(indexer), consumer(analytics))
+
+		result, err := pipeline.Process(context.Background(), event)
+
+		if !errors.Is(err, indexErr) {
+			t.Fatalf("expected indexer error, got %v", err)
+		}
+		if len(result) != 2 || storage.callCount != 1 || analytics.callCount != 1 {
+			t.Errorf("expected every consumer tried, got %d events, storage=%d analytics=%d", len(result), storage.callCount, analytics.callCount)
+		}
+	})
+
+	t.Run("best effort tolerates partial failure", func(t *testing.T) {
+		storage, indexer, analytics := newConsumers()
+		pipeline := NewPipeline().
+			WithDeliveryPolicy(DeliverBestEffort).
+			Build(consumer(storage), consumer(indexer), consumer(analytics))
+
+		result, err := pipeline.Process(context.Background(), event)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(result) != 2 {
+			t.Errorf("expected 2 events, got %d", len(result))
+		}
+
+		_, err = NewPipeline().
+			WithDeliveryPolicy(DeliverBestEffort).
+			Build(consumer(indexer), consumer(indexer)).
+			Process(context.Background(), event)
+		if !errors.Is(err, indexErr) {
+			t.Errorf("expected error once every consumer failed, got %v", err)
+		}
+	})
+
+	t.Run("first success stops at the first consumer that succeeds", func(t *testing.T) {
+		storage, indexer, analytics := newConsumers()
+		pipeline := NewPipeline().
+			WithDeliveryPolicy(DeliverFirstSuccess).
+			Build(consumer(indexer), consumer(storage), consumer(analytics))
+
+		result, err := pipeline.Process(context.Background(), event)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(result) != 1 || indexer.callCount != 1 || storage.callCount != 1 || analytics.callCount != 0 {
+			t.Errorf("expected fallback to storage only, got %d events, indexer=%d storage=%d analytics=%d",
+				len(result), indexer.callCount, storage.callCount, analytics.callCount)
+		}
+	})
+
+	t.Run("no consumer is a configuration error", func(t *testing.T) {
+		p := NewPipeline()
+		_, err := p.Build().Process(context.Background(), event)
+		if !errors.Is(err, ErrNoConsumer) || !errors.Is(p.Err(), ErrNoConsumer) {
+			t.Fatalf("expected ErrNoConsumer, got %v", err)
+		}
+	})
+}
+
+// Test Runner
+func TestRunner(t *testing.T) {
+	publish := func(t *testing.T, source *MemorySource, bodies ...string) []*MemoryMessage {
+		t.Helper()
+		msgs := make([]*MemoryMessage, len(bodies))
+		for i, body := range bodies {
+			msg, err := source.Publish(context.Background(), []byte(body))
+			if err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			msgs[i] = msg
+		}
+		return msgs
+	}
+
+	t.Run("acks processed messages and nacks failures", func(t *testing.T) {
+		source := NewMemorySource(3)
+		msgs := publish(t, source,
+			`{"user_id": "user123", "action": "UploadFile", "metadata": {"trace_id": "t-1"}}`,
+			`{"user_id": "", "action": "UploadFile"}`,
+			`{"user_id": "user123", "action": "Delete"}`,
+		)
+		source.Close()
+
+		var stored []Event
+		pipeline := NewPipeline().
+			Then(NewValidatorProcessorBuilder()).
+			Build(func() Processor {
+				return newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+					stored = append(stored, event)
+					return []Event{event}, nil
+				})
+			})
+
+		if err := NewRunner(source, pipeline, DecodeJSONEvent).Run(context.Background()); err != nil {
+			t.Fatalf("expected drained source, got %v", err)
+		}
+
+		if settled, acked, _ := msgs[0].Settled(); !settled || !acked {
+			t.Errorf("expected first message acked")
+		}
+		if len(stored) != 1 || stored[0].Metadata["trace_id"] != "t-1" {
+			t.Errorf("expected decoded event stored, got %v", stored)
+		}
+		for _, msg := range msgs[1:] {
+			if settled, acked, reason := msg.Settled(); !settled || acked || !errors.Is(reason, ErrInvalidEvent) {
+				t.Errorf("expected %s nacked as invalid, got settled=%v acked=%v reason=%v", msg.Data(), settled, acked, reason)
+			}
+		}
+	})
+
+	t.Run("bounds concurrency", func(t *testing.T) {
+		source := NewMemorySource(6)
+		msgs := publish(t, source, slices.Repeat([]string{`{"user_id": "u", "action": "UploadFile"}`}, 6)...)
+		source.Close()
+
+		var running, peak atomic.Int32
+		pipeline := ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			return []Event{event}, nil
+		})
+
+		if err := NewRunner(source, pipeline, DecodeJSONEvent, WithRunnerConcurrency(3)).Run(context.Background()); err != nil {
+			t.Fatalf("expected drained source, got %v", err)
+		}
+		if got := peak.Load(); got != 3 {
+			t.Errorf("expected 3 messages in flight at most and at best, got %d", got)
+		}
+		for _, msg := range msgs {
+			if _, acked, _ := msg.Settled(); !acked {
+				t.Errorf("expected every message acked")
+			}
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		source := NewMemorySource(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- NewRunner(source, NewStorageProcessor(), DecodeJSONEvent).Run(ctx) }()
+
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("runner did not stop")
+		}
+	})
+}
+
+// Test HTTP Handler
+func TestPipelineHandler(t *testing.T) {
+	pipeline := NewPipeline().
+		Then(NewTimeoutProcessorBuilder(50 * time.Millisecond)).
+		Then(NewValidatorProcessorBuilder()).
+		Build(func() Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				if event.Metadata["slow"] != "" {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return []Event{event}, nil
+			})
+		})
+	handler := NewPipelineHandler(pipeline, nil, nil)
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"processed", `{"user_id": "user123", "action": "UploadFile"}`, http.StatusOK, `{"events":[{"user_id":"user123","action":"UploadFile"}]}`},
+		{"invalid event", `{"user_id": "", "action": "UploadFile"}`, http.StatusBadRequest, `{"error":"invalid event"}`},
+		{"undecodable body", `{"user_id": `, http.StatusBadRequest, ""},
+		{"timeout", `{"user_id": "user123", "action": "UploadFile", "metadata": {"slow": "yes"}}`, http.StatusGatewayTimeout, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+			if tc.want != "" && strings.TrimSpace(rec.Body.String()) != tc.want {
+				t.Errorf("expected body %s, got %s", tc.want, rec.Body)
+			}
+		})
+	}
+
+	t.Run("custom codecs", func(t *testing.T) {
+		var gotErr error
+		handler := NewPipelineHandler(pipeline,
+			func(r *http.Request) (Event, error) {
+				return NewEvent(r.URL.Query().Get("user"), ActionUploadMetadata), nil
+			},
+			func(w http.ResponseWriter, events []Event, err error) {
+				gotErr = err
+				w.WriteHeader(http.StatusAccepted)
+			})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events?user=user123", nil))
+
+		if rec.Code != http.StatusAccepted || gotErr != nil {
+			t.Errorf("expected custom encoder to run cleanly, got %d, %v", rec.Code, gotErr)
+		}
+	})
+}
+
+// Test Async Pipeline
+func TestAsyncPipeline(t *testing.T) {
+	t.Run("submit returns before processing finishes", func(t *testing.T) {
+		pool := NewWorkerPool(1, 1)
+		defer pool.Close()
+		release := make(chan struct{})
+		async := NewPipeline().
+			Then(NewValidatorProcessorBuilder()).
+			BuildAsync(pool, func() Processor {
+				return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+					<-release
+					return []Event{event}, nil
+				})
+			})
+
+		future := async.Submit(context.Background(), NewEvent("user123", ActionUploadFile))
+		select {
+		case <-future.Done():
+			t.Fatal("expected future pending while the consumer blocks")
+		default:
+		}
+
+		close(release)
+		result, err := future.Wait(context.Background())
+		if err != nil || len(result) != 1 {
+			t.Fatalf("expected 1 event, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("pipeline errors resolve the future", func(t *testing.T) {
+		pool := NewWorkerPool(2, 4)
+		defer pool.Close()
+		async := NewPipeline().Then(NewValidatorProcessorBuilder()).BuildAsync(pool, NewStorageProcessor)
+
+		_, err := async.Submit(context.Background(), NewEvent("", ActionUploadFile)).Wait(context.Background())
+		if !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("expected ErrInvalidEvent, got %v", err)
+		}
+	})
+
+	t.Run("full queue rejects instead of blocking", func(t *testing.T) {
+		pool := NewWorkerPool(1, 0)
+		release := make(chan struct{})
+		async := NewPipeline().BuildAsync(pool, func() Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				<-release
+				return []Event{event}, nil
+			})
+		})
+
+		rejected := func(f *Future[[]Event]) bool {
+			select {
+			case <-f.Done():
+				return errors.Is(f.err, ErrPoolFull)
+			default:
+				return false
+			}
+		}
+		first := async.Submit(context.Background(), NewEvent("user123", ActionUploadFile))
+		for rejected(first) {
+			// The worker may not be waiting for work yet.
+			first = async.Submit(context.Background(), NewEvent("user123", ActionUploadFile))
+		}
+		_, err := async.Submit(context.Background(), NewEvent("user123", ActionUploadFile)).Wait(context.Background())
+		if !errors.Is(err, ErrPoolFull) {
+			t.Fatalf("expected ErrPoolFull, got %v", err)
+		}
+
+		close(release)
+		pool.Close()
+		if _, err := first.Wait(context.Background()); err != nil {
+			t.Fatalf("expected queued event processed before close, got %v", err)
+		}
+		if _, err := async.Submit(context.Background(), NewEvent("user123", ActionUploadFile)).Wait(context.Background()); !errors.Is(err, ErrPoolClosed) {
+			t.Fatalf("expected ErrPoolClosed, got %v", err)
+		}
+	})
+}
+
+// Test Transform Processor
+func TestTransformProcessor(t *testing.T) {
+	// legacyUpload is the payload format before buckets were introduced.
+	type legacyUpload struct {
+		Path     string
+		Size     int64
+		Checksum string
+	}
+	migrate := MapPayload(func(old legacyUpload) (FilePayload, error) {
+		if old.Path == "" {
+			return FilePayload{}, ErrInvalidPayload
+		}
+		return FilePayload{Bucket: "legacy", Key: old.Path, Size: old.Size, Checksum: old.Checksum}, nil
+	})
+
+	t.Run("migrates old payloads", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+		transform := NewTransformProcessorBuilder(migrate)(mockNext)
+		event := NewEvent("user123", ActionUploadFile, WithPayload(legacyUpload{Path: "a.pdf", Size: 3, Checksum: "c"}))
+
+		result, err := transform.Process(context.Background(), event)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := FilePayload{Bucket: "legacy", Key: "a.pdf", Size: 3, Checksum: "c"}
+		if payload, ok := PayloadOf[FilePayload](result[0]); !ok || payload != want {
+			t.Errorf("expected %v, got %v", want, result[0].Payload)
+		}
+	})
+
+	t.Run("other payloads pass unchanged", func(t *testing.T) {
+		current := FilePayload{Bucket: "uploads", Key: "a.pdf"}
+		transform := NewTransformProcessorBuilder(migrate)(newMockProcessor(nil))
+
+		result, err := transform.Process(context.Background(), NewEvent("user123", ActionUploadFile, WithPayload(current)))
+
+		if err != nil || result[0].Payload != current {
+			t.Errorf("expected payload untouched, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("renames actions and stops on error", func(t *testing.T) {
+		mockNext := newMockProcessor(nil)
+		rename := NewTransformProcessorBuilder(func(ctx context.Context, event Event) (Event, error) {
+			if event.Action == ActionUploadFile {
+				event.Action = ActionUploadToStorage
+			}
+			return migrate(ctx, event)
+		})(mockNext)
+
+		result, err := rename.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+		if err != nil || result[0].Action != ActionUploadToStorage {
+			t.Fatalf("expected renamed action, got %v, %v", result, err)
+		}
+
+		_, err = rename.Process(context.Background(), NewEvent("user123", ActionUploadFile, WithPayload(legacyUpload{})))
+		if !errors.Is(err, ErrInvalidPayload) || mockNext.callCount != 1 {
+			t.Errorf("expected failed migration to stop the event, got %v after %d calls", err, mockNext.callCount)
+		}
+	})
+}
+
+// Test Sampling Processor
+func TestSamplingProcessor(t *testing.T) {
+	countingBuilder := func(count *int) ProcessBuilder {
+		return func(next Processor) Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				*count++
+				return next.Process(ctx, event)
+			})
+		}
+	}
+
+	t.Run("applies inner to the sampled fraction", func(t *testing.T) {
+		var sampled int
+		draws := []float64{0.05, 0.5, 0.09, 0.95, 0.1}
+		mockNext := newMockProcessor(nil)
+		sampling := NewSamplingProcessorBuilder(0.1, countingBuilder(&sampled), WithRandomSource(func() float64 {
+			draw := draws[0]
+			draws = draws[1:]
+			return draw
+		}))(mockNext)
+
+		for range 5 {
+			if _, err := sampling.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if sampled != 2 {
+			t.Errorf("expected 2 sampled events, got %d", sampled)
+		}
+		if mockNext.callCount != 5 {
+			t.Errorf("expected every event to reach next, got %d", mockNext.callCount)
+		}
+	})
+
+	t.Run("rate bounds", func(t *testing.T) {
+		var never, always int
+		pipeline := NewPipeline().
+			Then(NewSamplingProcessorBuilder(0, countingBuilder(&never))).
+			Then(NewSamplingProcessorBuilder(1, countingBuilder(&always))).
+			Build(NewStorageProcessor)
+
+		for range 20 {
+			if _, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if never != 0 || always != 20 {
+			t.Errorf("expected 0 and 20 sampled, got %d and %d", never, always)
+		}
+	})
+}
+
+// Test Stage Timeouts
+func TestPipelineStageTimeouts(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterBuilder("validator", NewValidatorProcessorBuilder()); err != nil {
+		t.Fatalf("register validator: %v", err)
+	}
+	slowConsumer := func() Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			select {
+			case <-time.After(time.Second):
+				return []Event{event}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+	}
+
+	t.Run("timeout wraps the named stage", func(t *testing.T) {
+		pipeline := NewPipeline().
+			WithRegistry(registry).
+			WithStageTimeout("validator", 20*time.Millisecond).
+			ThenNamed("validator").
+			Build(slowConsumer)
+
+		start := time.Now()
+		_, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected the timeout to cut processing short, took %v", elapsed)
+		}
+	})
+
+	t.Run("timeout for an unknown stage fails the build", func(t *testing.T) {
+		p := NewPipeline().
+			WithRegistry(registry).
+			WithStageTimeout("validatr", time.Second).
+			ThenNamed("validator")
+		_, err := p.Build(NewStorageProcessor).Process(context.Background(), NewEvent("user123", ActionUploadFile))
+
+		if !errors.Is(err, ErrUnknownStage) || !strings.Contains(err.Error(), "validatr") {
+			t.Fatalf("expected ErrUnknownStage naming the stage, got %v", err)
+		}
+	})
+}
+
+// Test Audit Processor
+func TestAuditProcessor(t *testing.T) {
+	clock := func() func() time.Time {
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		return func() time.Time {
+			now = now.Add(5 * time.Millisecond)
+			return now
+		}
+	}
+
+	t.Run("records input, output, outcome and latency", func(t *testing.T) {
+		var auditLog bytes.Buffer
+		pipeline := NewPipeline().
+			Then(NewAuditProcessorBuilder(&auditLog, WithAuditClock(clock()))).
+			Then(NewValidatorProcessorBuilder()).
+			Build(NewStorageProcessor)
+
+		if _, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile, WithMetadata("trace_id", "t-1"))); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := pipeline.Process(context.Background(), NewEvent("", ActionUploadFile)); !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("expected ErrInvalidEvent, got %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(auditLog.String()), "\n")
+		want := []string{
+			`{"time":"2024-05-01T12:00:00.005Z","input":{"user_id":"user123","action":"UploadFile","metadata":{"trace_id":"t-1"}},"output":[{"user_id":"user123","action":"UploadFile","metadata":{"trace_id":"t-1"}}],"outcome":"ok","latency_ms":5}`,
+			`{"time":"2024-05-01T12:00:00.015Z","input":{"user_id":"","action":"UploadFile"},"outcome":"error","error":"invalid event","latency_ms":5}`,
+		}
+		if !slices.Equal(lines, want) {
+			t.Errorf("unexpected audit log:\n%s", auditLog.String())
+		}
+	})
+
+	t.Run("fails closed when the log cannot be written", func(t *testing.T) {
+		file, err := OpenRotatingFile(filepath.Join(t.TempDir(), "audit.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		audit := NewAuditProcessorBuilder(file)(newMockProcessor(nil))
+
+		_, err = audit.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+		if !errors.Is(err, os.ErrClosed) {
+			t.Fatalf("expected the write error, got %v", err)
+		}
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	file, err := OpenRotatingFile(path, WithMaxBytes(10), WithFsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tick := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	file.now = func() time.Time {
+		tick = tick.Add(time.Second)
+		return tick
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("write %q: %v", line, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"audit.log":                           "third\n",
+		"audit.log.20240501T120001.000000000": "first\n",
+		"audit.log.20240501T120002.000000000": "second\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+}
+
+// Test Buffered Stages
+func TestPipelineThenBuffered(t *testing.T) {
+	t.Run("callers return once the event is queued", func(t *testing.T) {
+		release := make(chan struct{})
+		var stored atomic.Int32
+		p := NewPipeline().
+			Then(NewValidatorProcessorBuilder()).
+			ThenBuffered(NewLoggerProcessorBuilder(), 4, 1)
+		pipeline := p.Build(func() Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				<-release
+				stored.Add(1)
+				return []Event{event}, nil
+			})
+		})
+
+		for range 3 {
+			result, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile))
+			if err != nil || len(result) != 1 {
+				t.Fatalf("expected event accepted, got %v, %v", result, err)
+			}
+		}
+		if got := stored.Load(); got != 0 {
+			t.Errorf("expected nothing stored while storage is blocked, got %d", got)
+		}
+
+		close(release)
+		p.Close()
+		if got := stored.Load(); got != 3 {
+			t.Errorf("expected Close to drain all 3 events, got %d", got)
+		}
+		if _, err := pipeline.Process(context.Background(), NewEvent("user123", ActionUploadFile)); !errors.Is(err, ErrPipelineClosed) {
+			t.Errorf("expected ErrPipelineClosed, got %v", err)
+		}
+	})
+
+	t.Run("full queue pushes back until the context ends", func(t *testing.T) {
+		release := make(chan struct{})
+		p := NewPipeline().ThenBuffered(NewValidatorProcessorBuilder(), 1, 1)
+		pipeline := p.Build(func() Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				<-release
+				return []Event{event}, nil
+			})
+		})
+		defer p.Close()
+		defer close(release)
+
+		// One event occupies the worker and two the queue, which the ring
+		// rounds up to a power of two; which is which depends on
+		// scheduling, so keep going until an event is refused.
+		var err error
+		for range 4 {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			_, err = pipeline.Process(ctx, NewEvent("user123", ActionUploadFile))
+			cancel()
+			if err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the full queue to block until the deadline, got %v", err)
+		}
+	})
+
+	t.Run("failures go to the dead-letter processor", func(t *testing.T) {
+		dead := make(chan error, 1)
+		p := NewPipeline().
+			WithDeadLetter(ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				dead <- DeadLetterReason(ctx)
+				return nil, nil
+			})).
+			ThenBuffered(NewValidatorProcessorBuilder(), 1, 1)
+		pipeline := p.Build(NewStorageProcessor)
+
+		if _, err := pipeline.Process(context.Background(), NewEvent("", ActionUploadFile)); err != nil {
+			t.Fatalf("expected event accepted, got %v", err)
+		}
+		p.Close()
+		select {
+		case reason := <-dead:
+			if !errors.Is(reason, ErrInvalidEvent) {
+				t.Errorf("expected ErrInvalidEvent, got %v", reason)
+			}
+		default:
+			t.Fatal("expected the invalid event dead-lettered")
+		}
+	})
+}
+
+// Test Migration Processor
+func TestMigrationProcessor(t *testing.T) {
+	// v1 events carry the file path in metadata, v2 moved it to a FilePayload
+	// and v3 requires a bucket.
+	migration := NewMigrationProcessorBuilder(
+		func(ctx context.Context, event Event) (Event, error) {
+			path, ok := event.Metadata["path"]
+			if !ok {
+				return Event{}, errors.New("v1 event without path")
+			}
+			event.Payload = FilePayload{Key: path}
+			return event, nil
+		},
+		MapPayload(func(p FilePayload) (FilePayload, error) {
+			p.Bucket = "default"
+			return p, nil
+		}),
+	)
+	var seen []Event
+	newNext := func() Processor {
+		seen = nil
+		return newMockProcessor(func(ctx context.Context, event Event) ([]Event, error) {
+			seen = append(seen, event)
+			return []Event{event}, nil
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		event Event
+	}{
+		{"unversioned", NewEvent("user123", ActionUploadFile, WithMetadata("path", "a.pdf"))},
+		{"v2", NewEvent("user123", ActionUploadFile, WithVersion(2), WithPayload(FilePayload{Key: "a.pdf"}))},
+		{"latest", NewEvent("user123", ActionUploadFile, WithVersion(3), WithPayload(FilePayload{Bucket: "default", Key: "a.pdf"}))},
+	} {
+		t.Run(tc.name+" events reach the latest schema", func(t *testing.T) {
+			if _, err := migration(newNext()).Process(context.Background(), tc.event); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			want := FilePayload{Bucket: "default", Key: "a.pdf"}
+			if seen[0].Version != 3 || seen[0].Payload != want {
+				t.Errorf("expected v3 with %v, got v%d with %v", want, seen[0].Version, seen[0].Payload)
+			}
+		})
+	}
+
+	t.Run("future and broken events are rejected", func(t *testing.T) {
+		next := newNext()
+		for _, event := range []Event{
+			NewEvent("user123", ActionUploadFile, WithVersion(4)),
+			NewEvent("user123", ActionUploadFile, WithVersion(1)),
+		} {
+			if _, err := migration(next).Process(context.Background(), event); !errors.Is(err, ErrUnsupportedVersion) {
+				t.Errorf("expected ErrUnsupportedVersion for %v, got %v", event, err)
+			}
+		}
+		if len(seen) != 0 {
+			t.Errorf("expected no event passed on, got %v", seen)
+		}
+	})
+}
+
+// Test Describe
+func TestPipelineDescribe(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterBuilder("validator", NewValidatorProcessorBuilder()); err != nil {
+		t.Fatalf("register validator: %v", err)
+	}
+	p := NewPipeline().
+		WithRegistry(registry).
+		WithMetricsSink(NewPrometheusSink()).
+		WithStageTimeout("validator", time.Second).
+		ThenNamed("validator").
+		Then(NewLoggerProcessorBuilder(), WithStageLabel("logger")).
+		ThenSplit(WithSplitRule(ActionUploadFile, []Action{ActionUploadToStorage, ActionUploadMetadata}), WithConcurrency(2)).
+		ThenRoute(map[Action]Processor{ActionUploadMetadata: NewStorageProcessor()}, nil)
+
+	d := p.Describe()
+
+	want := PipelineDescription{
+		Stages: []StageDescription{
+			{Name: "validator", Kind: "registered validator", Details: []string{"timeout 1s"}},
+			{Name: "logger", Kind: "stage"},
+			{Name: "3", Kind: "splitter", Details: []string{"UploadFile -> UploadToStorage, UploadMetadata", "concurrency 2"}},
+			{Name: "2", Kind: "router", Details: []string{"unrouted -> next stage"}, Branches: []string{"UploadMetadata"}},
+		},
+		Settings: []string{"metrics", "delivery DeliverAll"},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("expected %+v, got %+v", want, d)
+	}
+
+	t.Run("graph exports", func(t *testing.T) {
+		for _, line := range []string{
+			`s0 [label="validator (registered validator)\ntimeout 1s"];`,
+			`s2 -> s3;`,
+			`s3 -> s3_UploadMetadata [label="UploadMetadata"];`,
+			`s3 -> consumer;`,
+		} {
+			if !strings.Contains(d.DOT(), line) {
+				t.Errorf("DOT missing %q:\n%s", line, d.DOT())
+			}
+		}
+		for _, line := range []string{
+			`s2["3 (splitter)<br/>UploadFile -> UploadToStorage, UploadMetadata<br/>concurrency 2"]`,
+			`s3 -- UploadMetadata --> s3_UploadMetadata(["route UploadMetadata"])`,
+			`consumer["consumer<br/>metrics<br/>delivery DeliverAll"]`,
+		} {
+			if !strings.Contains(d.Mermaid(), line) {
+				t.Errorf("Mermaid missing %q:\n%s", line, d.Mermaid())
+			}
+		}
+	})
+
+	t.Run("described stages still process events", func(t *testing.T) {
+		result, err := p.Build(NewStorageProcessor).Process(context.Background(), NewEvent("user123", ActionUploadFile))
+		if err != nil || len(result) != 2 {
+			t.Fatalf("expected 2 events, got %v, %v", result, err)
+		}
+	})
+}
+
+// Test Checkpointed Replay
+func TestCheckpointReplay(t *testing.T) {
+	newLog := func() *MemoryEventLog {
+		events := &MemoryEventLog{}
+		for _, id := range []string{"e1", "e2", "e3", "e4"} {
+			events.Append(NewEvent("user123", ActionUploadFile, WithID(id)))
+		}
+		return events
+	}
+
+	t.Run("replay resumes after the checkpoint and processes effectively once", func(t *testing.T) {
+		events := newLog()
+		store := NewMemoryStore()
+		var processed []string
+		crashAt := "e3"
+		pipeline := NewPipeline().
+			Then(NewCheckpointProcessorBuilder("uploads", store)).
+			Then(NewDedupProcessorBuilder(store)).
+			Build(func() Processor {
+				return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+					if event.ID == crashAt {
+						return nil, errors.New("crash")
+					}
+					processed = append(processed, event.ID)
+					return []Event{event}, nil
+				})
+			})
+
+		// Live traffic gets through e1 and e2, and e2 is then redelivered.
+		all, _ := events.After(context.Background(), "")
+		for _, event := range []Event{all[0], all[1], all[1]} {
+			if _, err := pipeline.Process(context.Background(), event); err != nil {
+				t.Fatalf("process %s: %v", event.ID, err)
+			}
+		}
+		if checkpoint, _ := store.Load(context.Background(), "uploads"); checkpoint != "e2" {
+			t.Fatalf("expected checkpoint e2, got %q", checkpoint)
+		}
+
+		n, err := Replay(context.Background(), "uploads", events, store, pipeline)
+		if err == nil || n != 0 {
+			t.Fatalf("expected replay to stop at e3, got %d, %v", n, err)
+		}
+
+		crashAt = ""
+		n, err = Replay(context.Background(), "uploads", events, store, pipeline)
+		if err != nil || n != 2 {
+			t.Fatalf("expected e3 and e4 replayed, got %d, %v", n, err)
+		}
+		if !slices.Equal(processed, []string{"e1", "e2", "e3", "e4"}) {
+			t.Errorf("expected each event processed once, got %v", processed)
+		}
+		if checkpoint, _ := store.Load(context.Background(), "uploads"); checkpoint != "e4" {
+			t.Errorf("expected checkpoint e4, got %q", checkpoint)
+		}
+	})
+
+	t.Run("dedup drops events already processed", func(t *testing.T) {
+		store := NewMemoryStore()
+		mockNext := newMockProcessor(nil)
+		dedup := NewDedupProcessorBuilder(store)(mockNext)
+
+		for range 2 {
+			if _, err := dedup.Process(context.Background(), NewEvent("user123", ActionUploadFile, WithID("e1"))); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if _, err := dedup.Process(context.Background(), NewEvent("user123", ActionUploadFile)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if mockNext.callCount != 3 {
+			t.Errorf("expected the duplicate dropped and ID-less events kept, got %d calls", mockNext.callCount)
+		}
+	})
+
+	t.Run("bloom filter spares the store lookups of new IDs", func(t *testing.T) {
+		store := &countingSeenStore{SeenStore: NewMemoryStore()}
+		mockNext := newMockProcessor(nil)
+		dedup := NewDedupProcessorBuilder(NewBloomSeenStore(store, bloom.New(1000, 0.01)))(mockNext)
+
+		for _, id := range []string{"e1", "e2", "e3", "e2", "e1"} {
+			if _, err := dedup.Process(context.Background(), NewEvent("user123", ActionUploadFile, WithID(id))); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if mockNext.callCount != 3 {
+			t.Errorf("expected the duplicates dropped, got %d calls", mockNext.callCount)
+		}
+		if store.seenCalls != 2 {
+			t.Errorf("expected only the duplicates looked up in the store, got %d lookups", store.seenCalls)
+		}
+	})
+
+	t.Run("unknown checkpoint", func(t *testing.T) {
+		store := NewMemoryStore()
+		_ = store.Save(context.Background(), "uploads", "e9")
+
+		_, err := Replay(context.Background(), "uploads", newLog(), store, newMockProcessor(nil))
+		if !errors.Is(err, ErrCheckpointNotFound) {
+			t.Fatalf("expected ErrCheckpointNotFound, got %v", err)
+		}
+	})
+}
+
+// countingSeenStore counts the lookups reaching the store it wraps.
+type countingSeenStore struct {
+	SeenStore
+	seenCalls int
+}
+
+func (s *countingSeenStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.seenCalls++
+	return s.SeenStore.Seen(ctx, id)
+}
+
+// Test Atomic Pipeline
+func TestAtomicPipeline(t *testing.T) {
+	splitInto := func(actions ...Action) *Pipeline {
+		return NewPipeline().Then(NewEventSplitterProcessorBuilder(WithSplitRule(ActionUploadFile, actions)))
+	}
+	event := NewEvent("user123", ActionUploadFile)
+
+	t.Run("swaps split rules", func(t *testing.T) {
+		a := NewAtomicPipeline()
+		if _, err := a.Process(context.Background(), event); !errors.Is(err, ErrNoPipeline) {
+			t.Fatalf("expected ErrNoPipeline, got %v", err)
+		}
+
+		if err := a.Swap(context.Background(), splitInto(ActionUploadToStorage), NewStorageProcessor); err != nil {
+			t.Fatal(err)
+		}
+		result, _ := a.Process(context.Background(), event)
+		if len(result) != 1 {
+			t.Fatalf("expected 1 event, got %v", result)
+		}
+
+		if err := a.Swap(context.Background(), splitInto(ActionUploadToStorage, ActionUploadMetadata), NewStorageProcessor); err != nil {
+			t.Fatal(err)
+		}
+		result, _ = a.Process(context.Background(), event)
+		if len(result) != 2 {
+			t.Fatalf("expected the new rules to apply, got %v", result)
+		}
+	})
+
+	t.Run("rejected candidates leave the current pipeline", func(t *testing.T) {
+		canaryErr := errors.New("canary failed")
+		a := NewAtomicPipeline(WithSwapCheck(func(ctx context.Context, candidate Processor) error {
+			if result, err := candidate.Process(ctx, NewEvent("canary", ActionUploadFile)); err != nil || len(result) == 0 {
+				return errors.Join(canaryErr, err)
+			}
+			return nil
+		}))
+		if err := a.Swap(context.Background(), splitInto(ActionUploadToStorage), NewStorageProcessor); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.Swap(context.Background(), splitInto(), NewStorageProcessor); !errors.Is(err, canaryErr) {
+			t.Errorf("expected the canary to reject an empty split, got %v", err)
+		}
+		if err := a.Swap(context.Background(), NewPipeline().ThenNamed("missing"), NewStorageProcessor); !errors.Is(err, ErrUnknownStage) {
+			t.Errorf("expected ErrUnknownStage, got %v", err)
+		}
+		if result, _ := a.Process(context.Background(), event); len(result) != 1 {
+			t.Errorf("expected the original pipeline still installed, got %v", result)
+		}
+	})
+
+	t.Run("in-flight events finish in the old pipeline", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		a := NewAtomicPipeline()
+		err := a.Swap(context.Background(), NewPipeline(), func() Processor {
+			return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+				close(started)
+				<-release
+				return []Event{event}, nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inflight := make(chan []Event)
+		go func() {
+			result, _ := a.Process(context.Background(), event)
+			inflight <- result
+		}()
+		<-started
+
+		old := a.current.Load()
+		swapped := make(chan error)
+		go func() {
+			swapped <- a.Swap(context.Background(), splitInto(ActionUploadToStorage, ActionUploadMetadata), NewStorageProcessor)
+		}()
+		for a.current.Load() == old {
+			time.Sleep(time.Millisecond)
+		}
+
+		// New traffic reaches the new pipeline while the old event is held.
+		if result, _ := a.Process(context.Background(), event); len(result) != 2 {
+			t.Fatalf("expected the new pipeline to take traffic, got %v", result)
+		}
+		select {
+		case <-swapped:
+			t.Fatal("expected Swap to wait for the in-flight event")
+		default:
+		}
+
+		close(release)
+		if result := <-inflight; len(result) != 1 {
+			t.Errorf("expected the in-flight event to complete in the old pipeline, got %v", result)
+		}
+		if err := <-swapped; err != nil {
+			t.Errorf("expected swap to succeed, got %v", err)
+		}
+	})
+}
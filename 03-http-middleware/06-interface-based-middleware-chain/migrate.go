@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported event version")
+
+// NewMigrationProcessorBuilder upgrades every event to the latest schema
+// before the next stage sees it, so old producers keep working. upgrades
+// are ordered: upgrades[0] turns a version 1 event into version 2,
+// upgrades[1] version 2 into 3, and so on, making len(upgrades)+1 the
+// latest version. An event runs through every upgrade from its own version
+// on and has its Version set after each. Events newer than the latest fail
+// with ErrUnsupportedVersion, as do those whose upgrade fails.
+func NewMigrationProcessorBuilder(upgrades ...TransformFunc) ProcessBuilder {
+	latest := len(upgrades) + 1
+	return func(next Processor) Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Migration] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			version := max(event.Version, 1)
+			if version > latest {
+				return nil, fmt.Errorf("%w: %d, latest is %d", ErrUnsupportedVersion, version, latest)
+			}
+			for ; version < latest; version++ {
+				upgraded, err := upgrades[version-1](ctx, event)
+				if err != nil {
+					return nil, fmt.Errorf("%w: upgrading from %d: %w", ErrUnsupportedVersion, version, err)
+				}
+				event = upgraded
+				event.Version = version + 1
+			}
+			event.Version = latest
+			return next.Process(ctx, event)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// FilePayload describes an uploaded file.
+type FilePayload struct {
+	Bucket   string
+	Key      string
+	Size     int64
+	Checksum string // hex SHA-256 of the content
+}
+
+func (p FilePayload) Validate() error {
+	var errs []error
+	if p.Bucket == "" {
+		errs = append(errs, errors.New("bucket is required"))
+	}
+	if p.Key == "" {
+		errs = append(errs, errors.New("key is required"))
+	}
+	if p.Size <= 0 {
+		errs = append(errs, fmt.Errorf("size must be positive, got %d", p.Size))
+	}
+	if len(p.Checksum) != 64 {
+		errs = append(errs, errors.New("checksum must be a hex SHA-256"))
+	}
+	return errors.Join(errs...)
+}
+
+// PayloadOf returns event's payload as a T.
+func PayloadOf[T any](event Event) (T, bool) {
+	payload, ok := event.Payload.(T)
+	return payload, ok
+}
+
+// Schema checks an event's payload, returning an error that describes what
+// is wrong with it.
+type Schema func(payload any) error
+
+// PayloadSchema is the schema of payloads of type T that pass validate; a
+// nil validate accepts any T.
+func PayloadSchema[T any](validate func(T) error) Schema {
+	return func(payload any) error {
+		typed, ok := payload.(T)
+		if !ok {
+			var want T
+			return fmt.Errorf("payload is %T, want %T", payload, want)
+		}
+		if validate == nil {
+			return nil
+		}
+		return validate(typed)
+	}
+}
+
+type SchemaConfig struct {
+	schemas map[Action]Schema
+}
+
+type SchemaOption func(*SchemaConfig)
+
+func WithSchema(action Action, schema Schema) SchemaOption {
+	return func(cfg *SchemaConfig) {
+		cfg.schemas[action] = schema
+	}
+}
+
+// NewSchemaValidatorProcessorBuilder rejects events whose payload does not
+// match the schema registered for their action with an error wrapping
+// ErrInvalidPayload. Actions without a schema pass unchecked.
+func NewSchemaValidatorProcessorBuilder(opts ...SchemaOption) ProcessBuilder {
+	return func(next Processor) Processor {
+		cfg := &SchemaConfig{
+			schemas: make(map[Action]Schema),
+		}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[SchemaValidator] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			if schema, ok := cfg.schemas[event.Action]; ok {
+				if err := schema(event.Payload); err != nil {
+					return nil, fmt.Errorf("%w for %v: %w", ErrInvalidPayload, event.Action, err)
+				}
+			}
+			return next.Process(ctx, event)
+		})
+	}
+}
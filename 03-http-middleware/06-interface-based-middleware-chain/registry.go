@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicateStage = errors.New("stage already registered")
+	ErrUnknownStage   = errors.New("unknown stage")
+)
+
+// Registry maps names to middleware so stages can be contributed by other
+// code and referenced by name, from configuration or tooling, rather than by
+// Go identifier. It is safe for concurrent use; registration typically
+// happens at startup.
+type Registry struct {
+	mu       sync.RWMutex
+	builders map[string]ProcessBuilder
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		builders: make(map[string]ProcessBuilder),
+	}
+}
+
+// RegisterBuilder makes b available as name. Names are unique: registering
+// one twice fails with ErrDuplicateStage and keeps the first.
+func (r *Registry) RegisterBuilder(name string, b ProcessBuilder) error {
+	if name == "" || b == nil {
+		return fmt.Errorf("register %q: name and builder are required", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.builders[name]; ok {
+		return fmt.Errorf("register %q: %w", name, ErrDuplicateStage)
+	}
+	r.builders[name] = b
+	return nil
+}
+
+func (r *Registry) Lookup(name string) (ProcessBuilder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.builders[name]
+	return b, ok
+}
+
+// Names lists the registered stages in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.builders))
+	for name := range r.builders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+type StageConfig struct {
+	label string
+}
+
+type StageOption func(*StageConfig)
+
+// WithStageLabel names the stage label in metrics, timeouts and Describe,
+// instead of its registered name or its position.
+func WithStageLabel(label string) StageOption {
+	return func(cfg *StageConfig) {
+		cfg.label = label
+	}
+}
+
+func (p *Pipeline) WithRegistry(r *Registry) *Pipeline {
+	p.registry = r
+	return p
+}
+
+// ThenNamed appends the stage registered as name in the pipeline's registry.
+// A name that is not registered does not panic: it is recorded, returned by
+// Err and by every event the built pipeline processes.
+func (p *Pipeline) ThenNamed(name string, opts ...StageOption) *Pipeline {
+	cfg := &StageConfig{label: name}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var b ProcessBuilder
+	var ok bool
+	if p.registry != nil {
+		b, ok = p.registry.Lookup(name)
+	}
+	if !ok {
+		p.err = errors.Join(p.err, fmt.Errorf("stage %q: %w", name, ErrUnknownStage))
+		return p
+	}
+	p.stages = append(p.stages, stage{name: cfg.label, build: b, kind: "registered " + name})
+	return p
+}
+
+// WithStageTimeout bounds the named stage, together with the stages after
+// it, to d per event, as a NewTimeoutProcessorBuilder placed just before it
+// would. Keeping every timeout in one place makes them easy to review; a
+// name no stage carries fails Build with ErrUnknownStage.
+func (p *Pipeline) WithStageTimeout(name string, d time.Duration) *Pipeline {
+	if p.timeouts == nil {
+		p.timeouts = make(map[string]time.Duration)
+	}
+	p.timeouts[name] = d
+	return p
+}
+
+func (p *Pipeline) checkStageTimeouts() {
+	for name := range p.timeouts {
+		if !slices.ContainsFunc(p.stages, func(s stage) bool { return name != "" && s.name == name }) {
+			p.err = errors.Join(p.err, fmt.Errorf("timeout for stage %q: %w", name, ErrUnknownStage))
+		}
+	}
+}
+
+// Err reports what went wrong configuring the pipeline, if anything.
+func (p *Pipeline) Err() error {
+	return p.err
+}
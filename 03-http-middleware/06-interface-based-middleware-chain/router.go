@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+)
+
+// NewRouterProcessorBuilder sends each event to the sub-pipeline registered
+// for its action instead of down the rest of the chain, so storage, metadata
+// and notification events can each flow through stages of their own.
+// Events with no route go to fallback, or to the next stage if fallback is
+// nil. routes is copied, so changing it afterwards has no effect.
+func NewRouterProcessorBuilder(routes map[Action]Processor, fallback Processor) ProcessBuilder {
+	routes = maps.Clone(routes)
+	return func(next Processor) Processor {
+		unrouted := fallback
+		if unrouted == nil {
+			unrouted = next
+		}
+
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Router] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			if route, ok := routes[event.Action]; ok {
+				return route.Process(ctx, event)
+			}
+			return unrouted.Process(ctx, event)
+		})
+	}
+}
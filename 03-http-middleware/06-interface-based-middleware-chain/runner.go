@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Message is one delivery from a MessageSource. Exactly one of Ack or Nack
+// is called for it; Nack asks the source to redeliver, or dead-letter, it.
+type Message interface {
+	Data() []byte
+	Ack() error
+	Nack(reason error) error
+}
+
+// MessageSource is a queue or topic subscription the Runner drains. Receive
+// blocks until a message arrives, returning io.EOF once the source is
+// closed and drained. Adapters for brokers such as Kafka or NATS implement
+// it around their client; MemorySource is an in-process one.
+type MessageSource interface {
+	Receive(ctx context.Context) (Message, error)
+}
+
+// MessageDecoder turns a message body into an Event.
+type MessageDecoder func(data []byte) (Event, error)
+
+// Runner drives a built pipeline from a MessageSource: every message is
+// decoded, processed, and acked if the pipeline succeeds or nacked with its
+// error if decoding or processing fails.
+type Runner struct {
+	source      MessageSource
+	pipeline    Processor
+	decode      MessageDecoder
+	concurrency int
+}
+
+type RunnerOption func(*Runner)
+
+// WithRunnerConcurrency processes up to n messages at once; the default is
+// one at a time. The pipeline must then be safe for concurrent use.
+func WithRunnerConcurrency(n int) RunnerOption {
+	return func(r *Runner) {
+		r.concurrency = n
+	}
+}
+
+func NewRunner(source MessageSource, pipeline Processor, decode MessageDecoder, opts ...RunnerOption) *Runner {
+	r := &Runner{
+		source:      source,
+		pipeline:    pipeline,
+		decode:      decode,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run consumes messages until the source is drained, returning nil, or ctx
+// ends or Receive fails, returning why. Either way it waits for the messages
+// already received to be acked or nacked first. Messages still in the
+// pipeline when ctx ends see it canceled and are nacked.
+func (r *Runner) Run(ctx context.Context) error {
+	var g errgroup.Group
+	g.SetLimit(max(r.concurrency, 1))
+	defer g.Wait()
+
+	for {
+		msg, err := r.source.Receive(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		g.Go(func() error {
+			r.handle(ctx, msg)
+			return nil
+		})
+	}
+}
+
+func (r *Runner) handle(ctx context.Context, msg Message) {
+	event, err := r.decode(msg.Data())
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	} else {
+		_, err = r.pipeline.Process(ctx, event)
+	}
+
+	if err != nil {
+		log.Default().Println("[Runner] Nack message:", err)
+		err = msg.Nack(err)
+	} else {
+		err = msg.Ack()
+	}
+	if err != nil {
+		log.Default().Println("[Runner] Failed to settle message:", err)
+	}
+}
+
+// jsonEvent is the wire form of an Event; see DecodeJSONEvent.
+type jsonEvent struct {
+	ID       string            `json:"id,omitempty"`
+	UserID   string            `json:"user_id"`
+	Action   string            `json:"action"`
+	Version  int               `json:"version,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// DecodeJSONEvent decodes {"id": ..., "user_id": ..., "action": ...,
+// "version": ..., "metadata": {...}} with the action spelled as Action.String does. Payloads are not decoded;
+// their type depends on the action, so a service with payloads wraps this
+// with its own decoder.
+func DecodeJSONEvent(data []byte) (Event, error) {
+	var wire jsonEvent
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return Event{}, err
+	}
+	action, err := ParseAction(wire.Action)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: wire.ID, UserID: wire.UserID, Action: action, Version: wire.Version, Metadata: wire.Metadata}, nil
+}
+
+// MemorySource is an in-process MessageSource, for tests and for wiring a
+// pipeline up before a broker is available. Nacked messages are not
+// redelivered; their reason is kept on the message.
+type MemorySource struct {
+	messages  chan *MemoryMessage
+	closeOnce sync.Once
+}
+
+func NewMemorySource(buffer int) *MemorySource {
+	return &MemorySource{messages: make(chan *MemoryMessage, buffer)}
+}
+
+// Publish enqueues data, blocking while the buffer is full.
+func (s *MemorySource) Publish(ctx context.Context, data []byte) (*MemoryMessage, error) {
+	msg := &MemoryMessage{data: data}
+	select {
+	case s.messages <- msg:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the source once the published messages are received. Publish
+// must not be called after Close.
+func (s *MemorySource) Close() {
+	s.closeOnce.Do(func() { close(s.messages) })
+}
+
+func (s *MemorySource) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg, ok := <-s.messages:
+		if !ok {
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type MemoryMessage struct {
+	data []byte
+
+	mu      sync.Mutex
+	settled bool
+	acked   bool
+	reason  error
+}
+
+var errAlreadySettled = errors.New("message already acked or nacked")
+
+func (m *MemoryMessage) Data() []byte {
+	return m.data
+}
+
+func (m *MemoryMessage) Ack() error {
+	return m.settle(true, nil)
+}
+
+func (m *MemoryMessage) Nack(reason error) error {
+	return m.settle(false, reason)
+}
+
+func (m *MemoryMessage) settle(acked bool, reason error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.settled {
+		return errAlreadySettled
+	}
+	m.settled, m.acked, m.reason = true, acked, reason
+	return nil
+}
+
+// Settled reports whether the message was acked or nacked, whether it was
+// acked, and the reason it was nacked.
+func (m *MemoryMessage) Settled() (settled, acked bool, reason error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settled, m.acked, m.reason
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+)
+
+type SamplingConfig struct {
+	random func() float64
+}
+
+type SamplingOption func(*SamplingConfig)
+
+// WithRandomSource replaces the source of the uniform [0, 1) numbers events
+// are sampled by, for deterministic tests or a seeded generator.
+func WithRandomSource(random func() float64) SamplingOption {
+	return func(cfg *SamplingConfig) {
+		cfg.random = random
+	}
+}
+
+// NewSamplingProcessorBuilder applies inner, typically verbose logging or
+// tracing too expensive for every event, to a fraction rate of events only;
+// the rest skip straight to the next stage. A rate of 0 skips inner always
+// and 1 applies it always.
+func NewSamplingProcessorBuilder(rate float64, inner ProcessBuilder, opts ...SamplingOption) ProcessBuilder {
+	return func(next Processor) Processor {
+		cfg := &SamplingConfig{
+			random: rand.Float64,
+		}
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		sampled := inner(next)
+
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Sampling] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			if cfg.random() < rate {
+				return sampled.Process(ctx, event)
+			}
+			return next.Process(ctx, event)
+		})
+	}
+}
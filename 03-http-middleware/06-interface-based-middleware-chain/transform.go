@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// TransformFunc rewrites an event, for instance migrating an old action or
+// payload format to the current one.
+type TransformFunc func(ctx context.Context, event Event) (Event, error)
+
+// NewTransformProcessorBuilder passes every event through fn before the next
+// stage. An error from fn stops the event there.
+func NewTransformProcessorBuilder(fn TransformFunc) ProcessBuilder {
+	return func(next Processor) Processor {
+		return ProcessorFunc(func(ctx context.Context, event Event) ([]Event, error) {
+			if IsCtxDone(ctx) {
+				log.Default().Println("[Transform] Context done before processing event:", event.String())
+				return nil, ctx.Err()
+			}
+
+			transformed, err := fn(ctx, event)
+			if err != nil {
+				return nil, err
+			}
+			return next.Process(ctx, transformed)
+		})
+	}
+}
+
+// MapPayload is a TransformFunc converting payloads of type From with fn.
+// Events carrying any other payload pass unchanged, so a migration can sit
+// in front of traffic that is only partly in the old format.
+func MapPayload[From, To any](fn func(From) (To, error)) TransformFunc {
+	return func(ctx context.Context, event Event) (Event, error) {
+		from, ok := event.Payload.(From)
+		if !ok {
+			return event, nil
+		}
+		to, err := fn(from)
+		if err != nil {
+			return Event{}, err
+		}
+		event.Payload = to
+		return event, nil
+	}
+}
@@ -0,0 +1,3 @@
+module request-metadata-propagation
+
+go 1.25.0
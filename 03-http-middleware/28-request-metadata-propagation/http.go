@@ -0,0 +1,130 @@
+package requestmeta
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type middlewareConfig struct {
+	maxBudget     time.Duration
+	requireTenant bool
+}
+
+type MiddlewareOption func(*middlewareConfig)
+
+// WithMaxBudget caps the budget a caller may ask for, and applies it to
+// requests that ask for none. The default is 30 seconds.
+func WithMaxBudget(d time.Duration) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.maxBudget = d
+	}
+}
+
+// WithRequiredTenant rejects requests without a tenant header.
+func WithRequiredTenant() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.requireTenant = true
+	}
+}
+
+// Middleware puts the metadata of incoming requests into their context.
+// Headers come from outside and are validated: a missing or malformed
+// request ID is replaced by a new one, a malformed tenant or budget is a
+// 400. The request ID is echoed in the response.
+func Middleware(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	cfg := &middlewareConfig{maxBudget: 30 * time.Second}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id := r.Header.Get(HeaderRequestID)
+		if !validToken(id) {
+			id = NewRequestID()
+		}
+		ctx = WithRequestID(ctx, id)
+		w.Header().Set(HeaderRequestID, id)
+
+		tenant := r.Header.Get(HeaderTenant)
+		switch {
+		case tenant == "" && cfg.requireTenant:
+			http.Error(w, "missing "+HeaderTenant, http.StatusBadRequest)
+			return
+		case tenant != "" && !validToken(tenant):
+			http.Error(w, "malformed "+HeaderTenant, http.StatusBadRequest)
+			return
+		case tenant != "":
+			ctx = WithTenant(ctx, tenant)
+		}
+
+		budget := cfg.maxBudget
+		if raw := r.Header.Get(HeaderBudget); raw != "" {
+			ms, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || ms <= 0 {
+				http.Error(w, "malformed "+HeaderBudget, http.StatusBadRequest)
+				return
+			}
+			budget = min(time.Duration(ms)*time.Millisecond, cfg.maxBudget)
+		}
+		ctx, cancel := WithBudget(ctx, budget)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Transport sends the metadata of each request's context to the server as
+// headers, with the budget that is left at the time of sending.
+type Transport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	header := make(http.Header)
+	if id, ok := RequestID(ctx); ok {
+		header.Set(HeaderRequestID, id)
+	}
+	if tenant, ok := Tenant(ctx); ok {
+		header.Set(HeaderTenant, tenant)
+	}
+	if budget, ok := Budget(ctx); ok {
+		if budget <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		header.Set(HeaderBudget, strconv.FormatInt(max(budget.Milliseconds(), 1), 10))
+	}
+
+	if len(header) > 0 {
+		// A RoundTripper must not modify the request it was given.
+		r = r.Clone(ctx)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// validToken accepts what can safely go into logs and headers: 1 to 128
+// letters, digits, '-', '_' and '.'.
+func validToken(s string) bool {
+	if s == "" || len(s) > 128 {
+		return false
+	}
+	for i := range len(s) {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+// Package requestmeta carries request-scoped metadata, the request ID, the
+// tenant and the time budget left, through a context.Context, and across
+// process boundaries in HTTP headers.
+//
+// Each value has its own unexported key type, so no other package can read
+// or overwrite it by accident, and typed accessors, so callers never type
+// assert. Only metadata lives here: dependencies and optional parameters
+// belong in function arguments, not in the context.
+package requestmeta
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	HeaderRequestID = "X-Request-Id"
+	HeaderTenant    = "X-Tenant-Id"
+	// HeaderBudget carries the time the caller still allows for the request,
+	// in milliseconds. A deadline would need synchronised clocks; a budget
+	// only needs both sides to measure from when they got it.
+	HeaderBudget = "X-Request-Budget-Ms"
+)
+
+type requestIDKey struct{}
+type tenantKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, if it has one.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant ctx works for, if any.
+func Tenant(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// WithBudget bounds ctx to d from now, unless its deadline is already
+// sooner. The budget is the context's deadline: nothing else needs to be
+// stored, and everything downstream honours it.
+func WithBudget(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d)
+}
+
+// Budget returns the time left before ctx's deadline, if it has one.
+func Budget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return max(time.Until(deadline), 0), true
+}
+
+// NewRequestID returns a random 128-bit ID in hex.
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Attrs returns ctx's metadata as log attributes, for handlers and
+// middleware to add to every record of the request.
+func Attrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if id, ok := RequestID(ctx); ok {
+		attrs = append(attrs, slog.String("requestID", id))
+	}
+	if tenant, ok := Tenant(ctx); ok {
+		attrs = append(attrs, slog.String("tenant", tenant))
+	}
+	if budget, ok := Budget(ctx); ok {
+		attrs = append(attrs, slog.Duration("budget", budget))
+	}
+	return attrs
+}
+
+// Annotate prefixes err with ctx's request ID, so an error reported far
+// from where it happened can be matched with the request's logs. It
+// returns err unchanged when ctx has no request ID, and nil for nil.
+func Annotate(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	id, ok := RequestID(ctx)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("request %s: %w", id, err)
+}
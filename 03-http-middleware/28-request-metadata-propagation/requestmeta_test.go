@@ -0,0 +1,175 @@
+package requestmeta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// captured records the metadata a handler saw.
+type captured struct {
+	id, tenant string
+	budget     time.Duration
+	hasBudget  bool
+}
+
+func capture(got *captured) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.id, _ = RequestID(r.Context())
+		got.tenant, _ = Tenant(r.Context())
+		got.budget, got.hasBudget = Budget(r.Context())
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		opts       []MiddlewareOption
+		wantStatus int
+		check      func(t *testing.T, got captured, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "ExtractsHeaders",
+			headers:    map[string]string{HeaderRequestID: "req-1", HeaderTenant: "acme", HeaderBudget: "500"},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, got captured, rec *httptest.ResponseRecorder) {
+				if got.id != "req-1" || got.tenant != "acme" {
+					t.Errorf("expected req-1 for acme, got %q for %q", got.id, got.tenant)
+				}
+				if !got.hasBudget || got.budget > 500*time.Millisecond || got.budget < 400*time.Millisecond {
+					t.Errorf("expected a budget of about 500ms, got %v", got.budget)
+				}
+				if rec.Header().Get(HeaderRequestID) != "req-1" {
+					t.Errorf("expected the request ID echoed, got %q", rec.Header().Get(HeaderRequestID))
+				}
+			},
+		},
+		{
+			name:       "ReplacesMalformedRequestID",
+			headers:    map[string]string{HeaderRequestID: "bad id\nInjected: yes"},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, got captured, rec *httptest.ResponseRecorder) {
+				if len(got.id) != 32 || got.id != rec.Header().Get(HeaderRequestID) {
+					t.Errorf("expected a generated request ID, got %q", got.id)
+				}
+			},
+		},
+		{
+			name:       "CapsBudget",
+			headers:    map[string]string{HeaderBudget: "3600000"},
+			opts:       []MiddlewareOption{WithMaxBudget(time.Second)},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, got captured, rec *httptest.ResponseRecorder) {
+				if got.budget > time.Second {
+					t.Errorf("expected the budget capped to 1s, got %v", got.budget)
+				}
+			},
+		},
+		{
+			name:       "MalformedBudget",
+			headers:    map[string]string{HeaderBudget: "-5"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "MalformedTenant",
+			headers:    map[string]string{HeaderTenant: "acme/../admin"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "RequiredTenant",
+			opts:       []MiddlewareOption{WithRequiredTenant()},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got captured
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			Middleware(capture(&got), tt.opts...).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.check != nil {
+				tt.check(t, got, rec)
+			}
+		})
+	}
+}
+
+func TestTransport_PropagatesAcrossServices(t *testing.T) {
+	var downstream captured
+	backend := httptest.NewServer(Middleware(capture(&downstream)))
+	defer backend.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	frontend := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond) // spend some of the budget
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	})))
+	defer frontend.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, frontend.URL, nil)
+	req.Header.Set(HeaderRequestID, "trace-42")
+	req.Header.Set(HeaderTenant, "acme")
+	req.Header.Set(HeaderBudget, strconv.Itoa(1000))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if downstream.id != "trace-42" || downstream.tenant != "acme" {
+		t.Errorf("expected trace-42 for acme downstream, got %q for %q", downstream.id, downstream.tenant)
+	}
+	if downstream.budget > 950*time.Millisecond {
+		t.Errorf("expected the downstream budget to shrink by the time spent, got %v", downstream.budget)
+	}
+}
+
+func TestTransport_ExpiredBudget(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.invalid", nil)
+
+	if _, err := (&Transport{}).RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded without sending, got %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	ctx := WithTenant(WithRequestID(context.Background(), "req-7"), "acme")
+
+	if attrs := Attrs(ctx); len(attrs) != 2 || attrs[0].Value.String() != "req-7" || attrs[1].Value.String() != "acme" {
+		t.Errorf("expected request ID and tenant attributes, got %v", attrs)
+	}
+
+	errNotFound := errors.New("not found")
+	err := Annotate(ctx, errNotFound)
+	if !errors.Is(err, errNotFound) || err.Error() != "request req-7: not found" {
+		t.Errorf("expected the error annotated with the request ID, got %v", err)
+	}
+	if Annotate(context.Background(), errNotFound) != errNotFound {
+		t.Error("expected errors without a request ID unchanged")
+	}
+	if Annotate(ctx, nil) != nil {
+		t.Error("expected Annotate(nil) to be nil")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "context"
+
+// Result is the outcome of a call of Do started with DoChan.
+type Result struct {
+	Err error
+}
+
+// DoChan runs Do on its own goroutine and delivers its error on the
+// returned channel, so the caller can select on it alongside other events.
+// The channel is buffered: the goroutine ends with Do even if nobody ever
+// receives.
+func (r *Retryer) DoChan(ctx context.Context, fn func(ctx context.Context) error) <-chan Result {
+	ch := make(chan Result, 1)
+	go func() {
+		ch <- Result{Err: r.Do(ctx, fn)}
+		close(ch)
+	}()
+	return ch
+}
+
+// Future is the pending result of DoAsync, shaped like the Future of the
+// graceful shutdown kata.
+type Future[T any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	value  T
+	err    error
+}
+
+// DoAsync is DoValue on its own goroutine. The Future it returns can be
+// waited on, polled or cancelled.
+func DoAsync[T any](ctx context.Context, r *Retryer, fn func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := &Future[T]{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer cancel()
+		f.value, f.err = DoValue(ctx, r, fn)
+		close(f.done)
+	}()
+	return f
+}
+
+// Get waits for the result.
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Cancel cancels the context of the retry loop; Get then returns what Do
+// returns on cancellation, usually context.Canceled.
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Future[T]) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
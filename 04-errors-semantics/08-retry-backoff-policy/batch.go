@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DoBatch calls fn for every item, then retries only the items that failed
+// with a transient error, in rounds sharing one backoff, until all of them
+// succeed or the Retryer gives up. It returns one error per item, nil for
+// those that succeeded, for bulk calls where partial failure is normal.
+//
+// A round counts as one attempt against maxAttempts, the budget and the
+// breaker; WithFallback does not apply. Items are processed one at a time
+// in their original order.
+func DoBatch[T any](ctx context.Context, r *Retryer, items []T, fn func(ctx context.Context, item T) error) []error {
+	errs := make([]error, len(items))
+	pending := make([]int, len(items))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	rounds := 0
+	_, err := doValue(ctx, r, "", func(ctx context.Context) (struct{}, error) {
+		rounds++
+		var transient error
+		failed := pending[:0]
+		for _, i := range pending {
+			errs[i] = fn(ctx, items[i])
+			if errs[i] != nil && r.shouldRetry(errs[i]) {
+				failed = append(failed, i)
+				transient = errs[i]
+			}
+		}
+		pending = failed
+		return struct{}{}, transient
+	})
+	if err == nil {
+		return errs
+	}
+
+	for _, i := range pending {
+		errs[i] = batchItemError(err, rounds, errs[i])
+	}
+	return errs
+}
+
+// batchItemError is why a pending item was given up on: the reason the
+// whole batch stopped, wrapped around the item's own last error.
+func batchItemError(err error, rounds int, itemErr error) error {
+	if itemErr == nil {
+		return err
+	}
+	for _, reason := range []error{ErrMaxRetryReached, ErrRetryBudgetExhausted, ErrCircuitOpen, ErrRetryAborted} {
+		if errors.Is(err, reason) {
+			return fmt.Errorf("%w after %d attempts: %w", reason, rounds, itemErr)
+		}
+	}
+	return fmt.Errorf("%w after %d attempts: %w", err, rounds, itemErr)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget caps how many retries all the Retryers sharing it may make per
+// window, as a token bucket refilled evenly across the window. First
+// attempts are free; only retries draw from it. When it runs dry, callers
+// fail fast instead of piling retries onto a downstream that is already
+// struggling. A Budget is safe for concurrent use.
+type Budget struct {
+	capacity float64
+	perSec   float64
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+type BudgetOption func(*Budget)
+
+// WithBudgetClock replaces time.Now, for tests.
+func WithBudgetClock(now func() time.Time) BudgetOption {
+	return func(b *Budget) {
+		b.now = now
+	}
+}
+
+// NewBudget allows retries retries per window, starting full.
+func NewBudget(retries int, window time.Duration, opts ...BudgetOption) *Budget {
+	b := &Budget{
+		capacity: float64(retries),
+		perSec:   float64(retries) / window.Seconds(),
+		now:      time.Now,
+		tokens:   float64(retries),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.last = b.now()
+	return b
+}
+
+// take spends one retry, reporting false if none is left.
+func (b *Budget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.perSec)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
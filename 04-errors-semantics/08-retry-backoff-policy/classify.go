@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"syscall"
+)
+
+// Classifier reports whether err is worth retrying. Classifiers passed to
+// WithRetryIf widen what Do retries beyond timeouts, ErrTransient and
+// Retryable; they cannot make those permanent.
+type Classifier func(err error) bool
+
+// HTTPStatusError is implemented by errors that carry an HTTP response
+// status, as returned by most HTTP client wrappers.
+type HTTPStatusError interface {
+	StatusCode() int
+}
+
+// RetryOnHTTPStatus retries HTTPStatusErrors with one of codes, typically
+// http.StatusTooManyRequests and http.StatusServiceUnavailable. Combine it
+// with a DelayHinter error to honour Retry-After.
+func RetryOnHTTPStatus(codes ...int) Classifier {
+	return func(err error) bool {
+		var statusErr HTTPStatusError
+		return errors.As(err, &statusErr) && slices.Contains(codes, statusErr.StatusCode())
+	}
+}
+
+// RetryOnGRPCCode retries errors whose gRPC code, as reported by codeOf, is
+// one of codes. The kata does not depend on gRPC, so codeOf adapts
+// status.Code from google.golang.org/grpc/status:
+//
+//	RetryOnGRPCCode(func(err error) uint32 { return uint32(status.Code(err)) },
+//		uint32(codes.Unavailable), uint32(codes.ResourceExhausted))
+func RetryOnGRPCCode(codeOf func(err error) uint32, codes ...uint32) Classifier {
+	return func(err error) bool {
+		return slices.Contains(codes, codeOf(err))
+	}
+}
+
+// RetryOnConnectionError retries failures to reach the peer at all: a
+// refused or reset connection, or a DNS lookup that timed out or failed
+// temporarily. The request never got through, so retrying is safe even for
+// calls that are not idempotent.
+func RetryOnConnectionError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+}
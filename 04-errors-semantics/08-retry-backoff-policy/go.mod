@@ -1,3 +1,7 @@
 module retry-backoff-policy
 
 go 1.25.0
+
+require circuit-breaker v0.0.0
+
+replace circuit-breaker => ../26-circuit-breaker
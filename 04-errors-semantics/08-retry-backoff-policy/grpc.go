@@ -0,0 +1,99 @@
+package main
+
+import "context"
+
+// The kata does not depend on google.golang.org/grpc, so these stand in for
+// the grpc types of the same names. With grpc in your module, make them
+// aliases (type ClientConn = grpc.ClientConn, and so on) and the
+// interceptors below are a grpc.UnaryClientInterceptor and a
+// grpc.StreamClientInterceptor, ready for grpc.WithChainUnaryInterceptor.
+type (
+	ClientConn struct{}
+	CallOption interface{}
+	StreamDesc struct {
+		StreamName    string
+		ServerStreams bool
+		ClientStreams bool
+	}
+	ClientStream interface {
+		Context() context.Context
+		SendMsg(m any) error
+		RecvMsg(m any) error
+		CloseSend() error
+	}
+)
+
+// UnaryInvoker, Streamer and the interceptor types have grpc's signatures.
+type (
+	UnaryInvoker           func(ctx context.Context, method string, req, reply any, cc *ClientConn, opts ...CallOption) error
+	UnaryClientInterceptor func(ctx context.Context, method string, req, reply any, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error
+
+	Streamer                func(ctx context.Context, desc *StreamDesc, cc *ClientConn, method string, opts ...CallOption) (ClientStream, error)
+	StreamClientInterceptor func(ctx context.Context, desc *StreamDesc, cc *ClientConn, method string, streamer Streamer, opts ...CallOption) (ClientStream, error)
+)
+
+// Standard gRPC status codes worth retrying, as numbered by
+// google.golang.org/grpc/codes.
+const (
+	GRPCResourceExhausted uint32 = 8
+	GRPCUnavailable       uint32 = 14
+)
+
+type interceptorConfig struct {
+	methods map[string]*Retryer
+}
+
+type InterceptorOption func(*interceptorConfig)
+
+// WithMethodRetryer retries calls of method, a full method name such as
+// "/orders.v1.Orders/Create", with override instead of the interceptor's
+// own Retryer. An override of NewRetryer(WithMaxAttempts(1)) turns retries
+// off for a method that is not idempotent.
+func WithMethodRetryer(method string, override *Retryer) InterceptorOption {
+	return func(c *interceptorConfig) {
+		c.methods[method] = override
+	}
+}
+
+// UnaryClientInterceptor retries every unary call with Do. Which failures
+// are retried is the Retryer's classification, so give it the codes to
+// retry, reading them with status.Code:
+//
+//	NewRetryer(WithRetryIf(RetryOnGRPCCode(
+//		func(err error) uint32 { return uint32(status.Code(err)) },
+//		GRPCUnavailable, GRPCResourceExhausted)))
+func (r *Retryer) UnaryClientInterceptor(opts ...InterceptorOption) UnaryClientInterceptor {
+	cfg := newInterceptorConfig(opts)
+	return func(ctx context.Context, method string, req, reply any, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error {
+		return cfg.retryer(r, method).Do(ctx, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// StreamClientInterceptor retries opening a stream. Once the stream is
+// returned its messages may already have been sent or received, so failures
+// after that are the caller's to handle, not retried.
+func (r *Retryer) StreamClientInterceptor(opts ...InterceptorOption) StreamClientInterceptor {
+	cfg := newInterceptorConfig(opts)
+	return func(ctx context.Context, desc *StreamDesc, cc *ClientConn, method string, streamer Streamer, opts ...CallOption) (ClientStream, error) {
+		return DoValue(ctx, cfg.retryer(r, method), func(ctx context.Context) (ClientStream, error) {
+			return streamer(ctx, desc, cc, method, opts...)
+		})
+	}
+}
+
+func newInterceptorConfig(opts []InterceptorOption) interceptorConfig {
+	cfg := interceptorConfig{methods: make(map[string]*Retryer)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c interceptorConfig) retryer(def *Retryer, method string) *Retryer {
+	if override, ok := c.methods[method]; ok {
+		return override
+	}
+	return def
+}
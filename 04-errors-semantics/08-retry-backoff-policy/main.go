@@ -4,20 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
-	"math/rand"
+	"math/rand/v2"
 	"net"
-	"sync"
 	"time"
+
+	"circuit-breaker"
 )
 
+// Retryer is immutable once built: every call of Do keeps its timer and
+// random numbers to itself, so one Retryer can be shared by any number of
+// goroutines without contention.
 type Retryer struct {
 	baseDelay   time.Duration
 	maxDelay    time.Duration
 	jitter      time.Duration
+	strategy    JitterStrategy
 	maxAttempts int
-	rand        *rand.Rand
-	mu          sync.Mutex
+	seed        *uint64 // jitter seed for every call; nil draws from math/rand/v2
+	onRetry     func(attempt int, delay time.Duration, err error)
+	budget      *Budget
+	breaker     *circuitbreaker.Breaker
+	name        string
+	metrics     MetricsSink
+	fallback    func(ctx context.Context, lastErr error) error
+	stop        <-chan struct{}
+	classifiers []Classifier
+	store       StateStore
+	logger      *slog.Logger
 }
 
 func NewRetryer(opts ...Options) *Retryer {
@@ -26,7 +41,6 @@ func NewRetryer(opts ...Options) *Retryer {
 		maxDelay:    5 * time.Second,
 		maxAttempts: 3,
 		jitter:      0,
-		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 
 	for _, opt := range opts {
@@ -37,51 +51,212 @@ func NewRetryer(opts ...Options) *Retryer {
 }
 
 func (r *Retryer) Do(ctx context.Context, fn func(ctx2 context.Context) error) error {
+	_, err := DoValue(ctx, r, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoIndexed is Do for functions that change what they do per attempt, such
+// as alternating endpoints. attempt counts from 1, as in Attempt.Number.
+func (r *Retryer) DoIndexed(ctx context.Context, fn func(ctx context.Context, attempt int) error) error {
+	return r.Do(ctx, func(ctx context.Context) error {
+		attempt, _ := AttemptFromContext(ctx)
+		return fn(ctx, attempt.Number)
+	})
+}
+
+// DoValue is Do for functions that produce a value. It returns the value of
+// the first successful attempt, or the zero value with Do's error.
+func DoValue[T any](ctx context.Context, r *Retryer, fn func(ctx context.Context) (T, error)) (T, error) {
+	value, err := doValue(ctx, r, "", fn)
+	if err != nil && r.fallback != nil && gaveUp(err) {
+		return value, r.fallback(ctx, err)
+	}
+	return value, err
+}
+
+// gaveUp reports whether err means the Retryer stopped retrying an error it
+// would otherwise have retried.
+func gaveUp(err error) bool {
+	return errors.Is(err, ErrMaxRetryReached) ||
+		errors.Is(err, ErrRetryBudgetExhausted) ||
+		errors.Is(err, ErrCircuitOpen)
+}
+
+// doValue runs the retry loop. A non-empty key persists its state in the
+// Retryer's StateStore, if it has one.
+func doValue[T any](ctx context.Context, r *Retryer, key string, fn func(ctx context.Context) (T, error)) (_ T, retErr error) {
 	if r.maxAttempts <= 0 {
 		return fn(ctx)
 	}
 
+	var zero T
 	var lastErr error
 	var timer *time.Timer
+	var delay time.Duration
+	report := CallReport{Name: r.name}
+	firstAttempt := time.Now()
+	randN := r.newRand()
+	persist := r.store != nil && key != ""
 	defer func() {
 		if timer != nil {
 			timer.Stop()
 		}
+		if persist && ctx.Err() == nil && !errors.Is(retErr, ErrRetryAborted) {
+			if err := r.store.Delete(ctx, key); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("delete retry state %q: %w", key, err))
+			}
+		}
+		if r.logger != nil && gaveUp(retErr) {
+			r.logger.WarnContext(ctx, "retry gave up",
+				slog.String("operation", r.name),
+				slog.Int("attempts", report.Attempts),
+				slog.String("error", retErr.Error()),
+			)
+		}
+		if r.metrics != nil {
+			report.Err = retErr
+			r.metrics.ReportCall(report)
+		}
 	}()
 
-	for attempt := range r.maxAttempts {
-		lastErr = fn(ctx)
-		if lastErr == nil {
-			return nil
+	start := 0
+	if persist {
+		state, ok, err := r.store.Load(ctx, key)
+		if err != nil {
+			persist = false
+			return zero, fmt.Errorf("load retry state %q: %w", key, err)
+		}
+		if ok {
+			start = min(state.Attempts, r.maxAttempts-1)
+			firstAttempt = state.FirstAttempt
+			if wait := time.Until(state.NextEligible); wait > 0 {
+				if timer, err = r.backoff(ctx, timer, wait); err != nil {
+					return zero, err
+				}
+			}
+		}
+	}
+
+	for attempt := start; attempt < r.maxAttempts; attempt++ {
+		select {
+		case <-r.stop:
+			if lastErr == nil {
+				return zero, ErrRetryAborted
+			}
+			return zero, fmt.Errorf("%w after %d attempts: %w", ErrRetryAborted, attempt, lastErr)
+		default:
 		}
+		done, allowed := r.allowAttempt()
+		if !allowed {
+			if lastErr == nil {
+				return zero, ErrCircuitOpen
+			}
+			return zero, fmt.Errorf("%w after %d attempts: %w", ErrCircuitOpen, attempt, lastErr)
+		}
+
+		value, err := fn(context.WithValue(ctx, attemptKey{}, Attempt{Number: attempt + 1, FirstAttempt: firstAttempt}))
+		report.Attempts++
+		r.recordAttempt(done, err)
+		if err == nil {
+			return value, nil
+		}
+		lastErr = err
 
-		if !r.shouldRetry(lastErr) {
-			return lastErr
+		reason := r.retryReason(lastErr)
+		if reason == "" {
+			return zero, lastErr
 		}
 
 		if attempt < r.maxAttempts-1 {
-			var err error
-			if timer, err = r.backoff(ctx, timer, attempt); err != nil {
-				return err
+			if r.budget != nil && !r.budget.take() {
+				return zero, fmt.Errorf("%w after %d attempts: %w", ErrRetryBudgetExhausted, attempt+1, lastErr)
+			}
+			delay = r.retryDelay(attempt, delay, lastErr, randN)
+			if persist {
+				state := RetryState{Attempts: attempt + 1, FirstAttempt: firstAttempt, NextEligible: time.Now().Add(delay)}
+				if err := r.store.Save(ctx, key, state); err != nil {
+					return zero, fmt.Errorf("save retry state %q: %w", key, err)
+				}
+			}
+			if r.onRetry != nil {
+				r.onRetry(attempt+1, delay, lastErr)
+			}
+			if r.logger != nil {
+				r.logRetry(ctx, attempt+1, reason, delay, lastErr)
+			}
+			report.Backoff += delay
+			if timer, err = r.backoff(ctx, timer, delay); err != nil {
+				if err == ErrRetryAborted {
+					return zero, fmt.Errorf("%w after %d attempts: %w", ErrRetryAborted, attempt+1, lastErr)
+				}
+				return zero, err
 			}
 		}
 	}
 
-	return fmt.Errorf("%w after %d attempts: %w", ErrMaxRetryReached, r.maxAttempts, lastErr)
+	return zero, fmt.Errorf("%w after %d attempts: %w", ErrMaxRetryReached, r.maxAttempts, lastErr)
 }
+
+// allowAttempt asks the breaker, if any, to let an attempt through. done
+// is nil when there is no breaker.
+func (r *Retryer) allowAttempt() (done func(success bool), allowed bool) {
+	if r.breaker == nil {
+		return nil, true
+	}
+	done, err := r.breaker.Allow()
+	return done, err == nil
+}
+
+// recordAttempt reports an attempt the breaker let through. Only transient
+// failures count against downstream: a permanent error is an answer.
+func (r *Retryer) recordAttempt(done func(success bool), err error) {
+	if done != nil {
+		done(err == nil || !r.shouldRetry(err))
+	}
+}
+
 func (r *Retryer) shouldRetry(err error) bool {
+	return r.retryReason(err) != ""
+}
+
+// retryReason says why err is worth retrying, or "" if it is not.
+func (r *Retryer) retryReason(err error) string {
+	var retryable *retryableError
+	if errors.As(err, &retryable) {
+		return "retryable"
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
-		return true
+		return "timeout"
 	}
 	if errors.Is(err, ErrTransient) {
-		return true
+		return "transient"
+	}
+	for _, classify := range r.classifiers {
+		if classify(err) {
+			return "classifier"
+		}
+	}
+	return ""
+}
+
+func (r *Retryer) logRetry(ctx context.Context, attempt int, reason string, delay time.Duration, err error) {
+	attrs := []slog.Attr{
+		slog.String("operation", r.name),
+		slog.Int("attempt", attempt),
+		slog.String("reason", reason),
+		slog.Duration("delay", delay),
+		slog.String("error", err.Error()),
 	}
-	return false
+	if deadline, ok := ctx.Deadline(); ok {
+		attrs = append(attrs, slog.Duration("deadlineRemaining", time.Until(deadline)))
+	}
+	r.logger.LogAttrs(ctx, slog.LevelDebug, "retrying", attrs...)
 }
 
-func (r *Retryer) backoff(ctx context.Context, t *time.Timer, attempt int) (*time.Timer, error) {
-	delay := r.calcBackoffTime(attempt)
+func (r *Retryer) backoff(ctx context.Context, t *time.Timer, delay time.Duration) (*time.Timer, error) {
 	if t == nil {
 		t = time.NewTimer(delay)
 	} else {
@@ -91,6 +266,8 @@ func (r *Retryer) backoff(ctx context.Context, t *time.Timer, attempt int) (*tim
 	select {
 	case <-ctx.Done():
 		return t, ctx.Err()
+	case <-r.stop:
+		return t, ErrRetryAborted
 	case <-t.C:
 		return t, nil
 	}
@@ -106,12 +283,82 @@ func (r *Retryer) resetTimer(t *time.Timer, d time.Duration) {
 	t.Reset(d)
 }
 
-func (r *Retryer) calcBackoffTime(attempt int) time.Duration {
+type attemptKey struct{}
+
+// Attempt identifies one call of the retried function.
+type Attempt struct {
+	Number       int       // counting from 1
+	FirstAttempt time.Time // when the first attempt started
+}
+
+// AttemptFromContext returns the attempt the function called with ctx is
+// making, so it can vary what it does per attempt, for instance switching
+// replica or allowing itself more time. ok is false outside of a Retryer.
+func AttemptFromContext(ctx context.Context) (attempt Attempt, ok bool) {
+	attempt, ok = ctx.Value(attemptKey{}).(Attempt)
+	return attempt, ok
+}
+
+// Retryable marks err as worth retrying even though it is neither a timeout
+// nor ErrTransient, for domain failures the caller knows to be recoverable.
+// The result wraps err, so errors.Is and errors.As still see it. Retryable
+// of nil is nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// DelayHinter is implemented by errors that carry the server's own idea of
+// when to retry, such as an HTTP 429 or 503 with a Retry-After header.
+type DelayHinter interface {
+	RetryAfter() (time.Duration, bool)
+}
+
+// retryDelay is the wait before the attempt after attempt: the error's hint
+// if it has one, else the computed backoff, capped by maxDelay either way.
+// prev is the wait before attempt, zero for the first.
+func (r *Retryer) retryDelay(attempt int, prev time.Duration, err error, randN func(int64) int64) time.Duration {
+	var hinter DelayHinter
+	if errors.As(err, &hinter) {
+		if hint, ok := hinter.RetryAfter(); ok {
+			return min(max(hint, 0), r.maxDelay)
+		}
+	}
+	return r.calcBackoffTime(attempt, prev, randN)
+}
+
+// newRand returns the jitter source of one call of Do.
+func (r *Retryer) newRand() func(n int64) int64 {
+	if r.seed == nil {
+		return rand.Int64N
+	}
+	return rand.New(rand.NewPCG(*r.seed, 0)).Int64N
+}
+
+func (r *Retryer) calcBackoffTime(attempt int, prev time.Duration, randN func(int64) int64) time.Duration {
 	backOff := r.baseDelay * time.Duration(math.Pow(2, float64(attempt)))
-	if r.jitter > 0 {
-		r.mu.Lock()
-		backOff = backOff + time.Duration(r.rand.Int63n(int64(r.jitter)))
-		r.mu.Unlock()
+	switch r.strategy {
+	case FullJitter:
+		backOff = randDuration(min(backOff, r.maxDelay), randN)
+	case EqualJitter:
+		half := min(backOff, r.maxDelay) / 2
+		backOff = half + randDuration(half, randN)
+	case DecorrelatedJitter:
+		prev = max(prev, r.baseDelay)
+		backOff = r.baseDelay + randDuration(3*prev-r.baseDelay, randN)
+	default:
+		if r.jitter > 0 {
+			backOff = backOff + time.Duration(randN(int64(r.jitter)))
+		}
 	}
 
 	if backOff > r.maxDelay {
@@ -120,6 +367,32 @@ func (r *Retryer) calcBackoffTime(attempt int) time.Duration {
 	return backOff
 }
 
+// randDuration is uniform in [0, d), or 0 when d is not positive.
+func randDuration(d time.Duration, randN func(int64) int64) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(randN(int64(d)))
+}
+
+// JitterStrategy decides how randomness spreads the retries of clients that
+// failed together, so they do not all come back at the same instant.
+type JitterStrategy int
+
+const (
+	// AdditiveJitter waits the exponential backoff plus up to WithJitter. It
+	// is the default, and barely decorrelates clients once backoffs grow.
+	AdditiveJitter JitterStrategy = iota
+	// FullJitter waits anywhere from zero to the exponential backoff.
+	FullJitter
+	// EqualJitter waits at least half the exponential backoff and at most
+	// all of it, trading some spread for a guaranteed pause.
+	EqualJitter
+	// DecorrelatedJitter waits between the base delay and three times the
+	// previous wait, so each client's schedule drifts away from the others'.
+	DecorrelatedJitter
+)
+
 type Options func(retryer *Retryer)
 
 func WithBaseDelay(delay time.Duration) Options {
@@ -146,13 +419,122 @@ func WithMaxAttempts(attempts int) Options {
 	}
 }
 
-func WithRandSource(source rand.Source) Options {
+// WithOnRetry calls fn before each wait between attempts with the number of
+// the attempt that just failed, counting from 1, the delay before the next
+// one and the error that caused the retry. fn runs on the caller's
+// goroutine and delays the retry by as long as it takes.
+func WithOnRetry(fn func(attempt int, delay time.Duration, err error)) Options {
+	return func(retryer *Retryer) {
+		retryer.onRetry = fn
+	}
+}
+
+// WithBudget makes every retry draw from b, which may be shared with other
+// Retryers. Once it is exhausted Do stops retrying and fails with
+// ErrRetryBudgetExhausted.
+func WithBudget(b *Budget) Options {
+	return func(retryer *Retryer) {
+		retryer.budget = b
+	}
+}
+
+// WithCircuitBreaker consults cb before every attempt, failing with
+// ErrCircuitOpen when it refuses, and reports each attempt's outcome to it.
+// Share one breaker between the Retryers calling the same downstream.
+func WithCircuitBreaker(cb *circuitbreaker.Breaker) Options {
 	return func(retryer *Retryer) {
-		retryer.rand = rand.New(source)
+		retryer.breaker = cb
+	}
+}
+
+// WithFallback runs fn when Do gives up on a transient failure, because
+// attempts, the retry budget or the circuit breaker ran out, and returns its
+// result instead, so the degraded path, such as serving cached data or
+// queueing the work for later, is defined once next to the policy. lastErr
+// is the error Do would have returned. Permanent errors and cancellation
+// are returned as they are. DoValue returns the zero value when fn
+// succeeds.
+func WithFallback(fn func(ctx context.Context, lastErr error) error) Options {
+	return func(retryer *Retryer) {
+		retryer.fallback = fn
+	}
+}
+
+// WithRetryIf also retries the errors any of classifiers accepts, such as
+// RetryOnHTTPStatus(http.StatusTooManyRequests). It adds to the classifiers
+// of earlier calls.
+func WithRetryIf(classifiers ...Classifier) Options {
+	return func(retryer *Retryer) {
+		retryer.classifiers = append(retryer.classifiers, classifiers...)
+	}
+}
+
+// WithStateStore persists the schedules of DoKeyed calls in store. Do and
+// DoValue are not keyed and do not use it.
+func WithStateStore(store StateStore) Options {
+	return func(retryer *Retryer) {
+		retryer.store = store
+	}
+}
+
+// WithLogger logs every retry at debug level, with the attempt that failed,
+// why its error was retried, the delay and the time left before ctx's
+// deadline, and a warning when the Retryer gives up. The Retryer logs
+// nothing without one.
+func WithLogger(logger *slog.Logger) Options {
+	return func(retryer *Retryer) {
+		if logger != nil {
+			retryer.logger = logger
+		}
+	}
+}
+
+// WithStopChan halts every call of Do as soon as stop is closed, whatever
+// its context: a call waiting to retry returns at once and no call starts
+// another attempt, failing with ErrRetryAborted instead. An attempt already
+// running is left to finish. Close stop on shutdown or when an operator
+// wants to stop hammering a downstream.
+func WithStopChan(stop <-chan struct{}) Options {
+	return func(retryer *Retryer) {
+		retryer.stop = stop
+	}
+}
+
+// WithName names the operation the Retryer guards in its metrics.
+func WithName(name string) Options {
+	return func(retryer *Retryer) {
+		retryer.name = name
+	}
+}
+
+// WithMetrics reports every call to sink once it returns.
+func WithMetrics(sink MetricsSink) Options {
+	return func(retryer *Retryer) {
+		retryer.metrics = sink
+	}
+}
+
+// WithJitterStrategy picks how delays are randomised. Every strategy but
+// AdditiveJitter ignores WithJitter, and all of them stay within maxDelay.
+func WithJitterStrategy(strategy JitterStrategy) Options {
+	return func(retryer *Retryer) {
+		retryer.strategy = strategy
+	}
+}
+
+// WithJitterSeed makes jitter deterministic: every call of Do draws the
+// same sequence, from its own generator seeded with seed.
+func WithJitterSeed(seed uint64) Options {
+	return func(retryer *Retryer) {
+		retryer.seed = &seed
 	}
 }
 
 var (
 	ErrMaxRetryReached = errors.New("max retry reached")
 	ErrTransient       = errors.New("transient error")
+
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+	ErrCircuitOpen          = errors.New("circuit breaker open")
+	ErrRetryAborted         = errors.New("retry aborted")
 )
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"math/rand"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	"circuit-breaker"
 )
 
 type mockNetError struct {
@@ -144,30 +152,868 @@ func TestRetryer_Do(t *testing.T) {
 
 	t.Run("DeterministicJitter", func(t *testing.T) {
 		// Use a fixed seed for deterministic jitter
-		source := rand.NewSource(42)
+		var delays []time.Duration
 		r := NewRetryer(
-			WithMaxAttempts(2),
+			WithMaxAttempts(3),
 			WithBaseDelay(10*time.Millisecond),
 			WithJitter(5*time.Millisecond),
-			WithRandSource(source),
+			WithJitterSeed(42),
+			WithOnRetry(func(attempt int, delay time.Duration, err error) {
+				delays = append(delays, delay)
+			}),
 		)
 
-		// Verification is tricky because calcBackoffTime is private,
-		// but we can trust the implementation if we inject the source correctly.
-		// For now we just ensure it doesn't crash and follows the flow.
+		for range 2 {
+			err := r.Do(context.Background(), func(ctx context.Context) error {
+				return ErrTransient
+			})
+			if !errors.Is(err, ErrMaxRetryReached) {
+				t.Errorf("expected ErrMaxRetryReached, got %v", err)
+			}
+		}
+		if len(delays) != 4 || delays[0] != delays[2] || delays[1] != delays[3] {
+			t.Errorf("expected every call to draw the same jitter, got %v", delays)
+		}
+		for i, delay := range delays {
+			base := 10 * time.Millisecond << (i % 2)
+			if delay < base || delay >= base+5*time.Millisecond {
+				t.Errorf("delay %d: expected within [%v, %v), got %v", i, base, base+5*time.Millisecond, delay)
+			}
+		}
+	})
+}
+
+func TestWithJitterStrategy(t *testing.T) {
+	const base, maxDelay = 1 * time.Millisecond, 8 * time.Millisecond
+	retryDelays := func(strategy JitterStrategy) []time.Duration {
+		var delays []time.Duration
+		r := NewRetryer(
+			WithMaxAttempts(7),
+			WithBaseDelay(base),
+			WithMaxDelay(maxDelay),
+			WithJitterStrategy(strategy),
+			WithJitterSeed(7),
+			WithOnRetry(func(attempt int, delay time.Duration, err error) {
+				delays = append(delays, delay)
+			}),
+		)
 		err := r.Do(context.Background(), func(ctx context.Context) error {
 			return ErrTransient
 		})
+		if !errors.Is(err, ErrMaxRetryReached) {
+			t.Fatalf("expected ErrMaxRetryReached, got %v", err)
+		}
+		return delays
+	}
+	exponential := func(i int) time.Duration {
+		return min(base<<i, maxDelay)
+	}
+
+	t.Run("FullJitter", func(t *testing.T) {
+		for i, delay := range retryDelays(FullJitter) {
+			if delay < 0 || delay >= exponential(i) {
+				t.Errorf("delay %d: expected within [0, %v), got %v", i, exponential(i), delay)
+			}
+		}
+	})
+
+	t.Run("EqualJitter", func(t *testing.T) {
+		for i, delay := range retryDelays(EqualJitter) {
+			if delay < exponential(i)/2 || delay >= exponential(i) {
+				t.Errorf("delay %d: expected within [%v, %v), got %v", i, exponential(i)/2, exponential(i), delay)
+			}
+		}
+	})
+
+	t.Run("DecorrelatedJitter", func(t *testing.T) {
+		prev := base
+		for i, delay := range retryDelays(DecorrelatedJitter) {
+			if delay < base || delay >= min(3*prev, maxDelay+1) {
+				t.Errorf("delay %d: expected within [%v, %v), got %v", i, base, 3*prev, delay)
+			}
+			prev = delay
+		}
+	})
+}
+
+func TestWithOnRetry(t *testing.T) {
+	type retry struct {
+		attempt int
+		delay   time.Duration
+		err     error
+	}
+	var retries []retry
+	r := NewRetryer(
+		WithMaxAttempts(3),
+		WithBaseDelay(1*time.Millisecond),
+		WithOnRetry(func(attempt int, delay time.Duration, err error) {
+			retries = append(retries, retry{attempt, delay, err})
+		}),
+	)
+
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		return ErrTransient
+	})
+	if !errors.Is(err, ErrMaxRetryReached) {
+		t.Fatalf("expected ErrMaxRetryReached, got %v", err)
+	}
+
+	want := []retry{
+		{1, 1 * time.Millisecond, ErrTransient},
+		{2, 2 * time.Millisecond, ErrTransient},
+	}
+	if len(retries) != len(want) {
+		t.Fatalf("expected %d hook calls, got %d", len(want), len(retries))
+	}
+	for i := range want {
+		if retries[i] != want[i] {
+			t.Errorf("call %d: expected %+v, got %+v", i, want[i], retries[i])
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	now := time.Unix(0, 0)
+	budget := NewBudget(2, time.Second, WithBudgetClock(func() time.Time { return now }))
+	a := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond), WithBudget(budget))
+	b := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond), WithBudget(budget))
+	failing := func(calls *int) func(context.Context) error {
+		return func(ctx context.Context) error {
+			*calls++
+			return ErrTransient
+		}
+	}
+
+	calls := 0
+	if err := a.Do(context.Background(), failing(&calls)); !errors.Is(err, ErrMaxRetryReached) {
+		t.Fatalf("expected the first call to use the budget fully, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	calls = 0
+	err := b.Do(context.Background(), failing(&calls))
+	if !errors.Is(err, ErrRetryBudgetExhausted) || !errors.Is(err, ErrTransient) {
+		t.Fatalf("expected ErrRetryBudgetExhausted wrapping ErrTransient, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no retries once the budget is spent, got %d calls", calls)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	calls = 0
+	if err := b.Do(context.Background(), failing(&calls)); !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("expected the refilled retry to be spent, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected one retry after half a window, got %d calls", calls)
+	}
+}
+
+// retryAfterError is a transient error with a server-suggested delay.
+type retryAfterError struct {
+	after time.Duration
+	ok    bool
+}
+
+func (e *retryAfterError) Error() string                     { return "429 too many requests" }
+func (e *retryAfterError) Unwrap() error                     { return ErrTransient }
+func (e *retryAfterError) RetryAfter() (time.Duration, bool) { return e.after, e.ok }
+
+func TestDelayHinter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"HintUsed", &retryAfterError{after: 3 * time.Millisecond, ok: true}, 3 * time.Millisecond},
+		{"HintCappedByMaxDelay", fmt.Errorf("charge: %w", &retryAfterError{after: time.Hour, ok: true}), 5 * time.Millisecond},
+		{"NoHintFallsBackToBackoff", &retryAfterError{ok: false}, 1 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delays []time.Duration
+			r := NewRetryer(
+				WithMaxAttempts(2),
+				WithBaseDelay(1*time.Millisecond),
+				WithMaxDelay(5*time.Millisecond),
+				WithOnRetry(func(attempt int, delay time.Duration, err error) {
+					delays = append(delays, delay)
+				}),
+			)
+			_ = r.Do(context.Background(), func(ctx context.Context) error {
+				return tt.err
+			})
+			if len(delays) != 1 || delays[0] != tt.want {
+				t.Errorf("expected delay %v, got %v", tt.want, delays)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	cb := circuitbreaker.New(
+		circuitbreaker.WithFailureThreshold(1, 3),
+		circuitbreaker.WithCooldown(time.Second),
+		circuitbreaker.WithClock(func() time.Time { return now }),
+	)
+	newRetryer := func() *Retryer {
+		return NewRetryer(WithMaxAttempts(5), WithBaseDelay(1*time.Millisecond), WithCircuitBreaker(cb))
+	}
+	calls := 0
+	down := func(ctx context.Context) error {
+		calls++
+		return ErrTransient
+	}
+
+	err := newRetryer().Do(context.Background(), down)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrTransient) {
+		t.Fatalf("expected ErrCircuitOpen wrapping the last failure, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected the breaker to open after 3 failures, got %d calls", calls)
+	}
+
+	calls = 0
+	if err := newRetryer().Do(context.Background(), down); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected other callers to fail fast, got %d calls", calls)
+	}
+
+	now = now.Add(time.Second)
+	calls = 0
+	if err := newRetryer().Do(context.Background(), down); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the failed trial to reopen the breaker, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected a single trial attempt, got %d calls", calls)
+	}
+
+	now = now.Add(time.Second)
+	calls = 0
+	err = newRetryer().Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return nil
+		}
+		return ErrTransient
+	})
+	if err != nil {
+		t.Fatalf("expected the trial to succeed, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Error("expected a successful trial to close the breaker")
+	}
+}
+
+func TestCircuitBreaker_PermanentErrorsDoNotTrip(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.WithFailureThreshold(1, 1), circuitbreaker.WithCooldown(time.Hour))
+	r := NewRetryer(WithMaxAttempts(3), WithCircuitBreaker(cb))
+	errFatal := errors.New("fatal error")
+
+	for range 3 {
+		if err := r.Do(context.Background(), func(ctx context.Context) error { return errFatal }); !errors.Is(err, errFatal) {
+			t.Fatalf("expected errFatal, got %v", err)
+		}
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Error("expected permanent errors to leave the breaker closed")
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewRetryer(WithMaxAttempts(2), WithBaseDelay(1*time.Millisecond), WithName("charge"), WithLogger(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r.Do(ctx, func(ctx context.Context) error {
+		return ErrTransient
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %q", buf.String())
+	}
+	for _, want := range []string{"level=DEBUG", "msg=retrying", "operation=charge", "attempt=1", "reason=transient", "delay=1ms", "deadlineRemaining="} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected retry record to contain %q, got %q", want, lines[0])
+		}
+	}
+	for _, want := range []string{"level=WARN", `msg="retry gave up"`, "attempts=2"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("expected give-up record to contain %q, got %q", want, lines[1])
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	r := NewRetryer(
+		WithName("charge-card"),
+		WithMetrics(metrics),
+		WithMaxAttempts(3),
+		WithBaseDelay(1*time.Millisecond),
+	)
+	errFatal := errors.New("card declined")
+
+	_ = r.Do(context.Background(), func(ctx context.Context) error { return nil })
+	calls := 0
+	_ = r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return ErrTransient
+		}
+		return nil
+	})
+	_ = r.Do(context.Background(), func(ctx context.Context) error { return errFatal })
+	_ = r.Do(context.Background(), func(ctx context.Context) error { return ErrTransient })
+
+	want := OperationStats{
+		Calls:               4,
+		Attempts:            1 + 3 + 1 + 3,
+		SucceededFirstTry:   1,
+		SucceededAfterRetry: 1,
+		Failed:              2,
+		Backoff:             2 * (1*time.Millisecond + 2*time.Millisecond),
+	}
+	if got := metrics.Stats("charge-card"); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := metrics.Stats("other"); got != (OperationStats{}) {
+		t.Errorf("expected no stats for an unknown name, got %+v", got)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	errLocked := errors.New("account locked by another transaction")
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("debit: %w", Retryable(errLocked))
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third call, got %v after %d calls", err, calls)
+	}
+
+	err = r.Do(context.Background(), func(ctx context.Context) error {
+		return Retryable(errLocked)
+	})
+	if !errors.Is(err, ErrMaxRetryReached) || !errors.Is(err, errLocked) {
+		t.Errorf("expected ErrMaxRetryReached wrapping errLocked, got %v", err)
+	}
+	if err := Retryable(nil); err != nil {
+		t.Errorf("expected Retryable(nil) to be nil, got %v", err)
+	}
+}
+
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+type grpcError uint32
+
+func (e grpcError) Error() string    { return fmt.Sprintf("rpc error: code = %d", uint32(e)) }
+func (e grpcError) GRPCCode() uint32 { return uint32(e) }
+
+// grpcCode stands in for status.Code.
+func grpcCode(err error) uint32 {
+	var code interface{ GRPCCode() uint32 }
+	if errors.As(err, &code) {
+		return code.GRPCCode()
+	}
+	return 0
+}
+
+func TestWithRetryIf(t *testing.T) {
+	r := NewRetryer(
+		WithMaxAttempts(2),
+		WithBaseDelay(1*time.Millisecond),
+		WithRetryIf(RetryOnHTTPStatus(429, 503), RetryOnGRPCCode(grpcCode, 14)),
+		WithRetryIf(RetryOnConnectionError),
+	)
+
+	tests := []struct {
+		name  string
+		err   error
+		retry bool
+	}{
+		{"HTTPTooManyRequests", fmt.Errorf("get: %w", statusError(429)), true},
+		{"HTTPBadRequest", statusError(400), false},
+		{"GRPCUnavailable", grpcError(14), true},
+		{"GRPCInvalidArgument", grpcError(3), false},
+		{"ConnectionRefused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"DNSTemporary", &net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+		{"DNSNotFound", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"Other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			r.Do(context.Background(), func(ctx context.Context) error {
+				calls++
+				return tt.err
+			})
+			if retried := calls > 1; retried != tt.retry {
+				t.Errorf("expected retry %v, got %v", tt.retry, retried)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	retryOnCodes := WithRetryIf(RetryOnGRPCCode(grpcCode, GRPCUnavailable, GRPCResourceExhausted))
+	r := NewRetryer(WithMaxAttempts(4), WithBaseDelay(time.Millisecond), retryOnCodes)
+	noRetry := NewRetryer(WithMaxAttempts(1))
+	twice := NewRetryer(WithMaxAttempts(2), WithBaseDelay(time.Millisecond), retryOnCodes)
+	interceptor := r.UnaryClientInterceptor(
+		WithMethodRetryer("/orders.v1.Orders/Create", noRetry),
+		WithMethodRetryer("/orders.v1.Orders/Watch", twice),
+	)
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		calls  int
+	}{
+		{"RetriesUnavailable", "/orders.v1.Orders/Get", grpcError(GRPCUnavailable), 4},
+		{"RetriesResourceExhausted", "/orders.v1.Orders/Get", grpcError(GRPCResourceExhausted), 4},
+		{"PermanentCode", "/orders.v1.Orders/Get", grpcError(3), 1},
+		{"OverrideWithoutRetries", "/orders.v1.Orders/Create", grpcError(GRPCUnavailable), 1},
+		{"OverrideWithFewerAttempts", "/orders.v1.Orders/Watch", grpcError(GRPCUnavailable), 2},
+		{"Success", "/orders.v1.Orders/Get", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var gotMethod string
+			var gotOpts []CallOption
+			invoker := func(ctx context.Context, method string, req, reply any, cc *ClientConn, opts ...CallOption) error {
+				calls++
+				gotMethod, gotOpts = method, opts
+				return tt.err
+			}
+			err := interceptor(context.Background(), tt.method, "req", nil, &ClientConn{}, invoker, "opt")
+			if calls != tt.calls {
+				t.Errorf("expected %d calls, got %d", tt.calls, calls)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+			if gotMethod != tt.method || !slices.Equal(gotOpts, []CallOption{"opt"}) {
+				t.Errorf("expected the call to be passed through, got %q %v", gotMethod, gotOpts)
+			}
+		})
+	}
+}
+
+type fakeClientStream struct{ ctx context.Context }
+
+func (s *fakeClientStream) Context() context.Context { return s.ctx }
+func (s *fakeClientStream) SendMsg(any) error        { return nil }
+func (s *fakeClientStream) RecvMsg(any) error        { return nil }
+func (s *fakeClientStream) CloseSend() error         { return nil }
+
+func TestStreamClientInterceptor(t *testing.T) {
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(time.Millisecond),
+		WithRetryIf(RetryOnGRPCCode(grpcCode, GRPCUnavailable)))
+	interceptor := r.StreamClientInterceptor(
+		WithMethodRetryer("/orders.v1.Orders/Upload", NewRetryer(WithMaxAttempts(1))))
+	desc := &StreamDesc{StreamName: "Watch", ServerStreams: true}
+
+	calls := 0
+	streamer := func(ctx context.Context, d *StreamDesc, cc *ClientConn, method string, opts ...CallOption) (ClientStream, error) {
+		calls++
+		if d != desc {
+			t.Errorf("expected the stream description to be passed through")
+		}
+		if calls < 3 {
+			return nil, grpcError(GRPCUnavailable)
+		}
+		return &fakeClientStream{ctx: ctx}, nil
+	}
+	stream, err := interceptor(context.Background(), desc, &ClientConn{}, "/orders.v1.Orders/Watch", streamer)
+	if err != nil || stream == nil {
+		t.Fatalf("expected the stream to open on the third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	calls = 0
+	if _, err := interceptor(context.Background(), desc, &ClientConn{}, "/orders.v1.Orders/Upload", streamer); !errors.Is(err, grpcError(GRPCUnavailable)) {
+		t.Errorf("expected the override to give up at once, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call with the override, got %d", calls)
+	}
+}
+
+func TestAttemptFromContext(t *testing.T) {
+	if _, ok := AttemptFromContext(context.Background()); ok {
+		t.Fatal("expected no attempt outside of a Retryer")
+	}
+
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+	var attempts []Attempt
+	start := time.Now()
+	_ = r.Do(context.Background(), func(ctx context.Context) error {
+		attempt, ok := AttemptFromContext(ctx)
+		if !ok {
+			t.Fatal("expected an attempt in the context")
+		}
+		attempts = append(attempts, attempt)
+		return ErrTransient
+	})
+
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	for i, attempt := range attempts {
+		if attempt.Number != i+1 {
+			t.Errorf("expected attempt %d, got %d", i+1, attempt.Number)
+		}
+		if attempt.FirstAttempt != attempts[0].FirstAttempt || attempt.FirstAttempt.Before(start) {
+			t.Errorf("expected every attempt to share the first attempt's start, got %v", attempt.FirstAttempt)
+		}
+	}
+}
+
+func TestDoIndexed(t *testing.T) {
+	endpoints := []string{"primary", "replica-a", "replica-b"}
+	var tried []string
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+
+	err := r.DoIndexed(context.Background(), func(ctx context.Context, attempt int) error {
+		endpoint := endpoints[(attempt-1)%len(endpoints)]
+		tried = append(tried, endpoint)
+		if endpoint != "replica-b" {
+			return ErrTransient
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !slices.Equal(tried, endpoints) {
+		t.Errorf("expected endpoints tried in turn, got %v", tried)
+	}
+}
+
+func TestWithStopChan(t *testing.T) {
+	t.Run("InterruptsBackoff", func(t *testing.T) {
+		stop := make(chan struct{})
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(time.Hour), WithMaxDelay(time.Hour), WithStopChan(stop))
+
+		done := make(chan error, 1)
+		go func() {
+			done <- r.Do(context.Background(), func(ctx context.Context) error {
+				return ErrTransient
+			})
+		}()
+		time.Sleep(10 * time.Millisecond)
+		close(stop)
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrRetryAborted) || !errors.Is(err, ErrTransient) {
+				t.Errorf("expected ErrRetryAborted wrapping ErrTransient, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Do did not return after stop was closed")
+		}
+	})
+
+	t.Run("NoAttemptOnceStopped", func(t *testing.T) {
+		stop := make(chan struct{})
+		close(stop)
+		r := NewRetryer(WithStopChan(stop))
+
+		calls := 0
+		err := r.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return nil
+		})
+		if !errors.Is(err, ErrRetryAborted) {
+			t.Errorf("expected ErrRetryAborted, got %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("expected no attempt, got %d", calls)
+		}
+	})
+}
+
+func TestWithFallback(t *testing.T) {
+	var fellBack []error
+	r := NewRetryer(
+		WithMaxAttempts(2),
+		WithBaseDelay(1*time.Millisecond),
+		WithFallback(func(ctx context.Context, lastErr error) error {
+			fellBack = append(fellBack, lastErr)
+			return nil
+		}),
+	)
+
+	if err := r.Do(context.Background(), func(ctx context.Context) error { return ErrTransient }); err != nil {
+		t.Fatalf("expected the fallback to recover, got %v", err)
+	}
+	if len(fellBack) != 1 || !errors.Is(fellBack[0], ErrMaxRetryReached) {
+		t.Fatalf("expected the fallback to see ErrMaxRetryReached, got %v", fellBack)
+	}
+
+	errFatal := errors.New("fatal error")
+	if err := r.Do(context.Background(), func(ctx context.Context) error { return errFatal }); !errors.Is(err, errFatal) {
+		t.Errorf("expected permanent errors to skip the fallback, got %v", err)
+	}
+	if len(fellBack) != 1 {
+		t.Errorf("expected no fallback for permanent errors, got %v", fellBack)
+	}
+
+	errQueueFull := errors.New("queue full")
+	open := circuitbreaker.New(circuitbreaker.WithFailureThreshold(1, 1), circuitbreaker.WithCooldown(time.Hour))
+	if err := open.Execute(func() error { return ErrTransient }); !errors.Is(err, ErrTransient) {
+		t.Fatalf("expected ErrTransient, got %v", err)
+	}
+	r = NewRetryer(WithCircuitBreaker(open), WithFallback(func(ctx context.Context, lastErr error) error {
+		return fmt.Errorf("enqueue: %w", errQueueFull)
+	}))
+	if err := r.Do(context.Background(), func(ctx context.Context) error { return nil }); !errors.Is(err, errQueueFull) {
+		t.Errorf("expected the fallback's error, got %v", err)
+	}
+}
+
+func TestDoValue(t *testing.T) {
+	t.Run("ReturnsValueAfterRetries", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+		calls := 0
+		got, err := DoValue(context.Background(), r, func(ctx context.Context) (string, error) {
+			calls++
+			if calls < 3 {
+				return "partial", ErrTransient
+			}
+			return "done", nil
+		})
+		if err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+		if got != "done" || calls != 3 {
+			t.Errorf("expected \"done\" after 3 calls, got %q after %d", got, calls)
+		}
+	})
+
+	t.Run("ZeroValueOnFailure", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(2), WithBaseDelay(1*time.Millisecond))
+		got, err := DoValue(context.Background(), r, func(ctx context.Context) (int, error) {
+			return 42, ErrTransient
+		})
 		if !errors.Is(err, ErrMaxRetryReached) {
 			t.Errorf("expected ErrMaxRetryReached, got %v", err)
 		}
+		if got != 0 {
+			t.Errorf("expected zero value, got %d", got)
+		}
+	})
+}
+
+func TestDoChan(t *testing.T) {
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+
+	var calls atomic.Int32
+	ch := r.DoChan(context.Background(), func(ctx context.Context) error {
+		if calls.Add(1) < 2 {
+			return ErrTransient
+		}
+		return nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			t.Errorf("expected success, got %v", res.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DoChan did not deliver a result")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed after the result")
+	}
+}
+
+func TestDoAsync(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+		f := DoAsync(context.Background(), r, func(ctx context.Context) (int, error) {
+			if attempt, _ := AttemptFromContext(ctx); attempt.Number < 2 {
+				return 0, ErrTransient
+			}
+			return 42, nil
+		})
+
+		value, err := f.Get()
+		if err != nil || value != 42 {
+			t.Errorf("expected 42, got %d, %v", value, err)
+		}
+		if !f.IsDone() {
+			t.Error("expected the future to be done after Get")
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(time.Hour), WithMaxDelay(time.Hour))
+		f := DoAsync(context.Background(), r, func(ctx context.Context) (int, error) {
+			return 0, ErrTransient
+		})
+		if f.IsDone() {
+			t.Fatal("expected the future to wait for its retry")
+		}
+		f.Cancel()
+
+		select {
+		case <-f.Done():
+		case <-time.After(time.Second):
+			t.Fatal("future did not finish after Cancel")
+		}
+		if _, err := f.Get(); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestDoBatch(t *testing.T) {
+	errBadItem := errors.New("bad item")
+
+	t.Run("RetriesOnlyFailedItems", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond))
+		calls := make(map[string]int)
+		errs := DoBatch(context.Background(), r, []string{"ok", "flaky", "bad"}, func(ctx context.Context, item string) error {
+			calls[item]++
+			switch {
+			case item == "flaky" && calls[item] < 3:
+				return ErrTransient
+			case item == "bad":
+				return errBadItem
+			}
+			return nil
+		})
+
+		if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], errBadItem) {
+			t.Errorf("expected [nil nil %v], got %v", errBadItem, errs)
+		}
+		want := map[string]int{"ok": 1, "flaky": 3, "bad": 1}
+		for item, n := range want {
+			if calls[item] != n {
+				t.Errorf("%s: expected %d calls, got %d", item, n, calls[item])
+			}
+		}
+	})
+
+	t.Run("GivesUpPerItem", func(t *testing.T) {
+		r := NewRetryer(WithMaxAttempts(2), WithBaseDelay(1*time.Millisecond))
+		errs := DoBatch(context.Background(), r, []int{1, 2}, func(ctx context.Context, item int) error {
+			if item == 2 {
+				return fmt.Errorf("item %d: %w", item, ErrTransient)
+			}
+			return nil
+		})
+
+		if errs[0] != nil {
+			t.Errorf("item 1: expected success, got %v", errs[0])
+		}
+		expectedMsg := "max retry reached after 2 attempts: item 2: transient error"
+		if !errors.Is(errs[1], ErrMaxRetryReached) || errs[1].Error() != expectedMsg {
+			t.Errorf("item 2: expected %q, got %v", expectedMsg, errs[1])
+		}
+	})
+
+	t.Run("ContextCanceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(time.Hour), WithMaxDelay(time.Hour))
+		errs := DoBatch(ctx, r, []int{1}, func(ctx context.Context, item int) error {
+			cancel()
+			return ErrTransient
+		})
+
+		if !errors.Is(errs[0], context.Canceled) || !errors.Is(errs[0], ErrTransient) {
+			t.Errorf("expected context.Canceled wrapping ErrTransient, got %v", errs[0])
+		}
+	})
+}
+
+func TestDoKeyed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ResumesSavedSchedule", func(t *testing.T) {
+		store := NewFileStateStore(t.TempDir())
+		first := time.Now().Add(-time.Hour)
+		// The store keeps wall-clock times only, so compare on the wall clock.
+		nextEligible := time.Now().Add(20 * time.Millisecond).Round(0)
+		if err := store.Save(ctx, "reconcile/42", RetryState{Attempts: 2, FirstAttempt: first, NextEligible: nextEligible}); err != nil {
+			t.Fatal(err)
+		}
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(1*time.Millisecond), WithStateStore(store))
+
+		var attempts []Attempt
+		var attemptedAt time.Time
+		err := r.DoKeyed(ctx, "reconcile/42", func(ctx context.Context) error {
+			attemptedAt = time.Now().Round(0)
+			attempt, _ := AttemptFromContext(ctx)
+			attempts = append(attempts, attempt)
+			return ErrTransient
+		})
+
+		if !errors.Is(err, ErrMaxRetryReached) {
+			t.Errorf("expected ErrMaxRetryReached, got %v", err)
+		}
+		if attemptedAt.Before(nextEligible) {
+			t.Errorf("expected to wait for the saved next eligible time, attempted %v early", nextEligible.Sub(attemptedAt))
+		}
+		if len(attempts) != 1 || attempts[0].Number != 3 || !attempts[0].FirstAttempt.Equal(first) {
+			t.Errorf("expected only attempt 3 of the saved schedule, got %+v", attempts)
+		}
+		if _, ok, err := store.Load(ctx, "reconcile/42"); ok || err != nil {
+			t.Errorf("expected the state to be deleted, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("KeepsStateOnCancel", func(t *testing.T) {
+		store := NewFileStateStore(t.TempDir())
+		r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(time.Hour), WithMaxDelay(time.Hour), WithStateStore(store))
+
+		ctx, cancel := context.WithCancel(ctx)
+		err := r.DoKeyed(ctx, "reconcile/42", func(ctx context.Context) error {
+			cancel()
+			return ErrTransient
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+
+		state, ok, err := store.Load(context.Background(), "reconcile/42")
+		if err != nil || !ok {
+			t.Fatalf("expected saved state, got ok=%v err=%v", ok, err)
+		}
+		if state.Attempts != 1 || time.Until(state.NextEligible) < 59*time.Minute {
+			t.Errorf("expected 1 attempt and the next in an hour, got %+v", state)
+		}
 	})
 }
 
 func TestRetryer_Concurrency(t *testing.T) {
-	// This test checks if multiple goroutines can use the same Retryer
-	// Current implementation uses a shared timer, so this should fail or race
-	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(10*time.Millisecond))
+	// This test checks if multiple goroutines can use the same Retryer,
+	// jitter included, without racing
+	r := NewRetryer(WithMaxAttempts(3), WithBaseDelay(10*time.Millisecond), WithJitter(5*time.Millisecond))
 	var wg sync.WaitGroup
 	var errorCount int32
 
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// CallReport describes one call of Do from start to finish.
+type CallReport struct {
+	Name     string        // set with WithName
+	Attempts int           // how many times fn ran
+	Backoff  time.Duration // total delay scheduled between attempts
+	Err      error         // what Do returned
+}
+
+// MetricsSink receives a CallReport for every call of Do.
+type MetricsSink interface {
+	ReportCall(report CallReport)
+}
+
+// OperationStats aggregates the calls of one named operation. A service
+// that only succeeds after retries shows a growing SucceededAfterRetry and
+// Backoff long before Failed moves.
+type OperationStats struct {
+	Calls               int
+	Attempts            int
+	SucceededFirstTry   int
+	SucceededAfterRetry int
+	Failed              int
+	Backoff             time.Duration
+}
+
+// Metrics is an in-memory MetricsSink keeping OperationStats per name, to
+// be exported to whatever the service uses for dashboards. It is safe for
+// concurrent use.
+type Metrics struct {
+	mu  sync.Mutex
+	ops map[string]*OperationStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[string]*OperationStats)}
+}
+
+func (m *Metrics) ReportCall(report CallReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.ops[report.Name]
+	if !ok {
+		stats = &OperationStats{}
+		m.ops[report.Name] = stats
+	}
+
+	stats.Calls++
+	stats.Attempts += report.Attempts
+	stats.Backoff += report.Backoff
+	switch {
+	case report.Err != nil:
+		stats.Failed++
+	case report.Attempts > 1:
+		stats.SucceededAfterRetry++
+	default:
+		stats.SucceededFirstTry++
+	}
+}
+
+// Stats returns a copy of name's stats so far.
+func (m *Metrics) Stats(name string) OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stats, ok := m.ops[name]; ok {
+		return *stats
+	}
+	return OperationStats{}
+}
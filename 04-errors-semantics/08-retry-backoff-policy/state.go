@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RetryState is where a keyed call of Do stands between attempts.
+type RetryState struct {
+	Attempts     int       `json:"attempts"`      // attempts made so far
+	FirstAttempt time.Time `json:"first_attempt"` // when the first attempt started
+	NextEligible time.Time `json:"next_eligible"` // no attempt before then
+}
+
+// StateStore persists RetryState per operation key, so a retry schedule
+// spanning hours survives a restart of the process driving it, such as a
+// background reconciliation job. ok is false for a key with no state.
+type StateStore interface {
+	Load(ctx context.Context, key string) (state RetryState, ok bool, err error)
+	Save(ctx context.Context, key string, state RetryState) error
+	Delete(ctx context.Context, key string) error
+}
+
+// DoKeyed is Do for a long-running operation identified by key. With a
+// StateStore set by WithStateStore, it resumes key's schedule where a
+// previous process left it: it waits until the saved next eligible time and
+// counts the attempts already made against maxAttempts. The state is saved
+// before every wait and deleted once the operation is over, but kept when
+// ctx ends or the Retryer is stopped, for the next process to resume.
+func (r *Retryer) DoKeyed(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	_, err := doValue(ctx, r, key, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	if err != nil && r.fallback != nil && gaveUp(err) {
+		return r.fallback(ctx, err)
+	}
+	return err
+}
+
+// FileStateStore is a StateStore keeping one JSON file per key in a
+// directory. Saves replace the file atomically, so a crash leaves either
+// the old state or the new one.
+type FileStateStore struct {
+	dir string
+}
+
+// NewFileStateStore stores state in dir, which must exist.
+func NewFileStateStore(dir string) *FileStateStore {
+	return &FileStateStore{dir: dir}
+}
+
+func (s *FileStateStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
+
+func (s *FileStateStore) Load(_ context.Context, key string) (RetryState, bool, error) {
+	var state RetryState
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, err
+	}
+	return state, true, nil
+}
+
+func (s *FileStateStore) Save(_ context.Context, key string, state RetryState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Without a sync the rename may reach the disk before the data does,
+	// and a crash would leave an empty file under the key.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStateStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
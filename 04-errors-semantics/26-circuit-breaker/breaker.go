@@ -0,0 +1,296 @@
+// Package circuitbreaker stops calls to a failing dependency for a while,
+// so it can recover and callers fail fast instead of queueing on timeouts.
+//
+// A Breaker starts Closed and counts outcomes over a sliding window. When
+// the failure ratio over the window crosses a threshold it opens, refusing
+// every call. After a cooldown it lets a limited number of probe calls
+// through (HalfOpen): enough successes close it, any failure opens it again.
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOpen          = errors.New("circuit breaker open")
+	ErrTooManyProbes = errors.New("circuit breaker half-open: too many probes")
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type config struct {
+	window       time.Duration
+	buckets      int
+	failureRatio float64
+	minRequests  int
+	cooldown     time.Duration
+	maxProbes    int
+	probesToPass int
+	isFailure    func(err error) bool
+	onChange     func(from, to State)
+	now          func() time.Time
+}
+
+type Option func(*config)
+
+// WithWindow counts outcomes over the last d, in buckets slices that expire
+// one at a time. The default is 10 seconds in 10 buckets.
+func WithWindow(d time.Duration, buckets int) Option {
+	return func(c *config) {
+		c.window = d
+		c.buckets = max(buckets, 1)
+	}
+}
+
+// WithFailureThreshold opens the breaker once at least ratio of the calls in
+// the window failed, provided there were at least minRequests of them, so
+// one failure out of one call does not trip it. The default is half of at
+// least 10 calls.
+func WithFailureThreshold(ratio float64, minRequests int) Option {
+	return func(c *config) {
+		c.failureRatio = ratio
+		c.minRequests = minRequests
+	}
+}
+
+// WithCooldown is how long the breaker stays open before probing. The
+// default is 5 seconds.
+func WithCooldown(d time.Duration) Option {
+	return func(c *config) {
+		c.cooldown = d
+	}
+}
+
+// WithProbes lets at most maxConcurrent calls through at a time while half
+// open, and closes the breaker after successes of them succeed in a row. The
+// default is one probe at a time, closing after one success.
+func WithProbes(maxConcurrent, successes int) Option {
+	return func(c *config) {
+		c.maxProbes = max(maxConcurrent, 1)
+		c.probesToPass = max(successes, 1)
+	}
+}
+
+// WithIsFailure decides which errors returned to Execute count as failures
+// of the dependency. By default every error does; a caller mistake, such as
+// a validation error, should not.
+func WithIsFailure(isFailure func(err error) bool) Option {
+	return func(c *config) {
+		c.isFailure = isFailure
+	}
+}
+
+// WithOnStateChange calls fn after every transition, outside the breaker's
+// lock, so fn may use the breaker. Calls are made one at a time, in the
+// order the transitions happened.
+func WithOnStateChange(fn func(from, to State)) Option {
+	return func(c *config) {
+		c.onChange = fn
+	}
+}
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// Breaker is safe for concurrent use. Share one per dependency between
+// everything calling it.
+type Breaker struct {
+	cfg config
+
+	mu       sync.Mutex
+	state    State
+	window   window
+	openedAt time.Time
+	probes   int // probes in flight
+	passed   int // consecutive successful probes
+	// generation changes with every transition, so outcomes of calls let
+	// through in an earlier state are not counted against the current one.
+	generation uint64
+	// notifyMu guards the transitions waiting for the hook, and draining,
+	// set while one goroutine calls it.
+	notifyMu sync.Mutex
+	pending  []transition
+	draining bool
+}
+
+type transition struct{ from, to State }
+
+func New(opts ...Option) *Breaker {
+	cfg := config{
+		window:       10 * time.Second,
+		buckets:      10,
+		failureRatio: 0.5,
+		minRequests:  10,
+		cooldown:     5 * time.Second,
+		maxProbes:    1,
+		probesToPass: 1,
+		isFailure:    func(err error) bool { return err != nil },
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	b := &Breaker{cfg: cfg}
+	b.window = newWindow(cfg.window, cfg.buckets)
+	return b
+}
+
+// Allow asks to make one call. If the breaker lets it through, the caller
+// must report the outcome with done exactly once; otherwise err is ErrOpen
+// or ErrTooManyProbes.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	generation, err := b.allow()
+	b.notify()
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.record(generation, success) })
+	}, nil
+}
+
+func (b *Breaker) allow() (generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.cfg.now()
+	if b.state == Open && now.Sub(b.openedAt) >= b.cfg.cooldown {
+		b.setState(HalfOpen, now)
+	}
+
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.probes >= b.cfg.maxProbes {
+			return 0, ErrTooManyProbes
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// Execute runs fn if the breaker allows it and records its outcome. A panic
+// in fn counts as a failure and is re-raised.
+func (b *Breaker) Execute(fn func() error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			done(false)
+			panic(r)
+		}
+	}()
+	err = fn()
+	done(!b.cfg.isFailure(err))
+	return err
+}
+
+// State is the breaker's current state. An open breaker whose cooldown has
+// passed reports HalfOpen only once a call has asked to go through.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.notify()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	now := b.cfg.now()
+	switch b.state {
+	case Closed:
+		b.window.add(now, success)
+		total, failures := b.window.counts(now)
+		if total >= b.cfg.minRequests && float64(failures) >= b.cfg.failureRatio*float64(total) {
+			b.setState(Open, now)
+		}
+	case HalfOpen:
+		b.probes--
+		switch {
+		case !success:
+			b.setState(Open, now)
+		case b.passed+1 >= b.cfg.probesToPass:
+			b.setState(Closed, now)
+		default:
+			b.passed++
+		}
+	}
+}
+
+// setState moves to state and queues the hook call. b.mu must be held.
+func (b *Breaker) setState(state State, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.passed = 0
+	switch state {
+	case Open:
+		b.openedAt = now
+	case Closed:
+		b.window.reset()
+	}
+	if b.cfg.onChange != nil {
+		b.notifyMu.Lock()
+		b.pending = append(b.pending, transition{from, state})
+		b.notifyMu.Unlock()
+	}
+}
+
+// notify calls the hook for the transitions queued by setState, once b.mu
+// is released. If another goroutine, or the hook itself through the
+// breaker, is already calling it, that call picks them up instead.
+func (b *Breaker) notify() {
+	if b.cfg.onChange == nil {
+		return
+	}
+	for {
+		b.notifyMu.Lock()
+		if b.draining || len(b.pending) == 0 {
+			b.notifyMu.Unlock()
+			return
+		}
+		t := b.pending[0]
+		b.pending = b.pending[1:]
+		b.draining = true
+		b.notifyMu.Unlock()
+
+		b.cfg.onChange(t.from, t.to)
+
+		b.notifyMu.Lock()
+		b.draining = false
+		b.notifyMu.Unlock()
+	}
+}
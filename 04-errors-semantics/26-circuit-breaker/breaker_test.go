@@ -0,0 +1,228 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("downstream unavailable")
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(opts ...Option) (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	opts = append([]Option{
+		WithClock(clock.Now),
+		WithWindow(10*time.Second, 10),
+		WithFailureThreshold(0.5, 4),
+		WithCooldown(time.Second),
+	}, opts...)
+	return New(opts...), clock
+}
+
+func fail() error    { return errDown }
+func succeed() error { return nil }
+
+func TestBreaker_Trips(t *testing.T) {
+	t.Run("BelowMinRequests", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		for range 3 {
+			b.Execute(fail)
+		}
+		if b.State() != Closed {
+			t.Errorf("expected closed below the minimum request count, got %v", b.State())
+		}
+	})
+
+	t.Run("FailureRatio", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		b.Execute(succeed)
+		b.Execute(succeed)
+		b.Execute(succeed)
+		b.Execute(fail)
+		b.Execute(fail)
+		if b.State() != Closed {
+			t.Fatalf("expected closed at 2 failures out of 5, got %v", b.State())
+		}
+		b.Execute(fail)
+		if b.State() != Open {
+			t.Fatalf("expected open at 3 failures out of 6, got %v", b.State())
+		}
+
+		called := false
+		err := b.Execute(func() error { called = true; return nil })
+		if !errors.Is(err, ErrOpen) || called {
+			t.Errorf("expected ErrOpen without calling, got %v, called %v", err, called)
+		}
+	})
+
+	t.Run("WindowSlides", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		b.Execute(fail)
+		b.Execute(fail)
+		b.Execute(fail)
+		clock.Advance(11 * time.Second)
+		b.Execute(fail)
+		b.Execute(succeed)
+		b.Execute(succeed)
+		b.Execute(succeed)
+		if b.State() != Closed {
+			t.Errorf("expected failures older than the window to be forgotten, got %v", b.State())
+		}
+	})
+
+	t.Run("IsFailure", func(t *testing.T) {
+		errInvalid := errors.New("invalid argument")
+		b, _ := newTestBreaker(WithIsFailure(func(err error) bool {
+			return err != nil && !errors.Is(err, errInvalid)
+		}))
+		for range 10 {
+			if err := b.Execute(func() error { return errInvalid }); !errors.Is(err, errInvalid) {
+				t.Fatalf("expected the call's own error, got %v", err)
+			}
+		}
+		if b.State() != Closed {
+			t.Errorf("expected caller errors not to trip the breaker, got %v", b.State())
+		}
+	})
+}
+
+func tripped(t *testing.T, opts ...Option) (*Breaker, *fakeClock) {
+	t.Helper()
+	b, clock := newTestBreaker(opts...)
+	for range 4 {
+		b.Execute(fail)
+	}
+	if b.State() != Open {
+		t.Fatalf("expected open, got %v", b.State())
+	}
+	return b, clock
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	t.Run("ProbeLimit", func(t *testing.T) {
+		b, clock := tripped(t, WithProbes(2, 2))
+		clock.Advance(time.Second)
+
+		first, err := b.Allow()
+		if err != nil {
+			t.Fatalf("expected a probe after the cooldown, got %v", err)
+		}
+		second, err := b.Allow()
+		if err != nil {
+			t.Fatalf("expected a second probe, got %v", err)
+		}
+		if _, err := b.Allow(); !errors.Is(err, ErrTooManyProbes) {
+			t.Errorf("expected ErrTooManyProbes, got %v", err)
+		}
+
+		first(true)
+		if b.State() != HalfOpen {
+			t.Errorf("expected half-open after one of two successes, got %v", b.State())
+		}
+		second(true)
+		if b.State() != Closed {
+			t.Errorf("expected closed after two successes, got %v", b.State())
+		}
+	})
+
+	t.Run("FailureReopens", func(t *testing.T) {
+		b, clock := tripped(t)
+		clock.Advance(time.Second)
+		b.Execute(fail)
+		if b.State() != Open {
+			t.Fatalf("expected a failed probe to reopen, got %v", b.State())
+		}
+		if err := b.Execute(succeed); !errors.Is(err, ErrOpen) {
+			t.Errorf("expected a fresh cooldown, got %v", err)
+		}
+	})
+
+	t.Run("StaleOutcomeIgnored", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		slow, _ := b.Allow()
+		for range 4 {
+			b.Execute(fail)
+		}
+		// Reported after the breaker opened: it says nothing about now.
+		slow(true)
+		if b.State() != Open {
+			t.Errorf("expected a stale success not to close the breaker, got %v", b.State())
+		}
+	})
+}
+
+func TestBreaker_OnStateChange(t *testing.T) {
+	var got []string
+	var b *Breaker
+	b, clock := newTestBreaker(WithOnStateChange(func(from, to State) {
+		// The hook may use the breaker.
+		if b.State() != to {
+			t.Errorf("expected the breaker to be %v in the hook", to)
+		}
+		got = append(got, from.String()+"->"+to.String())
+	}))
+	for range 4 {
+		b.Execute(fail)
+	}
+	clock.Advance(time.Second)
+	b.Execute(succeed)
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestBreaker_PanicCountsAsFailure(t *testing.T) {
+	b, _ := newTestBreaker(WithFailureThreshold(0.5, 1))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to propagate")
+			}
+		}()
+		b.Execute(func() error { panic("boom") })
+	}()
+	if b.State() != Open {
+		t.Errorf("expected the panic to count as a failure, got %v", b.State())
+	}
+}
+
+func TestBreaker_Concurrency(t *testing.T) {
+	b, clock := newTestBreaker(WithProbes(3, 5), WithOnStateChange(func(from, to State) {}))
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Go(func() {
+			for j := range 500 {
+				if (i+j)%3 == 0 {
+					b.Execute(fail)
+				} else {
+					b.Execute(succeed)
+				}
+				if j%50 == 0 {
+					clock.Advance(time.Second)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,52 @@
+package circuitbreaker
+
+import "time"
+
+// window counts successes and failures over a sliding time window split
+// into buckets. A bucket is reused, and its counts dropped, once a full
+// window has passed since it was started.
+type window struct {
+	bucketSize time.Duration
+	buckets    []bucket
+}
+
+type bucket struct {
+	start     int64 // index of the bucketSize slice of time it counts
+	successes int
+	failures  int
+}
+
+func newWindow(d time.Duration, n int) window {
+	return window{
+		bucketSize: max(d/time.Duration(n), 1),
+		buckets:    make([]bucket, n),
+	}
+}
+
+func (w *window) add(now time.Time, success bool) {
+	slot := now.UnixNano() / int64(w.bucketSize)
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.start != slot {
+		*b = bucket{start: slot}
+	}
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+}
+
+func (w *window) counts(now time.Time) (total, failures int) {
+	slot := now.UnixNano() / int64(w.bucketSize)
+	for _, b := range w.buckets {
+		if slot-b.start < int64(len(w.buckets)) {
+			total += b.successes + b.failures
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *window) reset() {
+	clear(w.buckets)
+}
@@ -0,0 +1,3 @@
+module config-store
+
+go 1.25.0
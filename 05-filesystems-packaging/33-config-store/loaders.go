@@ -0,0 +1,112 @@
+package configstore
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// JSONFile reads name from fsys, os.DirFS in production and fstest.MapFS in
+// tests. Fields missing from the file keep their earlier value; unknown
+// fields are an error, so a typo does not go unnoticed.
+func JSONFile[T any](fsys fs.FS, name string) Loader[T] {
+	return func(cfg *T) error {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// Env sets the fields of T tagged `env:"NAME"` from the variable prefix +
+// NAME, when it is set. Strings, bools, integers, floats, time.Duration and
+// encoding.TextUnmarshaler fields are supported; nested structs are not.
+func Env[T any](prefix string) Loader[T] {
+	return func(cfg *T) error {
+		v := reflect.ValueOf(cfg).Elem()
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("env: %T is not a struct", *cfg)
+		}
+		for i := range v.NumField() {
+			name, ok := v.Type().Field(i).Tag.Lookup("env")
+			if !ok {
+				continue
+			}
+			value, ok := os.LookupEnv(prefix + name)
+			if !ok {
+				continue
+			}
+			if err := setField(v.Field(i), value); err != nil {
+				return fmt.Errorf("env %s%s: %w", prefix, name, err)
+			}
+		}
+		return nil
+	}
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+func setField(f reflect.Value, value string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		f.SetInt(int64(d))
+		return err
+	}
+
+	var err error
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, f.Type().Bits())
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, f.Type().Bits())
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, err = strconv.ParseFloat(value, f.Type().Bits())
+		f.SetFloat(x)
+	default:
+		err = fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return err
+}
+
+// Duration is a time.Duration written as "1m30s" in JSON files and
+// environment variables.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package configstore holds a service's configuration as an immutable
+// snapshot that can be replaced at runtime, for example on SIGHUP.
+//
+// Readers call Load on every use and get a consistent *T without taking a
+// lock; Reload builds a whole new snapshot from the defaults and the
+// loaders, validates it, and only then swaps it in. A bad file or variable
+// is reported and the service keeps running on the last good config.
+package configstore
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Loader applies one configuration source on top of cfg, which holds the
+// defaults and what the loaders before it set.
+type Loader[T any] func(cfg *T) error
+
+type Option[T any] func(*Store[T])
+
+// WithLoader adds a source. Loaders run in the order given, so later ones
+// override earlier ones: typically a file, then the environment.
+func WithLoader[T any](l Loader[T]) Option[T] {
+	return func(s *Store[T]) {
+		s.loaders = append(s.loaders, l)
+	}
+}
+
+// WithValidator rejects snapshots for which validate returns an error.
+func WithValidator[T any](validate func(*T) error) Option[T] {
+	return func(s *Store[T]) {
+		s.validate = validate
+	}
+}
+
+// Store is safe for concurrent use. T is copied shallowly from the
+// defaults on each reload, so it should not hold maps or slices the
+// loaders modify in place.
+type Store[T any] struct {
+	current  atomic.Pointer[T]
+	defaults T
+	loaders  []Loader[T]
+	validate func(*T) error
+
+	mu   sync.Mutex // serialises reloads and their notifications
+	subs map[int]func(old, new *T)
+	next int
+}
+
+// New builds the first snapshot, failing if a loader or the validator does.
+func New[T any](defaults T, opts ...Option[T]) (*Store[T], error) {
+	s := &Store[T]{defaults: defaults, subs: make(map[int]func(old, new *T))}
+	for _, opt := range opts {
+		opt(s)
+	}
+	cfg, err := s.build()
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(cfg)
+	return s, nil
+}
+
+// Load returns the current snapshot. It is shared: callers must not modify
+// it.
+func (s *Store[T]) Load() *T {
+	return s.current.Load()
+}
+
+// Reload builds and validates a new snapshot and swaps it in, then calls
+// the subscribers with the old and new snapshots. On error the current
+// snapshot stays in place and nobody is notified.
+func (s *Store[T]) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg, err := s.build()
+	if err != nil {
+		return err
+	}
+	old := s.current.Swap(cfg)
+	for _, fn := range s.subs {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+func (s *Store[T]) build() (*T, error) {
+	cfg := new(T)
+	*cfg = s.defaults
+	for _, load := range s.loaders {
+		if err := load(cfg); err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+	}
+	if s.validate != nil {
+		if err := s.validate(cfg); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// Subscribe calls fn after every successful Reload, on the reloading
+// goroutine, one reload at a time. It returns a function removing fn.
+func (s *Store[T]) Subscribe(fn func(old, new *T)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	s.subs[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subs, id)
+	}
+}
@@ -0,0 +1,158 @@
+package configstore
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type testConfig struct {
+	Name    string        `json:"name" env:"NAME"`
+	Workers int           `json:"workers" env:"WORKERS"`
+	Debug   bool          `json:"debug" env:"DEBUG"`
+	Ratio   float64       `json:"ratio" env:"RATIO"`
+	Poll    time.Duration `json:"-" env:"POLL"`
+	Timeout Duration      `json:"timeout" env:"TIMEOUT"`
+}
+
+func validate(c *testConfig) error {
+	if c.Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	return nil
+}
+
+func defaults() testConfig {
+	return testConfig{Name: "svc", Workers: 4, Timeout: Duration(time.Second)}
+}
+
+func TestNew_LayersSources(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": {Data: []byte(`{"workers": 8, "timeout": "30s", "ratio": 0.5}`)},
+	}
+	t.Setenv("APP_WORKERS", "16")
+	t.Setenv("APP_DEBUG", "true")
+	t.Setenv("APP_POLL", "250ms")
+
+	s, err := New(defaults(),
+		WithLoader(JSONFile[testConfig](fsys, "config.json")),
+		WithLoader(Env[testConfig]("APP_")),
+		WithValidator(validate),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{
+		Name:    "svc",                      // default
+		Workers: 16,                         // env over file
+		Debug:   true,                       // env
+		Ratio:   0.5,                        // file
+		Poll:    250 * time.Millisecond,     // env, time.Duration
+		Timeout: Duration(30 * time.Second), // file, text
+	}
+	if got := *s.Load(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"typo.json": {Data: []byte(`{"wokers": 8}`)},
+	}
+	tests := []struct {
+		name    string
+		loader  Loader[testConfig]
+		env     map[string]string
+		wantErr string
+	}{
+		{"missing file", JSONFile[testConfig](fsys, "missing.json"), nil, "missing.json"},
+		{"unknown field", JSONFile[testConfig](fsys, "typo.json"), nil, "wokers"},
+		{"bad variable", Env[testConfig]("APP_"), map[string]string{"APP_WORKERS": "many"}, "APP_WORKERS"},
+		{"bad duration", Env[testConfig]("APP_"), map[string]string{"APP_TIMEOUT": "soon"}, "APP_TIMEOUT"},
+		{"invalid", Env[testConfig]("APP_"), map[string]string{"APP_WORKERS": "0"}, "workers must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := New(defaults(), WithLoader(tt.loader), WithValidator(validate))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": {Data: []byte(`{"workers": 8}`)},
+	}
+	s, err := New(defaults(),
+		WithLoader(JSONFile[testConfig](fsys, "config.json")),
+		WithValidator(validate),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := s.Load()
+
+	var changes []int
+	unsubscribe := s.Subscribe(func(old, new *testConfig) {
+		changes = append(changes, old.Workers, new.Workers)
+	})
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"workers": 0}`)}
+	if err := s.Reload(); err == nil {
+		t.Error("expected the invalid config to be rejected")
+	}
+	if s.Load() != first {
+		t.Error("expected a failed reload to keep the current snapshot")
+	}
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"workers": 12}`)}
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Load().Workers != 12 || first.Workers != 8 {
+		t.Error("expected a new snapshot, leaving the old one untouched")
+	}
+
+	unsubscribe()
+	s.Reload()
+	if len(changes) != 2 || changes[0] != 8 || changes[1] != 12 {
+		t.Errorf("expected one notification 8 -> 12, got %v", changes)
+	}
+}
+
+func TestReload_ConcurrentReaders(t *testing.T) {
+	s, err := New(defaults(), WithValidator(validate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if s.Load().Workers != 4 {
+					t.Error("unexpected snapshot")
+					return
+				}
+			}
+		})
+	}
+	for range 100 {
+		s.Reload()
+	}
+	close(done)
+	wg.Wait()
+}